@@ -0,0 +1,81 @@
+package esni
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEADFunc defines a function that constructs
+// the AEAD of a cipher suite from a key
+type AEADFunc func(key []byte) (cipher.AEAD, error)
+
+// CipherSuite_aead defines a map of cipher suites
+// and the functions that construct their AEAD
+var CipherSuite_aead = map[CipherSuite]AEADFunc{
+	CipherSuite_TLS_AES_128_GCM_SHA256:       newAESGCM,
+	CipherSuite_TLS_AES_256_GCM_SHA384:       newAESGCM,
+	CipherSuite_TLS_CHACHA20_POLY1305_SHA256: chacha20poly1305.New,
+	CipherSuite_TLS_AES_128_CCM_SHA256:       newAESCCM(16),
+	CipherSuite_TLS_AES_128_CCM_8_SHA256:     newAESCCM(8),
+}
+
+// RegisterCipherSuiteAEAD registers the function
+// that constructs the AEAD of a cipher suite, allowing
+// cipher suites not supported natively to be used
+func RegisterCipherSuiteAEAD(suite CipherSuite, fn AEADFunc) {
+	if _, exists := CipherSuite_aead[suite]; exists {
+		panic("cipher suite aead already registered")
+	}
+
+	CipherSuite_aead[suite] = fn
+}
+
+// NewAEAD constructs the AEAD of the CipherSuite
+// using the function registered in CipherSuite_aead,
+// the key must be of the length used by the suite
+func (suite CipherSuite) NewAEAD(key []byte) (cipher.AEAD, error) {
+	fn, ok := CipherSuite_aead[suite]
+	if !ok {
+		return nil, errors.Errorf("unsupported cipher suite %s", suite)
+	}
+
+	if keyLen := suite.KeyLen(); keyLen != 0 && len(key) != keyLen {
+		return nil, errors.Errorf("invalid key length of %d for %s", len(key), suite)
+	}
+
+	return fn(key)
+}
+
+// supportsAEAD returns if an AEAD can be
+// constructed for the cipher suite
+func (suite CipherSuite) supportsAEAD() bool {
+	_, ok := CipherSuite_aead[suite]
+	return ok
+}
+
+// newAESGCM constructs an AES-GCM AEAD,
+// the key size selects the AES variant
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// newAESCCM returns a function that constructs
+// an AES-CCM AEAD with the tag size
+func newAESCCM(tagSize int) AEADFunc {
+	return func(key []byte) (cipher.AEAD, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return newCCM(block, tagSize)
+	}
+}
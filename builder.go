@@ -0,0 +1,258 @@
+package esni
+
+import (
+	"crypto/rand"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultLifetime specifies the validity period
+	// used by KeysBuilder when no lifetime is set
+	DefaultLifetime = 7 * 24 * time.Hour
+)
+
+// KeysBuilder provides a fluent API for constructing
+// a Keys record, filling in defaults appropriate for
+// the selected ESNI version and validating the record
+// before it is returned.
+//
+// Errors encountered while building are deferred
+// until Build is called.
+type KeysBuilder struct {
+	keys     Keys
+	groups   []Group
+	lifetime time.Duration
+	ectx     *EvalContext
+	random   io.Reader
+	rawName  bool
+	err      error
+}
+
+// NewKeysBuilder returns a new KeysBuilder
+// targeting draft-03 of the ESNI specification
+func NewKeysBuilder() *KeysBuilder {
+	return &KeysBuilder{
+		keys: Keys{Version: VersionDraft03},
+	}
+}
+
+// Version sets the ESNI specification version
+// of the record
+func (builder *KeysBuilder) Version(version Version) *KeysBuilder {
+	builder.keys.Version = version
+	return builder
+}
+
+// PublicName sets the clear text SNI to be
+// used during the TLS handshake, an internationalized
+// name is converted to its ASCII form when the record
+// is built
+func (builder *KeysBuilder) PublicName(name string) *KeysBuilder {
+	builder.keys.PublicName = name
+	return builder
+}
+
+// DisableIDNA stops the public name being converted
+// to its ASCII form, the name is used exactly as set
+func (builder *KeysBuilder) DisableIDNA() *KeysBuilder {
+	builder.rawName = true
+	return builder
+}
+
+// AddGroup adds a group that the record must
+// contain a key share for, if no key share for
+// the group is supplied using AddKeyShare a key
+// pair is generated when the record is built
+func (builder *KeysBuilder) AddGroup(group Group) *KeysBuilder {
+	for _, existing := range builder.groups {
+		if existing == group {
+			return builder
+		}
+	}
+
+	builder.groups = append(builder.groups, group)
+	return builder
+}
+
+// AddKeyShare adds a key share entry to the
+// record, adding its group if not already
+// added
+func (builder *KeysBuilder) AddKeyShare(entry KeyShareEntry) *KeysBuilder {
+	if builder.keys.Keys.Contains(entry) {
+		builder.setErr(errors.Errorf("duplicate key share for group %s", entry.Group))
+		return builder
+	}
+
+	builder.keys.Keys = append(builder.keys.Keys, entry)
+	return builder.AddGroup(entry.Group)
+}
+
+// AddCipherSuite adds a cipher suite that is
+// permitted to be used for encrypting the SNI
+func (builder *KeysBuilder) AddCipherSuite(suite CipherSuite) *KeysBuilder {
+	if !containsCipherSuite(builder.keys.CipherSuites, suite) {
+		builder.keys.CipherSuites = append(builder.keys.CipherSuites, suite)
+	}
+
+	return builder
+}
+
+// PaddedLength sets the length the SNI must
+// be padded to before encryption
+func (builder *KeysBuilder) PaddedLength(length uint16) *KeysBuilder {
+	builder.keys.PaddedLength = length
+	return builder
+}
+
+// PaddedLengthFor sets the padded length to the
+// length recommended by the padding policy for the
+// set of host names the server expects to receive
+func (builder *KeysBuilder) PaddedLengthFor(hostnames []string, policy PaddingPolicy) *KeysBuilder {
+	length, err := RecommendPaddedLength(hostnames, policy)
+	if err != nil {
+		builder.setErr(errors.Wrap(err, "recommend padded length"))
+		return builder
+	}
+
+	return builder.PaddedLength(length)
+}
+
+// NotBefore sets the time the record becomes
+// valid, if not set the current time of the
+// evaluation context is used
+func (builder *KeysBuilder) NotBefore(t time.Time) *KeysBuilder {
+	builder.keys.NotBefore = t
+	return builder
+}
+
+// Lifetime sets the duration after NotBefore
+// that the record remains valid
+func (builder *KeysBuilder) Lifetime(lifetime time.Duration) *KeysBuilder {
+	if lifetime <= 0 {
+		builder.setErr(errors.New("lifetime must be greater than zero"))
+		return builder
+	}
+
+	builder.lifetime = lifetime
+	return builder
+}
+
+// AddExtension adds an ESNI extension to
+// the record
+func (builder *KeysBuilder) AddExtension(ext Extension) *KeysBuilder {
+	builder.keys.Extensions = append(builder.keys.Extensions, ext)
+	return builder
+}
+
+// EvalContext sets the evaluation context used
+// to determine the current time when NotBefore
+// isn't set
+func (builder *KeysBuilder) EvalContext(ectx *EvalContext) *KeysBuilder {
+	builder.ectx = ectx
+	return builder
+}
+
+// Rand sets the random source key pairs are
+// generated from for groups without a supplied
+// key share, if not set crypto/rand is used
+func (builder *KeysBuilder) Rand(random io.Reader) *KeysBuilder {
+	builder.random = random
+	return builder
+}
+
+// setErr records the first error encountered
+// while building
+func (builder *KeysBuilder) setErr(err error) {
+	if builder.err == nil {
+		builder.err = err
+	}
+}
+
+// Build fills in the defaults for any unset fields,
+// generates a key share for every added group without
+// one and validates the resulting Keys record.
+//
+// The private keys of generated key shares are
+// discarded, BuildWithPrivateKeys must be used to
+// build a record the server can decrypt with.
+func (builder *KeysBuilder) Build() (*Keys, error) {
+	keys, _, err := builder.BuildWithPrivateKeys()
+	return keys, err
+}
+
+// BuildWithPrivateKeys builds the record as Build does,
+// returning the private keys of the key shares that
+// were generated alongside it. Key shares supplied
+// using AddKeyShare have no private key returned.
+func (builder *KeysBuilder) BuildWithPrivateKeys() (*Keys, *PrivateKeys, error) {
+	if builder.err != nil {
+		return nil, nil, builder.err
+	}
+
+	keys := builder.keys
+	keys.Keys = append(KeyShareEntryList(nil), builder.keys.Keys...)
+	keys.CipherSuites = append([]CipherSuite(nil), builder.keys.CipherSuites...)
+	keys.Extensions = append(ExtensionList(nil), builder.keys.Extensions...)
+
+	if !keys.Version.HasPublicName() && len(keys.PublicName) > 0 {
+		return nil, nil, errors.Errorf("public name is not supported by %s", keys.Version)
+	}
+
+	if !builder.rawName {
+		name, err := ToASCIIName(keys.PublicName)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "public name")
+		}
+
+		keys.PublicName = name
+	}
+
+	random := builder.random
+	if random == nil {
+		random = rand.Reader
+	}
+
+	privateKeys := new(PrivateKeys)
+	for _, group := range builder.groups {
+		if findKeyShare(keys.Keys, group) != nil {
+			continue
+		}
+
+		publicKey, privateKey, err := group.NewKeyPairFromReader(random)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "generate key share for group %s", group)
+		}
+
+		entry := KeyShareEntry{Group: group, KeyExchange: publicKey}
+		keys.Keys = append(keys.Keys, entry)
+		privateKeys.Entries = append(privateKeys.Entries, PrivateKeyEntry{KeyShareEntry: entry, PrivateKey: privateKey})
+	}
+
+	if len(keys.CipherSuites) == 0 {
+		keys.CipherSuites = []CipherSuite{CipherSuite_TLS_AES_128_GCM_SHA256}
+	}
+
+	if keys.PaddedLength == 0 {
+		keys.PaddedLength = MaxPaddedLength
+	}
+
+	if keys.NotBefore.IsZero() {
+		keys.NotBefore = builder.ectx.Now().Truncate(time.Second)
+	}
+
+	lifetime := builder.lifetime
+	if lifetime == 0 {
+		lifetime = DefaultLifetime
+	}
+
+	keys.NotAfter = keys.NotBefore.Add(lifetime)
+
+	if err := keys.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return &keys, privateKeys, nil
+}
@@ -0,0 +1,77 @@
+package esni
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestKeysBuilderGeneratesKeyShares(t *testing.T) {
+	supplied, _, err := Group(GroupX25519).NewKeyPair()
+	if err != nil {
+		t.Fatalf("generate key pair: %s", err)
+	}
+
+	tests := []struct {
+		name          string
+		builder       *KeysBuilder
+		wantShares    int
+		wantGenerated int
+	}{
+		{
+			name:          "group only",
+			builder:       NewKeysBuilder().PublicName("cdn.example").AddGroup(GroupX25519).Lifetime(24 * time.Hour),
+			wantShares:    1,
+			wantGenerated: 1,
+		},
+		{
+			name: "supplied key share",
+			builder: NewKeysBuilder().PublicName("cdn.example").
+				AddKeyShare(KeyShareEntry{Group: GroupX25519, KeyExchange: supplied}),
+			wantShares: 1,
+		},
+		{
+			name: "mixed",
+			builder: NewKeysBuilder().PublicName("cdn.example").
+				AddKeyShare(KeyShareEntry{Group: GroupX25519, KeyExchange: supplied}).
+				AddGroup(GroupECP256R1),
+			wantShares:    2,
+			wantGenerated: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, privateKeys, err := test.builder.BuildWithPrivateKeys()
+			if err != nil {
+				t.Fatalf("BuildWithPrivateKeys() error = %s", err)
+			}
+
+			if len(keys.Keys) != test.wantShares {
+				t.Errorf("record has %d key shares, want %d", len(keys.Keys), test.wantShares)
+			}
+
+			if len(privateKeys.Entries) != test.wantGenerated {
+				t.Fatalf("%d private keys returned, want %d", len(privateKeys.Entries), test.wantGenerated)
+			}
+
+			for _, entry := range privateKeys.Entries {
+				share := findKeyShare(keys.Keys, entry.Group)
+				if share == nil || !bytes.Equal(share.KeyExchange, entry.KeyExchange) {
+					t.Errorf("private key for group %s doesn't match the record", entry.Group)
+				}
+			}
+		})
+	}
+}
+
+func TestKeysBuilderBuildGroupOnly(t *testing.T) {
+	keys, err := NewKeysBuilder().PublicName("cdn.example").AddGroup(GroupX25519).Lifetime(24 * time.Hour).Build()
+	if err != nil {
+		t.Fatalf("Build() error = %s", err)
+	}
+
+	if share := findKeyShare(keys.Keys, GroupX25519); share == nil || len(share.KeyExchange) != 32 {
+		t.Errorf("Build() didn't generate an X25519 key share")
+	}
+}
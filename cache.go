@@ -0,0 +1,224 @@
+package esni
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL specifies how long fetched records
+	// are cached when the resolver doesn't report the
+	// TTL of the records
+	DefaultCacheTTL = 5 * time.Minute
+
+	// DefaultRefreshAhead specifies how long before
+	// a cache entry expires that it is refreshed
+	// in the background
+	DefaultRefreshAhead = 30 * time.Second
+
+	// cacheRefreshTimeout specifies the time
+	// limit of a background refresh
+	cacheRefreshTimeout = 30 * time.Second
+)
+
+// CacheStats represents the
+// statistics of a KeysCache
+type CacheStats struct {
+	// Hits specifies the number of lookups
+	// answered from the cache
+	Hits uint64
+
+	// Misses specifies the number of
+	// lookups that required a fetch
+	Misses uint64
+
+	// Refreshes specifies the number of
+	// background refreshes started
+	Refreshes uint64
+
+	// Errors specifies the number of
+	// fetches that failed
+	Errors uint64
+
+	// Entries specifies the number of
+	// domains currently cached
+	Entries int
+}
+
+// KeysCache caches the Keys records fetched for each
+// domain, an entry is served until the TTL of its DNS
+// records or the NotAfter time of any of its Keys
+// records is reached, whichever is sooner.
+//
+// Entries that are close to expiry are refreshed in
+// the background while the cached records continue to
+// be served, it is safe for concurrent use.
+type KeysCache struct {
+	fetcher      *Fetcher
+	defaultTTL   time.Duration
+	refreshAhead time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*keysCacheEntry
+	stats   CacheStats
+}
+
+// keysCacheEntry represents the
+// records cached for a domain
+type keysCacheEntry struct {
+	keys       []FetchedKeys
+	expires    time.Time
+	refreshing bool
+}
+
+// NewKeysCache returns a new KeysCache that fetches
+// records using the fetcher, or a default Fetcher if
+// nil. If zero, the default TTL and refresh ahead
+// durations are DefaultCacheTTL and DefaultRefreshAhead
+func NewKeysCache(fetcher *Fetcher, defaultTTL, refreshAhead time.Duration) *KeysCache {
+	if fetcher == nil {
+		fetcher = new(Fetcher)
+	}
+
+	if defaultTTL <= 0 {
+		defaultTTL = DefaultCacheTTL
+	}
+
+	if refreshAhead <= 0 {
+		refreshAhead = DefaultRefreshAhead
+	}
+
+	return &KeysCache{
+		fetcher:      fetcher,
+		defaultTTL:   defaultTTL,
+		refreshAhead: refreshAhead,
+		entries:      make(map[string]*keysCacheEntry),
+	}
+}
+
+// Get returns the Keys records of the domain, from the
+// cache if an entry hasn't expired, otherwise they are
+// fetched and cached. The evaluation context of the
+// fetcher, or of the context, determines the time
+// entries are evaluated at.
+func (cache *KeysCache) Get(ctx context.Context, domain string) ([]FetchedKeys, error) {
+	key := cacheKey(domain)
+	now := cache.now(ctx)
+
+	cache.mu.Lock()
+	if entry, ok := cache.entries[key]; ok && now.Before(entry.expires) {
+		cache.stats.Hits++
+
+		if !entry.refreshing && entry.expires.Sub(now) <= cache.refreshAhead {
+			entry.refreshing = true
+			cache.stats.Refreshes++
+
+			go cache.refresh(context.WithoutCancel(ctx), domain)
+		}
+
+		cache.mu.Unlock()
+		return entry.keys, nil
+	}
+
+	cache.stats.Misses++
+	cache.mu.Unlock()
+
+	return cache.fetch(ctx, domain)
+}
+
+// Invalidate removes the cached
+// records of the domain
+func (cache *KeysCache) Invalidate(domain string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.entries, cacheKey(domain))
+}
+
+// Stats returns the current
+// statistics of the cache
+func (cache *KeysCache) Stats() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Entries = len(cache.entries)
+
+	return stats
+}
+
+// refresh fetches the records of the domain in the
+// background, on failure the existing entry is kept
+// and a refresh is attempted on the next lookup
+func (cache *KeysCache) refresh(ctx context.Context, domain string) {
+	ctx, cancel := context.WithTimeout(ctx, cacheRefreshTimeout)
+	defer cancel()
+
+	if _, err := cache.fetch(ctx, domain); err != nil {
+		cache.mu.Lock()
+		if entry, ok := cache.entries[cacheKey(domain)]; ok {
+			entry.refreshing = false
+		}
+		cache.mu.Unlock()
+	}
+}
+
+// fetch fetches the records of the domain
+// and stores them in the cache
+func (cache *KeysCache) fetch(ctx context.Context, domain string) ([]FetchedKeys, error) {
+	fetched, _, err := cache.fetcher.FetchKeys(ctx, domain)
+	if err != nil {
+		cache.mu.Lock()
+		cache.stats.Errors++
+		cache.mu.Unlock()
+
+		return nil, err
+	}
+
+	entry := &keysCacheEntry{keys: fetched, expires: fetchedExpiry(fetched, cache.defaultTTL)}
+
+	cache.mu.Lock()
+	cache.entries[cacheKey(domain)] = entry
+	cache.mu.Unlock()
+
+	return fetched, nil
+}
+
+// fetchedExpiry returns the time the fetched records
+// expire, the sooner of the TTL of the lookup, or the
+// default if it isn't known, and the NotAfter time
+// of each record
+func fetchedExpiry(fetched []FetchedKeys, defaultTTL time.Duration) time.Time {
+	ttl := fetched[0].Source.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	expires := fetched[0].Source.FetchedAt.Add(ttl)
+	for i := range fetched {
+		keys := fetched[i].Keys
+		if keys.Version.HasValidityPeriod() && keys.NotAfter.Before(expires) {
+			expires = keys.NotAfter
+		}
+	}
+
+	return expires
+}
+
+// now returns the time entries are
+// evaluated at for the lookup
+func (cache *KeysCache) now(ctx context.Context) time.Time {
+	if cache.fetcher.EvalContext != nil {
+		return cache.fetcher.EvalContext.Now()
+	}
+
+	return EvalContextFrom(ctx).Now()
+}
+
+// cacheKey returns the key
+// the domain is cached under
+func cacheKey(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
@@ -0,0 +1,83 @@
+package esni
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Fingerprint represents a stable SHA-256 based
+// identifier of a Keys record, two records with
+// the same canonical encoding will always produce
+// the same fingerprint
+type Fingerprint [sha256.Size]byte
+
+// String returns the hexadecimal representation
+// of the fingerprint
+func (fp Fingerprint) String() string {
+	return hex.EncodeToString(fp[:])
+}
+
+// Canonical returns a copy of the Keys record
+// in its canonical form, where the extensions are
+// sorted by their type and the validity period is
+// normalised to whole seconds in UTC.
+//
+// The order of the key shares and cipher suites
+// is preserved as it conveys the preference of
+// the server.
+func (keys Keys) Canonical() Keys {
+	canonical := keys
+
+	canonical.NotBefore = keys.NotBefore.UTC().Truncate(time.Second)
+	canonical.NotAfter = keys.NotAfter.UTC().Truncate(time.Second)
+
+	canonical.Extensions = make(ExtensionList, len(keys.Extensions))
+	copy(canonical.Extensions, keys.Extensions)
+
+	sort.SliceStable(canonical.Extensions, func(i, j int) bool {
+		return canonical.Extensions[i].Type() < canonical.Extensions[j].Type()
+	})
+
+	return canonical
+}
+
+// MarshalCanonical will marshal the canonical form
+// of the Keys record into its binary format, allowing
+// records that are semantically equal to be compared
+// byte-for-byte
+func (keys Keys) MarshalCanonical() ([]byte, error) {
+	return keys.Canonical().MarshalBinary()
+}
+
+// Fingerprint returns the SHA-256 sum of the canonical
+// binary encoding of the Keys record, allowing records
+// to be deduplicated and compared across sources
+func (keys Keys) Fingerprint() (Fingerprint, error) {
+	data, err := keys.MarshalCanonical()
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	return sha256.Sum256(data), nil
+}
+
+// MarshalText encodes the fingerprint
+// as hexadecimal
+func (fp Fingerprint) MarshalText() ([]byte, error) {
+	return []byte(fp.String()), nil
+}
+
+// UnmarshalText decodes the fingerprint
+// from hexadecimal
+func (fp *Fingerprint) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(fp) {
+		return errors.New("fingerprint must be 32 bytes")
+	}
+
+	_, err := hex.Decode(fp[:], text)
+	return err
+}
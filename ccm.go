@@ -0,0 +1,192 @@
+package esni
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ccmNonceSize specifies the size of the nonce
+	// used by the TLS 1.3 CCM cipher suites
+	ccmNonceSize = 12
+
+	// ccmLengthSize specifies the size of the
+	// message length field, which is the space
+	// left in a block after the flags and nonce
+	ccmLengthSize = 15 - ccmNonceSize
+
+	// ccmMaxLength specifies the largest message
+	// that can be encoded in the length field
+	ccmMaxLength = 1<<(8*ccmLengthSize) - 1
+)
+
+// ccm implements the Counter with CBC-MAC mode
+// of RFC 3610 with a 12 byte nonce, as used by
+// the TLS_AES_128_CCM cipher suites
+type ccm struct {
+	block   cipher.Block
+	tagSize int
+}
+
+// newCCM returns the CCM mode AEAD for the
+// block cipher with the tag size, which must be
+// an even number between 4 and 16
+func newCCM(block cipher.Block, tagSize int) (cipher.AEAD, error) {
+	if block.BlockSize() != 16 {
+		return nil, errors.New("ccm requires a 128-bit block cipher")
+	}
+
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, errors.Errorf("invalid ccm tag size of %d", tagSize)
+	}
+
+	return &ccm{block: block, tagSize: tagSize}, nil
+}
+
+// NonceSize returns the size of the
+// nonce passed to Seal and Open
+func (mode *ccm) NonceSize() int {
+	return ccmNonceSize
+}
+
+// Overhead returns the size of the tag
+// appended to the plaintext by Seal
+func (mode *ccm) Overhead() int {
+	return mode.tagSize
+}
+
+// Seal encrypts and authenticates the plaintext,
+// authenticates the additional data and appends
+// the result to dst
+func (mode *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != ccmNonceSize {
+		panic("esni: incorrect nonce length given to CCM")
+	}
+
+	if len(plaintext) > ccmMaxLength {
+		panic("esni: message too large for CCM")
+	}
+
+	tag := mode.mac(nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+mode.tagSize)
+	mode.ctr(nonce, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag)
+
+	return ret
+}
+
+// Open decrypts and authenticates the ciphertext,
+// authenticates the additional data and, if
+// successful, appends the plaintext to dst
+func (mode *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != ccmNonceSize {
+		panic("esni: incorrect nonce length given to CCM")
+	}
+
+	if len(ciphertext) < mode.tagSize || len(ciphertext)-mode.tagSize > ccmMaxLength {
+		return nil, errors.New("message authentication failed")
+	}
+
+	tag := ciphertext[len(ciphertext)-mode.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-mode.tagSize]
+
+	plaintext := make([]byte, len(ciphertext))
+	mode.ctr(nonce, plaintext, ciphertext)
+
+	if subtle.ConstantTimeCompare(mode.mac(nonce, plaintext, additionalData), tag) != 1 {
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+
+		return nil, errors.New("message authentication failed")
+	}
+
+	return append(dst, plaintext...), nil
+}
+
+// ctr applies the CTR mode keystream starting
+// at counter 1 to src, writing the result to dst
+func (mode *ccm) ctr(nonce, dst, src []byte) {
+	var counter [16]byte
+	counter[0] = ccmLengthSize - 1
+	copy(counter[1:], nonce)
+	counter[15] = 1
+
+	cipher.NewCTR(mode.block, counter[:]).XORKeyStream(dst, src)
+}
+
+// mac computes the CBC-MAC of the message and
+// additional data, encrypted with the keystream
+// block for counter 0 and truncated to the tag size
+func (mode *ccm) mac(nonce, plaintext, additionalData []byte) []byte {
+	var b0 [16]byte
+	b0[0] = byte((mode.tagSize-2)/2) << 3
+	b0[0] |= ccmLengthSize - 1
+	if len(additionalData) > 0 {
+		b0[0] |= 1 << 6
+	}
+
+	copy(b0[1:], nonce)
+	b0[13] = byte(len(plaintext) >> 16)
+	b0[14] = byte(len(plaintext) >> 8)
+	b0[15] = byte(len(plaintext))
+
+	var mac [16]byte
+	mode.block.Encrypt(mac[:], b0[:])
+
+	if len(additionalData) > 0 {
+		var header []byte
+		if len(additionalData) < 0xff00 {
+			header = make([]byte, 2)
+			binary.BigEndian.PutUint16(header, uint16(len(additionalData)))
+		} else {
+			header = make([]byte, 6)
+			header[0], header[1] = 0xff, 0xfe
+			binary.BigEndian.PutUint32(header[2:], uint32(len(additionalData)))
+		}
+
+		mode.cbcMAC(&mac, append(header, additionalData...))
+	}
+
+	mode.cbcMAC(&mac, plaintext)
+
+	var s0 [16]byte
+	counter := [16]byte{0: ccmLengthSize - 1}
+	copy(counter[1:], nonce)
+	mode.block.Encrypt(s0[:], counter[:])
+
+	tag := make([]byte, mode.tagSize)
+	subtle.XORBytes(tag, mac[:mode.tagSize], s0[:mode.tagSize])
+
+	return tag
+}
+
+// cbcMAC absorbs the data into the CBC-MAC state,
+// padding the final block with zeros
+func (mode *ccm) cbcMAC(mac *[16]byte, data []byte) {
+	for len(data) > 0 {
+		n := subtle.XORBytes(mac[:], mac[:], data)
+		data = data[n:]
+
+		mode.block.Encrypt(mac[:], mac[:])
+	}
+}
+
+// sliceForAppend extends the slice by n bytes,
+// returning the extended slice and the tail of
+// n bytes
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+	return
+}
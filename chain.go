@@ -0,0 +1,74 @@
+package esni
+
+import (
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrValidityMismatch is returned when the validity
+	// period of a Keys record doesn't overlap the validity
+	// period of the certificate chain for its public name
+	ErrValidityMismatch = errors.New("keys validity does not overlap certificate chain")
+)
+
+// VerifyAgainstChain checks the Keys record is consistent
+// with the certificate chain for its public name, the chain
+// is ordered from the leaf and isn't verified against any
+// roots, which is left to VerifySignature.
+//
+// The leaf certificate must be valid for the public name,
+// the validity period of the record must overlap that of
+// every certificate in the chain and, if the record is
+// signed, the signature must verify with the key of the
+// leaf certificate.
+func VerifyAgainstChain(keys *Keys, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return errors.New("empty certificate chain")
+	}
+
+	if len(keys.PublicName) == 0 {
+		return errors.New("keys record has no public name")
+	}
+
+	if err := chain[0].VerifyHostname(keys.PublicName); err != nil {
+		return errors.Wrap(err, "verify public name")
+	}
+
+	if keys.Version.HasValidityPeriod() {
+		notBefore, notAfter := chainValidity(chain)
+
+		if keys.NotAfter.Before(notBefore) || keys.NotBefore.After(notAfter) {
+			return errors.Wrapf(ErrValidityMismatch, "keys valid %s to %s, chain valid %s to %s",
+				keys.NotBefore.UTC().Format(time.RFC3339), keys.NotAfter.UTC().Format(time.RFC3339),
+				notBefore.UTC().Format(time.RFC3339), notAfter.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if err := verifyKeysSignature(keys, chain[0]); err != nil && err != ErrNoSignature {
+		return err
+	}
+
+	return nil
+}
+
+// chainValidity returns the period during
+// which every certificate of the chain
+// is valid
+func chainValidity(chain []*x509.Certificate) (notBefore, notAfter time.Time) {
+	notBefore, notAfter = chain[0].NotBefore, chain[0].NotAfter
+
+	for _, cert := range chain[1:] {
+		if cert.NotBefore.After(notBefore) {
+			notBefore = cert.NotBefore
+		}
+
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	return notBefore, notAfter
+}
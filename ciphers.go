@@ -1,5 +1,11 @@
 package esni
 
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
 // CipherSuite represents a specific
 // TLS cipher and signature set
 type CipherSuite uint16
@@ -32,4 +38,55 @@ func (suite CipherSuite) String() string {
 	}
 
 	return "UNKNOWN"
-}
\ No newline at end of file
+}
+
+// CipherSuiteParameters describes the hash
+// function and the AEAD key and nonce lengths
+// used by a cipher suite
+type CipherSuiteParameters struct {
+	Hash        crypto.Hash
+	KeyLength   int
+	NonceLength int
+}
+
+// CipherSuite_parameters defines a map of cipher
+// suites and their respective parameters
+var CipherSuite_parameters = map[CipherSuite]CipherSuiteParameters{
+	CipherSuite_TLS_AES_128_GCM_SHA256:       {Hash: crypto.SHA256, KeyLength: 16, NonceLength: 12},
+	CipherSuite_TLS_AES_256_GCM_SHA384:       {Hash: crypto.SHA384, KeyLength: 32, NonceLength: 12},
+	CipherSuite_TLS_CHACHA20_POLY1305_SHA256: {Hash: crypto.SHA256, KeyLength: 32, NonceLength: 12},
+	CipherSuite_TLS_AES_128_CCM_SHA256:       {Hash: crypto.SHA256, KeyLength: 16, NonceLength: 12},
+	CipherSuite_TLS_AES_128_CCM_8_SHA256:     {Hash: crypto.SHA256, KeyLength: 16, NonceLength: 12},
+}
+
+// Hash attempts to return the hash function of
+// the CipherSuite based on those specified in
+// CipherSuite_parameters, if no match is found
+// 0 is returned
+func (suite CipherSuite) Hash() crypto.Hash {
+	return CipherSuite_parameters[suite].Hash
+}
+
+// KeyLen attempts to return the length, in bytes,
+// of the AEAD key of the CipherSuite based on those
+// specified in CipherSuite_parameters, if no match
+// is found 0 is returned
+func (suite CipherSuite) KeyLen() int {
+	return CipherSuite_parameters[suite].KeyLength
+}
+
+// NonceLen attempts to return the length, in bytes,
+// of the AEAD nonce of the CipherSuite based on those
+// specified in CipherSuite_parameters, if no match
+// is found 0 is returned
+func (suite CipherSuite) NonceLen() int {
+	return CipherSuite_parameters[suite].NonceLength
+}
+
+// Supported returns if the parameters and AEAD
+// of the CipherSuite are known, which is required
+// to encrypt or decrypt an SNI with the suite
+func (suite CipherSuite) Supported() bool {
+	params, ok := CipherSuite_parameters[suite]
+	return ok && params.Hash.Available() && suite.supportsAEAD()
+}
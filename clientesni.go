@@ -0,0 +1,109 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ClientEncryptedSNI represents the body of the
+// encrypted_server_name extension sent by a client,
+// carrying the encrypted SNI and the information
+// the server needs to decrypt it
+type ClientEncryptedSNI struct {
+	// Suite specifies the cipher suite used
+	// to encrypt the SNI
+	Suite CipherSuite
+
+	// KeyShare specifies the client's ephemeral
+	// key share for the key exchange
+	KeyShare KeyShareEntry
+
+	// RecordDigest specifies the digest of the
+	// Keys record used to encrypt the SNI
+	RecordDigest []byte
+
+	// EncryptedSNI specifies the encrypted
+	// ClientESNIInner
+	EncryptedSNI []byte
+}
+
+// MarshalBinary will marshal the ClientEncryptedSNI
+// into the binary format used as the body of the
+// encrypted_server_name extension
+func (encrypted ClientEncryptedSNI) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+
+	if err := binary.Write(&data, binary.BigEndian, encrypted.Suite); err != nil {
+		return nil, errors.Wrap(err, "write cipher suite")
+	}
+
+	entry, err := encrypted.KeyShare.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal key share entry")
+	}
+
+	data.Write(entry)
+
+	if err := writeVector16(&data, encrypted.RecordDigest); err != nil {
+		return nil, errors.Wrap(err, "write record digest")
+	}
+
+	if err := writeVector16(&data, encrypted.EncryptedSNI); err != nil {
+		return nil, errors.Wrap(err, "write encrypted sni")
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ClientEncryptedSNI from the body of an
+// encrypted_server_name extension, trailing data
+// after the structure is treated as an error
+func (encrypted *ClientEncryptedSNI) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	if err := binary.Read(reader, binary.BigEndian, &encrypted.Suite); err != nil {
+		return errors.Wrap(err, "read cipher suite")
+	}
+
+	if err := encrypted.KeyShare.UnmarshalBinary(data[2:]); err != nil {
+		return err
+	}
+
+	if _, err := reader.Seek(int64(encrypted.KeyShare.Size()), io.SeekCurrent); err != nil {
+		return errors.Wrap(err, "skip key share entry")
+	}
+
+	var err error
+	if encrypted.RecordDigest, err = readVector16(reader); err != nil {
+		return errors.Wrap(err, "read record digest")
+	}
+
+	if encrypted.EncryptedSNI, err = readVector16(reader); err != nil {
+		return errors.Wrap(err, "read encrypted sni")
+	}
+
+	if reader.Len() > 0 {
+		offset := len(data) - reader.Len()
+		return &TrailingDataError{Offset: offset, Data: data[offset:]}
+	}
+
+	return nil
+}
+
+// String returns a friendly representation
+// of the ClientEncryptedSNI
+func (encrypted *ClientEncryptedSNI) String() string {
+	return fmt.Sprintf(
+		"{Suite:%s, KeyShare:%s, RecordDigest:%s, EncryptedSNI:%d bytes}",
+		encrypted.Suite,
+		KeyShareEntryList{encrypted.KeyShare},
+		hex.EncodeToString(encrypted.RecordDigest),
+		len(encrypted.EncryptedSNI),
+	)
+}
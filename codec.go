@@ -0,0 +1,87 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// codec of each version with a known layout
+func init() {
+	for version := range versionLayouts {
+		RegisterVersionCodec(version, layoutCodec{})
+	}
+}
+
+var (
+	// Version_codec defines a map of versions
+	// to the codec used to marshal and unmarshal
+	// Keys records of the version
+	Version_codec = map[Version]VersionCodec{}
+)
+
+// VersionCodec specifies the methods a structure
+// must implement to encode and decode the binary
+// format of Keys records for a specific version
+type VersionCodec interface {
+	// MarshalKeys must marshal the Keys
+	// record into its binary format
+	MarshalKeys(keys *Keys) ([]byte, error)
+
+	// UnmarshalKeys must unmarshal a single Keys
+	// record from the start of the binary data,
+	// which includes the version, and return the
+	// number of bytes occupied by the record. The
+	// Version of the record is already set and the
+	// provided limits should be enforced.
+	UnmarshalKeys(keys *Keys, data []byte, limits Limits) (int, error)
+}
+
+// RegisterVersionCodec will register the codec used
+// to marshal and unmarshal Keys records of a specific
+// version, allowing experimental versions to be
+// supported without modifying this package
+func RegisterVersionCodec(version Version, codec VersionCodec) {
+	if _, exists := Version_codec[version]; exists {
+		panic("version codec already registered")
+	}
+
+	Version_codec[version] = codec
+}
+
+// Codec attempts to return the codec for the
+// Version based on those specified in Version_codec,
+// if no match is found nil is returned
+func (v Version) Codec() VersionCodec {
+	if codec, ok := Version_codec[v]; ok {
+		return codec
+	}
+
+	return nil
+}
+
+// layoutCodec implements VersionCodec for the
+// versions built into the package, the fields
+// encoded are determined by the layout of the
+// version of the record
+type layoutCodec struct{}
+
+// MarshalKeys will marshal the Keys record
+// using the layout of its version
+func (layoutCodec) MarshalKeys(keys *Keys) ([]byte, error) {
+	if _, ok := versionLayouts[keys.Version]; !ok {
+		return nil, errors.Wrapf(ErrUnsupportedVersion, "no layout for version 0x%04x", uint16(keys.Version))
+	}
+
+	return keys.marshalLayout()
+}
+
+// UnmarshalKeys will unmarshal a Keys record
+// using the layout of its version
+func (layoutCodec) UnmarshalKeys(keys *Keys, data []byte, limits Limits) (int, error) {
+	if _, ok := versionLayouts[keys.Version]; !ok {
+		return 0, errors.Wrapf(ErrUnsupportedVersion, "no layout for version 0x%04x", uint16(keys.Version))
+	}
+
+	return keys.unmarshalLayout(data, limits)
+}
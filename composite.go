@@ -0,0 +1,302 @@
+package esni
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultUnhealthyThreshold specifies the number of
+	// consecutive failures after which a resolver of a
+	// CompositeResolver is considered unhealthy
+	DefaultUnhealthyThreshold = 3
+
+	// DefaultHealthCooldown specifies how long an
+	// unhealthy resolver is demoted for before it is
+	// queried in its configured order again
+	DefaultHealthCooldown = 30 * time.Second
+)
+
+// CompositeMode represents the way a CompositeResolver
+// queries the resolvers it is composed of
+type CompositeMode uint8
+
+const (
+	// CompositeSequential queries the resolvers one at a
+	// time, falling back to the next on failure
+	CompositeSequential CompositeMode = iota
+
+	// CompositeRace queries the resolvers concurrently,
+	// the first successful answer is used
+	CompositeRace
+)
+
+// CompositeMode_name provides a mapping between
+// a CompositeMode and its string representation
+var CompositeMode_name = map[CompositeMode]string{
+	CompositeSequential: "sequential",
+	CompositeRace:       "race",
+}
+
+// String attempts to return the string
+// representation of the CompositeMode based
+// on those specified in CompositeMode_name,
+// if no match is found "UNKNOWN" is returned
+func (mode CompositeMode) String() string {
+	if name, ok := CompositeMode_name[mode]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// ResolverHealth represents the health of
+// a resolver of a CompositeResolver
+type ResolverHealth struct {
+	// Successes and Failures specify the number
+	// of lookups that succeeded and failed
+	Successes uint64
+	Failures  uint64
+
+	// ConsecutiveFailures specifies the number
+	// of failures since the last success
+	ConsecutiveFailures int
+
+	// LastError and LastFailure specify the
+	// error and time of the last failure
+	LastError   error
+	LastFailure time.Time
+
+	// Healthy specifies if the resolver is
+	// queried in its configured order
+	Healthy bool
+}
+
+// CompositeResolver is a Resolver that queries several
+// resolvers, either in turn or concurrently, tracking
+// the health of each of them.
+//
+// A resolver that fails repeatedly is demoted and only
+// queried after the healthy resolvers until its cooldown
+// passes. An answer that the name doesn't exist is taken
+// as authoritative and isn't treated as a failure.
+type CompositeResolver struct {
+	resolvers []Resolver
+	mode      CompositeMode
+
+	// UnhealthyThreshold specifies the number of
+	// consecutive failures after which a resolver is
+	// demoted, if zero DefaultUnhealthyThreshold is used
+	UnhealthyThreshold int
+
+	// Cooldown specifies how long a resolver is
+	// demoted for, if zero DefaultHealthCooldown
+	// is used
+	Cooldown time.Duration
+
+	mu     sync.Mutex
+	health []ResolverHealth
+}
+
+// compositeLookup performs a single
+// lookup using one of the resolvers
+type compositeLookup func(ctx context.Context, resolver Resolver) (interface{}, LookupInfo, error)
+
+// compositeResult represents the result of
+// a lookup using one of the resolvers
+type compositeResult struct {
+	index  int
+	result interface{}
+	info   LookupInfo
+	err    error
+}
+
+// NewCompositeResolver returns a new CompositeResolver
+// that queries the resolvers in the mode
+func NewCompositeResolver(mode CompositeMode, resolvers ...Resolver) *CompositeResolver {
+	return &CompositeResolver{
+		resolvers: resolvers,
+		mode:      mode,
+		health:    make([]ResolverHealth, len(resolvers)),
+	}
+}
+
+// LookupTXT returns the TXT records of the name
+func (composite *CompositeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := composite.LookupTXTDetailed(ctx, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name and the details reported by the resolver that
+// answered
+func (composite *CompositeResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	result, info, err := composite.lookup(ctx, func(ctx context.Context, resolver Resolver) (interface{}, LookupInfo, error) {
+		return lookupTXTDetailed(ctx, resolver, name)
+	})
+
+	txts, _ := result.([]string)
+	return txts, info, err
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (composite *CompositeResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := composite.LookupHTTPSDetailed(ctx, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of the
+// name and the details reported by the resolver that
+// answered
+func (composite *CompositeResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	result, info, err := composite.lookup(ctx, func(ctx context.Context, resolver Resolver) (interface{}, LookupInfo, error) {
+		return lookupHTTPSDetailed(ctx, resolver, name)
+	})
+
+	records, _ := result.([]SVCBRecord)
+	return records, info, err
+}
+
+// Health returns the health of each
+// resolver in the configured order
+func (composite *CompositeResolver) Health() []ResolverHealth {
+	composite.mu.Lock()
+	defer composite.mu.Unlock()
+
+	now := time.Now()
+
+	health := make([]ResolverHealth, len(composite.health))
+	for i := range composite.health {
+		health[i] = composite.health[i]
+		health[i].Healthy = composite.healthy(i, now)
+	}
+
+	return health
+}
+
+// lookup performs the lookup using
+// the resolvers in the mode
+func (composite *CompositeResolver) lookup(ctx context.Context, lookup compositeLookup) (interface{}, LookupInfo, error) {
+	if len(composite.resolvers) == 0 {
+		return nil, LookupInfo{}, errors.New("no resolvers configured")
+	}
+
+	if composite.mode == CompositeRace {
+		return composite.race(ctx, lookup)
+	}
+
+	var lastErr error
+	for _, i := range composite.order() {
+		result, info, err := lookup(ctx, composite.resolvers[i])
+		if composite.record(i, err) {
+			return result, info, err
+		}
+
+		if ctx.Err() != nil {
+			return nil, LookupInfo{}, ctx.Err()
+		}
+
+		lastErr = err
+	}
+
+	return nil, LookupInfo{}, lastErr
+}
+
+// race performs the lookup using all the resolvers
+// concurrently, returning the first answer and
+// cancelling the remaining lookups
+func (composite *CompositeResolver) race(ctx context.Context, lookup compositeLookup) (interface{}, LookupInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan compositeResult, len(composite.resolvers))
+	for i := range composite.resolvers {
+		go func(i int) {
+			result, info, err := lookup(ctx, composite.resolvers[i])
+			results <- compositeResult{index: i, result: result, info: info, err: err}
+		}(i)
+	}
+
+	var lastErr error
+	for range composite.resolvers {
+		result := <-results
+
+		if ctx.Err() != nil {
+			return nil, LookupInfo{}, ctx.Err()
+		}
+
+		if composite.record(result.index, result.err) {
+			return result.result, result.info, result.err
+		}
+
+		lastErr = result.err
+	}
+
+	return nil, LookupInfo{}, lastErr
+}
+
+// record updates the health of the resolver with the
+// result of a lookup, returning true if the result
+// answers the lookup
+func (composite *CompositeResolver) record(i int, err error) bool {
+	composite.mu.Lock()
+	defer composite.mu.Unlock()
+
+	health := &composite.health[i]
+
+	if err == nil || isNotFound(err) {
+		health.Successes++
+		health.ConsecutiveFailures = 0
+		return true
+	}
+
+	health.Failures++
+	health.ConsecutiveFailures++
+	health.LastError = err
+	health.LastFailure = time.Now()
+
+	return false
+}
+
+// order returns the indexes of the resolvers in the
+// order they are queried, the healthy resolvers in
+// their configured order followed by those demoted
+func (composite *CompositeResolver) order() []int {
+	composite.mu.Lock()
+	defer composite.mu.Unlock()
+
+	now := time.Now()
+	order := make([]int, 0, len(composite.resolvers))
+	var demoted []int
+
+	for i := range composite.resolvers {
+		if composite.healthy(i, now) {
+			order = append(order, i)
+		} else {
+			demoted = append(demoted, i)
+		}
+	}
+
+	return append(order, demoted...)
+}
+
+// healthy returns if the resolver is queried in
+// its configured order, the caller must hold the
+// lock of the resolver
+func (composite *CompositeResolver) healthy(i int, now time.Time) bool {
+	threshold := composite.UnhealthyThreshold
+	if threshold <= 0 {
+		threshold = DefaultUnhealthyThreshold
+	}
+
+	cooldown := composite.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultHealthCooldown
+	}
+
+	health := composite.health[i]
+	return health.ConsecutiveFailures < threshold || now.Sub(health.LastFailure) >= cooldown
+}
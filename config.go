@@ -0,0 +1,189 @@
+package esni
+
+import (
+	"encoding/hex"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// KeysConfig represents a declarative description
+// of a Keys record, allowing operators to specify
+// the parameters of a record in a YAML file and have
+// the record materialised from it
+type KeysConfig struct {
+	// Version specifies the ESNI specification
+	// version of the record, if not set draft-03
+	// is used
+	Version Version `yaml:"version,omitempty"`
+
+	// PublicName specifies the clear text SNI
+	// to be used during the TLS handshake
+	PublicName string `yaml:"public_name"`
+
+	// KeyShares specifies the public keys to be
+	// included in the record
+	KeyShares []KeyShareConfig `yaml:"key_shares"`
+
+	// CipherSuites specifies the cipher suites
+	// permitted for encryption of the SNI, if
+	// not set TLS_AES_128_GCM_SHA256 is used
+	CipherSuites []CipherSuite `yaml:"cipher_suites,omitempty"`
+
+	// PaddedLength specifies the padded length
+	// of the SNI, if not set MaxPaddedLength is
+	// used
+	PaddedLength uint16 `yaml:"padded_length,omitempty"`
+
+	// NotBefore specifies the time the record
+	// becomes valid, if not set the time of
+	// materialisation is used
+	NotBefore time.Time `yaml:"not_before,omitempty"`
+
+	// Lifetime specifies the duration after
+	// NotBefore that the record remains valid
+	Lifetime time.Duration `yaml:"lifetime"`
+
+	// Extensions specifies the ESNI extensions
+	// to include in the record
+	Extensions ExtensionsConfig `yaml:"extensions,omitempty"`
+}
+
+// KeyShareConfig represents a declarative
+// description of a single key share entry
+type KeyShareConfig struct {
+	// Group specifies the group of the key
+	Group Group `yaml:"group"`
+
+	// KeyExchange specifies the hexadecimal
+	// encoding of the public key, if not set a
+	// key pair is generated for the group
+	KeyExchange string `yaml:"key_exchange,omitempty"`
+}
+
+// ExtensionsConfig represents a declarative
+// description of the extensions in a record
+type ExtensionsConfig struct {
+	// AddressSet specifies the addresses to
+	// be included in an address_set extension
+	AddressSet []string `yaml:"address_set,omitempty"`
+}
+
+// LoadKeysConfig attempts to decode a KeysConfig
+// from the YAML document provided by the reader,
+// unknown fields in the document are treated as
+// an error to catch mistakes in the configuration
+func LoadKeysConfig(r io.Reader) (*KeysConfig, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	cfg := new(KeysConfig)
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, errors.Wrap(err, "decode keys config")
+	}
+
+	return cfg, nil
+}
+
+// SaveKeysConfig encodes the KeysConfig as a
+// YAML document and writes it to the writer
+func SaveKeysConfig(w io.Writer, cfg *KeysConfig) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(cfg); err != nil {
+		return errors.Wrap(err, "encode keys config")
+	}
+
+	return encoder.Close()
+}
+
+// Keys materialises a Keys record from the config,
+// filling in defaults for any unset parameters, the
+// evaluation context is used to determine NotBefore
+// when it isn't specified by the config.
+//
+// The materialised record is validated before it is
+// returned. The private keys of key shares generated
+// for groups without a key exchange are discarded,
+// KeysWithPrivateKeys must be used to retain them.
+func (cfg *KeysConfig) Keys(ectx *EvalContext) (*Keys, error) {
+	keys, _, err := cfg.KeysWithPrivateKeys(ectx)
+	return keys, err
+}
+
+// KeysWithPrivateKeys materialises a Keys record from
+// the config as Keys does, returning the private keys
+// of the key shares generated for groups without a
+// key exchange alongside it
+func (cfg *KeysConfig) KeysWithPrivateKeys(ectx *EvalContext) (*Keys, *PrivateKeys, error) {
+	if cfg.Lifetime <= 0 {
+		return nil, nil, errors.New("lifetime must be greater than zero")
+	}
+
+	builder := NewKeysBuilder().
+		PublicName(cfg.PublicName).
+		PaddedLength(cfg.PaddedLength).
+		NotBefore(cfg.NotBefore).
+		Lifetime(cfg.Lifetime).
+		EvalContext(ectx)
+
+	if cfg.Version != 0 {
+		builder.Version(cfg.Version)
+	}
+
+	for i := range cfg.KeyShares {
+		if len(cfg.KeyShares[i].KeyExchange) == 0 {
+			builder.AddGroup(cfg.KeyShares[i].Group)
+			continue
+		}
+
+		keyExchange, err := hex.DecodeString(cfg.KeyShares[i].KeyExchange)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "decode key share %d", i)
+		}
+
+		builder.AddKeyShare(KeyShareEntry{Group: cfg.KeyShares[i].Group, KeyExchange: keyExchange})
+	}
+
+	for _, suite := range cfg.CipherSuites {
+		builder.AddCipherSuite(suite)
+	}
+
+	extensions, err := cfg.Extensions.extensions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ext := range extensions {
+		builder.AddExtension(ext)
+	}
+
+	return builder.BuildWithPrivateKeys()
+}
+
+// extensions materialises the list of ESNI
+// extensions declared in the config
+func (cfg ExtensionsConfig) extensions() (ExtensionList, error) {
+	var list ExtensionList
+
+	if len(cfg.AddressSet) > 0 {
+		set := new(AddressSet)
+
+		for _, value := range cfg.AddressSet {
+			address := net.ParseIP(value)
+			if address == nil {
+				return nil, errors.Errorf("invalid address %q in address set", value)
+			}
+
+			set.Addresses = append(set.Addresses, address)
+		}
+
+		list = append(list, set)
+	}
+
+	return list, nil
+}
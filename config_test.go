@@ -0,0 +1,45 @@
+package esni
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestKeysConfigGeneratesKeyShares(t *testing.T) {
+	supplied, _, err := Group(GroupX25519).NewKeyPair()
+	if err != nil {
+		t.Fatalf("generate key pair: %s", err)
+	}
+
+	document := `
+public_name: cdn.example
+lifetime: 24h
+key_shares:
+  - group: x25519
+    key_exchange: ` + hex.EncodeToString(supplied) + `
+  - group: ecp256r1
+`
+
+	cfg, err := LoadKeysConfig(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("LoadKeysConfig() error = %s", err)
+	}
+
+	keys, privateKeys, err := cfg.KeysWithPrivateKeys(nil)
+	if err != nil {
+		t.Fatalf("KeysWithPrivateKeys() error = %s", err)
+	}
+
+	if len(keys.Keys) != 2 {
+		t.Fatalf("record has %d key shares, want 2", len(keys.Keys))
+	}
+
+	if len(privateKeys.Entries) != 1 || privateKeys.Entries[0].Group != GroupECP256R1 {
+		t.Fatalf("private keys = %v, want a single %s key", privateKeys.Entries, Group(GroupECP256R1))
+	}
+
+	if _, ok := privateKeys.LookupKeyShare(*findKeyShare(keys.Keys, GroupECP256R1)); !ok {
+		t.Errorf("generated private key doesn't match the record")
+	}
+}
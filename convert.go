@@ -0,0 +1,155 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+// groupKemIds defines a map of groups and the
+// HPKE KEM that uses the same public key format,
+// allowing a key share to be reused in an ECHConfig
+var groupKemIds = map[Group]HpkeKemId{
+	GroupECP256R1:  HpkeKemId_DHKEM_P256_HKDF_SHA256,
+	GroupSECP384R1: HpkeKemId_DHKEM_P384_HKDF_SHA384,
+	GroupSECP521R1: HpkeKemId_DHKEM_P521_HKDF_SHA512,
+	GroupX25519:    HpkeKemId_DHKEM_X25519_HKDF_SHA256,
+	GroupX448:      HpkeKemId_DHKEM_X448_HKDF_SHA512,
+}
+
+// cipherSuiteHpkeSuites defines a map of TLS cipher
+// suites and the equivalent HPKE symmetric cipher
+// suite, the CCM cipher suites have no equivalent
+var cipherSuiteHpkeSuites = map[CipherSuite]HpkeSymmetricCipherSuite{
+	CipherSuite_TLS_AES_128_GCM_SHA256:       {KDF: HpkeKdfId_HKDF_SHA256, AEAD: HpkeAeadId_AES_128_GCM},
+	CipherSuite_TLS_AES_256_GCM_SHA384:       {KDF: HpkeKdfId_HKDF_SHA384, AEAD: HpkeAeadId_AES_256_GCM},
+	CipherSuite_TLS_CHACHA20_POLY1305_SHA256: {KDF: HpkeKdfId_HKDF_SHA256, AEAD: HpkeAeadId_CHACHA20_POLY1305},
+}
+
+// ConvertKeysToECHConfig produces an ECHConfig from
+// the Keys record, the first key share with a group
+// that has an equivalent HPKE KEM is used as the
+// public key of the config.
+//
+// The validity period of the record can't be carried
+// by an ECHConfig and is discarded, as are any ESNI
+// extensions that aren't mandatory. A record with a
+// mandatory extension can't be converted.
+func ConvertKeysToECHConfig(keys *Keys, configID uint8) (*ECHConfig, error) {
+	if len(keys.PublicName) == 0 {
+		return nil, errors.New("keys record has no public name")
+	}
+
+	config := &ECHConfig{
+		Version:    VersionDraft13,
+		ConfigID:   configID,
+		PublicName: keys.PublicName,
+	}
+
+	for _, entry := range keys.Keys {
+		if kem, ok := groupKemIds[entry.Group]; ok {
+			config.KemID = kem
+			config.PublicKey = append([]byte(nil), entry.KeyExchange...)
+			break
+		}
+	}
+
+	if len(config.PublicKey) == 0 {
+		return nil, errors.New("no key share with a group supported by HPKE")
+	}
+
+	for _, suite := range keys.CipherSuites {
+		if hpkeSuite, ok := cipherSuiteHpkeSuites[suite]; ok {
+			config.CipherSuites = append(config.CipherSuites, hpkeSuite)
+		}
+	}
+
+	if len(config.CipherSuites) == 0 {
+		return nil, errors.New("no cipher suite supported by HPKE")
+	}
+
+	for _, ext := range keys.Extensions {
+		if ext.Type().Mandatory() {
+			return nil, errors.Errorf("mandatory extension %s can't be converted", ext.Type())
+		}
+	}
+
+	if nameLength := int(keys.PaddedLength) - serverNameListSize(""); nameLength > 0xff {
+		config.MaximumNameLength = 0xff
+	} else if nameLength > 0 {
+		config.MaximumNameLength = uint8(nameLength)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// ConvertECHConfigToKeys produces a Keys record of the
+// specified version from the ECHConfig, the record is
+// valid from the current time of the evaluation context
+// for DefaultLifetime.
+//
+// An ECHConfig with a mandatory extension can't be
+// converted, other extensions are discarded.
+func ConvertECHConfigToKeys(config *ECHConfig, version Version, ectx *EvalContext) (*Keys, error) {
+	builder := NewKeysBuilder().
+		Version(version).
+		EvalContext(ectx)
+
+	if version.HasPublicName() {
+		builder.PublicName(config.PublicName)
+	}
+
+	group, ok := kemGroup(config.KemID)
+	if !ok {
+		return nil, errors.Errorf("kem %s has no equivalent group", config.KemID)
+	}
+
+	builder.AddKeyShare(KeyShareEntry{Group: group, KeyExchange: append([]byte(nil), config.PublicKey...)})
+
+	var converted int
+	for _, hpkeSuite := range config.CipherSuites {
+		for suite, equivalent := range cipherSuiteHpkeSuites {
+			if equivalent == hpkeSuite {
+				builder.AddCipherSuite(suite)
+				converted++
+			}
+		}
+	}
+
+	if converted == 0 {
+		return nil, errors.New("no cipher suite has an equivalent TLS cipher suite")
+	}
+
+	for _, ext := range config.Extensions {
+		if ext.Type.Mandatory() {
+			return nil, errors.Errorf("mandatory extension 0x%04x can't be converted", uint16(ext.Type))
+		}
+	}
+
+	if config.MaximumNameLength > 0 {
+		length := uint16(serverNameListSize("")) + uint16(config.MaximumNameLength)
+		if length < MinPaddedLength {
+			length = MinPaddedLength
+		} else if length > MaxPaddedLength {
+			length = MaxPaddedLength
+		}
+
+		builder.PaddedLength(length)
+	}
+
+	return builder.Build()
+}
+
+// kemGroup returns the group that uses the same
+// public key format as the HPKE KEM
+func kemGroup(kem HpkeKemId) (Group, bool) {
+	for group, equivalent := range groupKemIds {
+		if equivalent == kem {
+			return group, true
+		}
+	}
+
+	return 0, false
+}
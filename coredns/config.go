@@ -0,0 +1,98 @@
+package coredns
+
+import (
+	"sync"
+	"time"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+var (
+	// keyManagers holds the key managers registered
+	// by the process embedding CoreDNS
+	keyManagersMu sync.RWMutex
+	keyManagers   = make(map[string]esni.KeyManager)
+)
+
+// RegisterKeyManager registers the key manager under
+// the name, allowing a process that embeds CoreDNS
+// to serve the records it generates by referring to
+// the name in the "manager" option of the plugin
+func RegisterKeyManager(name string, manager esni.KeyManager) {
+	keyManagersMu.Lock()
+	defer keyManagersMu.Unlock()
+
+	keyManagers[name] = manager
+}
+
+// Config represents the configuration of the
+// plugin as parsed from a Corefile
+type Config struct {
+	// Zones specifies the zones
+	// records are published for
+	Zones []string
+
+	// KeysDir specifies the directory the
+	// records are loaded from
+	KeysDir string
+
+	// Reload specifies how often the directory is
+	// reloaded, if zero DefaultReloadInterval is used
+	Reload time.Duration
+
+	// Manager specifies the name of a registered
+	// key manager the records are sourced from
+	Manager string
+
+	// Options specifies the options used to
+	// derive the TTL and HTTPS record of each
+	// zone
+	Options esni.ZoneOptions
+}
+
+// Plugin returns the plugin described by the
+// config, exactly one of the keys directory and
+// key manager must be specified
+func (cfg Config) Plugin() (*Plugin, error) {
+	if len(cfg.Zones) == 0 {
+		return nil, errors.New("no zones specified")
+	}
+
+	var manager esni.KeyManager
+
+	switch {
+	case len(cfg.KeysDir) > 0 && len(cfg.Manager) > 0:
+		return nil, errors.New("only one of keys and manager can be specified")
+
+	case len(cfg.KeysDir) > 0:
+		dirManager, err := NewDirKeyManager(cfg.KeysDir, cfg.Reload)
+		if err != nil {
+			return nil, err
+		}
+
+		manager = dirManager
+
+	case len(cfg.Manager) > 0:
+		keyManagersMu.RLock()
+		registered, ok := keyManagers[cfg.Manager]
+		keyManagersMu.RUnlock()
+
+		if !ok {
+			return nil, errors.Errorf("no key manager registered as %q", cfg.Manager)
+		}
+
+		manager = registered
+
+	default:
+		return nil, errors.New("one of keys or manager must be specified")
+	}
+
+	plugin := &Plugin{Handlers: make([]*esni.DNSHandler, len(cfg.Zones))}
+	for i, zone := range cfg.Zones {
+		plugin.Handlers[i] = esni.NewDNSHandler(zone, manager)
+		plugin.Handlers[i].Options = cfg.Options
+	}
+
+	return plugin, nil
+}
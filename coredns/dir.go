@@ -0,0 +1,104 @@
+package coredns
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultReloadInterval specifies how often
+	// a DirKeyManager reloads its directory
+	DefaultReloadInterval = time.Minute
+
+	// keysFileExtension specifies the extension
+	// of the files records are loaded from
+	keysFileExtension = ".pem"
+)
+
+// DirKeyManager implements an esni.KeyManager that
+// loads the "ESNI KEYS" PEM blocks of every .pem file
+// in a directory, blocks of other types such as private
+// keys are skipped. The directory is reloaded when the
+// records are requested after the reload interval, if
+// a reload fails the previous records are kept.
+type DirKeyManager struct {
+	dir    string
+	reload time.Duration
+
+	mu     sync.Mutex
+	keys   []*esni.Keys
+	loaded time.Time
+}
+
+// NewDirKeyManager returns a DirKeyManager for the
+// directory, an error is returned if the directory
+// can't be loaded
+func NewDirKeyManager(dir string, reload time.Duration) (*DirKeyManager, error) {
+	if reload <= 0 {
+		reload = DefaultReloadInterval
+	}
+
+	keys, err := loadKeysDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DirKeyManager{dir: dir, reload: reload, keys: keys, loaded: time.Now()}, nil
+}
+
+// Keys returns the records loaded from the directory
+func (manager *DirKeyManager) Keys() []*esni.Keys {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if time.Since(manager.loaded) >= manager.reload {
+		if keys, err := loadKeysDir(manager.dir); err == nil {
+			manager.keys = keys
+		}
+
+		manager.loaded = time.Now()
+	}
+
+	return manager.keys
+}
+
+// loadKeysDir loads the records from
+// the PEM files in the directory
+func loadKeysDir(dir string) ([]*esni.Keys, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read keys directory")
+	}
+
+	var keys []*esni.Keys
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), keysFileExtension) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", file.Name())
+		}
+
+		for {
+			record, rest, err := esni.DecodePEM(data)
+			if err == esni.ErrNoPEMBlock {
+				break
+			} else if err != nil {
+				return nil, errors.Wrapf(err, "decode %s", file.Name())
+			}
+
+			keys = append(keys, record)
+			data = rest
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,87 @@
+// Package coredns provides the "esni" CoreDNS plugin,
+// which answers queries for the _esni TXT records, and
+// optionally the HTTPS record, of its zones from the
+// Keys records held by a key manager or stored as PEM
+// files in a directory.
+//
+// The plugin is a separate module that depends on
+// CoreDNS, so the go-esni module itself doesn't, and
+// registers itself with CoreDNS when imported. To
+// include the plugin in a CoreDNS build, add the
+// following line to its plugin.cfg:
+//
+//	esni:github.com/LiamHaworth/go-esni/coredns
+//
+// The plugin is configured in a Corefile as follows,
+// the zones default to those of the server block:
+//
+//	esni [ZONES...] {
+//	    keys DIR
+//	    manager NAME
+//	    reload DURATION
+//	    margin DURATION
+//	    max_ttl DURATION
+//	    https [PRIORITY]
+//	    config_id ID
+//	}
+package coredns
+
+import (
+	"context"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name specifies the name of the plugin
+	// as used in Corefiles and plugin.cfg
+	Name = "esni"
+)
+
+// NextHandler is implemented by the next plugin
+// in the chain, it has the same method set as the
+// plugin.Handler interface of CoreDNS
+type NextHandler interface {
+	ServeDNS(ctx context.Context, w dns.ResponseWriter, query *dns.Msg) (int, error)
+	Name() string
+}
+
+// Plugin represents the "esni" plugin, it answers
+// the queries of the records it publishes and passes
+// all other queries to the next plugin
+type Plugin struct {
+	// Next specifies the next
+	// plugin in the chain
+	Next NextHandler
+
+	// Handlers specifies the
+	// handler of each zone
+	Handlers []*esni.DNSHandler
+}
+
+// ServeDNS answers the query if it is for records
+// published by one of the zones, otherwise the query
+// is passed to the next plugin
+func (plugin *Plugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, query *dns.Msg) (int, error) {
+	if len(query.Question) == 1 {
+		for _, handler := range plugin.Handlers {
+			if handler.Answers(query.Question[0].Name) {
+				handler.ServeDNS(w, query)
+				return dns.RcodeSuccess, nil
+			}
+		}
+	}
+
+	if plugin.Next == nil {
+		return dns.RcodeServerFailure, errors.New("no next plugin found")
+	}
+
+	return plugin.Next.ServeDNS(ctx, w, query)
+}
+
+// Name returns the name of the plugin
+func (plugin *Plugin) Name() string {
+	return Name
+}
@@ -0,0 +1,132 @@
+package coredns
+
+import (
+	"strconv"
+	"time"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// plugin with CoreDNS
+func init() {
+	plugin.Register(Name, setup)
+}
+
+// setup parses the configuration of the plugin
+// and adds it to the plugin chain of the server
+func setup(c *caddy.Controller) error {
+	esniPlugin, err := Setup(c)
+	if err != nil {
+		return plugin.Error(Name, err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		esniPlugin.Next = next
+		return esniPlugin
+	})
+
+	return nil
+}
+
+// Setup parses the "esni" directive of the
+// Corefile and returns the plugin it describes
+func Setup(c *caddy.Controller) (*Plugin, error) {
+	var cfg Config
+
+	for i := 0; c.Next(); i++ {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+
+		cfg.Zones = plugin.OriginsFromArgsOrServerBlock(c.RemainingArgs(), c.ServerBlockKeys)
+
+		for c.NextBlock() {
+			if err := parseOption(c, &cfg); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cfg.Plugin()
+}
+
+// parseOption parses a single option
+// of the block into the config
+func parseOption(c *caddy.Controller, cfg *Config) error {
+	option := c.Val()
+	args := c.RemainingArgs()
+
+	switch option {
+	case "keys":
+		if len(args) != 1 {
+			return c.ArgErr()
+		}
+
+		cfg.KeysDir = args[0]
+
+	case "manager":
+		if len(args) != 1 {
+			return c.ArgErr()
+		}
+
+		cfg.Manager = args[0]
+
+	case "reload", "margin", "max_ttl":
+		if len(args) != 1 {
+			return c.ArgErr()
+		}
+
+		duration, err := time.ParseDuration(args[0])
+		if err != nil || duration <= 0 {
+			return c.Errf("invalid %s duration %q", option, args[0])
+		}
+
+		switch option {
+		case "reload":
+			cfg.Reload = duration
+		case "margin":
+			cfg.Options.Margin = duration
+		default:
+			cfg.Options.MaxTTL = duration
+		}
+
+	case "https":
+		if len(args) > 1 {
+			return c.ArgErr()
+		}
+
+		record := &esni.SVCBRecord{Priority: 1, Target: "."}
+		if len(args) == 1 {
+			priority, err := strconv.ParseUint(args[0], 10, 16)
+			if err != nil || priority == 0 {
+				return c.Errf("invalid https priority %q", args[0])
+			}
+
+			record.Priority = uint16(priority)
+		}
+
+		cfg.Options.HTTPS = record
+
+	case "config_id":
+		if len(args) != 1 {
+			return c.ArgErr()
+		}
+
+		id, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil {
+			return c.Errf("invalid config id %q", args[0])
+		}
+
+		cfg.Options.ConfigID = uint8(id)
+
+	default:
+		return c.Errf("unknown option %q", option)
+	}
+
+	return nil
+}
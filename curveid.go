@@ -0,0 +1,58 @@
+package esni
+
+import (
+	"crypto/tls"
+)
+
+// Group_curveID defines a map of groups and their
+// respective crypto/tls CurveID, which is the TLS
+// NamedGroup code point of the group
+var Group_curveID = map[Group]tls.CurveID{
+	GroupECP256R1:  tls.CurveP256,
+	GroupSECP384R1: tls.CurveP384,
+	GroupSECP521R1: tls.CurveP521,
+	GroupX25519:    tls.X25519,
+	GroupX448:      tls.CurveID(0x001E),
+	GroupFFDHE2048: tls.CurveID(0x0100),
+	GroupFFDHE3072: tls.CurveID(0x0101),
+	GroupFFDHE4096: tls.CurveID(0x0102),
+	GroupFFDHE6144: tls.CurveID(0x0103),
+	GroupFFDHE8192: tls.CurveID(0x0104),
+}
+
+// ToCurveID attempts to return the crypto/tls
+// CurveID of the Group based on those specified
+// in Group_curveID, ok is false if no match is found
+func (g Group) ToCurveID() (id tls.CurveID, ok bool) {
+	id, ok = Group_curveID[g]
+	return
+}
+
+// FromCurveID attempts to return the Group of the
+// crypto/tls CurveID based on those specified in
+// Group_curveID, ok is false if no match is found
+func FromCurveID(id tls.CurveID) (group Group, ok bool) {
+	for group, known := range Group_curveID {
+		if known == id {
+			return group, true
+		}
+	}
+
+	return 0, false
+}
+
+// GroupsFromCurvePreferences translates the curve
+// preferences of a crypto/tls config into groups,
+// preserving their order, curves without a group
+// are skipped
+func GroupsFromCurvePreferences(preferences []tls.CurveID) []Group {
+	groups := make([]Group, 0, len(preferences))
+
+	for _, id := range preferences {
+		if group, ok := FromCurveID(id); ok {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
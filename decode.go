@@ -0,0 +1,60 @@
+package esni
+
+// DecodeOptions specifies the options that
+// control how a Keys record is decoded from
+// binary data
+type DecodeOptions struct {
+	// RejectTrailingData specifies if decoding
+	// should fail with a *TrailingDataError when
+	// data remains after the Keys record
+	RejectTrailingData bool
+
+	// Limits specifies the parse limits enforced
+	// while decoding the record, if nil DefaultLimits
+	// is used
+	Limits *Limits
+
+	// ValidatePublicName specifies if decoding
+	// should fail with a *PublicNameError when the
+	// public name of the record is invalid
+	ValidatePublicName bool
+}
+
+// Decode will attempt to unmarshal a single Keys
+// record from the start of the binary data, returning
+// the number of bytes consumed by the record.
+//
+// Unlike UnmarshalBinary the caller is able to detect
+// data following the record, such as a corrupted or
+// concatenated input.
+func (keys *Keys) Decode(data []byte) (int, error) {
+	return keys.DecodeWithOptions(data, DecodeOptions{})
+}
+
+// DecodeWithOptions will attempt to unmarshal a single
+// Keys record from the start of the binary data using
+// the provided options, returning the number of bytes
+// consumed by the record
+func (keys *Keys) DecodeWithOptions(data []byte, opts DecodeOptions) (int, error) {
+	limits := DefaultLimits
+	if opts.Limits != nil {
+		limits = *opts.Limits
+	}
+
+	n, err := keys.unmarshal(data, limits)
+	if err != nil {
+		return n, err
+	}
+
+	if opts.ValidatePublicName && keys.Version.HasPublicName() {
+		if err := ValidatePublicName(keys.PublicName); err != nil {
+			return n, err
+		}
+	}
+
+	if opts.RejectTrailingData && n < len(data) {
+		return n, &TrailingDataError{Offset: n, Data: data[n:]}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,166 @@
+package esni
+
+import (
+	"bytes"
+	"crypto/subtle"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrRecordDigestMismatch is returned when the
+	// record digest of an encrypted SNI doesn't match
+	// the digest of the Keys record
+	ErrRecordDigestMismatch = errors.New("record digest does not match keys record")
+
+	// ErrDecryptionFailed is returned when the
+	// encrypted SNI fails to authenticate
+	ErrDecryptionFailed = errors.New("failed to decrypt sni")
+)
+
+// DecryptOptions specifies the options
+// used when decrypting an SNI
+type DecryptOptions struct {
+	// ConstantTime enables the hardened mode intended
+	// for servers exposed to active probing, the record
+	// digest is compared in constant time, a mismatch
+	// doesn't short-circuit the decryption and every
+	// failure after the key exchange is reported as
+	// ErrDecryptionFailed so failures are
+	// indistinguishable in both error and timing
+	ConstantTime bool
+
+	// KeySchedule specifies the options
+	// of the key schedule
+	KeySchedule KeyScheduleOptions
+}
+
+// DecryptSNI decrypts the SNI sent by a client using the
+// Keys record and its private keys, returning the server
+// name and the nonce that must be echoed to the client.
+//
+// The client hello random is the random value of the
+// ClientHello and the client key share is the body of its
+// key_share extension, which authenticates the encryption.
+// The record digest must match the record and the padded
+// length of the decrypted SNI must match its padded length.
+func DecryptSNI(encrypted ClientEncryptedSNI, keys *Keys, priv *PrivateKeys, clientHelloRandom, clientKeyShare []byte) (string, [NonceSize]byte, error) {
+	return DecryptSNIWithOptions(encrypted, keys, priv, clientHelloRandom, clientKeyShare, DecryptOptions{})
+}
+
+// DecryptSNIWithOptions decrypts the SNI sent by a
+// client in the same manner as DecryptSNI using the
+// provided options
+func DecryptSNIWithOptions(encrypted ClientEncryptedSNI, keys *Keys, priv *PrivateKeys, clientHelloRandom, clientKeyShare []byte, opts DecryptOptions) (string, [NonceSize]byte, error) {
+	serverShare := findKeyShare(keys.Keys, encrypted.KeyShare.Group)
+	if serverShare == nil {
+		return "", [NonceSize]byte{}, errors.Wrapf(ErrUnsupportedGroup, "record has no key share for %s", encrypted.KeyShare.Group)
+	}
+
+	entry, ok := priv.LookupKeyShare(*serverShare)
+	if !ok {
+		return "", [NonceSize]byte{}, errors.Errorf("no private key for key share of group %s", serverShare.Group)
+	}
+
+	return decryptSNI(encrypted, keys, entry, clientHelloRandom, clientKeyShare, true, opts)
+}
+
+// DecryptSNIWithKeyExchanger decrypts the SNI sent by a
+// client in the same manner as DecryptSNI, performing the
+// key exchange with the KeyExchanger instead of a private
+// key held by the process, the key share of the exchanger
+// must belong to the Keys record
+func DecryptSNIWithKeyExchanger(encrypted ClientEncryptedSNI, keys *Keys, exchanger KeyExchanger, clientHelloRandom, clientKeyShare []byte) (string, [NonceSize]byte, error) {
+	return decryptSNI(encrypted, keys, exchanger, clientHelloRandom, clientKeyShare, true, DecryptOptions{})
+}
+
+// decryptSNI implements the decryption of the SNI, if
+// checkDigest is false the record digest sent by the client
+// isn't required to match the record, which is used to trial
+// decrypt against records the client may have used
+func decryptSNI(encrypted ClientEncryptedSNI, keys *Keys, exchanger KeyExchanger, clientHelloRandom, clientKeyShare []byte, checkDigest bool, opts DecryptOptions) (string, [NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+
+	if len(clientHelloRandom) != 32 {
+		return "", nonce, errors.New("client hello random must be 32 bytes")
+	}
+
+	if !containsCipherSuite(keys.CipherSuites, encrypted.Suite) || !encrypted.Suite.supportsAEAD() {
+		return "", nonce, errors.Errorf("unsupported cipher suite %s", encrypted.Suite)
+	}
+
+	if len(encrypted.RecordDigest) != encrypted.Suite.Hash().Size() {
+		return "", nonce, errors.Wrapf(ErrRecordDigestMismatch, "record digest must be %d bytes for %s", encrypted.Suite.Hash().Size(), encrypted.Suite)
+	}
+
+	digestMatch := true
+	if checkDigest {
+		digest, err := keys.RecordDigest(encrypted.Suite)
+		if err != nil {
+			return "", nonce, err
+		}
+
+		if opts.ConstantTime {
+			digestMatch = subtle.ConstantTimeCompare(digest, encrypted.RecordDigest) == 1
+		} else if !bytes.Equal(digest, encrypted.RecordDigest) {
+			return "", nonce, ErrRecordDigestMismatch
+		}
+	}
+
+	share := exchanger.KeyShare()
+	if share.Group != encrypted.KeyShare.Group {
+		return "", nonce, errors.Errorf("key exchanger is for group %s not %s", share.Group, encrypted.KeyShare.Group)
+	}
+
+	if serverShare := findKeyShare(keys.Keys, share.Group); serverShare == nil || !bytes.Equal(serverShare.KeyExchange, share.KeyExchange) {
+		return "", nonce, errors.New("key exchanger does not belong to a key share of the record")
+	}
+
+	sharedSecret, err := exchanger.ECDH(encrypted.KeyShare.KeyExchange)
+	if err != nil {
+		return "", nonce, err
+	}
+
+	defer Zeroize(sharedSecret)
+
+	contents := ESNIContents{RecordDigest: encrypted.RecordDigest, KeyShare: encrypted.KeyShare}
+	copy(contents.ClientHelloRandom[:], clientHelloRandom)
+
+	key, iv, err := DeriveSNIKeyWithOptions(encrypted.Suite, sharedSecret, contents, opts.KeySchedule)
+	if err != nil {
+		return "", nonce, err
+	}
+
+	defer Zeroize(key, iv)
+
+	aead, err := encrypted.Suite.NewAEAD(key)
+	if err != nil {
+		return "", nonce, err
+	}
+
+	plaintext, err := aead.Open(nil, iv, encrypted.EncryptedSNI, clientKeyShare)
+	if err != nil || !digestMatch {
+		return "", nonce, ErrDecryptionFailed
+	}
+
+	defer Zeroize(plaintext)
+
+	var inner ClientESNIInner
+	if err := inner.UnmarshalBinary(plaintext); err != nil {
+		if opts.ConstantTime {
+			return "", nonce, ErrDecryptionFailed
+		}
+
+		return "", nonce, err
+	}
+
+	if subtle.ConstantTimeEq(int32(inner.RealSNI.PaddedLength), int32(keys.PaddedLength)) != 1 {
+		if opts.ConstantTime {
+			return "", nonce, ErrDecryptionFailed
+		}
+
+		return "", nonce, errors.Errorf("padded length of %d does not match record padded length of %d", inner.RealSNI.PaddedLength, keys.PaddedLength)
+	}
+
+	return inner.RealSNI.ServerName, inner.Nonce, nil
+}
@@ -0,0 +1,138 @@
+package esni
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// decrypt decrypts the encrypted SNI of the test
+func (test *sniTest) decrypt(opts DecryptOptions) (string, [NonceSize]byte, error) {
+	return DecryptSNIWithOptions(*test.encrypted, test.keys, test.privateKeys, test.random, test.keyShare, opts)
+}
+
+// reseal decrypts the encrypted SNI using the private
+// key of the record, edits the plaintext and encrypts
+// it again so it still authenticates
+func (test *sniTest) reseal(t *testing.T, edit func(plaintext []byte)) {
+	t.Helper()
+
+	entry, ok := test.privateKeys.LookupKeyShare(*findKeyShare(test.keys.Keys, test.encrypted.KeyShare.Group))
+	if !ok {
+		t.Fatal("no private key for the key share")
+	}
+
+	sharedSecret, err := entry.ECDH(test.encrypted.KeyShare.KeyExchange)
+	if err != nil {
+		t.Fatalf("key exchange: %s", err)
+	}
+
+	contents := ESNIContents{RecordDigest: test.encrypted.RecordDigest, KeyShare: test.encrypted.KeyShare}
+	copy(contents.ClientHelloRandom[:], test.random)
+
+	key, iv, err := DeriveSNIKey(test.encrypted.Suite, sharedSecret, contents)
+	if err != nil {
+		t.Fatalf("derive key: %s", err)
+	}
+
+	aead, err := test.encrypted.Suite.NewAEAD(key)
+	if err != nil {
+		t.Fatalf("construct aead: %s", err)
+	}
+
+	plaintext, err := aead.Open(nil, iv, test.encrypted.EncryptedSNI, test.keyShare)
+	if err != nil {
+		t.Fatalf("open encrypted sni: %s", err)
+	}
+
+	edit(plaintext)
+	test.encrypted.EncryptedSNI = aead.Seal(nil, iv, plaintext, test.keyShare)
+}
+
+func TestDecryptSNIRoundTrip(t *testing.T) {
+	for _, suite := range supportedCipherSuites() {
+		t.Run(suite.String(), func(t *testing.T) {
+			test := newSNITest(t, suite)
+			if test.encrypted.Suite != suite {
+				t.Fatalf("encrypted with %s, want %s", test.encrypted.Suite, suite)
+			}
+
+			for _, opts := range []DecryptOptions{{}, {ConstantTime: true}} {
+				name, nonce, err := test.decrypt(opts)
+				if err != nil {
+					t.Fatalf("DecryptSNI() error = %v", err)
+				}
+
+				if name != testServerName {
+					t.Errorf("DecryptSNI() name = %q, want %q", name, testServerName)
+				}
+
+				if nonce != test.inner.Nonce {
+					t.Errorf("DecryptSNI() nonce = %x, want %x", nonce, test.inner.Nonce)
+				}
+			}
+		})
+	}
+}
+
+func TestDecryptSNIRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, test *sniTest)
+		want   error
+	}{
+		{
+			name: "record digest",
+			tamper: func(t *testing.T, test *sniTest) {
+				test.encrypted.RecordDigest = append([]byte(nil), test.encrypted.RecordDigest...)
+				test.encrypted.RecordDigest[0] ^= 0xff
+			},
+			want: ErrRecordDigestMismatch,
+		},
+		{
+			name: "key share aad",
+			tamper: func(t *testing.T, test *sniTest) {
+				test.keyShare[len(test.keyShare)-1] ^= 0xff
+			},
+			want: ErrDecryptionFailed,
+		},
+		{
+			name: "client hello random",
+			tamper: func(t *testing.T, test *sniTest) {
+				test.random[0] ^= 0xff
+			},
+			want: ErrDecryptionFailed,
+		},
+		{
+			name: "ciphertext",
+			tamper: func(t *testing.T, test *sniTest) {
+				test.encrypted.EncryptedSNI[0] ^= 0xff
+			},
+			want: ErrDecryptionFailed,
+		},
+		{
+			name: "padding",
+			tamper: func(t *testing.T, test *sniTest) {
+				test.reseal(t, func(plaintext []byte) { plaintext[len(plaintext)-1] = 0x01 })
+			},
+			want: ErrNonZeroPadding,
+		},
+	}
+
+	for _, suite := range supportedCipherSuites() {
+		for _, tt := range tests {
+			t.Run(suite.String()+"/"+tt.name, func(t *testing.T) {
+				test := newSNITest(t, suite)
+				tt.tamper(t, test)
+
+				if _, _, err := test.decrypt(DecryptOptions{}); errors.Cause(err) != tt.want {
+					t.Errorf("DecryptSNI() error = %v, want %v", err, tt.want)
+				}
+
+				if _, _, err := test.decrypt(DecryptOptions{ConstantTime: true}); err != ErrDecryptionFailed {
+					t.Errorf("DecryptSNI() in constant time error = %v, want %v", err, ErrDecryptionFailed)
+				}
+			})
+		}
+	}
+}
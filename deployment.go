@@ -0,0 +1,299 @@
+package esni
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DiscrepancyKind represents the type of difference
+// found between the published and local Keys records
+type DiscrepancyKind uint8
+
+const (
+	// DiscrepancyMissing indicates a local record
+	// is not published
+	DiscrepancyMissing DiscrepancyKind = iota
+
+	// DiscrepancyUnexpected indicates a published
+	// record doesn't match any local record
+	DiscrepancyUnexpected
+
+	// DiscrepancyDigest indicates a published record
+	// shares its keys with a local record but their
+	// record digests differ
+	DiscrepancyDigest
+
+	// DiscrepancyValidity indicates the validity
+	// window of a published record differs from
+	// the local record
+	DiscrepancyValidity
+
+	// DiscrepancyAddressSet indicates the addresses
+	// in the address_set extension of a published
+	// record differ from the local record
+	DiscrepancyAddressSet
+
+	// DiscrepancyInvalid indicates a published record
+	// could not be parsed or is not currently valid
+	DiscrepancyInvalid
+)
+
+// DiscrepancyKind_name specifies a map of discrepancy
+// kinds to their respective string representation
+var DiscrepancyKind_name = map[DiscrepancyKind]string{
+	DiscrepancyMissing:    "missing",
+	DiscrepancyUnexpected: "unexpected",
+	DiscrepancyDigest:     "digest",
+	DiscrepancyValidity:   "validity",
+	DiscrepancyAddressSet: "address_set",
+	DiscrepancyInvalid:    "invalid",
+}
+
+// String attempts to return the string
+// representation of the DiscrepancyKind based
+// on those specified in DiscrepancyKind_name, if
+// no match is found "UNKNOWN" is returned
+func (kind DiscrepancyKind) String() string {
+	if name, ok := DiscrepancyKind_name[kind]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// Discrepancy represents a single difference
+// between the published and local Keys records
+// of a domain
+type Discrepancy struct {
+	// Kind specifies the type of the difference
+	Kind DiscrepancyKind
+
+	// Local specifies the local record, it is
+	// nil for unexpected and invalid records
+	Local *Keys
+
+	// Published specifies the published record,
+	// it is nil for missing and invalid records
+	Published *Keys
+
+	// Index specifies the index of the published
+	// TXT record, it is -1 for missing records
+	Index int
+
+	// Changes specifies the field changes from
+	// the local to the published record, it is
+	// only set for records that were paired
+	Changes KeysDiff
+
+	// Err specifies why a published record is
+	// invalid
+	Err error
+}
+
+// DeploymentReport represents the result of comparing
+// the Keys records published for a domain against the
+// records held locally
+type DeploymentReport struct {
+	// Domain specifies the domain that was checked
+	Domain string
+
+	// CheckedAt specifies the time the check was
+	// performed as of the evaluation context
+	CheckedAt time.Time
+
+	// Published specifies the usable records that
+	// were fetched from DNS
+	Published []FetchedKeys
+
+	// Discrepancies specifies every difference
+	// found, it is empty for a healthy deployment
+	Discrepancies []Discrepancy
+}
+
+// Healthy returns if the published records
+// exactly match the local records
+func (report *DeploymentReport) Healthy() bool {
+	return len(report.Discrepancies) == 0
+}
+
+// CheckDeployment compares the Keys records published
+// for the domain against the local records using the
+// default Fetcher
+func CheckDeployment(ctx context.Context, domain string, localKeys []*Keys) (*DeploymentReport, error) {
+	return new(Fetcher).CheckDeployment(ctx, domain, localKeys)
+}
+
+// CheckDeployment fetches the Keys records published
+// for the domain and compares them against the local
+// records, allowing monitoring systems to detect stale
+// or unexpected deployments.
+//
+// Records that are byte-for-byte identical after
+// canonicalisation are matched by their fingerprint,
+// the remaining records are paired when they share a
+// key exchange and their record digests, validity
+// windows and address sets are compared.
+//
+// An error is only returned if the lookup itself
+// fails, a domain with no records, or no usable
+// records, reports each local record as missing.
+func (fetcher *Fetcher) CheckDeployment(ctx context.Context, domain string, localKeys []*Keys) (*DeploymentReport, error) {
+	fetched, skipped, err := fetcher.FetchKeys(ctx, domain)
+	if isNotFound(err) {
+		err = nil
+	}
+
+	if err != nil && err != ErrNoKeys {
+		return nil, err
+	}
+
+	ectx := fetcher.EvalContext
+	if ectx == nil {
+		ectx = EvalContextFrom(ctx)
+	}
+
+	report := &DeploymentReport{Domain: domain, CheckedAt: ectx.Now(), Published: fetched}
+
+	for _, record := range skipped {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: DiscrepancyInvalid, Index: record.Index, Err: record.Err})
+	}
+
+	paired := make([]bool, len(fetched))
+
+	for _, local := range localKeys {
+		index, err := matchPublished(local, fetched, paired)
+		if err != nil {
+			return nil, err
+		}
+
+		if index < 0 {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: DiscrepancyMissing, Local: local, Index: -1})
+			continue
+		}
+
+		paired[index] = true
+		report.Discrepancies = append(report.Discrepancies, compareDeployed(local, fetched[index])...)
+	}
+
+	for i := range fetched {
+		if !paired[i] {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{Kind: DiscrepancyUnexpected, Published: fetched[i].Keys, Index: fetched[i].Source.Index})
+		}
+	}
+
+	return report, nil
+}
+
+// matchPublished returns the index of the published
+// record for the local record, preferring an identical
+// record over one that only shares a key exchange, if
+// there is no match -1 is returned
+func matchPublished(local *Keys, fetched []FetchedKeys, paired []bool) (int, error) {
+	fingerprint, err := local.Fingerprint()
+	if err != nil {
+		return -1, errors.Wrap(err, "fingerprint local keys")
+	}
+
+	for i := range fetched {
+		if paired[i] {
+			continue
+		}
+
+		published, err := fetched[i].Keys.Fingerprint()
+		if err != nil {
+			return -1, errors.Wrap(err, "fingerprint published keys")
+		}
+
+		if published == fingerprint {
+			return i, nil
+		}
+	}
+
+	for i := range fetched {
+		if !paired[i] && sharesKeyExchange(local.Keys, fetched[i].Keys.Keys) {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// compareDeployed returns the discrepancies
+// between a local record and the published
+// record it was paired with
+func compareDeployed(local *Keys, published FetchedKeys) []Discrepancy {
+	changes := Diff(local, published.Keys)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	discrepancy := Discrepancy{Local: local, Published: published.Keys, Index: published.Source.Index, Changes: changes}
+
+	var discrepancies []Discrepancy
+
+	discrepancy.Kind = DiscrepancyDigest
+	discrepancies = append(discrepancies, discrepancy)
+
+	if !local.NotBefore.Equal(published.Keys.NotBefore) || !local.NotAfter.Equal(published.Keys.NotAfter) {
+		discrepancy.Kind = DiscrepancyValidity
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	if !equalAddresses(addressSetOf(local), addressSetOf(published.Keys)) {
+		discrepancy.Kind = DiscrepancyAddressSet
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies
+}
+
+// sharesKeyExchange checks if any key share
+// entry appears in both lists
+func sharesKeyExchange(a, b KeyShareEntryList) bool {
+	for i := range a {
+		if match := findKeyShare(b, a[i].Group); match != nil && bytes.Equal(match.KeyExchange, a[i].KeyExchange) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addressSetOf returns the addresses in the
+// address_set extension of the record, if it
+// has no such extension nil is returned
+func addressSetOf(keys *Keys) []net.IP {
+	if set, ok := findExtension(keys.Extensions, ExtensionTypeAddressSet).(*AddressSet); ok {
+		return set.Addresses
+	}
+
+	return nil
+}
+
+// equalAddresses checks if both lists contain
+// the same addresses, ignoring their order
+func equalAddresses(a, b []net.IP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		found := false
+		for j := range b {
+			if a[i].Equal(b[j]) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,253 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChangeKind represents the type of change
+// made to a field between two Keys records
+type ChangeKind uint8
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+// ChangeKind_name specifies a map of change
+// kinds to their respective string representation
+var ChangeKind_name = map[ChangeKind]string{
+	ChangeAdded:    "added",
+	ChangeRemoved:  "removed",
+	ChangeModified: "modified",
+}
+
+// String attempts to return the string
+// representation of the ChangeKind based
+// on those specified in ChangeKind_name, if
+// no match is found "UNKNOWN" is returned
+func (kind ChangeKind) String() string {
+	if name, ok := ChangeKind_name[kind]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// symbol returns the single character prefix
+// used when displaying a change of this kind
+func (kind ChangeKind) symbol() string {
+	switch kind {
+	case ChangeAdded:
+		return "+"
+	case ChangeRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Change represents a single difference
+// between two Keys records
+type Change struct {
+	// Field specifies the name of the field
+	// in the Keys record that changed
+	Field string
+
+	// Kind specifies how the field changed
+	Kind ChangeKind
+
+	// Old specifies the previous value, it
+	// is empty for added values
+	Old string
+
+	// New specifies the new value, it is
+	// empty for removed values
+	New string
+}
+
+// String returns a single line representation
+// of the change suitable for display in a CLI
+func (change Change) String() string {
+	switch change.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("%s %s: %s", change.Kind.symbol(), change.Field, change.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%s %s: %s", change.Kind.symbol(), change.Field, change.Old)
+	default:
+		return fmt.Sprintf("%s %s: %s -> %s", change.Kind.symbol(), change.Field, change.Old, change.New)
+	}
+}
+
+// KeysDiff represents the list of changes
+// between two Keys records
+type KeysDiff []Change
+
+// String returns a multi-line representation
+// of every change in the diff
+func (diff KeysDiff) String() string {
+	lines := make([]string, len(diff))
+	for i := range diff {
+		lines[i] = diff[i].String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Diff compares the old and new Keys records field
+// by field and returns the list of changes required
+// to go from the old record to the new record, a nil
+// record is treated as an empty record
+func Diff(old, new *Keys) KeysDiff {
+	if old == nil {
+		old = &Keys{}
+	}
+
+	if new == nil {
+		new = &Keys{}
+	}
+
+	var diff KeysDiff
+
+	diff.modified("version", old.Version.String(), new.Version.String())
+	diff.modified("public_name", old.PublicName, new.PublicName)
+	diff.keyShares(old.Keys, new.Keys)
+	diff.cipherSuites(old.CipherSuites, new.CipherSuites)
+	diff.modified("padded_length", fmt.Sprint(old.PaddedLength), fmt.Sprint(new.PaddedLength))
+	diff.modified("not_before", formatDiffTime(old.NotBefore), formatDiffTime(new.NotBefore))
+	diff.modified("not_after", formatDiffTime(old.NotAfter), formatDiffTime(new.NotAfter))
+	diff.extensions(old.Extensions, new.Extensions)
+
+	return diff
+}
+
+// modified appends a modified change if the
+// old and new values differ
+func (diff *KeysDiff) modified(field, old, new string) {
+	if old != new {
+		*diff = append(*diff, Change{Field: field, Kind: ChangeModified, Old: old, New: new})
+	}
+}
+
+// keyShares compares the key share lists by
+// group, reporting added and removed groups
+// along with groups where the key changed
+func (diff *KeysDiff) keyShares(old, new KeyShareEntryList) {
+	for i := range old {
+		if match := findKeyShare(new, old[i].Group); match == nil {
+			*diff = append(*diff, Change{Field: "keys", Kind: ChangeRemoved, Old: formatKeyShare(old[i])})
+		} else if !bytes.Equal(match.KeyExchange, old[i].KeyExchange) {
+			*diff = append(*diff, Change{Field: "keys", Kind: ChangeModified, Old: formatKeyShare(old[i]), New: formatKeyShare(*match)})
+		}
+	}
+
+	for i := range new {
+		if findKeyShare(old, new[i].Group) == nil {
+			*diff = append(*diff, Change{Field: "keys", Kind: ChangeAdded, New: formatKeyShare(new[i])})
+		}
+	}
+}
+
+// cipherSuites compares the cipher suite lists
+// reporting added and removed suites
+func (diff *KeysDiff) cipherSuites(old, new []CipherSuite) {
+	for _, suite := range old {
+		if !containsCipherSuite(new, suite) {
+			*diff = append(*diff, Change{Field: "cipher_suites", Kind: ChangeRemoved, Old: formatCipherSuite(suite)})
+		}
+	}
+
+	for _, suite := range new {
+		if !containsCipherSuite(old, suite) {
+			*diff = append(*diff, Change{Field: "cipher_suites", Kind: ChangeAdded, New: formatCipherSuite(suite)})
+		}
+	}
+}
+
+// extensions compares the extension lists by
+// type, reporting added and removed extensions
+// along with extensions where the value changed
+func (diff *KeysDiff) extensions(old, new ExtensionList) {
+	for i := range old {
+		if match := findExtension(new, old[i].Type()); match == nil {
+			*diff = append(*diff, Change{Field: "extensions", Kind: ChangeRemoved, Old: formatExtension(old[i])})
+		} else if match.String() != old[i].String() {
+			*diff = append(*diff, Change{Field: "extensions", Kind: ChangeModified, Old: formatExtension(old[i]), New: formatExtension(match)})
+		}
+	}
+
+	for i := range new {
+		if findExtension(old, new[i].Type()) == nil {
+			*diff = append(*diff, Change{Field: "extensions", Kind: ChangeAdded, New: formatExtension(new[i])})
+		}
+	}
+}
+
+// findKeyShare returns the key share entry
+// for the group, if the list doesn't contain
+// the group nil is returned
+func findKeyShare(list KeyShareEntryList, group Group) *KeyShareEntry {
+	for i := range list {
+		if list[i].Group == group {
+			return &list[i]
+		}
+	}
+
+	return nil
+}
+
+// findExtension returns the extension of the
+// type, if the list doesn't contain the type
+// nil is returned
+func findExtension(list ExtensionList, extType ExtensionType) Extension {
+	for i := range list {
+		if list[i].Type() == extType {
+			return list[i]
+		}
+	}
+
+	return nil
+}
+
+// containsCipherSuite checks if the list
+// contains the cipher suite
+func containsCipherSuite(list []CipherSuite, suite CipherSuite) bool {
+	for i := range list {
+		if list[i] == suite {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatKeyShare returns the display form of
+// a key share entry in a change
+func formatKeyShare(entry KeyShareEntry) string {
+	group, _ := entry.Group.MarshalText()
+	return fmt.Sprintf("%s:%s", group, hex.EncodeToString(entry.KeyExchange))
+}
+
+// formatCipherSuite returns the display form
+// of a cipher suite in a change
+func formatCipherSuite(suite CipherSuite) string {
+	name, _ := suite.MarshalText()
+	return string(name)
+}
+
+// formatExtension returns the display form
+// of an extension in a change
+func formatExtension(ext Extension) string {
+	name, _ := ext.Type().MarshalText()
+	return fmt.Sprintf("%s:%s", name, ext)
+}
+
+// formatDiffTime returns the display form of
+// a validity time in a change
+func formatDiffTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,225 @@
+package esni
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDiskCacheMaxStale specifies how long past
+	// their expiry cached records continue to be served
+	// when the upstream resolver can't be reached
+	DefaultDiskCacheMaxStale = time.Hour
+)
+
+// DiskCacheResolver implements a Resolver that caches
+// the TXT and HTTPS records returned by an upstream
+// resolver in a single JSON file, allowing CLI tools
+// and other short-lived processes to reuse records
+// across invocations instead of querying DNS each time.
+//
+// Cached records are returned as is, so the records
+// are parsed and validated by the Fetcher exactly as
+// if they came from DNS. When the upstream resolver
+// fails, expired records are served for up to MaxStale
+// so lookups continue to work briefly while offline.
+//
+// The resolver is safe for concurrent use, processes
+// sharing the file overwrite each other's updates
+// but never observe a partially written file.
+type DiskCacheResolver struct {
+	// Resolver specifies the upstream resolver, if
+	// nil net.DefaultResolver is used through a
+	// NetResolver
+	Resolver Resolver
+
+	// Path specifies the path of the cache file
+	Path string
+
+	// DefaultTTL specifies how long records are
+	// cached when the upstream resolver doesn't
+	// report their TTL
+	DefaultTTL time.Duration
+
+	// MaxStale specifies how long past their expiry
+	// records are served when the upstream resolver
+	// fails, if zero expired records are never served
+	MaxStale time.Duration
+
+	mu sync.Mutex
+}
+
+// diskCacheEntry represents the records
+// cached for a single name and type
+type diskCacheEntry struct {
+	TXT     []string     `json:"txt,omitempty"`
+	HTTPS   []SVCBRecord `json:"https,omitempty"`
+	DNSSEC  DNSSECStatus `json:"dnssec"`
+	Expires time.Time    `json:"expires"`
+}
+
+// NewDiskCacheResolver returns a DiskCacheResolver
+// caching the records of the upstream resolver in
+// the file at path using DefaultCacheTTL and
+// DefaultDiskCacheMaxStale
+func NewDiskCacheResolver(path string, resolver Resolver) *DiskCacheResolver {
+	return &DiskCacheResolver{
+		Resolver:   resolver,
+		Path:       path,
+		DefaultTTL: DefaultCacheTTL,
+		MaxStale:   DefaultDiskCacheMaxStale,
+	}
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver *DiskCacheResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := resolver.LookupTXTDetailed(ctx, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name from the cache file, or from the upstream
+// resolver if they aren't cached or have expired
+func (resolver *DiskCacheResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	entry, info, err := resolver.lookup(ctx, "TXT "+cacheKey(name), func(upstream Resolver) (*diskCacheEntry, LookupInfo, error) {
+		txts, info, err := lookupTXTDetailed(ctx, upstream, name)
+		return &diskCacheEntry{TXT: txts}, info, err
+	})
+
+	if err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	return entry.TXT, info, nil
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver *DiskCacheResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := resolver.LookupHTTPSDetailed(ctx, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of
+// the name from the cache file, or from the upstream
+// resolver if they aren't cached or have expired
+func (resolver *DiskCacheResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	entry, info, err := resolver.lookup(ctx, "HTTPS "+cacheKey(name), func(upstream Resolver) (*diskCacheEntry, LookupInfo, error) {
+		records, info, err := lookupHTTPSDetailed(ctx, upstream, name)
+		return &diskCacheEntry{HTTPS: records}, info, err
+	})
+
+	if err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	return entry.HTTPS, info, nil
+}
+
+// lookup returns the cached entry for the key if it
+// hasn't expired, otherwise the records are looked up
+// from the upstream resolver and written to the cache
+// file, falling back to a stale entry if it fails
+func (resolver *DiskCacheResolver) lookup(ctx context.Context, key string, query func(Resolver) (*diskCacheEntry, LookupInfo, error)) (*diskCacheEntry, LookupInfo, error) {
+	now := time.Now()
+
+	resolver.mu.Lock()
+	entries, err := resolver.read()
+	resolver.mu.Unlock()
+
+	if err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	cached, ok := entries[key]
+	if ok && now.Before(cached.Expires) {
+		return &cached, LookupInfo{DNSSEC: cached.DNSSEC, TTL: cached.Expires.Sub(now)}, nil
+	}
+
+	entry, info, err := query(resolver.upstream())
+	if err != nil {
+		// Records that no longer exist aren't served
+		// stale as the answer is authoritative
+		if ok && !isNotFound(err) && now.Before(cached.Expires.Add(resolver.MaxStale)) {
+			return &cached, LookupInfo{DNSSEC: cached.DNSSEC}, nil
+		}
+
+		return nil, LookupInfo{}, err
+	}
+
+	ttl := info.TTL
+	if ttl <= 0 {
+		ttl = resolver.DefaultTTL
+	}
+
+	entry.DNSSEC = info.DNSSEC
+	entry.Expires = now.Add(ttl)
+
+	if err := resolver.store(key, entry, now); err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	return entry, info, nil
+}
+
+// store writes the entry to the cache file, dropping
+// the entries that can no longer be served
+func (resolver *DiskCacheResolver) store(key string, entry *diskCacheEntry, now time.Time) error {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	entries, err := resolver.read()
+	if err != nil {
+		return err
+	}
+
+	for existing := range entries {
+		if !now.Before(entries[existing].Expires.Add(resolver.MaxStale)) {
+			delete(entries, existing)
+		}
+	}
+
+	entries[key] = *entry
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "encode disk cache")
+	}
+
+	return writeFileAtomic(resolver.Path, data)
+}
+
+// read returns the entries in the cache file, a
+// missing or corrupt file holds no entries so the
+// cache recovers by overwriting it
+func (resolver *DiskCacheResolver) read() (map[string]diskCacheEntry, error) {
+	entries := make(map[string]diskCacheEntry)
+
+	data, err := ioutil.ReadFile(resolver.Path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "read disk cache")
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return make(map[string]diskCacheEntry), nil
+	}
+
+	return entries, nil
+}
+
+// upstream returns the resolver
+// records are looked up from
+func (resolver *DiskCacheResolver) upstream() Resolver {
+	if resolver.Resolver == nil {
+		return NetResolver{}
+	}
+
+	return resolver.Resolver
+}
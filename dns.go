@@ -0,0 +1,159 @@
+package esni
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// dnsExchanger is implemented by the resolvers that
+// send DNS messages over their own transport
+type dnsExchanger interface {
+	Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error)
+
+	// trustsAD returns if the channel to the server
+	// is authenticated, so the AD bit of responses
+	// can't have been set by an attacker on the path
+	trustsAD() bool
+}
+
+// newDNSQuery returns a recursive query for the
+// records of the type at the name, advertising
+// a large EDNS0 buffer size and requesting the
+// DNSSEC validation result in the AD bit
+func newDNSQuery(name string, qtype uint16) *dns.Msg {
+	query := new(dns.Msg)
+	query.SetQuestion(dns.Fqdn(name), qtype)
+	query.SetEdns0(4096, true)
+	query.AuthenticatedData = true
+
+	return query
+}
+
+// exchangeDNS sends the query for the records of the
+// type at the name and returns the answer records of
+// that type along with their lowest TTL and the DNSSEC
+// status reported by the AD bit of the response, an
+// AD bit received over a channel that isn't trusted
+// is reported as DNSSECIndeterminate. A *net.DNSError is returned for a failed lookup in the
+// same manner as net.Resolver
+func exchangeDNS(ctx context.Context, exchanger dnsExchanger, name string, qtype uint16) ([]dns.RR, LookupInfo, error) {
+	response, err := exchanger.Exchange(ctx, newDNSQuery(name, qtype))
+	if err != nil {
+		return nil, LookupInfo{}, err
+	}
+
+	switch response.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, LookupInfo{}, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	default:
+		return nil, LookupInfo{}, &net.DNSError{Err: "server responded with " + dns.RcodeToString[response.Rcode], Name: name, IsTemporary: response.Rcode == dns.RcodeServerFailure}
+	}
+
+	info := LookupInfo{DNSSEC: DNSSECInsecure}
+	if response.AuthenticatedData {
+		info.DNSSEC = DNSSECIndeterminate
+		if exchanger.trustsAD() {
+			info.DNSSEC = DNSSECSecure
+		}
+	}
+
+	var answers []dns.RR
+	for _, rr := range response.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+
+		if ttl := time.Duration(rr.Header().Ttl) * time.Second; len(answers) == 0 || ttl < info.TTL {
+			info.TTL = ttl
+		}
+
+		answers = append(answers, rr)
+	}
+
+	if len(answers) == 0 {
+		return nil, LookupInfo{}, &net.DNSError{Err: "no answer from DNS server", Name: name, IsNotFound: true}
+	}
+
+	return answers, info, nil
+}
+
+// lookupTXT returns the TXT records of the name
+// using the exchanger, each record as a single
+// string with its character-strings joined
+func lookupTXT(ctx context.Context, exchanger dnsExchanger, name string) ([]string, LookupInfo, error) {
+	answers, info, err := exchangeDNS(ctx, exchanger, name, dns.TypeTXT)
+	if err != nil {
+		return nil, info, err
+	}
+
+	txts := make([]string, 0, len(answers))
+	for _, rr := range answers {
+		txts = append(txts, strings.Join(rr.(*dns.TXT).Txt, ""))
+	}
+
+	return txts, info, nil
+}
+
+// lookupHTTPS returns the HTTPS records
+// of the name using the exchanger
+func lookupHTTPS(ctx context.Context, exchanger dnsExchanger, name string) ([]SVCBRecord, LookupInfo, error) {
+	answers, info, err := exchangeDNS(ctx, exchanger, name, dns.TypeHTTPS)
+	if err != nil {
+		return nil, info, err
+	}
+
+	records := make([]SVCBRecord, 0, len(answers))
+	for _, rr := range answers {
+		record, err := svcbRecordFromRR(rr)
+		if err != nil {
+			return nil, info, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, info, nil
+}
+
+// svcbRecordFromRR converts the SVCB or HTTPS
+// resource record into an SVCBRecord by parsing
+// its wire format RDATA
+func svcbRecordFromRR(rr dns.RR) (SVCBRecord, error) {
+	buf := make([]byte, dns.Len(rr))
+
+	end, err := dns.PackRR(rr, buf, 0, nil, false)
+	if err != nil {
+		return SVCBRecord{}, errors.Wrap(err, "pack svcb record")
+	}
+
+	var record SVCBRecord
+	if err := record.UnmarshalBinary(buf[end-int(rr.Header().Rdlength) : end]); err != nil {
+		return SVCBRecord{}, errors.Wrap(err, "unmarshal svcb record")
+	}
+
+	return record, nil
+}
+
+// checkDNSResponse checks the response
+// answers the query it was sent for
+func checkDNSResponse(query, response *dns.Msg) error {
+	if response.Id != query.Id {
+		return errors.New("dns response id does not match query")
+	}
+
+	if !response.Response {
+		return errors.New("dns message is not a response")
+	}
+
+	if len(response.Question) != 1 || !strings.EqualFold(response.Question[0].Name, query.Question[0].Name) || response.Question[0].Qtype != query.Question[0].Qtype {
+		return errors.New("dns response question does not match query")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package esni
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// fakeExchanger answers every query with a
+// single TXT record and the AD bit set
+type fakeExchanger struct {
+	trusted bool
+}
+
+func (exchanger fakeExchanger) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	response := new(dns.Msg)
+	response.SetReply(query)
+	response.AuthenticatedData = true
+	response.Answer = []dns.RR{&dns.TXT{
+		Hdr: dns.RR_Header{Name: query.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+		Txt: []string{"record"},
+	}}
+
+	return response, nil
+}
+
+func (exchanger fakeExchanger) trustsAD() bool {
+	return exchanger.trusted
+}
+
+func TestExchangeDNSTrustsAD(t *testing.T) {
+	tests := []struct {
+		trusted bool
+		want    DNSSECStatus
+	}{
+		{trusted: true, want: DNSSECSecure},
+		{trusted: false, want: DNSSECIndeterminate},
+	}
+
+	for _, test := range tests {
+		_, info, err := lookupTXT(context.Background(), fakeExchanger{trusted: test.trusted}, "_esni.example.com")
+		if err != nil {
+			t.Fatalf("lookupTXT() error = %s", err)
+		}
+
+		if info.DNSSEC != test.want {
+			t.Errorf("lookupTXT() over trusted=%t channel = %s, want %s", test.trusted, info.DNSSEC, test.want)
+		}
+	}
+}
+
+func TestResolversTrustAD(t *testing.T) {
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, nil
+	}
+
+	tests := []struct {
+		name      string
+		exchanger dnsExchanger
+		want      bool
+	}{
+		{name: "loopback ipv4", exchanger: &DNSClientResolver{Servers: []string{"127.0.0.53:53"}}, want: true},
+		{name: "loopback ipv6", exchanger: &DNSClientResolver{Servers: []string{"[::1]:53"}}, want: true},
+		{name: "localhost", exchanger: &DNSClientResolver{Servers: []string{"localhost:53"}}, want: true},
+		{name: "remote", exchanger: &DNSClientResolver{Servers: []string{"127.0.0.1:53", "192.0.2.1:53"}}},
+		{name: "remote trusted", exchanger: &DNSClientResolver{Servers: []string{"192.0.2.1:53"}, TrustAD: true}, want: true},
+		{name: "custom dial", exchanger: &DNSClientResolver{Servers: []string{"127.0.0.1:53"}, Dial: dial}},
+		{name: "tls", exchanger: &DNSClientResolver{Client: &dns.Client{Net: "tcp-tls"}, Servers: []string{"192.0.2.1:853"}}, want: true},
+		{
+			name:      "tls without verification",
+			exchanger: &DNSClientResolver{Client: &dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{InsecureSkipVerify: true}}, Servers: []string{"192.0.2.1:853"}},
+		},
+		{name: "doh", exchanger: NewDoHResolver(), want: true},
+		{name: "doh loopback", exchanger: NewDoHResolver("http://127.0.0.1:8053/dns-query"), want: true},
+		{name: "doh plain http", exchanger: NewDoHResolver("https://1.1.1.1/dns-query", "http://192.0.2.1/dns-query")},
+		{name: "dot", exchanger: &DoTResolver{Servers: []string{"192.0.2.1"}}, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.exchanger.trustsAD(); got != test.want {
+				t.Errorf("trustsAD() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+// DNSSECStatus represents the DNSSEC validation
+// result of the records returned by a lookup, as
+// defined in section 5 of RFC 4035
+type DNSSECStatus uint8
+
+const (
+	// DNSSECIndeterminate represents records whose
+	// validation result couldn't be determined, such
+	// as those looked up with a resolver that doesn't
+	// report it
+	DNSSECIndeterminate DNSSECStatus = iota
+
+	// DNSSECInsecure represents records the
+	// resolver didn't authenticate
+	DNSSECInsecure
+
+	// DNSSECSecure represents records the resolver
+	// authenticated, signalled by the AD bit of
+	// its response received over an authenticated
+	// channel
+	DNSSECSecure
+)
+
+// DNSSECStatus_name provides a mapping between
+// a DNSSECStatus and its string representation
+var DNSSECStatus_name = map[DNSSECStatus]string{
+	DNSSECIndeterminate: "indeterminate",
+	DNSSECInsecure:      "insecure",
+	DNSSECSecure:        "secure",
+}
+
+// String attempts to return the string
+// representation of the DNSSECStatus based
+// on those specified in DNSSECStatus_name,
+// if no match is found "UNKNOWN" is returned
+func (status DNSSECStatus) String() string {
+	if name, ok := DNSSECStatus_name[status]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+var (
+	// ErrNotAuthenticated is returned when DNSSEC is
+	// required but the records of a lookup weren't
+	// authenticated by the resolver
+	ErrNotAuthenticated = errors.New("dns records are not authenticated")
+)
+
+// leastSecure returns the weakest of the two statuses,
+// used when a result depends on several lookups
+func leastSecure(a, b DNSSECStatus) DNSSECStatus {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// checkDNSSEC returns ErrNotAuthenticated if the
+// fetcher requires DNSSEC and the status isn't secure
+func (fetcher *Fetcher) checkDNSSEC(name string, status DNSSECStatus) error {
+	if fetcher.RequireDNSSEC && status != DNSSECSecure {
+		return errors.Wrapf(ErrNotAuthenticated, "%s is %s", name, status)
+	}
+
+	return nil
+}
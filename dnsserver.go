@@ -0,0 +1,163 @@
+package esni
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// KeyManager is implemented by the types holding
+// the Keys records a server currently publishes,
+// *ServerKeySet satisfies it
+type KeyManager interface {
+	// Keys returns the records
+	// currently published
+	Keys() []*Keys
+}
+
+// DNSHandler is a miekg/dns handler that answers
+// queries for the _esni TXT records of a zone, and
+// optionally its HTTPS record, from the records held
+// by a KeyManager, so a server can publish its keys
+// directly from the process that generates them.
+//
+// Only the records that are valid as of the evaluation
+// context of the options are served, with the TTL
+// derived by ZoneRecords. Queries for other names are
+// passed to the next handler if set, otherwise they
+// are answered with NXDOMAIN within the zone and
+// REFUSED outside it.
+type DNSHandler struct {
+	// Zone specifies the domain the
+	// records are published for
+	Zone string
+
+	// Keys specifies the source
+	// of the published records
+	Keys KeyManager
+
+	// Options specifies the options used to derive
+	// the TTL and the HTTPS record of the zone, no
+	// HTTPS record is served if Options.HTTPS is nil
+	Options ZoneOptions
+
+	// Next optionally specifies the handler
+	// for queries of other names
+	Next dns.Handler
+}
+
+// NewDNSHandler returns a new DNSHandler publishing
+// the records of the key manager for the zone
+func NewDNSHandler(zone string, keys KeyManager) *DNSHandler {
+	return &DNSHandler{Zone: zone, Keys: keys}
+}
+
+// ServeDNS answers the query
+func (handler *DNSHandler) ServeDNS(w dns.ResponseWriter, query *dns.Msg) {
+	if len(query.Question) != 1 {
+		handler.reply(w, query, dns.RcodeFormatError, nil)
+		return
+	}
+
+	question := query.Question[0]
+
+	switch {
+	case strings.EqualFold(question.Name, handler.esniName()):
+		handler.answer(w, query, question, dns.TypeTXT)
+
+	case strings.EqualFold(question.Name, handler.zoneName()) && handler.Options.HTTPS != nil:
+		handler.answer(w, query, question, dns.TypeHTTPS)
+
+	case handler.Next != nil:
+		handler.Next.ServeDNS(w, query)
+
+	case dns.IsSubDomain(handler.zoneName(), question.Name):
+		handler.reply(w, query, dns.RcodeNameError, nil)
+
+	default:
+		handler.reply(w, query, dns.RcodeRefused, nil)
+	}
+}
+
+// Answers returns if the handler publishes
+// records at the name, queries for other names
+// are passed to the next handler
+func (handler *DNSHandler) Answers(name string) bool {
+	return strings.EqualFold(dns.Fqdn(name), handler.esniName()) ||
+		(handler.Options.HTTPS != nil && strings.EqualFold(dns.Fqdn(name), handler.zoneName()))
+}
+
+// zoneName returns the fully
+// qualified name of the zone
+func (handler *DNSHandler) zoneName() string {
+	return dns.Fqdn(strings.TrimSuffix(handler.Zone, "."))
+}
+
+// esniName returns the fully qualified
+// name of the _esni records of the zone
+func (handler *DNSHandler) esniName() string {
+	return dns.Fqdn(ESNIQueryName(handler.Zone))
+}
+
+// answer replies to the query of a name the handler
+// publishes records of the type at, other types
+// are answered with no records
+func (handler *DNSHandler) answer(w dns.ResponseWriter, query *dns.Msg, question dns.Question, rrType uint16) {
+	if question.Qclass != dns.ClassINET || (question.Qtype != rrType && question.Qtype != dns.TypeANY) {
+		handler.reply(w, query, dns.RcodeSuccess, nil)
+		return
+	}
+
+	var keys []*Keys
+	for _, record := range handler.Keys.Keys() {
+		if record.Valid(handler.Options.EvalContext) {
+			keys = append(keys, record)
+		}
+	}
+
+	if len(keys) == 0 {
+		handler.reply(w, query, dns.RcodeSuccess, nil)
+		return
+	}
+
+	ttl, err := zoneTTL(keys, handler.Options)
+	if err != nil {
+		handler.reply(w, query, dns.RcodeServerFailure, nil)
+		return
+	}
+
+	var answers []dns.RR
+	if rrType == dns.TypeTXT {
+		answers, err = zoneTXTRRs(handler.Zone, keys, ttl)
+	} else {
+		var rr dns.RR
+		rr, err = zoneHTTPSRR(handler.Zone, keys, ttl, handler.Options)
+		answers = []dns.RR{rr}
+	}
+
+	if err != nil {
+		handler.reply(w, query, dns.RcodeServerFailure, nil)
+		return
+	}
+
+	for _, rr := range answers {
+		rr.Header().Name = question.Name
+	}
+
+	handler.reply(w, query, dns.RcodeSuccess, answers)
+}
+
+// reply writes an authoritative response
+// to the query with the answers
+func (handler *DNSHandler) reply(w dns.ResponseWriter, query *dns.Msg, rcode int, answers []dns.RR) {
+	response := new(dns.Msg)
+	response.SetRcode(query, rcode)
+	response.Authoritative = rcode != dns.RcodeRefused
+	response.Answer = answers
+
+	if opt := query.IsEdns0(); opt != nil {
+		response.SetEdns0(opt.UDPSize(), false)
+	}
+
+	w.WriteMsg(response)
+}
@@ -0,0 +1,197 @@
+package esni
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DoHEndpointCloudflare specifies the
+	// DNS-over-HTTPS endpoint of Cloudflare
+	DoHEndpointCloudflare = "https://1.1.1.1/dns-query"
+
+	// DoHEndpointGoogle specifies the
+	// DNS-over-HTTPS endpoint of Google
+	DoHEndpointGoogle = "https://dns.google/dns-query"
+
+	// dohMediaType specifies the media type
+	// of a DNS message carried over HTTPS
+	dohMediaType = "application/dns-message"
+
+	// dohMaxResponseSize specifies the largest
+	// DNS message read from a response
+	dohMaxResponseSize = 65535
+)
+
+// DoHResolver is a Resolver that sends queries using
+// the DNS-over-HTTPS wire format of RFC 8484, keeping
+// the names looked up for ESNI keys hidden from the
+// local network.
+//
+// The endpoints are tried in order until one of
+// them responds.
+type DoHResolver struct {
+	// Endpoints specifies the URLs of the
+	// DNS-over-HTTPS services to query
+	Endpoints []string
+
+	// Client specifies the HTTP client used to
+	// send queries, if nil http.DefaultClient
+	// is used
+	Client *http.Client
+
+	// UseGET specifies if queries are sent as the
+	// dns parameter of a GET request, which is
+	// more cache friendly, rather than the body
+	// of a POST request
+	UseGET bool
+}
+
+// NewDoHResolver returns a new DoHResolver querying
+// the endpoints, if none are provided the endpoints
+// of Cloudflare and Google are used
+func NewDoHResolver(endpoints ...string) *DoHResolver {
+	if len(endpoints) == 0 {
+		endpoints = []string{DoHEndpointCloudflare, DoHEndpointGoogle}
+	}
+
+	return &DoHResolver{Endpoints: endpoints}
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver *DoHResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := lookupTXT(ctx, resolver, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name and the details reported by the server
+func (resolver *DoHResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	return lookupTXT(ctx, resolver, name)
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver *DoHResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := lookupHTTPS(ctx, resolver, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of the
+// name and the details reported by the server
+func (resolver *DoHResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	return lookupHTTPS(ctx, resolver, name)
+}
+
+// Exchange sends the query to each endpoint in turn
+// and returns the first response received, the ID of
+// the query is set to zero as recommended by RFC 8484
+func (resolver *DoHResolver) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	if len(resolver.Endpoints) == 0 {
+		return nil, errors.New("no dns-over-https endpoints configured")
+	}
+
+	query = query.Copy()
+	query.Id = 0
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, errors.Wrap(err, "pack dns query")
+	}
+
+	var lastErr error
+	for _, endpoint := range resolver.Endpoints {
+		response, err := resolver.exchange(ctx, endpoint, packed)
+		if err == nil {
+			if err = checkDNSResponse(query, response); err == nil {
+				return response, nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		lastErr = errors.Wrapf(err, "query %s", endpoint)
+	}
+
+	return nil, lastErr
+}
+
+// trustsAD returns if every endpoint is
+// reached over HTTPS or on a loopback
+// address
+func (resolver *DoHResolver) trustsAD() bool {
+	for _, endpoint := range resolver.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || !strings.EqualFold(u.Scheme, "https") && !isLoopbackHost(u.Hostname()) {
+			return false
+		}
+	}
+
+	return len(resolver.Endpoints) > 0
+}
+
+// exchange sends the packed query to
+// the endpoint and parses the response
+func (resolver *DoHResolver) exchange(ctx context.Context, endpoint string, packed []byte) (*dns.Msg, error) {
+	var (
+		request *http.Request
+		err     error
+	)
+
+	if resolver.UseGET {
+		request, err = http.NewRequest(http.MethodGet, endpoint+"?dns="+base64.RawURLEncoding.EncodeToString(packed), nil)
+	} else {
+		request, err = http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(packed))
+		if err == nil {
+			request.Header.Set("Content-Type", dohMediaType)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", dohMediaType)
+
+	client := resolver.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", response.Status)
+	}
+
+	if mediaType := response.Header.Get("Content-Type"); mediaType != dohMediaType {
+		return nil, errors.Errorf("unexpected content type %q", mediaType)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, dohMaxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "read response")
+	}
+
+	message := new(dns.Msg)
+	if err := message.Unpack(body); err != nil {
+		return nil, errors.Wrap(err, "unpack dns response")
+	}
+
+	return message, nil
+}
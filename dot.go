@@ -0,0 +1,316 @@
+package esni
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// dotDefaultPort specifies the port of
+	// DNS-over-TLS when a server has none
+	dotDefaultPort = "853"
+
+	// dotDefaultIdleTimeout specifies how long an
+	// idle connection is kept open for reuse
+	dotDefaultIdleTimeout = 30 * time.Second
+)
+
+var (
+	// ErrPinMismatch is returned when none of the
+	// public keys presented by a DNS-over-TLS server
+	// match the pinned keys
+	ErrPinMismatch = errors.New("server public key does not match pinned keys")
+)
+
+// DoTResolver is a Resolver that sends queries using
+// DNS-over-TLS as specified by RFC 7858, connections
+// are kept open between queries and reused.
+//
+// The servers are tried in order until one of
+// them responds, it is safe for concurrent use.
+type DoTResolver struct {
+	// Servers specifies the addresses of the
+	// DNS-over-TLS servers, port 853 is used
+	// if an address has no port
+	Servers []string
+
+	// ServerName specifies the name verified against
+	// the certificate of the servers, if empty the
+	// host of each server address is used
+	ServerName string
+
+	// RootCAs specifies the trust anchors used to
+	// verify the certificate of the servers, if nil
+	// the system roots are used
+	RootCAs *x509.CertPool
+
+	// PinnedKeys specifies the SHA-256 digests of the
+	// SubjectPublicKeyInfo the servers are expected to
+	// present, if set at least one certificate of the
+	// chain must match a pin
+	PinnedKeys [][sha256.Size]byte
+
+	// Timeout specifies the timeout of dialing a server
+	// and of each exchange when the context has no
+	// deadline, if zero no timeout is applied
+	Timeout time.Duration
+
+	// IdleTimeout specifies how long an idle connection
+	// is kept for reuse, if zero 30 seconds is used
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle map[string]*dotConn
+}
+
+// dotConn represents an idle
+// connection to a server
+type dotConn struct {
+	conn     *dns.Conn
+	lastUsed time.Time
+}
+
+// NewDoTResolver returns a new DoTResolver querying
+// the servers, if none are provided the resolvers of
+// Cloudflare and Google are used
+func NewDoTResolver(servers ...string) *DoTResolver {
+	if len(servers) == 0 {
+		servers = []string{"1.1.1.1:853", "8.8.8.8:853"}
+	}
+
+	return &DoTResolver{Servers: servers}
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver *DoTResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := lookupTXT(ctx, resolver, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name and the details reported by the server
+func (resolver *DoTResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	return lookupTXT(ctx, resolver, name)
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver *DoTResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := lookupHTTPS(ctx, resolver, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of the
+// name and the details reported by the server
+func (resolver *DoTResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	return lookupHTTPS(ctx, resolver, name)
+}
+
+// trustsAD returns true as the certificate
+// of every server is verified before any
+// queries are sent to it
+func (resolver *DoTResolver) trustsAD() bool {
+	return true
+}
+
+// Exchange sends the query to each server in turn
+// and returns the first response received
+func (resolver *DoTResolver) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	if len(resolver.Servers) == 0 {
+		return nil, errors.New("no dns-over-tls servers configured")
+	}
+
+	var lastErr error
+	for _, server := range resolver.Servers {
+		address := server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			address = net.JoinHostPort(server, dotDefaultPort)
+		}
+
+		response, err := resolver.exchange(ctx, address, query)
+		if err == nil {
+			return response, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		lastErr = errors.Wrapf(err, "query %s", address)
+	}
+
+	return nil, lastErr
+}
+
+// Close closes the idle connections
+// held by the resolver
+func (resolver *DoTResolver) Close() error {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	for address, idle := range resolver.idle {
+		idle.conn.Close()
+		delete(resolver.idle, address)
+	}
+
+	return nil
+}
+
+// exchange sends the query over an idle connection
+// to the server, if the connection has been closed
+// by the server the query is retried over a new one
+func (resolver *DoTResolver) exchange(ctx context.Context, address string, query *dns.Msg) (*dns.Msg, error) {
+	if conn := resolver.takeIdle(address); conn != nil {
+		if response, err := resolver.roundTrip(ctx, conn, query); err == nil {
+			resolver.putIdle(address, conn)
+			return response, nil
+		}
+
+		conn.Close()
+	}
+
+	conn, err := resolver.dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := resolver.roundTrip(ctx, conn, query)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resolver.putIdle(address, conn)
+	return response, nil
+}
+
+// roundTrip writes the query to the connection
+// and reads the response to it
+func (resolver *DoTResolver) roundTrip(ctx context.Context, conn *dns.Conn, query *dns.Msg) (*dns.Msg, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && resolver.Timeout > 0 {
+		deadline = time.Now().Add(resolver.Timeout)
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := conn.WriteMsg(query); err != nil {
+		return nil, errors.Wrap(err, "write dns query")
+	}
+
+	response, err := conn.ReadMsg()
+	if err != nil {
+		return nil, errors.Wrap(err, "read dns response")
+	}
+
+	if err := checkDNSResponse(query, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// dial opens a new TLS connection to the server
+// verifying it against the trust anchors and pins
+func (resolver *DoTResolver) dial(ctx context.Context, address string) (*dns.Conn, error) {
+	serverName := resolver.ServerName
+	if len(serverName) == 0 {
+		serverName, _, _ = net.SplitHostPort(address)
+	}
+
+	config := &tls.Config{
+		ServerName: serverName,
+		RootCAs:    resolver.RootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(resolver.PinnedKeys) > 0 {
+		config.VerifyConnection = resolver.verifyPins
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: resolver.Timeout}, Config: config}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+
+	return &dns.Conn{Conn: conn}, nil
+}
+
+// verifyPins checks a certificate presented by
+// the server matches one of the pinned keys
+func (resolver *DoTResolver) verifyPins(state tls.ConnectionState) error {
+	for _, cert := range state.PeerCertificates {
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+		for _, pin := range resolver.PinnedKeys {
+			if subtle.ConstantTimeCompare(digest[:], pin[:]) == 1 {
+				return nil
+			}
+		}
+	}
+
+	return ErrPinMismatch
+}
+
+// takeIdle removes and returns the idle connection
+// to the server, nil is returned if there is none
+// or it has been idle for too long
+func (resolver *DoTResolver) takeIdle(address string) *dns.Conn {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	idle, ok := resolver.idle[address]
+	if !ok {
+		return nil
+	}
+
+	delete(resolver.idle, address)
+
+	if time.Since(idle.lastUsed) > resolver.idleTimeout() {
+		idle.conn.Close()
+		return nil
+	}
+
+	return idle.conn
+}
+
+// putIdle stores the connection for reuse, if a
+// connection to the server is already stored the
+// connection is closed instead
+func (resolver *DoTResolver) putIdle(address string, conn *dns.Conn) {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	if _, exists := resolver.idle[address]; exists {
+		conn.Close()
+		return
+	}
+
+	if resolver.idle == nil {
+		resolver.idle = make(map[string]*dotConn)
+	}
+
+	resolver.idle[address] = &dotConn{conn: conn, lastUsed: time.Now()}
+}
+
+// idleTimeout returns how long an idle
+// connection is kept for reuse
+func (resolver *DoTResolver) idleTimeout() time.Duration {
+	if resolver.IdleTimeout > 0 {
+		return resolver.IdleTimeout
+	}
+
+	return dotDefaultIdleTimeout
+}
@@ -0,0 +1,108 @@
+package esni
+
+import (
+	"crypto/ecdh"
+
+	"github.com/pkg/errors"
+)
+
+// ECDHCurve attempts to return the crypto/ecdh
+// curve of the Group, if the group isn't an
+// elliptic curve supported by crypto/ecdh nil
+// is returned
+func (g Group) ECDHCurve() ecdh.Curve {
+	return ecdhCurves[g]
+}
+
+// GroupForCurve returns the Group of the crypto/ecdh
+// curve, ok is false if the curve has no group
+func GroupForCurve(curve ecdh.Curve) (group Group, ok bool) {
+	for group, known := range ecdhCurves {
+		if known == curve {
+			return group, true
+		}
+	}
+
+	return 0, false
+}
+
+// NewKeyShareEntry returns the key share entry
+// carrying the crypto/ecdh public key
+func NewKeyShareEntry(publicKey *ecdh.PublicKey) (KeyShareEntry, error) {
+	group, ok := GroupForCurve(publicKey.Curve())
+	if !ok {
+		return KeyShareEntry{}, errors.Wrap(ErrUnsupportedGroup, "no group for curve")
+	}
+
+	return KeyShareEntry{Group: group, KeyExchange: publicKey.Bytes()}, nil
+}
+
+// ECDHPublicKey parses the key exchange value of
+// the entry as a crypto/ecdh public key of the
+// curve of its group
+func (entry KeyShareEntry) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	curve := entry.Group.ECDHCurve()
+	if curve == nil {
+		return nil, errors.Wrapf(ErrUnsupportedGroup, "no curve for group %s", entry.Group)
+	}
+
+	return curve.NewPublicKey(entry.KeyExchange)
+}
+
+// NewPrivateKeyEntry returns the private key entry
+// for the crypto/ecdh private key, pairing it with
+// the key share entry of its public key
+func NewPrivateKeyEntry(privateKey *ecdh.PrivateKey) (PrivateKeyEntry, error) {
+	entry, err := NewKeyShareEntry(privateKey.PublicKey())
+	if err != nil {
+		return PrivateKeyEntry{}, err
+	}
+
+	return PrivateKeyEntry{KeyShareEntry: entry, PrivateKey: privateKey.Bytes()}, nil
+}
+
+// NewPrivateKeysFromECDH returns the PrivateKeys
+// holding the crypto/ecdh private keys, each key
+// must belong to a different group
+func NewPrivateKeysFromECDH(privateKeys ...*ecdh.PrivateKey) (*PrivateKeys, error) {
+	keys := new(PrivateKeys)
+
+	for _, privateKey := range privateKeys {
+		entry, err := NewPrivateKeyEntry(privateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, exists := keys.Lookup(entry.Group); exists {
+			return nil, errors.Errorf("duplicate private key for group %s", entry.Group)
+		}
+
+		keys.Entries = append(keys.Entries, entry)
+	}
+
+	return keys, nil
+}
+
+// ECDHPrivateKey parses the private key of the
+// entry as a crypto/ecdh private key of the
+// curve of its group
+func (entry PrivateKeyEntry) ECDHPrivateKey() (*ecdh.PrivateKey, error) {
+	curve := entry.Group.ECDHCurve()
+	if curve == nil {
+		return nil, errors.Wrapf(ErrUnsupportedGroup, "no curve for group %s", entry.Group)
+	}
+
+	return curve.NewPrivateKey(entry.PrivateKey)
+}
+
+// DeriveSharedSecretECDH performs the key exchange
+// using the crypto/ecdh private key and the key
+// exchange value of the peer's public key
+func DeriveSharedSecretECDH(privateKey *ecdh.PrivateKey, peerPublic []byte) ([]byte, error) {
+	public, err := privateKey.Curve().NewPublicKey(peerPublic)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid peer public key")
+	}
+
+	return privateKey.ECDH(public)
+}
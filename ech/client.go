@@ -0,0 +1,276 @@
+package ech
+
+import (
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// infoPrefix specifies the prefix of the HPKE
+	// info parameter, which is followed by the
+	// binary ECHConfig
+	infoPrefix = "tls ech\x00"
+
+	// aeadTagSize specifies the size of the
+	// authentication tag appended by each of
+	// the HPKE AEAD algorithms
+	aeadTagSize = 16
+)
+
+var (
+	// ErrNoSupportedCipherSuite is returned when
+	// none of the HPKE cipher suites of an ECHConfig
+	// are supported
+	ErrNoSupportedCipherSuite = errors.New("no supported cipher suite")
+)
+
+// Client holds the state of a single ECH handshake
+// attempt, it encrypts the ClientHelloInner to the
+// ECHConfig selected for the connection
+type Client struct {
+	config *esni.ECHConfig
+	suite  esni.HpkeSymmetricCipherSuite
+	enc    []byte
+	sender SealContext
+	inner  *ClientHello
+
+	firstInner *ClientHello
+	hrr        []byte
+
+	outerExtensions []uint16
+	rawName         bool
+}
+
+// NewClient sets up the HPKE context used to encrypt
+// the ClientHelloInner to the ECHConfig, the first
+// cipher suite of the config that is supported by
+// DefaultHPKEProvider is selected
+func NewClient(config *esni.ECHConfig) (*Client, error) {
+	return NewClientWithProvider(config, DefaultHPKEProvider)
+}
+
+// NewClientWithProvider sets up the HPKE context used
+// to encrypt the ClientHelloInner to the ECHConfig using
+// the provided HPKE implementation, the first cipher suite
+// of the config that is supported by the provider is
+// selected
+func NewClientWithProvider(config *esni.ECHConfig, provider HPKEProvider) (*Client, error) {
+	rawConfig, err := config.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal config")
+	}
+
+	client := &Client{config: config}
+
+	for _, suite := range config.CipherSuites {
+		hpkeSuite := HPKESuite{KEM: config.KemID, KDF: suite.KDF, AEAD: suite.AEAD}
+		if !provider.Supports(hpkeSuite) {
+			continue
+		}
+
+		client.suite = suite
+		client.enc, client.sender, err = provider.SetupSender(hpkeSuite, config.PublicKey, append([]byte(infoPrefix), rawConfig...))
+		if err != nil {
+			return nil, errors.Wrap(err, "setup hpke context")
+		}
+
+		return client, nil
+	}
+
+	return nil, ErrNoSupportedCipherSuite
+}
+
+// Config returns the ECHConfig the
+// client is encrypting to
+func (client *Client) Config() *esni.ECHConfig {
+	return client.config
+}
+
+// CipherSuite returns the HPKE cipher suite
+// selected from the ECHConfig
+func (client *Client) CipherSuite() esni.HpkeSymmetricCipherSuite {
+	return client.suite
+}
+
+// Inner returns the ClientHelloInner produced
+// by the last call to Seal, nil is returned if
+// Seal hasn't been called
+func (client *Client) Inner() *ClientHello {
+	return client.inner
+}
+
+// CompressOuterExtensions sets the types of the
+// extensions that are shared between the inner and
+// outer hellos, these are sent once in the outer hello
+// and referenced from the encrypted inner hello using
+// the ech_outer_extensions extension to reduce the
+// size of the payload
+func (client *Client) CompressOuterExtensions(types ...uint16) *Client {
+	client.outerExtensions = append([]uint16(nil), types...)
+	return client
+}
+
+// DisableIDNA stops the server name of the inner
+// hello being converted to its ASCII form by Seal,
+// the name is encrypted exactly as set
+func (client *Client) DisableIDNA() *Client {
+	client.rawName = true
+	return client
+}
+
+// Seal constructs the ClientHelloInner and the
+// ClientHelloOuter from the provided hellos, neither
+// of which are modified.
+//
+// After a HelloRetryRequest has been passed to the
+// client, Seal produces the second ClientHello using
+// the same HPKE context as the first.
+//
+// The inner hello should carry the true server name
+// and the extensions of the connection, the outer hello
+// the extensions visible to the network. The server name
+// of the outer hello is replaced by the public name of
+// the ECHConfig and the encrypted inner hello is carried
+// in its encrypted_client_hello extension.
+//
+// An internationalized server name of the inner hello
+// is converted to its ASCII form before it is sealed,
+// unless IDNA has been disabled for the client.
+func (client *Client) Seal(inner, outer *ClientHello) (*ClientHello, error) {
+	inner = inner.Clone()
+	inner.SessionID = append([]byte(nil), outer.SessionID...)
+
+	if !client.rawName {
+		if err := toASCIIServerName(inner); err != nil {
+			return nil, err
+		}
+	}
+
+	innerExt, _ := ECHClientHello{Type: ClientHelloTypeInner}.MarshalBinary()
+	inner.SetExtension(ExtensionEncryptedClientHello, innerExt)
+
+	inner, compressed, referenced, err := compressExtensions(inner, client.outerExtensions)
+	if err != nil {
+		return nil, errors.Wrap(err, "compress outer extensions")
+	}
+
+	encoded, err := client.encodeInner(inner, compressed)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode client hello inner")
+	}
+
+	outer = outer.Clone()
+	outer.SetServerName(client.config.PublicName)
+
+	// The compressed extensions must appear in the
+	// outer hello in the order they are referenced
+	for _, ext := range referenced {
+		outer.RemoveExtension(ext.Type)
+		outer.Extensions = append(outer.Extensions, ext)
+	}
+
+	ext := ECHClientHello{
+		Type:        ClientHelloTypeOuter,
+		CipherSuite: client.suite,
+		ConfigID:    client.config.ConfigID,
+		Enc:         client.enc,
+		Payload:     make([]byte, len(encoded)+aeadTagSize),
+	}
+
+	// The second ClientHello following a HelloRetryRequest
+	// reuses the HPKE context so the enc is omitted
+	if client.hrr != nil {
+		ext.Enc = []byte{}
+	}
+
+	// The additional data is the ClientHelloOuter
+	// with the payload of the extension zeroed
+	extData, err := ext.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal outer extension")
+	}
+
+	outer.SetExtension(ExtensionEncryptedClientHello, extData)
+
+	aad, err := outer.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal client hello outer aad")
+	}
+
+	if ext.Payload, err = client.sender.Seal(aad, encoded); err != nil {
+		return nil, errors.Wrap(err, "encrypt client hello inner")
+	}
+
+	if extData, err = ext.MarshalBinary(); err != nil {
+		return nil, errors.Wrap(err, "marshal outer extension")
+	}
+
+	outer.SetExtension(ExtensionEncryptedClientHello, extData)
+
+	if client.firstInner == nil {
+		client.firstInner = inner
+	}
+
+	client.inner = inner
+	return outer, nil
+}
+
+// toASCIIServerName converts the server name
+// of the hello to its ASCII form, a hello
+// without a server name is left unchanged
+func toASCIIServerName(hello *ClientHello) error {
+	name, err := hello.ServerName()
+	if err != nil {
+		return errors.Wrap(err, "read inner server name")
+	}
+
+	if len(name) == 0 {
+		return nil
+	}
+
+	ascii, err := esni.ToASCIIName(name)
+	if err != nil {
+		return errors.Wrap(err, "inner server name")
+	}
+
+	if ascii != name {
+		hello.SetServerName(ascii)
+	}
+
+	return nil
+}
+
+// encodeInner produces the EncodedClientHelloInner,
+// the compressed inner hello without its session id
+// followed by the padding recommended by the
+// specification to hide the length of the server name
+func (client *Client) encodeInner(inner, compressed *ClientHello) ([]byte, error) {
+	encoded := compressed.Clone()
+	encoded.SessionID = nil
+
+	data, err := encoded.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := inner.ServerName()
+	if err != nil {
+		return nil, err
+	}
+
+	maxNameLength := int(client.config.MaximumNameLength)
+
+	var padding int
+	if len(name) > 0 {
+		if len(name) < maxNameLength {
+			padding = maxNameLength - len(name)
+		}
+	} else {
+		// Length of a server_name extension
+		// containing a name of the maximum length
+		padding = maxNameLength + 9
+	}
+
+	padding += 31 - ((len(data) + padding - 1) % 32)
+	return append(data, make([]byte, padding)...), nil
+}
@@ -0,0 +1,41 @@
+package ech
+
+import (
+	"testing"
+)
+
+func TestToASCIIServerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "bücher.example", want: "xn--bcher-kva.example"},
+		{name: "www.example.com", want: "www.example.com"},
+		{name: "xn--zz.example", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hello := new(ClientHello)
+			hello.SetServerName(test.name)
+
+			err := toASCIIServerName(hello)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("toASCIIServerName() error = %v, want error %t", err, test.wantErr)
+			}
+
+			if test.wantErr {
+				return
+			}
+
+			if got, _ := hello.ServerName(); got != test.want {
+				t.Errorf("server name = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if err := toASCIIServerName(new(ClientHello)); err != nil {
+		t.Errorf("toASCIIServerName() without server name error = %s", err)
+	}
+}
@@ -0,0 +1,363 @@
+// Package ech provides helpers for constructing and
+// processing the ClientHello messages used by Encrypted
+// Client Hello, the successor to ESNI
+package ech
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExtensionServerName specifies the TLS
+	// extension type of the server_name extension
+	ExtensionServerName uint16 = 0x0000
+
+	// ExtensionEncryptedClientHello specifies the
+	// TLS extension type of the encrypted_client_hello
+	// extension
+	ExtensionEncryptedClientHello uint16 = 0xfe0d
+
+	// ExtensionECHOuterExtensions specifies the TLS
+	// extension type of the ech_outer_extensions
+	// extension
+	ExtensionECHOuterExtensions uint16 = 0xfd00
+
+	// maxSessionIDLength specifies the maximum
+	// length of the legacy_session_id field
+	maxSessionIDLength = 32
+)
+
+// Extension represents a single TLS
+// extension of a ClientHello
+type Extension struct {
+	// Type specifies the TLS extension type
+	Type uint16
+
+	// Data specifies the extension_data
+	// of the extension
+	Data []byte
+}
+
+// ClientHello represents the body of a TLS 1.3
+// ClientHello message, without the handshake
+// message header
+type ClientHello struct {
+	// Version specifies the legacy_version
+	Version uint16
+
+	// Random specifies the random value
+	// of the client
+	Random [32]byte
+
+	// SessionID specifies the legacy_session_id
+	SessionID []byte
+
+	// CipherSuites specifies the TLS cipher
+	// suites offered by the client
+	CipherSuites []uint16
+
+	// CompressionMethods specifies the
+	// legacy_compression_methods
+	CompressionMethods []byte
+
+	// Extensions specifies the extensions of
+	// the ClientHello in the order they are sent
+	Extensions []Extension
+}
+
+// Clone returns a deep copy of the ClientHello
+func (hello *ClientHello) Clone() *ClientHello {
+	clone := *hello
+	clone.SessionID = append([]byte(nil), hello.SessionID...)
+	clone.CipherSuites = append([]uint16(nil), hello.CipherSuites...)
+	clone.CompressionMethods = append([]byte(nil), hello.CompressionMethods...)
+
+	clone.Extensions = make([]Extension, len(hello.Extensions))
+	for i := range hello.Extensions {
+		clone.Extensions[i] = Extension{Type: hello.Extensions[i].Type, Data: append([]byte(nil), hello.Extensions[i].Data...)}
+	}
+
+	return &clone
+}
+
+// Extension returns the data of the extension of the
+// specified type and if the extension is present
+func (hello *ClientHello) Extension(extType uint16) ([]byte, bool) {
+	for i := range hello.Extensions {
+		if hello.Extensions[i].Type == extType {
+			return hello.Extensions[i].Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetExtension sets the data of the extension of
+// the specified type, replacing the extension in
+// place if it is already present otherwise it is
+// appended to the extensions
+func (hello *ClientHello) SetExtension(extType uint16, data []byte) {
+	for i := range hello.Extensions {
+		if hello.Extensions[i].Type == extType {
+			hello.Extensions[i].Data = data
+			return
+		}
+	}
+
+	hello.Extensions = append(hello.Extensions, Extension{Type: extType, Data: data})
+}
+
+// RemoveExtension removes the extension of the
+// specified type if it is present
+func (hello *ClientHello) RemoveExtension(extType uint16) {
+	for i := range hello.Extensions {
+		if hello.Extensions[i].Type == extType {
+			hello.Extensions = append(hello.Extensions[:i], hello.Extensions[i+1:]...)
+			return
+		}
+	}
+}
+
+// ServerName returns the host name carried in
+// the server_name extension, an empty string is
+// returned if the extension isn't present
+func (hello *ClientHello) ServerName() (string, error) {
+	data, ok := hello.Extension(ExtensionServerName)
+	if !ok {
+		return "", nil
+	}
+
+	reader := bytes.NewReader(data)
+
+	list, err := readVector(reader, 2)
+	if err != nil {
+		return "", errors.Wrap(err, "read server name list")
+	}
+
+	reader = bytes.NewReader(list)
+	for reader.Len() > 0 {
+		nameType, err := reader.ReadByte()
+		if err != nil {
+			return "", errors.Wrap(err, "read name type")
+		}
+
+		name, err := readVector(reader, 2)
+		if err != nil {
+			return "", errors.Wrap(err, "read host name")
+		}
+
+		if nameType == 0 {
+			return string(name), nil
+		}
+	}
+
+	return "", nil
+}
+
+// SetServerName sets the server_name extension
+// to contain the single host name provided
+func (hello *ClientHello) SetServerName(name string) {
+	data := make([]byte, 5+len(name))
+	binary.BigEndian.PutUint16(data[0:], uint16(3+len(name)))
+	data[2] = 0
+	binary.BigEndian.PutUint16(data[3:], uint16(len(name)))
+	copy(data[5:], name)
+
+	hello.SetExtension(ExtensionServerName, data)
+}
+
+// MarshalBinary will marshal the ClientHello
+// into its binary format, without the handshake
+// message header
+func (hello *ClientHello) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+
+	_ = binary.Write(&data, binary.BigEndian, hello.Version)
+	data.Write(hello.Random[:])
+
+	if len(hello.SessionID) > maxSessionIDLength {
+		return nil, errors.New("session id is too large")
+	}
+
+	_ = writeVector(&data, 1, hello.SessionID)
+
+	suites := make([]byte, 2*len(hello.CipherSuites))
+	for i, suite := range hello.CipherSuites {
+		binary.BigEndian.PutUint16(suites[2*i:], suite)
+	}
+
+	if err := writeVector(&data, 2, suites); err != nil {
+		return nil, errors.Wrap(err, "write cipher suites")
+	}
+
+	if err := writeVector(&data, 1, hello.CompressionMethods); err != nil {
+		return nil, errors.Wrap(err, "write compression methods")
+	}
+
+	extensions, err := marshalExtensions(hello.Extensions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeVector(&data, 2, extensions); err != nil {
+		return nil, errors.Wrap(err, "write extensions")
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ClientHello from the provided binary data, the
+// data must not contain the handshake message header
+func (hello *ClientHello) UnmarshalBinary(data []byte) error {
+	n, err := hello.unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	if n != len(data) {
+		return errors.Errorf("%d bytes of unexpected data after client hello", len(data)-n)
+	}
+
+	return nil
+}
+
+// unmarshal will attempt to unmarshal a ClientHello
+// from the start of the binary data, returning the
+// number of bytes occupied by it
+func (hello *ClientHello) unmarshal(data []byte) (int, error) {
+	reader := bytes.NewReader(data)
+
+	if err := binary.Read(reader, binary.BigEndian, &hello.Version); err != nil {
+		return 0, errors.Wrap(err, "read version")
+	}
+
+	if _, err := io.ReadFull(reader, hello.Random[:]); err != nil {
+		return 0, errors.Wrap(err, "read random")
+	}
+
+	var err error
+	if hello.SessionID, err = readVector(reader, 1); err != nil {
+		return 0, errors.Wrap(err, "read session id")
+	} else if len(hello.SessionID) > maxSessionIDLength {
+		return 0, errors.New("session id is too large")
+	}
+
+	suites, err := readVector(reader, 2)
+	if err != nil {
+		return 0, errors.Wrap(err, "read cipher suites")
+	} else if len(suites)%2 != 0 {
+		return 0, errors.New("invalid cipher suites length")
+	}
+
+	hello.CipherSuites = make([]uint16, len(suites)/2)
+	for i := range hello.CipherSuites {
+		hello.CipherSuites[i] = binary.BigEndian.Uint16(suites[2*i:])
+	}
+
+	if hello.CompressionMethods, err = readVector(reader, 1); err != nil {
+		return 0, errors.Wrap(err, "read compression methods")
+	}
+
+	extensions, err := readVector(reader, 2)
+	if err != nil {
+		return 0, errors.Wrap(err, "read extensions")
+	}
+
+	if hello.Extensions, err = unmarshalExtensions(extensions); err != nil {
+		return 0, err
+	}
+
+	return len(data) - reader.Len(), nil
+}
+
+// marshalExtensions will marshal the extensions
+// into the body of a TLS extensions list
+func marshalExtensions(extensions []Extension) ([]byte, error) {
+	var data bytes.Buffer
+
+	for _, ext := range extensions {
+		_ = binary.Write(&data, binary.BigEndian, ext.Type)
+		if err := writeVector(&data, 2, ext.Data); err != nil {
+			return nil, errors.Wrapf(err, "write extension 0x%04x", ext.Type)
+		}
+	}
+
+	return data.Bytes(), nil
+}
+
+// unmarshalExtensions will unmarshal the body of
+// a TLS extensions list, duplicate extensions are
+// rejected as required by TLS 1.3
+func unmarshalExtensions(data []byte) ([]Extension, error) {
+	var extensions []Extension
+	seen := make(map[uint16]bool)
+
+	reader := bytes.NewReader(data)
+	for reader.Len() > 0 {
+		var ext Extension
+		if err := binary.Read(reader, binary.BigEndian, &ext.Type); err != nil {
+			return nil, errors.Wrap(err, "read extension type")
+		}
+
+		if seen[ext.Type] {
+			return nil, errors.Errorf("duplicate extension 0x%04x", ext.Type)
+		}
+
+		seen[ext.Type] = true
+
+		var err error
+		if ext.Data, err = readVector(reader, 2); err != nil {
+			return nil, errors.Wrapf(err, "read extension 0x%04x", ext.Type)
+		}
+
+		extensions = append(extensions, ext)
+	}
+
+	return extensions, nil
+}
+
+// writeVector will write the data to the buffer
+// prefixed with its length encoded in either 1
+// or 2 bytes
+func writeVector(buffer *bytes.Buffer, lengthSize int, data []byte) error {
+	if len(data) >= 1<<(8*uint(lengthSize)) {
+		return errors.New("vector is too large")
+	}
+
+	if lengthSize == 1 {
+		buffer.WriteByte(uint8(len(data)))
+	} else {
+		_ = binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	}
+
+	buffer.Write(data)
+	return nil
+}
+
+// readVector will read a vector prefixed with its
+// length encoded in either 1 or 2 bytes
+func readVector(reader *bytes.Reader, lengthSize int) ([]byte, error) {
+	prefix := make([]byte, lengthSize)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return nil, err
+	}
+
+	length := int(prefix[0])
+	if lengthSize == 2 {
+		length = int(binary.BigEndian.Uint16(prefix))
+	}
+
+	if reader.Len() < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, length)
+	_, _ = io.ReadFull(reader, data)
+
+	return data, nil
+}
@@ -0,0 +1,186 @@
+package ech
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/LiamHaworth/go-esni/internal/tls13"
+	"github.com/pkg/errors"
+)
+
+const (
+	// acceptConfirmationLabel specifies the label used
+	// to derive the confirmation carried in the random
+	// of the ServerHello
+	acceptConfirmationLabel = "ech accept confirmation"
+
+	// AcceptConfirmationSize specifies the size
+	// of the acceptance confirmation
+	AcceptConfirmationSize = 8
+
+	// handshakeTypeClientHello specifies the
+	// handshake message type of a ClientHello
+	handshakeTypeClientHello = 1
+
+	// handshakeTypeMessageHash specifies the
+	// handshake message type of the synthetic
+	// message_hash message
+	handshakeTypeMessageHash = 254
+
+	// serverHelloRandomOffset specifies the offset of
+	// the random in a ServerHello handshake message,
+	// after the message header and legacy_version
+	serverHelloRandomOffset = 4 + 2
+
+	// serverHelloConfirmationOffset specifies the
+	// offset of the acceptance confirmation, which
+	// occupies the last 8 bytes of the random
+	serverHelloConfirmationOffset = serverHelloRandomOffset + 32 - AcceptConfirmationSize
+)
+
+// MarshalMessage will marshal the ClientHello
+// into a handshake message, as included in the
+// handshake transcript
+func (hello *ClientHello) MarshalMessage() ([]byte, error) {
+	body, err := hello.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) >= 1<<24 {
+		return nil, errors.New("client hello is too large")
+	}
+
+	message := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(message, uint32(len(body)))
+	message[0] = handshakeTypeClientHello
+	copy(message[4:], body)
+
+	return message, nil
+}
+
+// AcceptConfirmation computes the acceptance confirmation
+// a server places in the random of its ServerHello when
+// it accepts the ClientHelloInner.
+//
+// The hash must be the hash of the negotiated TLS cipher
+// suite and the server hello must be the complete ServerHello
+// handshake message, the confirmation bytes within its random
+// are treated as zero.
+func AcceptConfirmation(hash crypto.Hash, inner *ClientHello, serverHello []byte) ([]byte, error) {
+	return AcceptConfirmationAfterRetry(hash, nil, nil, inner, serverHello)
+}
+
+// AcceptConfirmationAfterRetry computes the acceptance
+// confirmation of a ServerHello sent in response to the
+// second ClientHello of a handshake that included a
+// HelloRetryRequest, the transcript covers the first inner
+// hello, the HelloRetryRequest and the second inner hello.
+//
+// If the HelloRetryRequest is nil the confirmation is
+// computed as done by AcceptConfirmation.
+func AcceptConfirmationAfterRetry(hash crypto.Hash, firstInner *ClientHello, hrr []byte, inner *ClientHello, serverHello []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.Errorf("hash %s is unavailable", hash)
+	}
+
+	var transcript [][]byte
+	if hrr != nil {
+		firstMessage, err := firstInner.MarshalMessage()
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal first client hello inner")
+		}
+
+		transcript = append(transcript, messageHash(hash, firstMessage), hrr)
+	}
+
+	innerMessage, err := inner.MarshalMessage()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal client hello inner")
+	}
+
+	transcript = append(transcript, innerMessage)
+	return computeConfirmation(hash, inner.Random, acceptConfirmationLabel, transcript, serverHello, serverHelloConfirmationOffset)
+}
+
+// ConfirmAcceptance returns a copy of the ServerHello
+// handshake message with the acceptance confirmation for
+// the ClientHelloInner placed in its random
+func ConfirmAcceptance(hash crypto.Hash, inner *ClientHello, serverHello []byte) ([]byte, error) {
+	return ConfirmAcceptanceAfterRetry(hash, nil, nil, inner, serverHello)
+}
+
+// ConfirmAcceptanceAfterRetry returns a copy of the
+// ServerHello handshake message with the acceptance
+// confirmation computed by AcceptConfirmationAfterRetry
+// placed in its random
+func ConfirmAcceptanceAfterRetry(hash crypto.Hash, firstInner *ClientHello, hrr []byte, inner *ClientHello, serverHello []byte) ([]byte, error) {
+	confirmation, err := AcceptConfirmationAfterRetry(hash, firstInner, hrr, inner, serverHello)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed := append([]byte(nil), serverHello...)
+	copy(confirmed[serverHelloConfirmationOffset:], confirmation)
+
+	return confirmed, nil
+}
+
+// Accepted returns if the ServerHello handshake message
+// carries the acceptance confirmation for the last
+// ClientHelloInner sealed by the client, taking into
+// account any HelloRetryRequest passed to the client
+func (client *Client) Accepted(hash crypto.Hash, serverHello []byte) (bool, error) {
+	if client.inner == nil {
+		return false, errors.New("no client hello inner has been sealed")
+	}
+
+	confirmation, err := AcceptConfirmationAfterRetry(hash, client.firstInner, client.hrr, client.inner, serverHello)
+	if err != nil {
+		return false, err
+	}
+
+	received := serverHello[serverHelloConfirmationOffset : serverHelloConfirmationOffset+AcceptConfirmationSize]
+	return subtle.ConstantTimeCompare(confirmation, received) == 1, nil
+}
+
+// messageHash produces the synthetic message_hash
+// handshake message that replaces the first ClientHello
+// in the transcript following a HelloRetryRequest
+func messageHash(hash crypto.Hash, message []byte) []byte {
+	digest := hash.New()
+	digest.Write(message)
+
+	return append([]byte{handshakeTypeMessageHash, 0, 0, byte(hash.Size())}, digest.Sum(nil)...)
+}
+
+// computeConfirmation derives a confirmation from the
+// random of the ClientHelloInner and the transcript of
+// the handshake messages followed by the server message,
+// the confirmation bytes at the offset within the server
+// message are treated as zero
+func computeConfirmation(hash crypto.Hash, innerRandom [32]byte, label string, messages [][]byte, serverMessage []byte, offset int) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.Errorf("hash %s is unavailable", hash)
+	}
+
+	if offset < 0 || len(serverMessage) < offset+AcceptConfirmationSize {
+		return nil, errors.New("server message is too small")
+	}
+
+	zeroed := append([]byte(nil), serverMessage...)
+	copy(zeroed[offset:offset+AcceptConfirmationSize], make([]byte, AcceptConfirmationSize))
+
+	transcript := hash.New()
+	for _, message := range messages {
+		transcript.Write(message)
+	}
+
+	transcript.Write(zeroed)
+
+	secret := tls13.Extract(hash.New, innerRandom[:], nil)
+	return tls13.ExpandLabel(hash.New, secret, label, transcript.Sum(nil), AcceptConfirmationSize), nil
+}
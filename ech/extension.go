@@ -0,0 +1,148 @@
+package ech
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+// ClientHelloType specifies if an encrypted_client_hello
+// extension belongs to the ClientHelloOuter or the
+// ClientHelloInner
+type ClientHelloType uint8
+
+const (
+	ClientHelloTypeOuter ClientHelloType = 0
+	ClientHelloTypeInner ClientHelloType = 1
+)
+
+// ClientHelloType_name specifies a map of ClientHelloTypes
+// to their respective string representation
+var ClientHelloType_name = map[ClientHelloType]string{
+	ClientHelloTypeOuter: "outer",
+	ClientHelloTypeInner: "inner",
+}
+
+// String attempts to return the string
+// representation of the ClientHelloType based
+// on those specified in ClientHelloType_name, if
+// no match is found "UNKNOWN" is returned
+func (helloType ClientHelloType) String() string {
+	if name, ok := ClientHelloType_name[helloType]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// ECHClientHello represents the payload of the
+// encrypted_client_hello extension sent by a client,
+// the remaining fields are only present in the
+// extension of a ClientHelloOuter
+type ECHClientHello struct {
+	// Type specifies which ClientHello the
+	// extension belongs to
+	Type ClientHelloType
+
+	// CipherSuite specifies the HPKE cipher
+	// suite used to encrypt the payload
+	CipherSuite esni.HpkeSymmetricCipherSuite
+
+	// ConfigID specifies the identifier of
+	// the ECHConfig used to encrypt the payload
+	ConfigID uint8
+
+	// Enc specifies the HPKE encapsulated key,
+	// it is empty in the ClientHello sent in
+	// response to a HelloRetryRequest
+	Enc []byte
+
+	// Payload specifies the encrypted
+	// EncodedClientHelloInner
+	Payload []byte
+}
+
+// MarshalBinary will marshal the extension
+// into its binary format
+func (ext ECHClientHello) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+	data.WriteByte(uint8(ext.Type))
+
+	switch ext.Type {
+	case ClientHelloTypeInner:
+		return data.Bytes(), nil
+
+	case ClientHelloTypeOuter:
+		_ = binary.Write(&data, binary.BigEndian, ext.CipherSuite.KDF)
+		_ = binary.Write(&data, binary.BigEndian, ext.CipherSuite.AEAD)
+		data.WriteByte(ext.ConfigID)
+
+		if err := writeVector(&data, 2, ext.Enc); err != nil {
+			return nil, errors.Wrap(err, "write enc")
+		}
+
+		if len(ext.Payload) == 0 {
+			return nil, errors.New("payload is empty")
+		}
+
+		if err := writeVector(&data, 2, ext.Payload); err != nil {
+			return nil, errors.Wrap(err, "write payload")
+		}
+
+		return data.Bytes(), nil
+
+	default:
+		return nil, errors.Errorf("unknown client hello type %d", ext.Type)
+	}
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// extension from the provided binary data
+func (ext *ECHClientHello) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	helloType, err := reader.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "read type")
+	}
+
+	*ext = ECHClientHello{Type: ClientHelloType(helloType)}
+
+	switch ext.Type {
+	case ClientHelloTypeInner:
+
+	case ClientHelloTypeOuter:
+		if err := binary.Read(reader, binary.BigEndian, &ext.CipherSuite.KDF); err != nil {
+			return errors.Wrap(err, "read kdf id")
+		}
+
+		if err := binary.Read(reader, binary.BigEndian, &ext.CipherSuite.AEAD); err != nil {
+			return errors.Wrap(err, "read aead id")
+		}
+
+		if ext.ConfigID, err = reader.ReadByte(); err != nil {
+			return errors.Wrap(err, "read config id")
+		}
+
+		if ext.Enc, err = readVector(reader, 2); err != nil {
+			return errors.Wrap(err, "read enc")
+		}
+
+		if ext.Payload, err = readVector(reader, 2); err != nil {
+			return errors.Wrap(err, "read payload")
+		} else if len(ext.Payload) == 0 {
+			return errors.New("payload is empty")
+		}
+
+	default:
+		return errors.Errorf("unknown client hello type %d", ext.Type)
+	}
+
+	if reader.Len() != 0 {
+		return errors.Errorf("%d bytes of unexpected data in extension", reader.Len())
+	}
+
+	return nil
+}
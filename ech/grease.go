@@ -0,0 +1,66 @@
+package ech
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// greaseEncSize specifies the size of the GREASE
+	// enc, matching an X25519 encapsulated key
+	greaseEncSize = 32
+
+	// greaseMinBlocks and greaseMaxBlocks specify the
+	// range of 32 byte blocks the GREASE payload
+	// occupies, matching the padded size of typical
+	// inner hellos
+	greaseMinBlocks = 4
+	greaseMaxBlocks = 8
+)
+
+// GenerateGreaseECH produces an encrypted_client_hello
+// extension for a ClientHelloOuter that is indistinguishable
+// from a real one to an observer, it is sent by clients when
+// no ECHConfig is available so that real uses of ECH don't
+// stand out.
+//
+// The extension uses the most widely deployed cipher suite
+// with a random config id, enc and payload.
+func GenerateGreaseECH() (*ECHClientHello, error) {
+	ext := &ECHClientHello{
+		Type: ClientHelloTypeOuter,
+		CipherSuite: esni.HpkeSymmetricCipherSuite{
+			KDF:  esni.HpkeKdfId_HKDF_SHA256,
+			AEAD: esni.HpkeAeadId_AES_128_GCM,
+		},
+	}
+
+	var configID [1]byte
+	if _, err := io.ReadFull(rand.Reader, configID[:]); err != nil {
+		return nil, errors.Wrap(err, "read random config id")
+	}
+
+	ext.ConfigID = configID[0]
+
+	blocks, err := rand.Int(rand.Reader, big.NewInt(greaseMaxBlocks-greaseMinBlocks+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read random payload length")
+	}
+
+	ext.Enc = make([]byte, greaseEncSize)
+	ext.Payload = make([]byte, 32*(greaseMinBlocks+int(blocks.Int64()))+aeadTagSize)
+
+	if _, err := io.ReadFull(rand.Reader, ext.Enc); err != nil {
+		return nil, errors.Wrap(err, "read random enc")
+	}
+
+	if _, err := io.ReadFull(rand.Reader, ext.Payload); err != nil {
+		return nil, errors.Wrap(err, "read random payload")
+	}
+
+	return ext, nil
+}
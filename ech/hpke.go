@@ -0,0 +1,134 @@
+package ech
+
+import (
+	"crypto/hpke"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+var (
+	// DefaultHPKEProvider specifies the HPKE provider
+	// used when none is supplied, it is backed by the
+	// crypto/hpke package of the standard library
+	DefaultHPKEProvider HPKEProvider = stdlibProvider{}
+)
+
+// HPKESuite represents the combination of
+// algorithms used to set up an HPKE context
+type HPKESuite struct {
+	// KEM specifies the key encapsulation
+	// mechanism of the context
+	KEM esni.HpkeKemId
+
+	// KDF specifies the key derivation
+	// function of the context
+	KDF esni.HpkeKdfId
+
+	// AEAD specifies the AEAD algorithm
+	// of the context
+	AEAD esni.HpkeAeadId
+}
+
+// SealContext represents the sending side
+// of an HPKE context
+type SealContext interface {
+	// Seal must encrypt the plaintext using the
+	// next nonce of the context
+	Seal(aad, plaintext []byte) ([]byte, error)
+}
+
+// OpenContext represents the receiving side
+// of an HPKE context
+type OpenContext interface {
+	// Open must decrypt the ciphertext using the
+	// next nonce of the context
+	Open(aad, ciphertext []byte) ([]byte, error)
+}
+
+// HPKEProvider specifies the methods an HPKE
+// implementation must provide to be used for
+// ECH, allowing alternative implementations to
+// be used in place of the standard library
+type HPKEProvider interface {
+	// Supports must return if the provider
+	// implements every algorithm of the suite
+	Supports(suite HPKESuite) bool
+
+	// SetupSender must set up a base mode sending
+	// context to the serialised public key, returning
+	// the encapsulated key along with the context
+	SetupSender(suite HPKESuite, publicKey, info []byte) ([]byte, SealContext, error)
+
+	// SetupRecipient must set up a base mode receiving
+	// context for the encapsulated key using the
+	// serialised private key
+	SetupRecipient(suite HPKESuite, enc, privateKey, info []byte) (OpenContext, error)
+}
+
+// stdlibProvider implements HPKEProvider
+// using the crypto/hpke package
+type stdlibProvider struct{}
+
+// Supports returns if crypto/hpke implements
+// every algorithm of the suite
+func (stdlibProvider) Supports(suite HPKESuite) bool {
+	_, _, _, err := stdlibSuite(suite)
+	return err == nil
+}
+
+// SetupSender sets up a crypto/hpke Sender
+func (stdlibProvider) SetupSender(suite HPKESuite, publicKey, info []byte) ([]byte, SealContext, error) {
+	kem, kdf, aead, err := stdlibSuite(suite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := kem.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parse public key")
+	}
+
+	return hpke.NewSender(key, kdf, aead, info)
+}
+
+// SetupRecipient sets up a crypto/hpke Recipient
+func (stdlibProvider) SetupRecipient(suite HPKESuite, enc, privateKey, info []byte) (OpenContext, error) {
+	kem, kdf, aead, err := stdlibSuite(suite)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kem.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+
+	return hpke.NewRecipient(enc, key, kdf, aead, info)
+}
+
+// stdlibSuite returns the crypto/hpke
+// implementations of the algorithms
+// of the suite
+func stdlibSuite(suite HPKESuite) (hpke.KEM, hpke.KDF, hpke.AEAD, error) {
+	if suite.AEAD == esni.HpkeAeadId_EXPORT_ONLY {
+		return nil, nil, nil, errors.New("export only aead can't be used for encryption")
+	}
+
+	kem, err := hpke.NewKEM(uint16(suite.KEM))
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "unsupported kem %s", suite.KEM)
+	}
+
+	kdf, err := hpke.NewKDF(uint16(suite.KDF))
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "unsupported kdf %s", suite.KDF)
+	}
+
+	aead, err := hpke.NewAEAD(uint16(suite.AEAD))
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "unsupported aead %s", suite.AEAD)
+	}
+
+	return kem, kdf, aead, nil
+}
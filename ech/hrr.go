@@ -0,0 +1,147 @@
+package ech
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// hrrAcceptConfirmationLabel specifies the label
+	// used to derive the confirmation carried in the
+	// encrypted_client_hello extension of a
+	// HelloRetryRequest
+	hrrAcceptConfirmationLabel = "hrr ech accept confirmation"
+)
+
+// HRRAcceptConfirmation computes the acceptance confirmation
+// a server places in the encrypted_client_hello extension of
+// a HelloRetryRequest when it accepts the first ClientHelloInner.
+//
+// The hrr must be the complete HelloRetryRequest handshake
+// message including the extension, its contents are treated
+// as zero.
+func HRRAcceptConfirmation(hash crypto.Hash, inner *ClientHello, hrr []byte) ([]byte, error) {
+	offset, length, err := serverHelloExtension(hrr, ExtensionEncryptedClientHello)
+	if err != nil {
+		return nil, err
+	} else if length != AcceptConfirmationSize {
+		return nil, errors.Errorf("encrypted_client_hello extension has length %d", length)
+	}
+
+	innerMessage, err := inner.MarshalMessage()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal client hello inner")
+	}
+
+	return computeConfirmation(hash, inner.Random, hrrAcceptConfirmationLabel, [][]byte{innerMessage}, hrr, offset)
+}
+
+// ConfirmHRRAcceptance returns a copy of the HelloRetryRequest
+// handshake message with the acceptance confirmation for the
+// ClientHelloInner placed in its encrypted_client_hello
+// extension, which must already be present with a length
+// of AcceptConfirmationSize
+func ConfirmHRRAcceptance(hash crypto.Hash, inner *ClientHello, hrr []byte) ([]byte, error) {
+	confirmation, err := HRRAcceptConfirmation(hash, inner, hrr)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, _, _ := serverHelloExtension(hrr, ExtensionEncryptedClientHello)
+
+	confirmed := append([]byte(nil), hrr...)
+	copy(confirmed[offset:], confirmation)
+
+	return confirmed, nil
+}
+
+// HelloRetryRequest passes the HelloRetryRequest handshake
+// message received in response to the first ClientHello to
+// the client, returning if the server confirmed it accepted
+// the ClientHelloInner.
+//
+// The HelloRetryRequest is recorded as part of the transcript
+// used to verify the acceptance confirmation of the final
+// ServerHello, the second ClientHello should be produced
+// using Seal.
+func (client *Client) HelloRetryRequest(hash crypto.Hash, hrr []byte) (bool, error) {
+	if client.inner == nil {
+		return false, errors.New("no client hello inner has been sealed")
+	} else if client.hrr != nil {
+		return false, errors.New("hello retry request already received")
+	}
+
+	client.hrr = append([]byte(nil), hrr...)
+
+	offset, length, err := serverHelloExtension(hrr, ExtensionEncryptedClientHello)
+	if err == errExtensionNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else if length != AcceptConfirmationSize {
+		return false, nil
+	}
+
+	confirmation, err := HRRAcceptConfirmation(hash, client.inner, hrr)
+	if err != nil {
+		return false, err
+	}
+
+	received := hrr[offset : offset+AcceptConfirmationSize]
+	return subtle.ConstantTimeCompare(confirmation, received) == 1, nil
+}
+
+// errExtensionNotFound is returned by serverHelloExtension
+// when the message doesn't contain the extension
+var errExtensionNotFound = errors.New("extension not found")
+
+// serverHelloExtension locates the data of an extension
+// within a ServerHello or HelloRetryRequest handshake
+// message, returning its offset and length
+func serverHelloExtension(message []byte, extType uint16) (int, int, error) {
+	reader := bytes.NewReader(message)
+
+	if _, err := reader.Seek(serverHelloRandomOffset+32, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := readVector(reader, 1); err != nil {
+		return 0, 0, errors.Wrap(err, "read session id")
+	}
+
+	// Skip the cipher_suite and
+	// legacy_compression_method
+	if _, err := reader.Seek(3, io.SeekCurrent); err != nil {
+		return 0, 0, err
+	}
+
+	var extensionsLength uint16
+	if err := binary.Read(reader, binary.BigEndian, &extensionsLength); err != nil {
+		return 0, 0, errors.Wrap(err, "read extensions length")
+	}
+
+	for reader.Len() > 0 {
+		var header struct{ Type, Length uint16 }
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return 0, 0, errors.Wrap(err, "read extension header")
+		}
+
+		offset := len(message) - reader.Len()
+		if reader.Len() < int(header.Length) {
+			return 0, 0, errors.Wrap(io.ErrUnexpectedEOF, "read extension data")
+		}
+
+		if header.Type == extType {
+			return offset, int(header.Length), nil
+		}
+
+		_, _ = reader.Seek(int64(header.Length), io.SeekCurrent)
+	}
+
+	return 0, 0, errExtensionNotFound
+}
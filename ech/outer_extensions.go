@@ -0,0 +1,215 @@
+package ech
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// marshalOuterExtensions will marshal the list of
+// extension types carried by the ech_outer_extensions
+// extension
+func marshalOuterExtensions(types []uint16) ([]byte, error) {
+	if len(types) == 0 {
+		return nil, errors.New("outer extensions list is empty")
+	}
+
+	list := make([]byte, 2*len(types))
+	for i, extType := range types {
+		binary.BigEndian.PutUint16(list[2*i:], extType)
+	}
+
+	var data bytes.Buffer
+	if err := writeVector(&data, 1, list); err != nil {
+		return nil, errors.Wrap(err, "write outer extensions list")
+	}
+
+	return data.Bytes(), nil
+}
+
+// unmarshalOuterExtensions will unmarshal the list
+// of extension types carried by the ech_outer_extensions
+// extension
+func unmarshalOuterExtensions(data []byte) ([]uint16, error) {
+	reader := bytes.NewReader(data)
+
+	list, err := readVector(reader, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "read outer extensions list")
+	} else if len(list) == 0 || len(list)%2 != 0 {
+		return nil, errors.New("invalid outer extensions list length")
+	} else if reader.Len() != 0 {
+		return nil, errors.Errorf("%d bytes of unexpected data in extension", reader.Len())
+	}
+
+	types := make([]uint16, len(list)/2)
+	for i := range types {
+		types[i] = binary.BigEndian.Uint16(list[2*i:])
+	}
+
+	return types, nil
+}
+
+// compressExtensions replaces the extensions of the inner
+// hello with the specified types by a single ech_outer_extensions
+// extension, which the server expands using the same extensions
+// of the ClientHelloOuter.
+//
+// The referenced extensions are made contiguous in the
+// returned inner hello, starting at the position of the
+// first of them, so that expanding the encoded hello
+// reproduces it exactly. Types absent from the inner
+// hello are ignored.
+func compressExtensions(inner *ClientHello, types []uint16) (*ClientHello, *ClientHello, []Extension, error) {
+	var referenced []Extension
+	for _, extType := range types {
+		switch extType {
+		case ExtensionServerName, ExtensionEncryptedClientHello, ExtensionECHOuterExtensions:
+			return nil, nil, nil, errors.Errorf("extension 0x%04x can't be compressed", extType)
+		}
+
+		if data, ok := inner.Extension(extType); ok {
+			referenced = append(referenced, Extension{Type: extType, Data: data})
+		}
+	}
+
+	if len(referenced) == 0 {
+		return inner, inner, nil, nil
+	}
+
+	refTypes := make([]uint16, len(referenced))
+	for i := range referenced {
+		refTypes[i] = referenced[i].Type
+	}
+
+	outerExt, err := marshalOuterExtensions(refTypes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ordered := inner.Clone()
+	ordered.Extensions = nil
+
+	encoded := inner.Clone()
+	encoded.Extensions = nil
+
+	var inserted bool
+	for _, ext := range inner.Extensions {
+		if !containsType(refTypes, ext.Type) {
+			ordered.Extensions = append(ordered.Extensions, ext)
+			encoded.Extensions = append(encoded.Extensions, ext)
+			continue
+		}
+
+		if !inserted {
+			ordered.Extensions = append(ordered.Extensions, referenced...)
+			encoded.Extensions = append(encoded.Extensions, Extension{Type: ExtensionECHOuterExtensions, Data: outerExt})
+			inserted = true
+		}
+	}
+
+	return ordered, encoded, referenced, nil
+}
+
+// expandExtensions replaces the ech_outer_extensions
+// extension of the encoded inner hello with the
+// referenced extensions of the outer hello, which must
+// appear in the outer hello in the same order
+func expandExtensions(encoded, outer *ClientHello) error {
+	var expanded []Extension
+	var found bool
+
+	for _, ext := range encoded.Extensions {
+		if ext.Type != ExtensionECHOuterExtensions {
+			expanded = append(expanded, ext)
+			continue
+		}
+
+		if found {
+			return errors.New("duplicate outer extensions extension")
+		}
+
+		found = true
+
+		types, err := unmarshalOuterExtensions(ext.Data)
+		if err != nil {
+			return err
+		}
+
+		pos := 0
+		for _, extType := range types {
+			if extType == ExtensionEncryptedClientHello {
+				return errors.New("outer extensions references encrypted_client_hello")
+			}
+
+			for pos < len(outer.Extensions) && outer.Extensions[pos].Type != extType {
+				pos++
+			}
+
+			if pos == len(outer.Extensions) {
+				return errors.Errorf("referenced extension 0x%04x not found in outer hello", extType)
+			}
+
+			expanded = append(expanded, Extension{Type: extType, Data: append([]byte(nil), outer.Extensions[pos].Data...)})
+			pos++
+		}
+	}
+
+	encoded.Extensions = expanded
+	return nil
+}
+
+// DecodeClientHelloInner reconstructs the ClientHelloInner
+// from the decrypted EncodedClientHelloInner, expanding any
+// extensions compressed using ech_outer_extensions from the
+// ClientHelloOuter and restoring its session id
+func DecodeClientHelloInner(encoded []byte, outer *ClientHello) (*ClientHello, error) {
+	inner := new(ClientHello)
+
+	n, err := inner.unmarshal(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal encoded client hello inner")
+	}
+
+	for _, b := range encoded[n:] {
+		if b != 0 {
+			return nil, errors.New("padding contains non-zero bytes")
+		}
+	}
+
+	if len(inner.SessionID) != 0 {
+		return nil, errors.New("encoded client hello inner has a session id")
+	}
+
+	if err := expandExtensions(inner, outer); err != nil {
+		return nil, errors.Wrap(err, "expand outer extensions")
+	}
+
+	data, ok := inner.Extension(ExtensionEncryptedClientHello)
+	if !ok {
+		return nil, errors.New("client hello inner has no encrypted_client_hello extension")
+	}
+
+	var ext ECHClientHello
+	if err := ext.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "unmarshal inner extension")
+	} else if ext.Type != ClientHelloTypeInner {
+		return nil, errors.Errorf("client hello inner has an extension of type %s", ext.Type)
+	}
+
+	inner.SessionID = append([]byte(nil), outer.SessionID...)
+	return inner, nil
+}
+
+// containsType returns if the extension
+// type is present in the list of types
+func containsType(types []uint16, extType uint16) bool {
+	for _, t := range types {
+		if t == extType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package ech
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// HPKE known answer tests with esni.SelfTest
+func init() {
+	esni.RegisterSelfTest("hpke", func() error {
+		return SelfTestProvider(DefaultHPKEProvider)
+	})
+}
+
+// hpkeVector specifies a base mode HPKE test
+// vector, the first two encryptions of the
+// context are checked
+type hpkeVector struct {
+	name                   string
+	suite                  HPKESuite
+	info, pkRm, skRm, enc  string
+	plaintext              string
+	additionalData, cipher [2]string
+}
+
+// hpkeVectors specifies the base mode vectors from
+// appendix A.1.1 and A.2.1 of RFC 9180, covering the
+// DHKEM(X25519, HKDF-SHA256) suites used by ECH
+var hpkeVectors = []hpkeVector{
+	{
+		name:           "DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM",
+		suite:          HPKESuite{KEM: esni.HpkeKemId_DHKEM_X25519_HKDF_SHA256, KDF: esni.HpkeKdfId_HKDF_SHA256, AEAD: esni.HpkeAeadId_AES_128_GCM},
+		info:           "4f6465206f6e2061204772656369616e2055726e",
+		pkRm:           "3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d",
+		skRm:           "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8",
+		enc:            "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431",
+		plaintext:      "4265617574792069732074727574682c20747275746820626561757479",
+		additionalData: [2]string{"436f756e742d30", "436f756e742d31"},
+		cipher: [2]string{
+			"f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a",
+			"af2d7e9ac9ae7e270f46ba1f975be53c09f8d875bdc8535458c2494e8a6eab251c03d0c22a56b8ca42c2063b84",
+		},
+	},
+	{
+		name:           "DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, ChaCha20Poly1305",
+		suite:          HPKESuite{KEM: esni.HpkeKemId_DHKEM_X25519_HKDF_SHA256, KDF: esni.HpkeKdfId_HKDF_SHA256, AEAD: esni.HpkeAeadId_CHACHA20_POLY1305},
+		info:           "4f6465206f6e2061204772656369616e2055726e",
+		pkRm:           "4310ee97d88cc1f088a5576c77ab0cf5c3ac797f3d95139c6c84b5429c59662a",
+		skRm:           "8057991eef8f1f1af18f4a9491d16a1ce333f695d4db8e38da75975c4478e0fb",
+		enc:            "1afa08d3dec047a643885163f1180476fa7ddb54c6a8029ea33f95796bf2ac4a",
+		plaintext:      "4265617574792069732074727574682c20747275746820626561757479",
+		additionalData: [2]string{"436f756e742d30", "436f756e742d31"},
+		cipher: [2]string{
+			"1c5250d8034ec2b784ba2cfd69dbdb8af406cfe3ff938e131f0def8c8b60b4db21993c62ce81883d2dd1b51a28",
+			"6b53c051e4199c518de79594e1c4ab18b96f081549d45ce015be002090bb119e85285337cc95ba5f59992dc98c",
+		},
+	},
+}
+
+// SelfTestProvider runs the RFC 9180 known answer
+// tests against the HPKE provider, decrypting the
+// ciphertexts of each vector and round-tripping a
+// message through a freshly set up sender. It is
+// run for DefaultHPKEProvider by esni.SelfTest and
+// should be called for any other provider before
+// it is used, the first failure is returned.
+func SelfTestProvider(provider HPKEProvider) error {
+	for _, vector := range hpkeVectors {
+		if err := vector.run(provider); err != nil {
+			return errors.Wrap(err, vector.name)
+		}
+	}
+
+	return nil
+}
+
+// run checks the provider against the vector
+func (vector hpkeVector) run(provider HPKEProvider) error {
+	if !provider.Supports(vector.suite) {
+		return errors.New("suite is not supported by the provider")
+	}
+
+	info, skRm := mustDecodeHex(vector.info), mustDecodeHex(vector.skRm)
+	plaintext := mustDecodeHex(vector.plaintext)
+
+	recipient, err := provider.SetupRecipient(vector.suite, mustDecodeHex(vector.enc), skRm, info)
+	if err != nil {
+		return errors.Wrap(err, "set up recipient")
+	}
+
+	for i := range vector.cipher {
+		opened, err := recipient.Open(mustDecodeHex(vector.additionalData[i]), mustDecodeHex(vector.cipher[i]))
+		if err != nil {
+			return errors.Wrapf(err, "open ciphertext %d", i)
+		}
+
+		if !bytes.Equal(opened, plaintext) {
+			return errors.Errorf("plaintext %d is %x, expected %s", i, opened, vector.plaintext)
+		}
+	}
+
+	enc, sender, err := provider.SetupSender(vector.suite, mustDecodeHex(vector.pkRm), info)
+	if err != nil {
+		return errors.Wrap(err, "set up sender")
+	}
+
+	ciphertext, err := sender.Seal(nil, plaintext)
+	if err != nil {
+		return errors.Wrap(err, "seal")
+	}
+
+	if recipient, err = provider.SetupRecipient(vector.suite, enc, skRm, info); err != nil {
+		return errors.Wrap(err, "set up recipient for sender")
+	}
+
+	if opened, err := recipient.Open(nil, ciphertext); err != nil {
+		return errors.Wrap(err, "open sealed message")
+	} else if !bytes.Equal(opened, plaintext) {
+		return errors.New("sealed message doesn't round trip")
+	}
+
+	return nil
+}
+
+// mustDecodeHex decodes the hexadecimal
+// vector, panicking if it is invalid
+func mustDecodeHex(value string) []byte {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		panic("ech: invalid test vector: " + err.Error())
+	}
+
+	return decoded
+}
@@ -0,0 +1,36 @@
+package ech
+
+import (
+	"testing"
+
+	esni "github.com/LiamHaworth/go-esni"
+)
+
+func TestSelfTestProvider(t *testing.T) {
+	if err := SelfTestProvider(DefaultHPKEProvider); err != nil {
+		t.Fatalf("self test failed: %v", err)
+	}
+}
+
+func TestSelfTestProviderDetectsCorruption(t *testing.T) {
+	vector := hpkeVectors[0]
+	vector.cipher[1] = vector.cipher[0]
+
+	if err := vector.run(DefaultHPKEProvider); err == nil {
+		t.Fatal("expected a replayed ciphertext to fail")
+	}
+}
+
+func TestSelfTestRunsHPKE(t *testing.T) {
+	if err := esni.SelfTest(); err != nil {
+		t.Fatalf("esni self test failed: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a duplicate registration to panic")
+		}
+	}()
+
+	esni.RegisterSelfTest("hpke", func() error { return nil })
+}
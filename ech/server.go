@@ -0,0 +1,137 @@
+package ech
+
+import (
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoECHExtension is returned by Open when
+	// the ClientHelloOuter has no encrypted_client_hello
+	// extension
+	ErrNoECHExtension = errors.New("client hello has no encrypted_client_hello extension")
+
+	// ErrConfigMismatch is returned by Open when the
+	// ClientHelloOuter was encrypted to a different
+	// config, the server should try its other configs
+	// or reject ECH
+	ErrConfigMismatch = errors.New("client hello was encrypted to a different config")
+)
+
+// Server holds the state of a single ECH handshake
+// on the server, it decrypts the ClientHelloInner
+// using the private key of the ECHConfig
+type Server struct {
+	config     *esni.ECHConfig
+	privateKey []byte
+	provider   HPKEProvider
+
+	suite   esni.HpkeSymmetricCipherSuite
+	context OpenContext
+}
+
+// NewServer returns a Server that decrypts using the
+// private key of the ECHConfig with DefaultHPKEProvider
+func NewServer(config *esni.ECHConfig, privateKey []byte) *Server {
+	return NewServerWithProvider(config, privateKey, DefaultHPKEProvider)
+}
+
+// NewServerWithProvider returns a Server that decrypts
+// using the private key of the ECHConfig with the
+// provided HPKE implementation
+func NewServerWithProvider(config *esni.ECHConfig, privateKey []byte, provider HPKEProvider) *Server {
+	return &Server{config: config, privateKey: privateKey, provider: provider}
+}
+
+// Open decrypts the ClientHelloInner carried by the
+// ClientHelloOuter, the first call sets up the HPKE
+// context from the enc of the extension and any call
+// following a HelloRetryRequest reuses it
+func (server *Server) Open(outer *ClientHello) (*ClientHello, error) {
+	data, ok := outer.Extension(ExtensionEncryptedClientHello)
+	if !ok {
+		return nil, ErrNoECHExtension
+	}
+
+	var ext ECHClientHello
+	if err := ext.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "unmarshal outer extension")
+	} else if ext.Type != ClientHelloTypeOuter {
+		return nil, errors.Errorf("client hello outer has an extension of type %s", ext.Type)
+	}
+
+	if ext.ConfigID != server.config.ConfigID {
+		return nil, ErrConfigMismatch
+	}
+
+	if server.context == nil {
+		if err := server.setup(ext); err != nil {
+			return nil, err
+		}
+	} else if len(ext.Enc) != 0 {
+		return nil, errors.New("second client hello has a non-empty enc")
+	} else if ext.CipherSuite != server.suite {
+		return nil, errors.New("second client hello changed cipher suite")
+	}
+
+	// The additional data is the ClientHelloOuter
+	// with the payload of the extension zeroed
+	zeroed := ext
+	zeroed.Payload = make([]byte, len(ext.Payload))
+
+	zeroedData, err := zeroed.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal outer extension")
+	}
+
+	aadHello := outer.Clone()
+	aadHello.SetExtension(ExtensionEncryptedClientHello, zeroedData)
+
+	aad, err := aadHello.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal client hello outer aad")
+	}
+
+	encoded, err := server.context.Open(aad, ext.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt client hello inner")
+	}
+
+	return DecodeClientHelloInner(encoded, outer)
+}
+
+// setup establishes the HPKE context from
+// the first ClientHelloOuter
+func (server *Server) setup(ext ECHClientHello) error {
+	rawConfig, err := server.config.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "marshal config")
+	}
+
+	hpkeSuite := HPKESuite{KEM: server.config.KemID, KDF: ext.CipherSuite.KDF, AEAD: ext.CipherSuite.AEAD}
+	if !containsSuite(server.config.CipherSuites, ext.CipherSuite) || !server.provider.Supports(hpkeSuite) {
+		return ErrNoSupportedCipherSuite
+	}
+
+	context, err := server.provider.SetupRecipient(hpkeSuite, ext.Enc, server.privateKey, append([]byte(infoPrefix), rawConfig...))
+	if err != nil {
+		return errors.Wrap(err, "setup hpke context")
+	}
+
+	server.suite = ext.CipherSuite
+	server.context = context
+
+	return nil
+}
+
+// containsSuite returns if the cipher
+// suite is present in the list
+func containsSuite(suites []esni.HpkeSymmetricCipherSuite, suite esni.HpkeSymmetricCipherSuite) bool {
+	for _, s := range suites {
+		if s == suite {
+			return true
+		}
+	}
+
+	return false
+}
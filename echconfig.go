@@ -0,0 +1,356 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// mandatoryECHExtensionMask is used in an
+	// AND bitwise operation to check if the
+	// highest bit of an ECH extension type is set
+	mandatoryECHExtensionMask uint16 = 0x8000
+)
+
+// ECHExtensionType represents the unique
+// identifier of an ECHConfig extension
+type ECHExtensionType uint16
+
+// Mandatory returns if a client must understand
+// the extension to use the ECHConfig, an extension
+// type is mandatory if the highest bit is set
+func (extType ECHExtensionType) Mandatory() bool {
+	return uint16(extType)&mandatoryECHExtensionMask == mandatoryECHExtensionMask
+}
+
+// ECHConfigExtension represents a single
+// extension included in an ECHConfig
+type ECHConfigExtension struct {
+	// Type specifies the type of the extension
+	Type ECHExtensionType
+
+	// Data specifies the opaque value of the
+	// extension
+	Data []byte
+}
+
+// HpkeSymmetricCipherSuite represents a
+// pairing of HPKE KDF and AEAD algorithms
+// supported by an ECHConfig
+type HpkeSymmetricCipherSuite struct {
+	// KDF specifies the HPKE KDF identifier
+	KDF HpkeKdfId
+
+	// AEAD specifies the HPKE AEAD identifier
+	AEAD HpkeAeadId
+}
+
+// String returns a friendly representation
+// of the cipher suite
+func (suite HpkeSymmetricCipherSuite) String() string {
+	return suite.KDF.String() + "/" + suite.AEAD.String()
+}
+
+// ECHConfig represents the HPKE based configuration
+// used by Encrypted Client Hello, the successor to
+// the ESNI Keys record.
+//
+// Only the layout introduced by draft-13 (version
+// 0xfe0d), which later drafts kept unchanged, is
+// supported. Configs of draft-07 to draft-12 use
+// earlier layouts, they are skipped when found in
+// an ECHConfigList and rejected otherwise.
+type ECHConfig struct {
+	// Version specifies the version of the
+	// ECHConfig structure
+	Version Version
+
+	// ConfigID specifies the identifier used by
+	// the server to select the config when
+	// decrypting a ClientHello
+	ConfigID uint8
+
+	// KemID specifies the HPKE KEM of the
+	// public key
+	KemID HpkeKemId
+
+	// PublicKey specifies the serialised HPKE
+	// public key of the server
+	PublicKey []byte
+
+	// CipherSuites specifies the HPKE symmetric
+	// cipher suites supported by the server
+	CipherSuites []HpkeSymmetricCipherSuite
+
+	// MaximumNameLength specifies the length of
+	// the longest name the server expects to
+	// receive, used by clients to compute padding
+	MaximumNameLength uint8
+
+	// PublicName specifies the clear text SNI
+	// used in the ClientHelloOuter
+	PublicName string
+
+	// Extensions specifies the extensions of
+	// the config
+	Extensions []ECHConfigExtension
+}
+
+// String returns a friendly representation
+// of the information stored in this structure
+func (config *ECHConfig) String() string {
+	var builder strings.Builder
+	builder.WriteString("{")
+
+	_, _ = fmt.Fprintf(&builder, "Version:%s, ", config.Version)
+	_, _ = fmt.Fprintf(&builder, "ConfigID:%d, ", config.ConfigID)
+	_, _ = fmt.Fprintf(&builder, "KemID:%s, ", config.KemID)
+	_, _ = fmt.Fprintf(&builder, "PublicKey:%s, ", hex.EncodeToString(config.PublicKey))
+	_, _ = fmt.Fprintf(&builder, "CipherSuites:%v, ", config.CipherSuites)
+	_, _ = fmt.Fprintf(&builder, "MaximumNameLength:%d, ", config.MaximumNameLength)
+	_, _ = fmt.Fprintf(&builder, "PublicName:%s, ", config.PublicName)
+	_, _ = fmt.Fprintf(&builder, "Extensions:%d", len(config.Extensions))
+
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// MarshalBinary will marshal the ECHConfig into
+// its binary format, the version followed by the
+// length prefixed contents of the config
+func (config ECHConfig) MarshalBinary() ([]byte, error) {
+	if config.Version != VersionDraft13 {
+		return nil, unsupportedVersionError(config.Version)
+	}
+
+	contents, err := config.marshalContents()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > 0xffff {
+		return nil, errors.New("config contents are too large")
+	}
+
+	data := make([]byte, 4+len(contents))
+	binary.BigEndian.PutUint16(data[0:], uint16(config.Version))
+	binary.BigEndian.PutUint16(data[2:], uint16(len(contents)))
+	copy(data[4:], contents)
+
+	return data, nil
+}
+
+// marshalContents will marshal the fields of the
+// config into the ECHConfigContents structure
+func (config ECHConfig) marshalContents() ([]byte, error) {
+	var data bytes.Buffer
+
+	data.WriteByte(config.ConfigID)
+	_ = binary.Write(&data, binary.BigEndian, config.KemID)
+
+	if len(config.PublicKey) == 0 {
+		return nil, errors.New("public key is empty")
+	}
+
+	if err := writeVector16(&data, config.PublicKey); err != nil {
+		return nil, errors.Wrap(err, "write public key")
+	}
+
+	if len(config.CipherSuites) == 0 {
+		return nil, errors.New("cipher suite list is empty")
+	}
+
+	suites := make([]byte, 0, len(config.CipherSuites)*4)
+	for _, suite := range config.CipherSuites {
+		suites = append(suites, byte(suite.KDF>>8), byte(suite.KDF), byte(suite.AEAD>>8), byte(suite.AEAD))
+	}
+
+	if err := writeVector16(&data, suites); err != nil {
+		return nil, errors.Wrap(err, "write cipher suite list")
+	}
+
+	data.WriteByte(config.MaximumNameLength)
+
+	if err := ValidatePublicName(config.PublicName); err != nil {
+		return nil, err
+	}
+
+	data.WriteByte(uint8(len(config.PublicName)))
+	data.WriteString(config.PublicName)
+
+	var extensions bytes.Buffer
+	for _, ext := range config.Extensions {
+		_ = binary.Write(&extensions, binary.BigEndian, ext.Type)
+		if err := writeVector16(&extensions, ext.Data); err != nil {
+			return nil, errors.Wrapf(err, "write extension 0x%04x", uint16(ext.Type))
+		}
+	}
+
+	if err := writeVector16(&data, extensions.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "write extensions list")
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal an
+// ECHConfig from the provided binary data
+func (config *ECHConfig) UnmarshalBinary(data []byte) error {
+	_, err := config.unmarshal(data)
+	return err
+}
+
+// unmarshal will attempt to unmarshal a single
+// ECHConfig from the start of the binary data,
+// returning the number of bytes occupied by it.
+//
+// The contents of a config with an unsupported
+// version are treated as opaque, the number of
+// bytes they occupy is returned along with an
+// error whose cause is ErrUnsupportedVersion so
+// the config can be skipped.
+func (config *ECHConfig) unmarshal(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for version and length")
+	}
+
+	config.Version = Version(binary.BigEndian.Uint16(data[0:]))
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < 4+length {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for config contents")
+	}
+
+	if config.Version != VersionDraft13 {
+		return 4 + length, unsupportedVersionError(config.Version)
+	}
+
+	reader := bytes.NewReader(data[4 : 4+length])
+	if err := config.unmarshalContents(reader); err != nil {
+		return 0, err
+	}
+
+	if reader.Len() != 0 {
+		return 0, errors.Errorf("%d bytes of unexpected data in config contents", reader.Len())
+	}
+
+	return 4 + length, nil
+}
+
+// unmarshalContents will read the fields of the
+// config from the ECHConfigContents structure
+func (config *ECHConfig) unmarshalContents(reader *bytes.Reader) error {
+	var err error
+
+	if config.ConfigID, err = reader.ReadByte(); err != nil {
+		return errors.Wrap(err, "read config id")
+	}
+
+	if err := binary.Read(reader, binary.BigEndian, &config.KemID); err != nil {
+		return errors.Wrap(err, "read kem id")
+	}
+
+	if config.PublicKey, err = readVector16(reader); err != nil {
+		return errors.Wrap(err, "read public key")
+	} else if len(config.PublicKey) == 0 {
+		return errors.New("public key is empty")
+	}
+
+	suites, err := readVector16(reader)
+	if err != nil {
+		return errors.Wrap(err, "read cipher suite list")
+	} else if len(suites) == 0 || len(suites)%4 != 0 {
+		return errors.New("invalid cipher suite list size")
+	}
+
+	config.CipherSuites = make([]HpkeSymmetricCipherSuite, len(suites)/4)
+	for i := range config.CipherSuites {
+		config.CipherSuites[i] = HpkeSymmetricCipherSuite{
+			KDF:  HpkeKdfId(binary.BigEndian.Uint16(suites[i*4:])),
+			AEAD: HpkeAeadId(binary.BigEndian.Uint16(suites[i*4+2:])),
+		}
+	}
+
+	if config.MaximumNameLength, err = reader.ReadByte(); err != nil {
+		return errors.Wrap(err, "read maximum name length")
+	}
+
+	nameLength, err := reader.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "read public name length")
+	} else if nameLength == 0 {
+		return errors.New("public name is empty")
+	}
+
+	if err := checkLength(reader, int(nameLength)); err != nil {
+		return errors.Wrap(err, "read public name")
+	}
+
+	name := make([]byte, nameLength)
+	_, _ = io.ReadFull(reader, name)
+	config.PublicName = string(name)
+
+	extensions, err := readVector16(reader)
+	if err != nil {
+		return errors.Wrap(err, "read extensions list")
+	}
+
+	config.Extensions = nil
+	for pos := 0; pos < len(extensions); {
+		if len(extensions[pos:]) < 4 {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for extension header")
+		}
+
+		extType := ECHExtensionType(binary.BigEndian.Uint16(extensions[pos:]))
+		extLen := int(binary.BigEndian.Uint16(extensions[pos+2:]))
+
+		if len(extensions[pos+4:]) < extLen {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for extension data")
+		}
+
+		extData := make([]byte, extLen)
+		copy(extData, extensions[pos+4:])
+
+		config.Extensions = append(config.Extensions, ECHConfigExtension{Type: extType, Data: extData})
+		pos += 4 + extLen
+	}
+
+	return nil
+}
+
+// writeVector16 will write the data to the
+// buffer prefixed with its length as a uint16
+func writeVector16(buffer *bytes.Buffer, data []byte) error {
+	if len(data) > 0xffff {
+		return errors.New("vector is too large")
+	}
+
+	_ = binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.Write(data)
+
+	return nil
+}
+
+// readVector16 will read a vector prefixed with
+// its length as a uint16 from the reader
+func readVector16(reader *bytes.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	if err := checkLength(reader, int(length)); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	_, _ = io.ReadFull(reader, data)
+
+	return data, nil
+}
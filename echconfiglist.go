@@ -0,0 +1,93 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ECHConfigList represents the list of ECHConfig
+// structures published by a server, such as in
+// the "ech" parameter of an HTTPS record
+type ECHConfigList []ECHConfig
+
+// MarshalBinary will marshal the list into its
+// binary format, the configs prefixed with the
+// total length of the list
+func (list ECHConfigList) MarshalBinary() ([]byte, error) {
+	var configs bytes.Buffer
+
+	for i := range list {
+		data, err := list[i].MarshalBinary()
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal config %d", i)
+		}
+
+		configs.Write(data)
+	}
+
+	var data bytes.Buffer
+	if err := writeVector16(&data, configs.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "write config list")
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// list from the provided binary data, configs with
+// a version that isn't supported, such as those of
+// draft-07 to draft-12, are skipped as required by
+// the specification which means the resulting list
+// may be empty
+func (list *ECHConfigList) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for list length")
+	}
+
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) != 2+length {
+		return errors.Errorf("list length %d does not match buffer size %d", length, len(data)-2)
+	}
+
+	configs := data[2:]
+	*list = nil
+
+	for pos := 0; pos < len(configs); {
+		var config ECHConfig
+
+		n, err := config.unmarshal(configs[pos:])
+		switch {
+		case errors.Cause(err) == ErrUnsupportedVersion:
+			pos += n
+			continue
+
+		case err != nil:
+			return errors.Wrapf(err, "unmarshal config at offset %d", pos)
+		}
+
+		*list = append(*list, config)
+		pos += n
+	}
+
+	return nil
+}
+
+// ParseECHConfigList will attempt to parse an
+// ECHConfigList from the provided binary data,
+// an error is returned if the list contains no
+// configs with a supported version
+func ParseECHConfigList(data []byte) (ECHConfigList, error) {
+	var list ECHConfigList
+	if err := list.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, errors.Wrap(ErrUnsupportedVersion, "no configs with a supported version")
+	}
+
+	return list, nil
+}
@@ -0,0 +1,60 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// opaqueECHConfig returns an encoded ECHConfig of
+// the version with arbitrary contents
+func opaqueECHConfig(version Version, contents []byte) []byte {
+	data := make([]byte, 4, 4+len(contents))
+	binary.BigEndian.PutUint16(data[0:], uint16(version))
+	binary.BigEndian.PutUint16(data[2:], uint16(len(contents)))
+	return append(data, contents...)
+}
+
+func TestECHConfigListSkipsUnsupportedVersions(t *testing.T) {
+	config := ECHConfig{
+		Version:      VersionDraft13,
+		ConfigID:     7,
+		KemID:        HpkeKemId_DHKEM_X25519_HKDF_SHA256,
+		PublicKey:    bytes.Repeat([]byte{0x42}, 32),
+		CipherSuites: []HpkeSymmetricCipherSuite{{KDF: HpkeKdfId_HKDF_SHA256, AEAD: HpkeAeadId_AES_128_GCM}},
+		PublicName:   "public.example.com",
+	}
+
+	supported, err := config.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal config: %s", err)
+	}
+
+	var configs []byte
+	configs = append(configs, opaqueECHConfig(0xff07, []byte("draft-07 contents"))...)
+	configs = append(configs, supported...)
+	configs = append(configs, opaqueECHConfig(0xfe0a, []byte("draft-10 contents"))...)
+
+	data := make([]byte, 2, 2+len(configs))
+	binary.BigEndian.PutUint16(data, uint16(len(configs)))
+	data = append(data, configs...)
+
+	list, err := ParseECHConfigList(data)
+	if err != nil {
+		t.Fatalf("ParseECHConfigList() error = %s", err)
+	}
+
+	if len(list) != 1 || list[0].ConfigID != config.ConfigID || list[0].PublicName != config.PublicName {
+		t.Fatalf("ParseECHConfigList() = %v, want only the draft-13 config", list)
+	}
+
+	if _, err := ParseECHConfigList([]byte{0x00, 0x06, 0xff, 0x07, 0x00, 0x02, 0x00, 0x00}); errors.Cause(err) != ErrUnsupportedVersion {
+		t.Errorf("ParseECHConfigList() of unsupported configs error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+
+	if _, err := ParseECHConfigList([]byte{0x00, 0x06, 0xff, 0x07, 0x00, 0x04, 0x00, 0x00}); errors.Cause(err) == ErrUnsupportedVersion || err == nil {
+		t.Errorf("ParseECHConfigList() of truncated config error = %v, want a length error", err)
+	}
+}
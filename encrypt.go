@@ -0,0 +1,187 @@
+package esni
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// EncryptSNI encrypts the server name using the Keys
+// record, producing the ClientEncryptedSNI to be sent in
+// the encrypted_server_name extension of the ClientHello.
+// An internationalized server name is converted to its
+// ASCII form before it is encrypted.
+//
+// The client random is the random value of the ClientHello
+// and the client key share is the entry sent in its key_share
+// extension, which is authenticated as the additional data
+// of the encryption. The ClientESNIInner that was encrypted
+// is returned so that its nonce can be matched against the
+// echo from the server.
+func EncryptSNI(keys *Keys, serverName string, clientRandom []byte, clientKeyShare KeyShareEntry) (*ClientEncryptedSNI, *ClientESNIInner, error) {
+	return EncryptSNIFromReader(keys, serverName, clientRandom, clientKeyShare, rand.Reader)
+}
+
+// EncryptSNIFromReader encrypts the server name in the
+// same manner as EncryptSNI, reading the ephemeral key
+// and the nonce from the random source
+func EncryptSNIFromReader(keys *Keys, serverName string, clientRandom []byte, clientKeyShare KeyShareEntry, random io.Reader) (*ClientEncryptedSNI, *ClientESNIInner, error) {
+	return EncryptSNIWithOptions(keys, serverName, clientRandom, clientKeyShare, EncryptOptions{Rand: random})
+}
+
+// EncryptOptions specifies the options
+// used when encrypting an SNI
+type EncryptOptions struct {
+	// Rand specifies the source of the ephemeral
+	// key and the nonce, if nil crypto/rand
+	// is used
+	Rand io.Reader
+
+	// KeySchedule specifies the options
+	// of the key schedule
+	KeySchedule KeyScheduleOptions
+
+	// DisableIDNA specifies if an internationalized
+	// server name is encrypted exactly as provided
+	// rather than converted to its ASCII form
+	DisableIDNA bool
+}
+
+// EncryptSNIWithOptions encrypts the server name
+// in the same manner as EncryptSNI using the
+// provided options
+func EncryptSNIWithOptions(keys *Keys, serverName string, clientRandom []byte, clientKeyShare KeyShareEntry, opts EncryptOptions) (*ClientEncryptedSNI, *ClientESNIInner, error) {
+	random := opts.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+
+	if len(clientRandom) != 32 {
+		return nil, nil, errors.New("client random must be 32 bytes")
+	}
+
+	if !opts.DisableIDNA {
+		name, err := ToASCIIName(serverName)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "server name")
+		}
+
+		serverName = name
+	}
+
+	suite, ok := selectCipherSuite(keys.CipherSuites)
+	if !ok {
+		return nil, nil, errors.New("record has no supported cipher suite")
+	}
+
+	serverShare, ok := selectKeyShare(keys.Keys)
+	if !ok {
+		return nil, nil, errors.Wrap(ErrUnsupportedGroup, "record has no supported key share")
+	}
+
+	publicKey, privateKey, err := serverShare.Group.NewKeyPairFromReader(random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sharedSecret, err := DeriveSharedSecret(serverShare.Group, privateKey, serverShare.KeyExchange)
+	Zeroize(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer Zeroize(sharedSecret)
+
+	digest, err := keys.RecordDigest(suite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encrypted := &ClientEncryptedSNI{
+		Suite:        suite,
+		KeyShare:     KeyShareEntry{Group: serverShare.Group, KeyExchange: publicKey},
+		RecordDigest: digest,
+	}
+
+	contents := ESNIContents{RecordDigest: digest, KeyShare: encrypted.KeyShare}
+	copy(contents.ClientHelloRandom[:], clientRandom)
+
+	key, iv, err := DeriveSNIKeyWithOptions(suite, sharedSecret, contents, opts.KeySchedule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer Zeroize(key, iv)
+
+	aead, err := suite.NewAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inner, err := NewClientESNIInnerFromReader(serverName, keys.PaddedLength, random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := inner.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer Zeroize(plaintext)
+
+	aad, err := marshalKeyShareClientHello(clientKeyShare)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encrypted.EncryptedSNI = aead.Seal(nil, iv, plaintext, aad)
+
+	return encrypted, inner, nil
+}
+
+// selectCipherSuite returns the first cipher
+// suite of the list that is supported
+func selectCipherSuite(suites []CipherSuite) (CipherSuite, bool) {
+	for _, suite := range suites {
+		if suite.Supported() {
+			return suite, true
+		}
+	}
+
+	return 0, false
+}
+
+// selectKeyShare returns the first key share
+// entry of the list whose group supports both
+// key generation and key exchange
+func selectKeyShare(list KeyShareEntryList) (KeyShareEntry, bool) {
+	for _, entry := range list {
+		_, canGenerate := Group_keyPairGenerator[entry.Group]
+		_, canExchange := Group_sharedSecret[entry.Group]
+
+		if canGenerate && canExchange {
+			return entry, true
+		}
+	}
+
+	return KeyShareEntry{}, false
+}
+
+// marshalKeyShareClientHello returns the body of
+// a key_share extension containing the entries
+func marshalKeyShareClientHello(entries ...KeyShareEntry) ([]byte, error) {
+	list, err := KeyShareEntryList(entries).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	if err := writeVector16(&data, list); err != nil {
+		return nil, errors.Wrap(err, "write key share list")
+	}
+
+	return data.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package esni
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerName specifies the server name
+// encrypted by the round-trip tests
+const testServerName = "secret.example.com"
+
+// sniTest holds a record along with an SNI
+// encrypted for it and the ClientHello values
+// that authenticate the encryption
+type sniTest struct {
+	keys        *Keys
+	privateKeys *PrivateKeys
+	encrypted   *ClientEncryptedSNI
+	inner       *ClientESNIInner
+	random      []byte
+	keyShare    []byte
+}
+
+// newSNITest builds a record for the cipher suite
+// and encrypts testServerName for it, the ephemeral
+// key and nonce are read from a seeded source
+func newSNITest(t *testing.T, suite CipherSuite) *sniTest {
+	t.Helper()
+
+	keys, privateKeys, err := NewKeysBuilder().
+		PublicName("public.example.com").
+		Lifetime(time.Hour).
+		AddGroup(GroupX25519).
+		AddCipherSuite(suite).
+		BuildWithPrivateKeys()
+	if err != nil {
+		t.Fatalf("build keys: %s", err)
+	}
+
+	clientShare := KeyShareEntry{Group: GroupX25519, KeyExchange: bytes.Repeat([]byte{0x09}, 32)}
+	keyShare, err := marshalKeyShareClientHello(clientShare)
+	if err != nil {
+		t.Fatalf("marshal key share: %s", err)
+	}
+
+	random := bytes.Repeat([]byte{0x5a}, 32)
+	encrypted, inner, err := EncryptSNIFromReader(keys, testServerName, random, clientShare, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("EncryptSNIFromReader() error = %v", err)
+	}
+
+	return &sniTest{keys: keys, privateKeys: privateKeys, encrypted: encrypted, inner: inner, random: random, keyShare: keyShare}
+}
+
+// supportedCipherSuites returns each cipher
+// suite an SNI can be encrypted with
+func supportedCipherSuites() []CipherSuite {
+	var suites []CipherSuite
+	for suite := range CipherSuite_name {
+		if suite.Supported() {
+			suites = append(suites, suite)
+		}
+	}
+
+	return suites
+}
+
+func TestEncryptSNIFromReader(t *testing.T) {
+	for _, suite := range supportedCipherSuites() {
+		t.Run(suite.String(), func(t *testing.T) {
+			test := newSNITest(t, suite)
+
+			digest, err := test.keys.RecordDigest(suite)
+			if err != nil {
+				t.Fatalf("RecordDigest() error = %v", err)
+			}
+
+			if !bytes.Equal(test.encrypted.RecordDigest, digest) {
+				t.Errorf("RecordDigest = %x, want %x", test.encrypted.RecordDigest, digest)
+			}
+
+			aead, err := suite.NewAEAD(make([]byte, suite.KeyLen()))
+			if err != nil {
+				t.Fatalf("construct aead: %s", err)
+			}
+
+			if want := NonceSize + int(test.keys.PaddedLength) + aead.Overhead(); len(test.encrypted.EncryptedSNI) != want {
+				t.Errorf("encrypted sni is %d bytes, want %d", len(test.encrypted.EncryptedSNI), want)
+			}
+
+			again, _, err := EncryptSNIFromReader(test.keys, testServerName, test.random, KeyShareEntry{Group: GroupX25519, KeyExchange: bytes.Repeat([]byte{0x09}, 32)}, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatalf("EncryptSNIFromReader() error = %v", err)
+			}
+
+			if !bytes.Equal(again.EncryptedSNI, test.encrypted.EncryptedSNI) {
+				t.Error("encryptions from the same random source differ")
+			}
+		})
+	}
+}
+
+func TestEncryptSNIRejects(t *testing.T) {
+	test := newSNITest(t, CipherSuite_TLS_AES_128_GCM_SHA256)
+	share := KeyShareEntry{Group: GroupX25519, KeyExchange: bytes.Repeat([]byte{0x09}, 32)}
+
+	tests := map[string]struct {
+		serverName string
+		random     []byte
+	}{
+		"short client random": {serverName: testServerName, random: test.random[:31]},
+		"empty server name":   {serverName: "", random: test.random},
+		"exceeds padding":     {serverName: strings.Repeat("a", int(test.keys.PaddedLength)), random: test.random},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := EncryptOptions{Rand: rand.New(rand.NewSource(1)), DisableIDNA: true}
+			if _, _, err := EncryptSNIWithOptions(test.keys, tt.serverName, tt.random, share, opts); err == nil {
+				t.Fatal("EncryptSNIWithOptions() succeeded, want error")
+			}
+		})
+	}
+}
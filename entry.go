@@ -141,7 +141,7 @@ func (list *KeyShareEntryList) UnmarshalBinary(data []byte) error {
 			return errors.New("duplicate key share group")
 		}
 
-		pos += int(entry.Size()) + 1
+		pos += int(entry.Size())
 		*list = append(*list, entry)
 	}
 
@@ -0,0 +1,252 @@
+package esni
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ServerESNIResponseType represents the type of the
+// response sent by the server in the encrypted_server_name
+// extension from the fourth draft of the specification
+type ServerESNIResponseType uint8
+
+const (
+	// ServerESNIResponseAccept specifies the server
+	// decrypted the SNI and echoes the nonce
+	ServerESNIResponseAccept ServerESNIResponseType = 0
+
+	// ServerESNIResponseRetryRequest specifies the
+	// server couldn't decrypt the SNI and supplies
+	// the keys the client should retry with
+	ServerESNIResponseRetryRequest ServerESNIResponseType = 1
+)
+
+// ServerESNIResponseType_name specifies a map of
+// response types and their string representations
+var ServerESNIResponseType_name = map[ServerESNIResponseType]string{
+	ServerESNIResponseAccept:       "esni_accept",
+	ServerESNIResponseRetryRequest: "esni_retry_request",
+}
+
+// String returns the string representation
+// of the response type
+func (responseType ServerESNIResponseType) String() string {
+	if name, ok := ServerESNIResponseType_name[responseType]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// ServerESNIResponse represents the body of the
+// encrypted_server_name extension sent by the server
+// from the fourth draft of the specification, which
+// either echoes the nonce or requests the client
+// retry with fresh keys
+type ServerESNIResponse struct {
+	// ResponseType specifies the
+	// type of the response
+	ResponseType ServerESNIResponseType
+
+	// Nonce specifies the nonce of the
+	// ClientESNIInner, it is only set for
+	// an esni_accept response
+	Nonce [NonceSize]byte
+
+	// RetryKeys specifies the Keys records the client
+	// should retry with, it is only set for an
+	// esni_retry_request response
+	RetryKeys []*Keys
+}
+
+// MarshalBinary will marshal the ServerESNIResponse
+// into the binary format used as the body of the
+// encrypted_server_name extension
+func (response ServerESNIResponse) MarshalBinary() ([]byte, error) {
+	data := bytes.NewBuffer([]byte{byte(response.ResponseType)})
+
+	switch response.ResponseType {
+	case ServerESNIResponseAccept:
+		data.Write(response.Nonce[:])
+
+	case ServerESNIResponseRetryRequest:
+		if len(response.RetryKeys) == 0 {
+			return nil, errors.New("retry request has no keys")
+		}
+
+		var retryKeys []byte
+		for i := range response.RetryKeys {
+			raw, err := response.RetryKeys[i].MarshalBinary()
+			if err != nil {
+				return nil, errors.Wrapf(err, "marshal retry keys %d", i)
+			}
+
+			retryKeys = append(retryKeys, raw...)
+		}
+
+		if len(retryKeys) > 0xFFFF {
+			return nil, errors.New("retry keys too long")
+		}
+
+		binary.Write(data, binary.BigEndian, uint16(len(retryKeys)))
+		data.Write(retryKeys)
+
+	default:
+		return nil, errors.Errorf("unsupported response type %s", response.ResponseType)
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ServerESNIResponse from the body of an
+// encrypted_server_name extension
+func (response *ServerESNIResponse) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("missing response type")
+	}
+
+	response.ResponseType = ServerESNIResponseType(data[0])
+	data = data[1:]
+
+	switch response.ResponseType {
+	case ServerESNIResponseAccept:
+		if len(data) != NonceSize {
+			return errors.Errorf("invalid nonce length of %d", len(data))
+		}
+
+		copy(response.Nonce[:], data)
+
+	case ServerESNIResponseRetryRequest:
+		if len(data) < 2 || int(binary.BigEndian.Uint16(data)) != len(data)-2 || len(data) == 2 {
+			return errors.New("invalid retry keys length")
+		}
+
+		response.RetryKeys = nil
+		for data = data[2:]; len(data) > 0; {
+			keys := new(Keys)
+
+			n, err := keys.Decode(data)
+			if err != nil {
+				return errors.Wrapf(err, "unmarshal retry keys %d", len(response.RetryKeys))
+			}
+
+			response.RetryKeys = append(response.RetryKeys, keys)
+			data = data[n:]
+		}
+
+	default:
+		return errors.Errorf("unsupported response type %s", response.ResponseType)
+	}
+
+	return nil
+}
+
+// RetryRequestedError is returned when the server
+// couldn't decrypt the SNI because the keys used by
+// the client were stale, it carries the fresh keys
+// supplied by the server so the client can retry
+// the handshake
+type RetryRequestedError struct {
+	// RetryKeys specifies the Keys records
+	// supplied by the server
+	RetryKeys []*Keys
+}
+
+// Error returns a description of the error
+func (err *RetryRequestedError) Error() string {
+	return fmt.Sprintf("server requested retry with %d fresh keys", len(err.RetryKeys))
+}
+
+// Keys returns the first of the retry keys that is
+// valid as of the evaluation context and has a cipher
+// suite supported by the package, nil is returned if
+// none of the keys are usable
+func (err *RetryRequestedError) Keys(ectx *EvalContext) *Keys {
+	for _, keys := range err.RetryKeys {
+		if !keys.Valid(ectx) {
+			continue
+		}
+
+		for _, suite := range keys.CipherSuites {
+			if suite.Supported() {
+				return keys
+			}
+		}
+	}
+
+	return nil
+}
+
+// VerifyServerESNIResponse parses the encrypted_server_name
+// extension from the server's EncryptedExtensions, for an
+// esni_accept response the echoed nonce is checked against
+// the ClientESNIInner sent by the client, for an
+// esni_retry_request response a *RetryRequestedError
+// carrying the fresh keys is returned
+func VerifyServerESNIResponse(inner *ClientESNIInner, extensionData []byte) error {
+	var response ServerESNIResponse
+	if err := response.UnmarshalBinary(extensionData); err != nil {
+		return errors.Wrap(err, "unmarshal server esni response")
+	}
+
+	if response.ResponseType == ServerESNIResponseRetryRequest {
+		return &RetryRequestedError{RetryKeys: response.RetryKeys}
+	}
+
+	if !inner.MatchNonce(response.Nonce[:]) {
+		return ErrNonceMismatch
+	}
+
+	return nil
+}
+
+// KeysFetcher is called to fetch the
+// Keys record for a handshake
+type KeysFetcher func(ctx context.Context) (*Keys, error)
+
+// HandshakeFunc is called to perform a handshake
+// using the Keys record, it returns a
+// *RetryRequestedError if the server
+// requested a retry
+type HandshakeFunc func(ctx context.Context, keys *Keys) error
+
+// RetryHandshake drives the fetch-retry loop, fetching
+// the keys and performing the handshake, when the server
+// requests a retry the handshake is repeated with the
+// fresh keys it supplied, or with freshly fetched keys
+// if none of them are usable.
+//
+// The retry is detected through errors.Cause, so the
+// handshake may wrap the *RetryRequestedError. At most
+// maxRetries retries are attempted, after which the
+// error of the last handshake is returned. The
+// evaluation context is taken from the context
+// when selecting the retry keys.
+func RetryHandshake(ctx context.Context, fetch KeysFetcher, handshake HandshakeFunc, maxRetries int) error {
+	keys, err := fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch keys")
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = handshake(ctx, keys)
+
+		retry, ok := errors.Cause(err).(*RetryRequestedError)
+		if !ok || attempt >= maxRetries {
+			return err
+		}
+
+		if keys = retry.Keys(EvalContextFrom(ctx)); keys != nil {
+			continue
+		}
+
+		if keys, err = fetch(ctx); err != nil {
+			return errors.Wrap(err, "fetch keys")
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package esni
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newTestKeys returns a draft-03 Keys record with
+// a freshly generated X25519 key share that is
+// valid for an hour from now
+func newTestKeys(t *testing.T) *Keys {
+	t.Helper()
+
+	publicKey, _, err := Group(GroupX25519).NewKeyPair()
+	if err != nil {
+		t.Fatalf("generate key pair: %s", err)
+	}
+
+	keys, err := NewKeysBuilder().
+		PublicName("public.example.com").
+		Lifetime(time.Hour).
+		AddKeyShare(KeyShareEntry{Group: GroupX25519, KeyExchange: publicKey}).
+		Build()
+	if err != nil {
+		t.Fatalf("build keys: %s", err)
+	}
+
+	return keys
+}
+
+func TestRetryHandshake(t *testing.T) {
+	stale, fresh := newTestKeys(t), newTestKeys(t)
+	fatal := errors.New("handshake failed")
+
+	tests := []struct {
+		name     string
+		retryErr func(*RetryRequestedError) error
+		wantErr  error
+		wantKeys *Keys
+	}{
+		{
+			name:     "retry error",
+			retryErr: func(err *RetryRequestedError) error { return err },
+			wantKeys: fresh,
+		},
+		{
+			name:     "wrapped retry error",
+			retryErr: func(err *RetryRequestedError) error { return errors.Wrap(err, "tls handshake") },
+			wantKeys: fresh,
+		},
+		{
+			name:     "fatal error",
+			retryErr: func(*RetryRequestedError) error { return fatal },
+			wantErr:  fatal,
+			wantKeys: stale,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var used []*Keys
+
+			fetch := func(context.Context) (*Keys, error) { return stale, nil }
+			handshake := func(ctx context.Context, keys *Keys) error {
+				used = append(used, keys)
+				if keys == stale {
+					return test.retryErr(&RetryRequestedError{RetryKeys: []*Keys{fresh}})
+				}
+
+				return nil
+			}
+
+			err := RetryHandshake(context.Background(), fetch, handshake, 1)
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("RetryHandshake() error = %v, want %v", err, test.wantErr)
+			}
+
+			if last := used[len(used)-1]; last != test.wantKeys {
+				t.Errorf("last handshake used %p, want %p", last, test.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package esni
+
+import (
+	"context"
+	"time"
+)
+
+// evalContextKey is the key used to store
+// an EvalContext within a context.Context
+type evalContextKey struct{}
+
+// EvalContext carries the parameters used when
+// evaluating time dependent properties of ESNI
+// records, such as the validity period of a Keys
+// record.
+//
+// A nil or zero EvalContext evaluates against the
+// current clock, setting AsOf allows archived records
+// to be analysed as they would have been at the time
+// they were fetched.
+type EvalContext struct {
+	// AsOf specifies the point in time that
+	// records should be evaluated at, if zero
+	// the current time is used
+	AsOf time.Time
+}
+
+// NewEvalContext returns a new EvalContext
+// that evaluates records as of the provided
+// point in time
+func NewEvalContext(asOf time.Time) *EvalContext {
+	return &EvalContext{AsOf: asOf}
+}
+
+// Now returns the time that records should
+// be evaluated at, if the context is nil or
+// no as-of time has been set the current time
+// is returned
+func (ectx *EvalContext) Now() time.Time {
+	if ectx == nil || ectx.AsOf.IsZero() {
+		return time.Now()
+	}
+
+	return ectx.AsOf
+}
+
+// WithEvalContext returns a copy of the parent
+// context carrying the provided EvalContext, allowing
+// for the as-of time to be threaded through APIs that
+// accept a context.Context
+func WithEvalContext(parent context.Context, ectx *EvalContext) context.Context {
+	return context.WithValue(parent, evalContextKey{}, ectx)
+}
+
+// EvalContextFrom returns the EvalContext stored
+// in the provided context, if no EvalContext has
+// been stored nil is returned which evaluates against
+// the current clock
+func EvalContextFrom(ctx context.Context) *EvalContext {
+	if ctx == nil {
+		return nil
+	}
+
+	ectx, _ := ctx.Value(evalContextKey{}).(*EvalContext)
+	return ectx
+}
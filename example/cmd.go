@@ -1,10 +1,9 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/hex"
 	"fmt"
-	"net"
 	"os"
 
 	esni "github.com/LiamHaworth/go-esni"
@@ -20,30 +19,24 @@ func main() {
 		return
 	}
 
-	records, err := net.LookupTXT(fmt.Sprintf("_esni.%s", os.Args[1]))
-	if err != nil {
+	records, skipped, err := esni.FetchKeys(context.Background(), os.Args[1])
+	if err != nil && err != esni.ErrNoKeys {
 		panic(err)
 	}
 
 	fmt.Printf("Target Domain: %s\n", os.Args[1])
 	fmt.Println()
 
+	for i := range skipped {
+		fmt.Printf("ERROR: Skipped %s\n", skipped[i])
+	}
+
 	for i := range records {
-		fmt.Printf("----------- ESNI Record %d\n", i)
-		data, err := base64.StdEncoding.DecodeString(records[i])
-		if err != nil {
-			fmt.Printf("ERROR: Decode record data: %s\n", err)
-			continue
-		}
-
-		fmt.Print(hex.Dump(data))
+		fmt.Printf("----------- ESNI Record %d\n", records[i].Source.Index)
+		fmt.Print(hex.Dump(records[i].Source.Raw))
 		fmt.Println()
 
-		key := new(esni.Keys)
-		if err := key.UnmarshalBinary(data); err != nil {
-			fmt.Printf("ERROR: Unmarshal record data: %s\n", err)
-			continue
-		}
+		key := records[i].Keys
 
 		fmt.Println("Version.............:", key.Version)
 		fmt.Println("Checksum............:", hex.EncodeToString(key.Checksum[:]))
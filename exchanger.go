@@ -0,0 +1,64 @@
+package esni
+
+import (
+	"crypto/ecdh"
+)
+
+// KeyExchanger represents the private half of a key
+// share entry that is able to perform a key exchange
+// without exposing the private key, allowing keys held
+// in an HSM, TPM or cloud KMS to decrypt SNIs
+type KeyExchanger interface {
+	// KeyShare returns the key share entry
+	// of the public half of the key
+	KeyShare() KeyShareEntry
+
+	// ECDH performs the key exchange with the key
+	// exchange value of the peer's public key,
+	// returning the shared secret
+	ECDH(peerPublic []byte) ([]byte, error)
+}
+
+// KeyShare returns the key share entry
+// the private key belongs to
+func (entry PrivateKeyEntry) KeyShare() KeyShareEntry {
+	return entry.KeyShareEntry
+}
+
+// ECDH performs the key exchange for the group of
+// the entry using the function registered in
+// Group_sharedSecret
+func (entry PrivateKeyEntry) ECDH(peerPublic []byte) ([]byte, error) {
+	return DeriveSharedSecret(entry.Group, entry.PrivateKey, peerPublic)
+}
+
+// ecdhKeyExchanger implements a KeyExchanger
+// for a crypto/ecdh private key
+type ecdhKeyExchanger struct {
+	privateKey *ecdh.PrivateKey
+	share      KeyShareEntry
+}
+
+// NewECDHKeyExchanger returns a KeyExchanger that
+// performs the key exchange with the crypto/ecdh
+// private key
+func NewECDHKeyExchanger(privateKey *ecdh.PrivateKey) (KeyExchanger, error) {
+	share, err := NewKeyShareEntry(privateKey.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecdhKeyExchanger{privateKey: privateKey, share: share}, nil
+}
+
+// KeyShare returns the key share entry
+// of the public key
+func (exchanger *ecdhKeyExchanger) KeyShare() KeyShareEntry {
+	return exchanger.share
+}
+
+// ECDH performs the key exchange with the
+// peer's public key
+func (exchanger *ecdhKeyExchanger) ECDH(peerPublic []byte) ([]byte, error) {
+	return DeriveSharedSecretECDH(exchanger.privateKey, peerPublic)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -147,7 +148,7 @@ func (list ExtensionList) String() string {
 // format would produce
 func (list ExtensionList) Size() (size uint16) {
 	for i := range list {
-		size += 2
+		size += 4
 		size += list[i].Size()
 	}
 
@@ -156,10 +157,10 @@ func (list ExtensionList) Size() (size uint16) {
 
 // MarshalBinary marshals the list of ESNI
 // extensions into a binary format of each
-// extension type and their respective marshaled
-// format
+// extension type followed by the length and
+// value of their respective marshaled format
 func (list ExtensionList) MarshalBinary() ([]byte, error) {
-	buffer := bytes.NewBuffer(make([]byte, list.Size()))
+	buffer := bytes.NewBuffer(make([]byte, 0, list.Size()))
 
 	for i := range list {
 		if err := binary.Write(buffer, binary.BigEndian, list[i].Type()); err != nil {
@@ -171,6 +172,10 @@ func (list ExtensionList) MarshalBinary() ([]byte, error) {
 			return nil, errors.Wrap(err, "marshal extension")
 		}
 
+		if err := binary.Write(buffer, binary.BigEndian, uint16(len(extData))); err != nil {
+			return nil, errors.Wrap(err, "write extension length")
+		}
+
 		if _, err := buffer.Write(extData); err != nil {
 			return nil, errors.Wrap(err, "write extension data")
 		}
@@ -185,7 +190,16 @@ func (list ExtensionList) MarshalBinary() ([]byte, error) {
 // will be called to be unmarshaled
 func (list *ExtensionList) UnmarshalBinary(data []byte) error {
 	for pos := 0; pos < len(data); {
+		if len(data[pos:]) < 4 {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for extension header")
+		}
+
 		extType := ExtensionType(binary.BigEndian.Uint16(data[pos:]))
+		extLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+
+		if len(data[pos+4:]) < extLen {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for extension data")
+		}
 
 		gen := extType.Generator()
 		if gen == nil {
@@ -193,12 +207,12 @@ func (list *ExtensionList) UnmarshalBinary(data []byte) error {
 		}
 
 		ext := gen()
-		if err := ext.UnmarshalBinary(data[pos+2:]); err != nil {
+		if err := ext.UnmarshalBinary(data[pos+4 : pos+4+extLen]); err != nil {
 			return errors.Wrap(err, "unmarshal extension")
 		}
 
 		*list = append(*list, ext)
-		pos += int(ext.Size()) + 2
+		pos += extLen + 4
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package esni
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"net"
 	"strings"
@@ -53,7 +54,7 @@ func (set *AddressSet) Size() (size uint16) {
 // value to a binary format for inclusion in an
 // extension list
 func (set *AddressSet) MarshalBinary() ([]byte, error) {
-	data := bytes.NewBuffer(make([]byte, set.Size()))
+	data := bytes.NewBuffer(make([]byte, 0, set.Size()))
 
 	for i := range set.Addresses {
 		if ipv4 := set.Addresses[i].To4(); ipv4 != nil {
@@ -96,6 +97,24 @@ func (set *AddressSet) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// MarshalJSON will marshal the addresses in
+// the set to a JSON list of address strings
+func (set *AddressSet) MarshalJSON() ([]byte, error) {
+	addresses := set.Addresses
+	if addresses == nil {
+		addresses = []net.IP{}
+	}
+
+	return json.Marshal(addresses)
+}
+
+// UnmarshalJSON will attempt to unmarshal the
+// addresses in the set from a JSON list of
+// address strings
+func (set *AddressSet) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &set.Addresses)
+}
+
 // String returns a friendly representation of
 // the ESNI extension value
 func (set *AddressSet) String() string {
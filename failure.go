@@ -0,0 +1,180 @@
+package esni
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrRateLimited is returned when the source of
+	// an encrypted SNI has exhausted its allowance
+	// of decryption failures
+	ErrRateLimited = errors.New("decryption rate limited")
+)
+
+// DecryptFailure describes an encrypted SNI
+// that a ServerKeySet failed to decrypt
+type DecryptFailure struct {
+	// Source specifies the address of the client
+	// that sent the encrypted SNI, it may be nil
+	// if the address is unknown
+	Source net.Addr
+
+	// RecordDigest specifies the record
+	// digest sent by the client
+	RecordDigest []byte
+
+	// Err specifies the reason
+	// decryption failed
+	Err error
+}
+
+// FailureHook is implemented by types that wish
+// to be notified each time a ServerKeySet fails to
+// decrypt an encrypted SNI
+type FailureHook interface {
+	DecryptFailed(failure DecryptFailure)
+}
+
+// FailureHookFunc adapts an ordinary
+// function to the FailureHook interface
+type FailureHookFunc func(failure DecryptFailure)
+
+// DecryptFailed calls the function
+func (fn FailureHookFunc) DecryptFailed(failure DecryptFailure) {
+	fn(failure)
+}
+
+// RateLimiter is a per-source token-bucket limiter
+// charged for every decryption failure, a source that
+// exhausts its bucket is refused until the bucket has
+// refilled, throttling clients that spray invalid
+// encrypted SNIs at a server.
+//
+// It implements FailureHook and is safe for
+// concurrent use.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// tokenBucket represents the
+// bucket for a single source
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// maxRateLimiterBuckets specifies the number of
+// buckets after which full buckets are discarded
+const maxRateLimiterBuckets = 4096
+
+// NewRateLimiter returns a new RateLimiter allowing
+// each source burst failures, refilled at the rate
+// of failures per second
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow returns true if the source has tokens
+// remaining in its bucket, a nil source is
+// always allowed
+func (limiter *RateLimiter) Allow(source net.Addr) bool {
+	if source == nil {
+		return true
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	bucket, ok := limiter.buckets[sourceKey(source)]
+	if !ok {
+		return true
+	}
+
+	limiter.refill(bucket, limiter.now())
+	return bucket.tokens >= 1
+}
+
+// DecryptFailed charges a token to the
+// bucket of the source of the failure
+func (limiter *RateLimiter) DecryptFailed(failure DecryptFailure) {
+	if failure.Source == nil {
+		return
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	key := sourceKey(failure.Source)
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		if len(limiter.buckets) >= maxRateLimiterBuckets {
+			limiter.sweep(now)
+		}
+
+		bucket = &tokenBucket{tokens: limiter.burst, updated: now}
+		limiter.buckets[key] = bucket
+	}
+
+	limiter.refill(bucket, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+	}
+}
+
+// refill adds the tokens accrued by the
+// bucket since it was last updated
+func (limiter *RateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * limiter.rate
+		bucket.updated = now
+	}
+
+	if bucket.tokens > limiter.burst {
+		bucket.tokens = limiter.burst
+	}
+}
+
+// sweep discards the buckets that have refilled,
+// they are indistinguishable from a new bucket
+func (limiter *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+
+		if bucket.tokens >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// sourceKey returns the key identifying the source,
+// the port is ignored so that a client is limited
+// across connections
+func sourceKey(source net.Addr) string {
+	switch addr := source.(type) {
+	case *net.TCPAddr:
+		return addr.IP.String()
+	case *net.UDPAddr:
+		return addr.IP.String()
+	}
+
+	if host, _, err := net.SplitHostPort(source.String()); err == nil {
+		return host
+	}
+
+	return source.String()
+}
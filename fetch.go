@@ -0,0 +1,251 @@
+package esni
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoKeys is returned when none of the
+	// records fetched for a domain are usable
+	ErrNoKeys = errors.New("no usable esni keys")
+
+	// ErrKeysNotValid is returned when a fetched
+	// Keys record isn't valid at the time it
+	// is evaluated
+	ErrKeysNotValid = errors.New("keys record is not valid")
+)
+
+// KeysSource describes where a fetched
+// Keys record was obtained from
+type KeysSource struct {
+	// Domain specifies the domain the
+	// record was fetched for
+	Domain string
+
+	// Name specifies the DNS
+	// name that was queried
+	Name string
+
+	// Index specifies the position of the
+	// record in the lookup response
+	Index int
+
+	// Raw contains the binary data
+	// of the record
+	Raw []byte
+
+	// FetchedAt specifies the time
+	// the record was fetched
+	FetchedAt time.Time
+
+	// DNSSEC specifies the DNSSEC
+	// status of the lookup
+	DNSSEC DNSSECStatus
+
+	// TTL specifies the TTL of the record,
+	// zero if the resolver doesn't report it
+	TTL time.Duration
+}
+
+// FetchedKeys represents a Keys record
+// fetched from DNS and its source
+type FetchedKeys struct {
+	Keys   *Keys
+	Source KeysSource
+}
+
+// SkippedRecord describes a record that was
+// discarded because it couldn't be parsed or
+// isn't usable
+type SkippedRecord struct {
+	// Index specifies the position of the
+	// record in the lookup response
+	Index int
+
+	// Err specifies why the
+	// record was skipped
+	Err error
+}
+
+// String returns a friendly representation
+// of the skipped record
+func (skipped SkippedRecord) String() string {
+	return fmt.Sprintf("record %d: %s", skipped.Index, skipped.Err)
+}
+
+// Fetcher fetches the ESNI Keys records
+// published in the _esni TXT records of
+// a domain
+type Fetcher struct {
+	// Resolver specifies the DNS backend, if
+	// nil net.DefaultResolver is used through
+	// a NetResolver
+	Resolver Resolver
+
+	// RequireDNSSEC specifies if lookups must be
+	// authenticated by DNSSEC, the resolver must
+	// implement DetailedResolver and if nil the
+	// name servers of the system resolver
+	// configuration are queried directly with a
+	// DNSClientResolver. Lookups fail unless the
+	// validating resolver is reached over TLS or
+	// on a loopback address.
+	RequireDNSSEC bool
+
+	// EvalContext specifies the time expired
+	// records are evaluated at, if nil the
+	// context of the fetch is consulted
+	EvalContext *EvalContext
+
+	// DecodeOptions specifies the options
+	// used when decoding each record
+	DecodeOptions DecodeOptions
+
+	// Pins specifies an optional store used to pin
+	// the records of each domain on first use, if
+	// set a fetch that replaces the pinned records
+	// before they expire fails with a *PinAlert
+	Pins PinStore
+}
+
+// FetchKeys fetches the Keys records of the
+// domain using net.DefaultResolver
+func FetchKeys(ctx context.Context, domain string) ([]FetchedKeys, []SkippedRecord, error) {
+	return new(Fetcher).FetchKeys(ctx, domain)
+}
+
+// FetchKeys queries the TXT records of _esni.<domain>,
+// base64 decodes and parses each of them and returns
+// the records that are valid as of the evaluation
+// context along with those that were skipped.
+//
+// ErrNoKeys is returned if the lookup succeeded but
+// none of the records are usable.
+func (fetcher *Fetcher) FetchKeys(ctx context.Context, domain string) ([]FetchedKeys, []SkippedRecord, error) {
+	name := ESNIQueryName(domain)
+
+	txts, info, err := fetcher.lookupTXT(ctx, name)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "lookup %s", name)
+	}
+
+	if err := fetcher.checkDNSSEC(name, info.DNSSEC); err != nil {
+		return nil, nil, err
+	}
+
+	ectx := fetcher.EvalContext
+	if ectx == nil {
+		ectx = EvalContextFrom(ctx)
+	}
+
+	fetchedAt := ectx.Now()
+
+	var (
+		fetched []FetchedKeys
+		skipped []SkippedRecord
+	)
+
+	for i, txt := range txts {
+		keys, raw, err := fetcher.parseTXT(txt, ectx)
+		if err != nil {
+			skipped = append(skipped, SkippedRecord{Index: i, Err: err})
+			continue
+		}
+
+		fetched = append(fetched, FetchedKeys{
+			Keys:   keys,
+			Source: KeysSource{Domain: domain, Name: name, Index: i, Raw: raw, FetchedAt: fetchedAt, DNSSEC: info.DNSSEC, TTL: info.TTL},
+		})
+	}
+
+	if len(fetched) == 0 {
+		return nil, skipped, ErrNoKeys
+	}
+
+	if err := fetcher.checkPin(ctx, domain, fetched, fetchedAt); err != nil {
+		return nil, skipped, err
+	}
+
+	return fetched, skipped, nil
+}
+
+// parseTXT decodes and parses a single TXT record,
+// returning an error if it isn't a valid Keys record
+// as of the evaluation context
+func (fetcher *Fetcher) parseTXT(txt string, ectx *EvalContext) (*Keys, []byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(txt))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "decode record")
+	}
+
+	keys, err := fetcher.parseRecord(raw, ectx)
+	return keys, raw, err
+}
+
+// parseRecord parses a single binary Keys record,
+// returning an error if it isn't a valid record
+// as of the evaluation context
+func (fetcher *Fetcher) parseRecord(raw []byte, ectx *EvalContext) (*Keys, error) {
+	opts := fetcher.DecodeOptions
+	opts.RejectTrailingData = true
+
+	keys := new(Keys)
+	if _, err := keys.DecodeWithOptions(raw, opts); err != nil {
+		return nil, errors.Wrap(err, "unmarshal keys")
+	}
+
+	if err := keys.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !keys.Valid(ectx) {
+		return nil, ErrKeysNotValid
+	}
+
+	return keys, nil
+}
+
+// lookupTXT looks up the TXT records of the name,
+// reporting their details if the resolver
+// supports it
+func (fetcher *Fetcher) lookupTXT(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	return lookupTXTDetailed(ctx, fetcher.resolver(), name)
+}
+
+// lookupTXTDetailed looks up the TXT records of
+// the name, reporting their details if the
+// resolver supports it
+func lookupTXTDetailed(ctx context.Context, resolver Resolver, name string) ([]string, LookupInfo, error) {
+	if detailed, ok := resolver.(DetailedResolver); ok {
+		return detailed.LookupTXTDetailed(ctx, name)
+	}
+
+	txts, err := resolver.LookupTXT(ctx, name)
+	return txts, LookupInfo{}, err
+}
+
+// resolver returns the DNS backend
+// used by the fetcher
+func (fetcher *Fetcher) resolver() Resolver {
+	if fetcher.Resolver != nil {
+		return fetcher.Resolver
+	}
+
+	if fetcher.RequireDNSSEC {
+		return new(DNSClientResolver)
+	}
+
+	return NetResolver{}
+}
+
+// ESNIQueryName returns the DNS name that the
+// ESNI TXT records of the domain are published at
+func ESNIQueryName(domain string) string {
+	return "_esni." + strings.TrimSuffix(domain, ".")
+}
@@ -0,0 +1,149 @@
+package esni
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxAliasDepth specifies the number of AliasMode
+	// records followed when fetching ECH configs
+	maxAliasDepth = 8
+)
+
+var (
+	// ErrNoECHConfigs is returned when none of the
+	// HTTPS records of a domain carry a usable
+	// ECHConfigList
+	ErrNoECHConfigs = errors.New("no usable ech configs")
+)
+
+// FetchedECHConfigs represents an ECHConfigList
+// fetched from the "ech" parameter of an HTTPS
+// record along with the record and its source
+type FetchedECHConfigs struct {
+	Configs ECHConfigList
+	Record  SVCBRecord
+	Source  KeysSource
+}
+
+// FetchECHConfigs fetches the ECH configs of
+// the domain using the name servers of the
+// system resolver configuration
+func FetchECHConfigs(ctx context.Context, domain string) ([]FetchedECHConfigs, []SkippedRecord, error) {
+	return new(Fetcher).FetchECHConfigs(ctx, domain)
+}
+
+// FetchECHConfigs queries the HTTPS records of the domain,
+// following AliasMode records, and returns the config lists
+// carried in the "ech" parameter of the ServiceMode records
+// in order of their priority along with the records whose
+// config list couldn't be parsed.
+//
+// ErrNoECHConfigs is returned if none
+// of the records carry a usable config list.
+func (fetcher *Fetcher) FetchECHConfigs(ctx context.Context, domain string) ([]FetchedECHConfigs, []SkippedRecord, error) {
+	ectx := fetcher.EvalContext
+	if ectx == nil {
+		ectx = EvalContextFrom(ctx)
+	}
+
+	name, records, info, err := lookupServiceRecords(ctx, fetcher.resolver(), strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := fetcher.checkDNSSEC(name, info.DNSSEC); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		fetched []FetchedECHConfigs
+		skipped []SkippedRecord
+	)
+
+	for i, record := range records {
+		list, ok, err := record.ECHConfigList()
+		if !ok {
+			continue
+		}
+
+		if err != nil {
+			skipped = append(skipped, SkippedRecord{Index: i, Err: err})
+			continue
+		}
+
+		raw, _ := record.Param(SvcParamKeyECH)
+		fetched = append(fetched, FetchedECHConfigs{
+			Configs: list,
+			Record:  record,
+			Source:  KeysSource{Domain: domain, Name: name, Index: i, Raw: raw, FetchedAt: ectx.Now(), DNSSEC: info.DNSSEC, TTL: info.TTL},
+		})
+	}
+
+	if len(fetched) == 0 {
+		return nil, skipped, ErrNoECHConfigs
+	}
+
+	sort.SliceStable(fetched, func(i, j int) bool {
+		return fetched[i].Record.Priority < fetched[j].Record.Priority
+	})
+
+	return fetched, skipped, nil
+}
+
+// lookupServiceRecords looks up the HTTPS records of
+// the name following AliasMode records, it returns the
+// name the ServiceMode records were found at along with
+// the weakest DNSSEC status and lowest TTL of the lookups
+func lookupServiceRecords(ctx context.Context, resolver Resolver, name string) (string, []SVCBRecord, LookupInfo, error) {
+	info := LookupInfo{DNSSEC: DNSSECSecure}
+
+	for depth := 0; depth <= maxAliasDepth; depth++ {
+		records, lookupInfo, err := lookupHTTPSDetailed(ctx, resolver, name)
+		if err != nil {
+			return "", nil, info, errors.Wrapf(err, "lookup https %s", name)
+		}
+
+		info.DNSSEC = leastSecure(info.DNSSEC, lookupInfo.DNSSEC)
+		if depth == 0 || lookupInfo.TTL < info.TTL {
+			info.TTL = lookupInfo.TTL
+		}
+
+		alias := -1
+		for i := range records {
+			if records[i].AliasMode() {
+				alias = i
+				break
+			}
+		}
+
+		if alias < 0 {
+			return name, records, info, nil
+		}
+
+		target := strings.TrimSuffix(records[alias].Target, ".")
+		if len(target) == 0 {
+			return "", nil, info, errors.Errorf("alias record of %s has no target", name)
+		}
+
+		name = target
+	}
+
+	return "", nil, info, errors.Errorf("more than %d alias records followed", maxAliasDepth)
+}
+
+// lookupHTTPSDetailed looks up the HTTPS records of
+// the name, reporting their details if the
+// resolver supports it
+func lookupHTTPSDetailed(ctx context.Context, resolver Resolver, name string) ([]SVCBRecord, LookupInfo, error) {
+	if detailed, ok := resolver.(DetailedResolver); ok {
+		return detailed.LookupHTTPSDetailed(ctx, name)
+	}
+
+	records, err := resolver.LookupHTTPS(ctx, name)
+	return records, LookupInfo{}, err
+}
@@ -1,5 +1,20 @@
 module github.com/LiamHaworth/go-esni
 
-go 1.12
+go 1.26
 
-require github.com/pkg/errors v0.8.1
+require (
+	github.com/cloudflare/circl v1.3.7
+	github.com/miekg/dns v1.1.63
+	github.com/pkg/errors v0.8.1
+	golang.org/x/crypto v0.29.0
+	golang.org/x/net v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.9.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
+	golang.org/x/text v0.20.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
+)
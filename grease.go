@@ -0,0 +1,73 @@
+package esni
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateGreaseESNI produces the data of an
+// encrypted_server_name extension that is
+// indistinguishable from a real one to an observer,
+// it is sent by clients when no Keys record is
+// available so that real uses of ESNI don't stand
+// out.
+//
+// The extension uses TLS_AES_128_GCM_SHA256 with
+// an X25519 key share and an encrypted SNI of the
+// length produced by the maximum padded length.
+func GenerateGreaseESNI(version Version) ([]byte, error) {
+	return GenerateGreaseESNIWithSuite(version, CipherSuite_TLS_AES_128_GCM_SHA256)
+}
+
+// GenerateGreaseESNIWithSuite produces the data of
+// a GREASE encrypted_server_name extension in the
+// same manner as GenerateGreaseESNI for the cipher
+// suite, the record digest and the authentication
+// tag are sized for the suite
+func GenerateGreaseESNIWithSuite(version Version, suite CipherSuite) ([]byte, error) {
+	if !version.supported() || version.UsesHPKE() {
+		return nil, unsupportedVersionError(version)
+	}
+
+	aead, err := suite.NewAEAD(make([]byte, suite.KeyLen()))
+	if err != nil {
+		return nil, err
+	}
+
+	keyExchange, err := greaseBytes(Group(GroupX25519).KeyExchangeLength())
+	if err != nil {
+		return nil, err
+	}
+
+	recordDigest, err := greaseBytes(suite.Hash().Size())
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedSNI, err := greaseBytes(NonceSize + int(MaxPaddedLength) + aead.Overhead())
+	if err != nil {
+		return nil, err
+	}
+
+	grease := ClientEncryptedSNI{
+		Suite:        suite,
+		KeyShare:     KeyShareEntry{Group: GroupX25519, KeyExchange: keyExchange},
+		RecordDigest: recordDigest,
+		EncryptedSNI: encryptedSNI,
+	}
+
+	return grease.MarshalBinary()
+}
+
+// greaseBytes returns n bytes read
+// from the system random source
+func greaseBytes(n int) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		return nil, errors.Wrap(err, "read random")
+	}
+
+	return data, nil
+}
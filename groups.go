@@ -43,3 +43,28 @@ func (g Group) String() string {
 
 	return "UNKNOWN"
 }
+
+// Group_keyExchangeLength defines a map of groups
+// and the length, in bytes, of the key exchange
+// value of a public key belonging to the group
+var Group_keyExchangeLength = map[Group]int{
+	GroupECP256R1:  65,
+	GroupSECP384R1: 97,
+	GroupSECP521R1: 133,
+	GroupX25519:    32,
+	GroupX448:      56,
+	GroupFFDHE2048: 256,
+	GroupFFDHE3072: 384,
+	GroupFFDHE4096: 512,
+	GroupFFDHE6144: 768,
+	GroupFFDHE8192: 1024,
+}
+
+// KeyExchangeLength attempts to return the
+// expected length of a key exchange value for
+// the Group based on those specified in
+// Group_keyExchangeLength, if no match is found
+// 0 is returned
+func (g Group) KeyExchangeLength() int {
+	return Group_keyExchangeLength[g]
+}
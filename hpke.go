@@ -0,0 +1,97 @@
+package esni
+
+// HpkeKemId represents the identifier of
+// an HPKE key encapsulation mechanism
+type HpkeKemId uint16
+
+const (
+	HpkeKemId_DHKEM_P256_HKDF_SHA256   HpkeKemId = 0x0010
+	HpkeKemId_DHKEM_P384_HKDF_SHA384   HpkeKemId = 0x0011
+	HpkeKemId_DHKEM_P521_HKDF_SHA512   HpkeKemId = 0x0012
+	HpkeKemId_DHKEM_X25519_HKDF_SHA256 HpkeKemId = 0x0020
+	HpkeKemId_DHKEM_X448_HKDF_SHA512   HpkeKemId = 0x0021
+)
+
+// HpkeKemId_name specifies a map of HpkeKemIds
+// to their respective string representation
+var HpkeKemId_name = map[HpkeKemId]string{
+	HpkeKemId_DHKEM_P256_HKDF_SHA256:   "DHKEM_P256_HKDF_SHA256",
+	HpkeKemId_DHKEM_P384_HKDF_SHA384:   "DHKEM_P384_HKDF_SHA384",
+	HpkeKemId_DHKEM_P521_HKDF_SHA512:   "DHKEM_P521_HKDF_SHA512",
+	HpkeKemId_DHKEM_X25519_HKDF_SHA256: "DHKEM_X25519_HKDF_SHA256",
+	HpkeKemId_DHKEM_X448_HKDF_SHA512:   "DHKEM_X448_HKDF_SHA512",
+}
+
+// String attempts to return the string
+// representation of the HpkeKemId based
+// on those specified in HpkeKemId_name, if
+// no match is found "UNKNOWN" is returned
+func (kem HpkeKemId) String() string {
+	if name, ok := HpkeKemId_name[kem]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// HpkeKdfId represents the identifier of
+// an HPKE key derivation function
+type HpkeKdfId uint16
+
+const (
+	HpkeKdfId_HKDF_SHA256 HpkeKdfId = 0x0001
+	HpkeKdfId_HKDF_SHA384 HpkeKdfId = 0x0002
+	HpkeKdfId_HKDF_SHA512 HpkeKdfId = 0x0003
+)
+
+// HpkeKdfId_name specifies a map of HpkeKdfIds
+// to their respective string representation
+var HpkeKdfId_name = map[HpkeKdfId]string{
+	HpkeKdfId_HKDF_SHA256: "HKDF_SHA256",
+	HpkeKdfId_HKDF_SHA384: "HKDF_SHA384",
+	HpkeKdfId_HKDF_SHA512: "HKDF_SHA512",
+}
+
+// String attempts to return the string
+// representation of the HpkeKdfId based
+// on those specified in HpkeKdfId_name, if
+// no match is found "UNKNOWN" is returned
+func (kdf HpkeKdfId) String() string {
+	if name, ok := HpkeKdfId_name[kdf]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// HpkeAeadId represents the identifier of
+// an HPKE AEAD algorithm
+type HpkeAeadId uint16
+
+const (
+	HpkeAeadId_AES_128_GCM       HpkeAeadId = 0x0001
+	HpkeAeadId_AES_256_GCM       HpkeAeadId = 0x0002
+	HpkeAeadId_CHACHA20_POLY1305 HpkeAeadId = 0x0003
+	HpkeAeadId_EXPORT_ONLY       HpkeAeadId = 0xffff
+)
+
+// HpkeAeadId_name specifies a map of HpkeAeadIds
+// to their respective string representation
+var HpkeAeadId_name = map[HpkeAeadId]string{
+	HpkeAeadId_AES_128_GCM:       "AES_128_GCM",
+	HpkeAeadId_AES_256_GCM:       "AES_256_GCM",
+	HpkeAeadId_CHACHA20_POLY1305: "CHACHA20_POLY1305",
+	HpkeAeadId_EXPORT_ONLY:       "EXPORT_ONLY",
+}
+
+// String attempts to return the string
+// representation of the HpkeAeadId based
+// on those specified in HpkeAeadId_name, if
+// no match is found "UNKNOWN" is returned
+func (aead HpkeAeadId) String() string {
+	if name, ok := HpkeAeadId_name[aead]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
@@ -0,0 +1,219 @@
+package esni
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// marshalIdentifier returns the text representation
+// of a 16-bit protocol identifier, if the identifier
+// has no known name its hexadecimal value is used
+func marshalIdentifier(value uint16, name string, known bool) []byte {
+	if known {
+		return []byte(name)
+	}
+
+	return []byte(fmt.Sprintf("0x%04x", value))
+}
+
+// parseIdentifier attempts to parse the hexadecimal
+// text representation of a 16-bit protocol identifier
+// that has no known name
+func parseIdentifier(text []byte) (uint16, error) {
+	value := string(text)
+	if !strings.HasPrefix(value, "0x") {
+		return 0, errors.Errorf("unknown identifier %q", value)
+	}
+
+	parsed, err := strconv.ParseUint(value[2:], 16, 16)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse identifier %q", value)
+	}
+
+	return uint16(parsed), nil
+}
+
+// MarshalText returns the name of the Version,
+// or its hexadecimal value if the name is unknown
+func (v Version) MarshalText() ([]byte, error) {
+	name, ok := Version_name[v]
+	return marshalIdentifier(uint16(v), name, ok), nil
+}
+
+// UnmarshalText parses a Version from either its
+// name or its hexadecimal value
+func (v *Version) UnmarshalText(text []byte) error {
+	for version, name := range Version_name {
+		if name == string(text) {
+			*v = version
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse version")
+	}
+
+	*v = Version(value)
+	return nil
+}
+
+// MarshalText returns the name of the Group,
+// or its hexadecimal value if the name is unknown
+func (g Group) MarshalText() ([]byte, error) {
+	name, ok := Group_name[g]
+	return marshalIdentifier(uint16(g), name, ok), nil
+}
+
+// UnmarshalText parses a Group from either its
+// name or its hexadecimal value
+func (g *Group) UnmarshalText(text []byte) error {
+	for group, name := range Group_name {
+		if name == string(text) {
+			*g = group
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse group")
+	}
+
+	*g = Group(value)
+	return nil
+}
+
+// MarshalText returns the name of the CipherSuite,
+// or its hexadecimal value if the name is unknown
+func (suite CipherSuite) MarshalText() ([]byte, error) {
+	name, ok := CipherSuite_name[suite]
+	return marshalIdentifier(uint16(suite), name, ok), nil
+}
+
+// UnmarshalText parses a CipherSuite from either
+// its name or its hexadecimal value
+func (suite *CipherSuite) UnmarshalText(text []byte) error {
+	for cipherSuite, name := range CipherSuite_name {
+		if name == string(text) {
+			*suite = cipherSuite
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse cipher suite")
+	}
+
+	*suite = CipherSuite(value)
+	return nil
+}
+
+// MarshalText returns the name of the ExtensionType,
+// or its hexadecimal value if the name is unknown
+func (extType ExtensionType) MarshalText() ([]byte, error) {
+	name, ok := ExtensionType_name[extType]
+	return marshalIdentifier(uint16(extType), name, ok), nil
+}
+
+// UnmarshalText parses an ExtensionType from either
+// its name or its hexadecimal value
+func (extType *ExtensionType) UnmarshalText(text []byte) error {
+	for registered, name := range ExtensionType_name {
+		if name == string(text) {
+			*extType = registered
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse extension type")
+	}
+
+	*extType = ExtensionType(value)
+	return nil
+}
+
+// MarshalText returns the name of the HpkeKemId,
+// or its hexadecimal value if the name is unknown
+func (kem HpkeKemId) MarshalText() ([]byte, error) {
+	name, ok := HpkeKemId_name[kem]
+	return marshalIdentifier(uint16(kem), name, ok), nil
+}
+
+// UnmarshalText parses an HpkeKemId from either
+// its name or its hexadecimal value
+func (kem *HpkeKemId) UnmarshalText(text []byte) error {
+	for id, name := range HpkeKemId_name {
+		if name == string(text) {
+			*kem = id
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse kem id")
+	}
+
+	*kem = HpkeKemId(value)
+	return nil
+}
+
+// MarshalText returns the name of the HpkeKdfId,
+// or its hexadecimal value if the name is unknown
+func (kdf HpkeKdfId) MarshalText() ([]byte, error) {
+	name, ok := HpkeKdfId_name[kdf]
+	return marshalIdentifier(uint16(kdf), name, ok), nil
+}
+
+// UnmarshalText parses an HpkeKdfId from either
+// its name or its hexadecimal value
+func (kdf *HpkeKdfId) UnmarshalText(text []byte) error {
+	for id, name := range HpkeKdfId_name {
+		if name == string(text) {
+			*kdf = id
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse kdf id")
+	}
+
+	*kdf = HpkeKdfId(value)
+	return nil
+}
+
+// MarshalText returns the name of the HpkeAeadId,
+// or its hexadecimal value if the name is unknown
+func (aead HpkeAeadId) MarshalText() ([]byte, error) {
+	name, ok := HpkeAeadId_name[aead]
+	return marshalIdentifier(uint16(aead), name, ok), nil
+}
+
+// UnmarshalText parses an HpkeAeadId from either
+// its name or its hexadecimal value
+func (aead *HpkeAeadId) UnmarshalText(text []byte) error {
+	for id, name := range HpkeAeadId_name {
+		if name == string(text) {
+			*aead = id
+			return nil
+		}
+	}
+
+	value, err := parseIdentifier(text)
+	if err != nil {
+		return errors.Wrap(err, "parse aead id")
+	}
+
+	*aead = HpkeAeadId(value)
+	return nil
+}
@@ -0,0 +1,31 @@
+package esni
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// ToASCIIName converts an internationalized domain
+// name to the ASCII form carried on the wire, each
+// label is mapped and validated according to IDNA2008
+// and non-ASCII labels are encoded as punycode A-labels.
+//
+// Names that are already ASCII are validated as well,
+// so malformed A-labels such as "xn--zz" are rejected
+// and upper case letters are folded to lower case.
+func ToASCIIName(name string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "convert %q to ascii", name)
+	}
+
+	return ascii, nil
+}
+
+// isALabel returns if the label carries
+// the ACE prefix of a punycode A-label
+func isALabel(label string) bool {
+	return len(label) >= 4 && strings.EqualFold(label[:4], "xn--")
+}
@@ -0,0 +1,54 @@
+package esni
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestToASCIIName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "bücher.example", want: "xn--bcher-kva.example"},
+		{name: "xn--bcher-kva.example", want: "xn--bcher-kva.example"},
+		{name: "Public.Example.COM", want: "public.example.com"},
+		{name: "xn--zz.example", wantErr: true},
+		{name: "xn--a.example", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ToASCIIName(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ToASCIIName() error = %v, want error %t", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("ToASCIIName() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidatePublicNameALabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr error
+	}{
+		{name: "xn--bcher-kva.example"},
+		{name: "XN--BCHER-KVA.example"},
+		{name: "xn--zz.example", wantErr: ErrPublicNameLabel},
+		{name: "bücher.example", wantErr: ErrPublicNameLabel},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidatePublicName(test.name); errors.Cause(err) != test.wantErr {
+				t.Errorf("ValidatePublicName() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package esni
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// NonceSize specifies the size of the nonce
+	// the client includes with the encrypted SNI
+	// and the server echoes in its response
+	NonceSize = 16
+)
+
+// ClientESNIInner represents the plaintext of the
+// encrypted SNI, carrying the real SNI along with a
+// random nonce that the server echoes back to prove
+// it was able to decrypt the SNI
+type ClientESNIInner struct {
+	// Nonce specifies the random value the
+	// server must echo in its response
+	Nonce [NonceSize]byte
+
+	// RealSNI specifies the padded server
+	// name of the server
+	RealSNI PaddedServerNameList
+}
+
+// NewClientESNIInner returns a new ClientESNIInner
+// for the server name padded to the padded length,
+// with a nonce read from the system random source
+func NewClientESNIInner(serverName string, paddedLength uint16) (*ClientESNIInner, error) {
+	return NewClientESNIInnerFromReader(serverName, paddedLength, rand.Reader)
+}
+
+// NewClientESNIInnerFromReader returns a new
+// ClientESNIInner in the same manner as NewClientESNIInner,
+// reading the nonce from the random source
+func NewClientESNIInnerFromReader(serverName string, paddedLength uint16, random io.Reader) (*ClientESNIInner, error) {
+	inner := &ClientESNIInner{
+		RealSNI: PaddedServerNameList{ServerName: serverName, PaddedLength: paddedLength},
+	}
+
+	if _, err := io.ReadFull(random, inner.Nonce[:]); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+
+	return inner, nil
+}
+
+// MatchNonce checks, in constant time, if the
+// nonce echoed by the server matches the nonce
+// of the ClientESNIInner
+func (inner *ClientESNIInner) MatchNonce(echo []byte) bool {
+	return subtle.ConstantTimeCompare(inner.Nonce[:], echo) == 1
+}
+
+// MarshalBinary will marshal the ClientESNIInner
+// into the binary format that is encrypted
+func (inner ClientESNIInner) MarshalBinary() ([]byte, error) {
+	paddedSNI, err := inner.RealSNI.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, NonceSize+len(paddedSNI))
+	data = append(data, inner.Nonce[:]...)
+
+	return append(data, paddedSNI...), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ClientESNIInner from the decrypted SNI
+func (inner *ClientESNIInner) UnmarshalBinary(data []byte) error {
+	if len(data) < NonceSize {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for nonce")
+	}
+
+	if err := inner.RealSNI.UnmarshalBinary(data[NonceSize:]); err != nil {
+		return errors.Wrap(err, "unmarshal padded server name list")
+	}
+
+	copy(inner.Nonce[:], data)
+	return nil
+}
@@ -0,0 +1,64 @@
+// Package tls13 implements the key derivation
+// primitives of TLS 1.3 shared by the ESNI and
+// ECH key schedules
+package tls13
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+const (
+	// labelPrefix specifies the prefix added to
+	// every label by HKDF-Expand-Label
+	labelPrefix = "tls13 "
+)
+
+// Extract implements HKDF-Extract, an empty salt
+// is treated as a string of zeros of the length
+// of the hash output
+func Extract(h func() hash.Hash, secret, salt []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, h().Size())
+	}
+
+	mac := hmac.New(h, salt)
+	mac.Write(secret)
+
+	return mac.Sum(nil)
+}
+
+// Expand implements HKDF-Expand, producing
+// length bytes of output keying material
+func Expand(h func() hash.Hash, secret, info []byte, length int) []byte {
+	mac := hmac.New(h, secret)
+
+	var out, block []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		mac.Reset()
+		mac.Write(block)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+
+		block = mac.Sum(nil)
+		out = append(out, block...)
+	}
+
+	return out[:length]
+}
+
+// ExpandLabel implements HKDF-Expand-Label as
+// specified by TLS 1.3, the label is prefixed
+// with "tls13 " before use
+func ExpandLabel(h func() hash.Hash, secret []byte, label string, context []byte, length int) []byte {
+	fullLabel := labelPrefix + label
+
+	info := make([]byte, 0, 4+len(fullLabel)+len(context))
+	info = append(info, byte(length>>8), byte(length))
+	info = append(info, byte(len(fullLabel)))
+	info = append(info, fullLabel...)
+	info = append(info, byte(len(context)))
+	info = append(info, context...)
+
+	return Expand(h, secret, info, length)
+}
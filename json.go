@@ -0,0 +1,179 @@
+package esni
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// hexBytes represents a byte slice that is
+// encoded as a hexadecimal string in JSON
+type hexBytes []byte
+
+// MarshalText encodes the bytes as hexadecimal
+func (b hexBytes) MarshalText() ([]byte, error) {
+	text := make([]byte, hex.EncodedLen(len(b)))
+	hex.Encode(text, b)
+
+	return text, nil
+}
+
+// UnmarshalText decodes the bytes from hexadecimal
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	decoded := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(decoded, text); err != nil {
+		return err
+	}
+
+	*b = decoded
+	return nil
+}
+
+// jsonKeys defines the JSON document structure
+// produced when marshalling a Keys record
+type jsonKeys struct {
+	Version      Version         `json:"version"`
+	Checksum     hexBytes        `json:"checksum,omitempty"`
+	PublicName   string          `json:"public_name,omitempty"`
+	Keys         []jsonKeyShare  `json:"keys"`
+	CipherSuites []CipherSuite   `json:"cipher_suites"`
+	PaddedLength uint16          `json:"padded_length"`
+	NotBefore    time.Time       `json:"not_before"`
+	NotAfter     time.Time       `json:"not_after"`
+	Extensions   []jsonExtension `json:"extensions"`
+}
+
+// jsonKeyShare defines the JSON document
+// structure of a single key share entry
+type jsonKeyShare struct {
+	Group       Group    `json:"group"`
+	KeyExchange hexBytes `json:"key_exchange"`
+}
+
+// jsonExtension defines the JSON document
+// structure of a single extension, the value
+// is produced by the extension itself if it
+// implements json.Marshaler, otherwise it is
+// the hexadecimal encoding of its binary format
+type jsonExtension struct {
+	Type      ExtensionType   `json:"type"`
+	Mandatory bool            `json:"mandatory"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// MarshalJSON will marshal the Keys record into
+// a structured JSON document with human-readable
+// representations of each field
+func (keys Keys) MarshalJSON() ([]byte, error) {
+	doc := jsonKeys{
+		Version:      keys.Version,
+		PublicName:   keys.PublicName,
+		Keys:         make([]jsonKeyShare, len(keys.Keys)),
+		CipherSuites: keys.CipherSuites,
+		PaddedLength: keys.PaddedLength,
+		NotBefore:    keys.NotBefore.UTC(),
+		NotAfter:     keys.NotAfter.UTC(),
+		Extensions:   make([]jsonExtension, len(keys.Extensions)),
+	}
+
+	if keys.Version.HasChecksum() {
+		doc.Checksum = keys.Checksum[:]
+	}
+
+	for i := range keys.Keys {
+		doc.Keys[i] = jsonKeyShare{Group: keys.Keys[i].Group, KeyExchange: keys.Keys[i].KeyExchange}
+	}
+
+	for i := range keys.Extensions {
+		value, err := marshalExtensionJSON(keys.Extensions[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal extension %s", keys.Extensions[i].Type())
+		}
+
+		doc.Extensions[i] = jsonExtension{
+			Type:      keys.Extensions[i].Type(),
+			Mandatory: keys.Extensions[i].Type().Mandatory(),
+			Value:     value,
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON will attempt to unmarshal a Keys
+// record from the JSON document produced by MarshalJSON
+func (keys *Keys) UnmarshalJSON(data []byte) error {
+	var doc jsonKeys
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Checksum) != 0 && len(doc.Checksum) != len(keys.Checksum) {
+		return errors.New("checksum must be 4 bytes")
+	}
+
+	keys.Version = doc.Version
+	copy(keys.Checksum[:], doc.Checksum)
+	keys.PublicName = doc.PublicName
+	keys.CipherSuites = doc.CipherSuites
+	keys.PaddedLength = doc.PaddedLength
+	keys.NotBefore = doc.NotBefore
+	keys.NotAfter = doc.NotAfter
+
+	keys.Keys = make(KeyShareEntryList, len(doc.Keys))
+	for i := range doc.Keys {
+		keys.Keys[i] = KeyShareEntry{Group: doc.Keys[i].Group, KeyExchange: doc.Keys[i].KeyExchange}
+	}
+
+	keys.Extensions = make(ExtensionList, len(doc.Extensions))
+	for i := range doc.Extensions {
+		ext, err := unmarshalExtensionJSON(doc.Extensions[i])
+		if err != nil {
+			return errors.Wrapf(err, "unmarshal extension %s", doc.Extensions[i].Type)
+		}
+
+		keys.Extensions[i] = ext
+	}
+
+	return nil
+}
+
+// marshalExtensionJSON produces the JSON value
+// for an extension, preferring the extension's
+// own JSON representation when it provides one
+func marshalExtensionJSON(ext Extension) (json.RawMessage, error) {
+	if marshaler, ok := ext.(json.Marshaler); ok {
+		return marshaler.MarshalJSON()
+	}
+
+	data, err := ext.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(hexBytes(data))
+}
+
+// unmarshalExtensionJSON creates a new instance
+// of the extension type and unmarshals its value
+// from the JSON document
+func unmarshalExtensionJSON(doc jsonExtension) (Extension, error) {
+	gen := doc.Type.Generator()
+	if gen == nil {
+		return nil, ErrUnsupportedExtensionType
+	}
+
+	ext := gen()
+	if unmarshaler, ok := ext.(json.Unmarshaler); ok {
+		return ext, unmarshaler.UnmarshalJSON(doc.Value)
+	}
+
+	var data hexBytes
+	if err := json.Unmarshal(doc.Value, &data); err != nil {
+		return nil, err
+	}
+
+	return ext, ext.UnmarshalBinary(data)
+}
@@ -0,0 +1,84 @@
+package esni
+
+import (
+	"crypto/rand"
+	"io"
+	"time"
+)
+
+// GenerateOptions specifies the parameters
+// used by GenerateKeys to produce a new Keys
+// record and its private keys
+type GenerateOptions struct {
+	// Profile specifies the profile providing the
+	// parameters of the record, if not set the
+	// ProfileDefault is used
+	Profile *Profile
+
+	// PublicName specifies the clear text SNI
+	// to be used during the TLS handshake
+	PublicName string
+
+	// Groups specifies the groups to generate key
+	// shares for, if not set the groups of the
+	// profile are used
+	Groups []Group
+
+	// Lifetime specifies the validity period of
+	// the record, if not set the lifetime of the
+	// profile is used
+	Lifetime time.Duration
+
+	// EvalContext specifies the evaluation context
+	// used to determine the time the record becomes
+	// valid
+	EvalContext *EvalContext
+
+	// Rand specifies the random source the key pairs
+	// are generated from, if not set crypto/rand is
+	// used
+	Rand io.Reader
+
+	// Seed specifies a seed that the key pairs are
+	// derived from deterministically, it takes
+	// precedence over Rand and is intended for test
+	// fixtures and reproducible infrastructure only
+	Seed []byte
+}
+
+// GenerateKeys produces a new Keys record with a freshly
+// generated key share for each requested group, the private
+// keys of the key shares are returned alongside the record.
+//
+// If opts is nil the default profile is used to
+// generate an X25519 key share.
+func GenerateKeys(opts *GenerateOptions) (*Keys, *PrivateKeys, error) {
+	if opts == nil {
+		opts = new(GenerateOptions)
+	}
+
+	profile := ProfileDefault
+	if opts.Profile != nil {
+		profile = *opts.Profile
+	}
+
+	if len(opts.Groups) > 0 {
+		profile.Groups = opts.Groups
+	}
+
+	if opts.Lifetime != 0 {
+		profile.Lifetime = opts.Lifetime
+	}
+
+	random := opts.Rand
+	if len(opts.Seed) > 0 {
+		random = NewSeededReader(opts.Seed)
+	} else if random == nil {
+		random = rand.Reader
+	}
+
+	return profile.Builder(opts.PublicName).
+		EvalContext(opts.EvalContext).
+		Rand(random).
+		BuildWithPrivateKeys()
+}
@@ -0,0 +1,204 @@
+package esni
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// key pair generators and shared secret functions
+// for the groups supported by crypto/ecdh
+func init() {
+	for group, curve := range ecdhCurves {
+		RegisterKeyPairGenerator(group, ecdhKeyPairGenerator(group, curve))
+		RegisterSharedSecretFunc(group, ecdhSharedSecretFunc(curve))
+	}
+}
+
+var (
+	// ErrUnsupportedGroup is returned when an
+	// operation requires a key exchange for a
+	// group that isn't supported
+	ErrUnsupportedGroup = errors.New("unsupported group")
+)
+
+// KeyPairGenerator defines a function that generates
+// a new key pair for a group using the random source,
+// returning the key exchange value of the public key
+// and the private key
+type KeyPairGenerator func(rand io.Reader) (publicKey, privateKey []byte, err error)
+
+// Group_keyPairGenerator defines a map of groups
+// and their respective key pair generators
+var Group_keyPairGenerator = map[Group]KeyPairGenerator{}
+
+// RegisterKeyPairGenerator registers the key pair
+// generator for the group, allowing key shares to
+// be generated for groups not supported natively
+func RegisterKeyPairGenerator(group Group, generator KeyPairGenerator) {
+	if _, exists := Group_keyPairGenerator[group]; exists {
+		panic("key pair generator already registered")
+	}
+
+	Group_keyPairGenerator[group] = generator
+}
+
+// NewKeyPair generates a new key pair for the
+// Group using the generator registered in
+// Group_keyPairGenerator, the key exchange value
+// of the public key and the private key are
+// returned
+func (g Group) NewKeyPair() (publicKey, privateKey []byte, err error) {
+	return g.NewKeyPairFromReader(rand.Reader)
+}
+
+// NewKeyPairFromReader generates a new key pair for
+// the Group in the same manner as NewKeyPair, reading
+// the private key from the random source, which allows
+// key pairs to be derived deterministically
+func (g Group) NewKeyPairFromReader(random io.Reader) (publicKey, privateKey []byte, err error) {
+	generator, ok := Group_keyPairGenerator[g]
+	if !ok {
+		return nil, nil, errors.Wrapf(ErrUnsupportedGroup, "generate key pair for %s", g)
+	}
+
+	publicKey, privateKey, err = generator(random)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "generate key pair for %s", g)
+	}
+
+	return publicKey, privateKey, nil
+}
+
+// SharedSecretFunc defines a function that performs
+// the key exchange for a group, deriving the shared
+// secret from a private key and the key exchange
+// value of the peer's public key
+type SharedSecretFunc func(privateKey, peerPublic []byte) ([]byte, error)
+
+// Group_sharedSecret defines a map of groups
+// and their respective shared secret functions
+var Group_sharedSecret = map[Group]SharedSecretFunc{}
+
+// RegisterSharedSecretFunc registers the shared
+// secret function for the group, allowing key
+// exchanges for groups not supported natively
+func RegisterSharedSecretFunc(group Group, fn SharedSecretFunc) {
+	if _, exists := Group_sharedSecret[group]; exists {
+		panic("shared secret function already registered")
+	}
+
+	Group_sharedSecret[group] = fn
+}
+
+// DeriveSharedSecret performs the key exchange for
+// the group using the function registered in
+// Group_sharedSecret, deriving the shared secret from
+// the private key and the key exchange value of the
+// peer's public key.
+//
+// For the elliptic curve groups the shared secret is
+// the x-coordinate of the resulting point, as required
+// by TLS 1.3.
+func DeriveSharedSecret(group Group, privateKey, peerPublic []byte) ([]byte, error) {
+	fn, ok := Group_sharedSecret[group]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnsupportedGroup, "derive shared secret for %s", group)
+	}
+
+	secret, err := fn(privateKey, peerPublic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "derive shared secret for %s", group)
+	}
+
+	return secret, nil
+}
+
+// ecdhCurves defines a map of groups and
+// their respective crypto/ecdh curve
+var ecdhCurves = map[Group]ecdh.Curve{
+	GroupECP256R1:  ecdh.P256(),
+	GroupSECP384R1: ecdh.P384(),
+	GroupSECP521R1: ecdh.P521(),
+	GroupX25519:    ecdh.X25519(),
+}
+
+// ecdhScalarSizes defines a map of groups and the
+// size of their private key along with the mask
+// applied to its first byte when it is sampled
+var ecdhScalarSizes = map[Group]struct {
+	size int
+	mask byte
+}{
+	GroupECP256R1:  {size: 32, mask: 0xff},
+	GroupSECP384R1: {size: 48, mask: 0xff},
+	GroupSECP521R1: {size: 66, mask: 0x01},
+	GroupX25519:    {size: 32, mask: 0xff},
+}
+
+// ecdhKeyPairGenerator returns a key pair
+// generator for the crypto/ecdh curve of the
+// group
+func ecdhKeyPairGenerator(group Group, curve ecdh.Curve) KeyPairGenerator {
+	return func(random io.Reader) ([]byte, []byte, error) {
+		var privateKey *ecdh.PrivateKey
+		var err error
+
+		if random == rand.Reader {
+			privateKey, err = curve.GenerateKey(random)
+		} else {
+			privateKey, err = sampleECDHPrivateKey(group, curve, random)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return privateKey.PublicKey().Bytes(), privateKey.Bytes(), nil
+	}
+}
+
+// sampleECDHPrivateKey reads a private key for the
+// curve from the random source, candidates that are
+// not valid scalars for the curve are rejected and
+// another is read, as crypto/ecdh only generates
+// keys using the system random source
+func sampleECDHPrivateKey(group Group, curve ecdh.Curve, random io.Reader) (*ecdh.PrivateKey, error) {
+	scalar := ecdhScalarSizes[group]
+	candidate := make([]byte, scalar.size)
+	defer Zeroize(candidate)
+
+	for {
+		if _, err := io.ReadFull(random, candidate); err != nil {
+			return nil, errors.Wrap(err, "read random")
+		}
+
+		candidate[0] &= scalar.mask
+
+		if privateKey, err := curve.NewPrivateKey(candidate); err == nil {
+			return privateKey, nil
+		}
+	}
+}
+
+// ecdhSharedSecretFunc returns a shared secret
+// function for the crypto/ecdh curve
+func ecdhSharedSecretFunc(curve ecdh.Curve) SharedSecretFunc {
+	return func(privateKey, peerPublic []byte) ([]byte, error) {
+		private, err := curve.NewPrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid private key")
+		}
+
+		public, err := curve.NewPublicKey(peerPublic)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid peer public key")
+		}
+
+		return private.ECDH(public)
+	}
+}
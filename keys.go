@@ -2,10 +2,12 @@ package esni
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"crypto"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -17,6 +19,11 @@ var (
 	// of a ESNI Keys record when the body of the record
 	// doesn't match the checksum included in the record
 	ErrChecksumMismatch = errors.New("calculated checksum did not match received checksum")
+
+	// ErrNoChecksum is returned when attempting to
+	// compute or verify the checksum of a record whose
+	// version doesn't carry a checksum
+	ErrNoChecksum = errors.New("record version does not carry a checksum")
 )
 
 // Keys represents a ENSIKeys record used
@@ -29,7 +36,8 @@ type Keys struct {
 
 	// Checksum is the first 4 bytes of a SHA-256
 	// sum of the binary Keys record, this field
-	// is ignored during marshalling
+	// is ignored during marshalling and isn't
+	// present from draft-04 onwards
 	Checksum [4]byte
 
 	// PublicName specifies the clear text SNI that
@@ -78,35 +86,75 @@ func (keys *Keys) String() string {
 	builder.WriteString("{")
 
 	_, _ = fmt.Fprintf(&builder, "Version:%s, ", keys.Version)
-	_, _ = fmt.Fprintf(&builder, "Checksum:%s, ", hex.EncodeToString(keys.Checksum[:]))
 
-	if keys.Version >= VersionDraft03 {
+	if keys.Version.HasChecksum() {
+		_, _ = fmt.Fprintf(&builder, "Checksum:%s, ", hex.EncodeToString(keys.Checksum[:]))
+	}
+
+	if keys.Version.HasPublicName() {
 		_, _ = fmt.Fprintf(&builder, "PublicName:%s, ", keys.PublicName)
 	}
 
 	_, _ = fmt.Fprintf(&builder, "Keys:%s, ", keys.Keys)
 	_, _ = fmt.Fprintf(&builder, "CipherSuites:%s, ", keys.CipherSuites)
 	_, _ = fmt.Fprintf(&builder, "PaddedLength:%d, ", keys.PaddedLength)
-	_, _ = fmt.Fprintf(&builder, "NotBefore:%s, ", keys.NotBefore)
-	_, _ = fmt.Fprintf(&builder, "NotAfter:%s, ", keys.NotAfter)
+
+	if keys.Version.HasValidityPeriod() {
+		_, _ = fmt.Fprintf(&builder, "NotBefore:%s, ", keys.NotBefore)
+		_, _ = fmt.Fprintf(&builder, "NotAfter:%s, ", keys.NotAfter)
+	}
+
 	_, _ = fmt.Fprintf(&builder, "Extensions:%s", keys.Extensions)
 
 	builder.WriteString("}")
 	return builder.String()
 }
 
+// ValidAt checks if the provided time falls
+// within the validity period of the Keys record,
+// records of versions without a validity period
+// are always considered valid
+func (keys *Keys) ValidAt(t time.Time) bool {
+	if !keys.Version.HasValidityPeriod() {
+		return true
+	}
+
+	return !t.Before(keys.NotBefore) && !t.After(keys.NotAfter)
+}
+
+// Valid checks if the Keys record is valid as
+// of the time specified by the evaluation context,
+// a nil context evaluates against the current time
+func (keys *Keys) Valid(ectx *EvalContext) bool {
+	return keys.ValidAt(ectx.Now())
+}
+
 // MarshalBinary will attempt to marshal the contents
 // of the Keys record into a binary format specified
 // by the ESNI specification
 func (keys Keys) MarshalBinary() ([]byte, error) {
+	codec := keys.Version.Codec()
+	if codec == nil {
+		return nil, unsupportedVersionError(keys.Version)
+	}
+
+	return codec.MarshalKeys(&keys)
+}
+
+// marshalLayout will marshal the Keys record using
+// the layout of its version, it is used by the codec
+// of each version built into the package
+func (keys *Keys) marshalLayout() ([]byte, error) {
 	var data bytes.Buffer
 
 	if err := binary.Write(&data, binary.BigEndian, keys.Version); err != nil {
 		return nil, errors.Wrap(err, "write version")
 	}
 
-	if _, err := data.Write([]byte{0x0, 0x0, 0x0, 0x0}); err != nil {
-		return nil, errors.Wrap(err, "write empty checksum")
+	if keys.Version.HasChecksum() {
+		if _, err := data.Write([]byte{0x0, 0x0, 0x0, 0x0}); err != nil {
+			return nil, errors.Wrap(err, "write empty checksum")
+		}
 	}
 
 	if err := keys.marshalPublicName(&data); err != nil {
@@ -125,8 +173,10 @@ func (keys Keys) MarshalBinary() ([]byte, error) {
 		return nil, errors.Wrap(err, "write padded length")
 	}
 
-	if err := keys.marshalValidityPeriod(&data); err != nil {
-		return nil, errors.Wrap(err, "marshal validity period")
+	if keys.Version.HasValidityPeriod() {
+		if err := keys.marshalValidityPeriod(&data); err != nil {
+			return nil, errors.Wrap(err, "marshal validity period")
+		}
 	}
 
 	if err := keys.marshalExtensions(&data); err != nil {
@@ -134,54 +184,266 @@ func (keys Keys) MarshalBinary() ([]byte, error) {
 	}
 
 	final := data.Bytes()
-	sum := sha256.Sum256(final)
+	if keys.Version.HasChecksum() {
+		sum := computeChecksum(final)
+		copy(final[2:6], sum[:])
+	}
 
-	copy(final[2:6], sum[:4])
 	return final, nil
 }
 
 // UnmarshalBinary will attempt to unmarshal and parse
 // information about a Keys record from the binary data
-// provided
+// provided.
+//
+// The provided data is treated as read-only and no
+// references to it are retained by the record.
 func (keys *Keys) UnmarshalBinary(b []byte) error {
-	keys.Version = Version(binary.BigEndian.Uint16(b[0:]))
+	_, err := keys.unmarshal(b, DefaultLimits)
+	return err
+}
 
-	copy(keys.Checksum[:], b[2:])
-	copy(b[2:], []byte{0x00, 0x00, 0x00, 0x00})
+// unmarshal will attempt to unmarshal a single Keys
+// record from the start of the binary data, returning
+// the number of bytes occupied by the record.
+//
+// For versions carrying a checksum it is verified
+// against the bytes of the record only, any data
+// following it is ignored. The provided limits are
+// enforced while parsing, the record is parsed from
+// at most MaxRecordSize bytes of the data so an
+// oversized record is rejected before its vectors
+// are read.
+func (keys *Keys) unmarshal(b []byte, limits Limits) (int, error) {
+	if len(b) < 2 {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for version")
+	}
 
-	sum := sha256.Sum256(b)
-	if bytes.Compare(keys.Checksum[:], sum[:4]) != 0 {
-		return ErrChecksumMismatch
+	version := Version(binary.BigEndian.Uint16(b[0:]))
+
+	codec := version.Codec()
+	if codec == nil {
+		return 0, unsupportedVersionError(version)
 	}
 
-	reader := bytes.NewReader(b[6:])
-	if err := keys.unmarshalPublicName(reader); err != nil {
-		return errors.Wrap(err, "unmarshal public name")
+	window := b
+	if limits.MaxRecordSize > 0 && len(window) > limits.MaxRecordSize {
+		window = window[:limits.MaxRecordSize]
 	}
 
-	if err := keys.unmarshalKeyShareList(reader); err != nil {
-		return errors.Wrap(err, "unmarshal key share list")
+	keys.Version = version
+	n, err := codec.UnmarshalKeys(keys, window, limits)
+	if err != nil && len(window) < len(b) && isTruncated(err) {
+		return 0, limits.check("record size", len(b), limits.MaxRecordSize)
+	}
+
+	return n, err
+}
+
+// unmarshalLayout will unmarshal a single Keys record
+// using the layout of the version already read into
+// the record, it is used by the codec of each version
+// built into the package
+func (keys *Keys) unmarshalLayout(b []byte, limits Limits) (int, error) {
+	keys.Checksum = [4]byte{}
+
+	if len(b) < keys.Version.headerSize() {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for checksum")
+	}
+
+	if keys.Version.HasChecksum() {
+		copy(keys.Checksum[:], b[2:])
+	}
+
+	reader := bytes.NewReader(b[keys.Version.headerSize():])
+	if err := keys.unmarshalPublicName(reader, limits); err != nil {
+		return 0, errors.Wrap(err, "unmarshal public name")
+	}
+
+	if err := keys.unmarshalKeyShareList(reader, limits); err != nil {
+		return 0, errors.Wrap(err, "unmarshal key share list")
 	}
 
 	if err := keys.unmarshalCipherSuites(reader); err != nil {
-		return errors.Wrap(err, "unmarshal cipher suite list")
+		return 0, errors.Wrap(err, "unmarshal cipher suite list")
 	}
 
 	if err := binary.Read(reader, binary.BigEndian, &keys.PaddedLength); err != nil {
-		return errors.Wrap(err, "read padded length")
+		return 0, errors.Wrap(err, "read padded length")
+	}
+
+	keys.NotBefore, keys.NotAfter = time.Time{}, time.Time{}
+	if keys.Version.HasValidityPeriod() {
+		if err := keys.unmarshalValidityPeriod(reader); err != nil {
+			return 0, errors.Wrap(err, "unmarshal validity period")
+		}
+	}
+
+	if err := keys.unmarshalExtensions(reader, limits); err != nil {
+		return 0, errors.Wrap(err, "unmarshal extensions list")
+	}
+
+	n := len(b) - reader.Len()
+	if err := limits.check("record size", n, limits.MaxRecordSize); err != nil {
+		return 0, err
+	}
+
+	if keys.Version.HasChecksum() {
+		if sum := computeChecksum(b[:n]); !bytes.Equal(keys.Checksum[:], sum[:]) {
+			return 0, ErrChecksumMismatch
+		}
+	}
+
+	return n, nil
+}
+
+// ComputeChecksum will marshal the Keys record and
+// return the checksum of the resulting binary data,
+// allowing for the Checksum field to be re-stamped
+// after the fields of the record have been edited.
+//
+// ErrNoChecksum is returned if the version of the
+// record doesn't carry a checksum.
+func (keys Keys) ComputeChecksum() ([4]byte, error) {
+	var sum [4]byte
+	if !keys.Version.HasChecksum() {
+		return sum, ErrNoChecksum
+	}
+
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return sum, err
+	}
+
+	copy(sum[:], data[2:6])
+	return sum, nil
+}
+
+// RecordDigest will marshal the Keys record and
+// return the digest of the resulting binary data using
+// the hash of the cipher suite, it identifies the record
+// used by a client to encrypt the SNI
+func (keys Keys) RecordDigest(suite CipherSuite) ([]byte, error) {
+	if !suite.Hash().Available() {
+		return nil, errors.Errorf("unsupported cipher suite %s", suite)
+	}
+
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := keys.unmarshalValidityPeriod(reader); err != nil {
-		return errors.Wrap(err, "unmarshal validity period")
+	digest := suite.Hash().New()
+	digest.Write(data)
+
+	return digest.Sum(nil), nil
+}
+
+// RecordDigests returns the record digest of the Keys
+// record for each of its supported cipher suites, the
+// record is marshalled once and hashed once for each
+// distinct hash function, such as SHA-256 and SHA-384
+func (keys Keys) RecordDigests() (map[CipherSuite][]byte, error) {
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := keys.unmarshalExtensions(reader); err != nil {
-		return errors.Wrap(err, "unmarshal extensions list")
+	digests := make(map[CipherSuite][]byte, len(keys.CipherSuites))
+	byHash := make(map[crypto.Hash][]byte)
+
+	for _, suite := range keys.CipherSuites {
+		hash := suite.Hash()
+		if !suite.Supported() || !hash.Available() {
+			continue
+		}
+
+		if _, ok := byHash[hash]; !ok {
+			digest := hash.New()
+			digest.Write(data)
+			byHash[hash] = digest.Sum(nil)
+		}
+
+		digests[suite] = byHash[hash]
+	}
+
+	return digests, nil
+}
+
+// VerifyChecksum will verify the checksum included in
+// the raw binary Keys record without unmarshalling the
+// record, the provided data is not modified.
+//
+// ErrNoChecksum is returned if the version of the
+// record doesn't carry a checksum.
+func VerifyChecksum(raw []byte) error {
+	if len(raw) < 2 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for version")
+	}
+
+	if version := Version(binary.BigEndian.Uint16(raw)); !version.supported() {
+		return unsupportedVersionError(version)
+	} else if !version.HasChecksum() {
+		return ErrNoChecksum
+	}
+
+	if len(raw) < 6 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for checksum")
+	}
+
+	sum := computeChecksum(raw)
+	if !bytes.Equal(raw[2:6], sum[:]) {
+		return ErrChecksumMismatch
 	}
 
 	return nil
 }
 
+// computeChecksum calculates the checksum of the
+// raw binary Keys record using the digest algorithm
+// of its version, the checksum field of the record
+// is treated as zero without modifying the provided
+// data
+func computeChecksum(raw []byte) (sum [4]byte) {
+	hash := Version(binary.BigEndian.Uint16(raw)).ChecksumHash().New()
+	hash.Write(raw[:2])
+	hash.Write([]byte{0x00, 0x00, 0x00, 0x00})
+	hash.Write(raw[6:])
+
+	copy(sum[:], hash.Sum(nil))
+	return
+}
+
+// MarshalText will marshal the Keys record into
+// its binary format and encode it using standard
+// base64, producing a value ready to be published
+// in a DNS TXT record
+func (keys Keys) MarshalText() ([]byte, error) {
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(text, data)
+
+	return text, nil
+}
+
+// UnmarshalText will decode the standard base64
+// value, as found in a DNS TXT record, and attempt
+// to unmarshal the Keys record from the decoded data
+func (keys *Keys) UnmarshalText(text []byte) error {
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		return errors.Wrap(err, "decode base64")
+	}
+
+	return keys.UnmarshalBinary(data[:n])
+}
+
 // marshalPublicName will write the length of
 // the public name field along with the value
 // of the field
@@ -190,7 +452,7 @@ func (keys Keys) marshalPublicName(data *bytes.Buffer) error {
 	//           status this will need to be removed
 	//           as it will most likely be mandatory
 	//           for all versions
-	if keys.Version < VersionDraft03 {
+	if keys.Version.publicNameLengthSize() == 0 {
 		return nil
 	}
 
@@ -200,7 +462,11 @@ func (keys Keys) marshalPublicName(data *bytes.Buffer) error {
 		return errors.New("public name is too large")
 	}
 
-	if err := data.WriteByte(uint8(len(keys.PublicName))); err != nil {
+	if keys.Version.publicNameLengthSize() == 2 {
+		if err := binary.Write(data, binary.BigEndian, uint16(len(keys.PublicName))); err != nil {
+			return errors.Wrap(err, "write public name length")
+		}
+	} else if err := data.WriteByte(uint8(len(keys.PublicName))); err != nil {
 		return errors.Wrap(err, "write public name length")
 	}
 
@@ -214,26 +480,43 @@ func (keys Keys) marshalPublicName(data *bytes.Buffer) error {
 // unmarshalPublicName will read the length of
 // the public name and attempt to read the public
 // name
-func (keys *Keys) unmarshalPublicName(reader *bytes.Reader) error {
+func (keys *Keys) unmarshalPublicName(reader *bytes.Reader, limits Limits) error {
 	// TODO(lh): Once the ESNI specific leaves draft
 	//           status this will need to be removed
 	//           as it will most likely be mandatory
 	//           for all versions
-	if keys.Version < VersionDraft03 {
+	if keys.Version.publicNameLengthSize() == 0 {
 		return nil
 	}
 
-	nameLength, err := reader.ReadByte()
-	if err != nil {
-		return errors.Wrap(err, "read length")
+	var nameLength uint16
+	if keys.Version.publicNameLengthSize() == 2 {
+		if err := binary.Read(reader, binary.BigEndian, &nameLength); err != nil {
+			return errors.Wrap(err, "read length")
+		}
+	} else {
+		length, err := reader.ReadByte()
+		if err != nil {
+			return errors.Wrap(err, "read length")
+		}
+
+		nameLength = uint16(length)
 	}
 
 	if nameLength == 0 {
 		return errors.New("public name is empty")
 	}
 
+	if err := limits.check("public name length", int(nameLength), limits.MaxPublicNameLength); err != nil {
+		return err
+	}
+
+	if err := checkLength(reader, int(nameLength)); err != nil {
+		return err
+	}
+
 	name := make([]byte, nameLength)
-	if _, err := reader.Read(name); err != nil {
+	if _, err := io.ReadFull(reader, name); err != nil {
 		return err
 	}
 
@@ -268,7 +551,7 @@ func (keys Keys) marshalKeyShareList(data *bytes.Buffer) error {
 // unmarshalKeyShareList will read the length of the
 // entry list and attempt to unmarshal a KeyShareEntryList
 // from the read data
-func (keys *Keys) unmarshalKeyShareList(reader *bytes.Reader) error {
+func (keys *Keys) unmarshalKeyShareList(reader *bytes.Reader, limits Limits) error {
 	var listLen uint16
 	if err := binary.Read(reader, binary.BigEndian, &listLen); err != nil {
 		return errors.Wrap(err, "read key share list size")
@@ -278,8 +561,12 @@ func (keys *Keys) unmarshalKeyShareList(reader *bytes.Reader) error {
 		return errors.New("key share list is empty")
 	}
 
+	if err := checkLength(reader, int(listLen)); err != nil {
+		return err
+	}
+
 	data := make([]byte, listLen)
-	if _, err := reader.Read(data); err != nil {
+	if _, err := io.ReadFull(reader, data); err != nil {
 		return errors.Wrap(err, "read key share list")
 	}
 
@@ -288,7 +575,7 @@ func (keys *Keys) unmarshalKeyShareList(reader *bytes.Reader) error {
 		return err
 	}
 
-	return nil
+	return limits.check("key share count", len(keys.Keys), limits.MaxKeyShares)
 }
 
 // marshalCipherSuites will write the binary size
@@ -321,6 +608,10 @@ func (keys *Keys) unmarshalCipherSuites(reader *bytes.Reader) error {
 		return errors.New("invalid cipher suite list size")
 	}
 
+	if err := checkLength(reader, int(suitesLen)); err != nil {
+		return err
+	}
+
 	keys.CipherSuites = make([]CipherSuite, suitesLen/2)
 	for i := range keys.CipherSuites {
 		var suite uint16
@@ -394,7 +685,7 @@ func (keys *Keys) marshalExtensions(data *bytes.Buffer) error {
 // unmarshalExtensions will read the binary length of
 // the extensions list and will attempt to unmarshal
 // a ExtensionList from that data
-func (keys *Keys) unmarshalExtensions(reader *bytes.Reader) error {
+func (keys *Keys) unmarshalExtensions(reader *bytes.Reader, limits Limits) error {
 	var extsLen uint16
 	if err := binary.Read(reader, binary.BigEndian, &extsLen); err != nil {
 		return errors.Wrap(err, "read extensions list length")
@@ -404,8 +695,12 @@ func (keys *Keys) unmarshalExtensions(reader *bytes.Reader) error {
 		return nil
 	}
 
+	if err := checkLength(reader, int(extsLen)); err != nil {
+		return err
+	}
+
 	extsData := make([]byte, extsLen)
-	if _, err := reader.Read(extsData); err != nil {
+	if _, err := io.ReadFull(reader, extsData); err != nil {
 		return errors.Wrap(err, "read extensions list")
 	}
 
@@ -414,5 +709,5 @@ func (keys *Keys) unmarshalExtensions(reader *bytes.Reader) error {
 		return err
 	}
 
-	return nil
+	return limits.check("extension count", len(keys.Extensions), limits.MaxExtensions)
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// draft04Record is a draft-04 ESNIKeys record
+// assembled by hand from the layout in the
+// draft, it has no checksum and a two byte
+// public name length
+var draft04Record = mustDecodeTestHex("" +
+	"ff03" + // version
+	"000b" + "6578616d706c652e636f6d" + // public_name "example.com"
+	"0024" + "001d" + "0020" + // keys, x25519 key share
+	"9fd7ad6dcff4298dd3f96d5b1b2af910a0535b1488d7f8fabb349a982880b615" +
+	"0002" + "1301" + // cipher_suites, TLS_AES_128_GCM_SHA256
+	"0104" + // padded_length 260
+	"000000005d7c1c00" + // not_before
+	"000000005d7d6d80" + // not_after
+	"0000") // extensions
+
+func mustDecodeTestHex(value string) []byte {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return decoded
+}
+
+func TestKeysDraft04RoundTrip(t *testing.T) {
+	keys := new(Keys)
+	if err := keys.UnmarshalBinary(draft04Record); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	if keys.Version != VersionDraft04 {
+		t.Errorf("Version = %s, want %s", keys.Version, VersionDraft04)
+	}
+
+	if keys.PublicName != "example.com" {
+		t.Errorf("PublicName = %q, want %q", keys.PublicName, "example.com")
+	}
+
+	if keys.Checksum != [4]byte{} {
+		t.Errorf("Checksum = %x, want none", keys.Checksum)
+	}
+
+	if len(keys.Keys) != 1 || len(keys.CipherSuites) != 1 || keys.PaddedLength != 260 {
+		t.Errorf("unexpected record contents %s", keys)
+	}
+
+	if want := time.Unix(0x5d7c1c00, 0); !keys.NotBefore.Equal(want) {
+		t.Errorf("NotBefore = %s, want %s", keys.NotBefore, want)
+	}
+
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(data, draft04Record) {
+		t.Fatalf("MarshalBinary() = %x, want %x", data, draft04Record)
+	}
+}
+
+func TestKeysDraft04TruncatedPublicName(t *testing.T) {
+	tests := map[string][]byte{
+		"length": draft04Record[:3],
+		"name":   draft04Record[:8],
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := new(Keys).UnmarshalBinary(data)
+			if errors.Cause(err) != io.ErrUnexpectedEOF {
+				t.Fatalf("UnmarshalBinary() error = %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+// draft05Record is the draft04Record in the
+// draft-05 layout, which drops the validity
+// period in favour of the DNS TTL
+var draft05Record = mustDecodeTestHex("" +
+	"ff04" + // version
+	"000b" + "6578616d706c652e636f6d" + // public_name "example.com"
+	"0024" + "001d" + "0020" + // keys, x25519 key share
+	"9fd7ad6dcff4298dd3f96d5b1b2af910a0535b1488d7f8fabb349a982880b615" +
+	"0002" + "1301" + // cipher_suites, TLS_AES_128_GCM_SHA256
+	"0104" + // padded_length 260
+	"0000") // extensions
+
+func TestKeysDraft05RoundTrip(t *testing.T) {
+	keys := new(Keys)
+	if err := keys.UnmarshalBinary(draft05Record); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	if keys.Version != VersionDraft05 || keys.PublicName != "example.com" || keys.PaddedLength != 260 {
+		t.Errorf("unexpected record contents %s", keys)
+	}
+
+	if !keys.NotBefore.IsZero() || !keys.NotAfter.IsZero() {
+		t.Errorf("validity period = %s to %s, want none", keys.NotBefore, keys.NotAfter)
+	}
+
+	if !keys.ValidAt(time.Time{}) {
+		t.Error("expected a record without a validity period to always be valid")
+	}
+
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(data, draft05Record) {
+		t.Fatalf("MarshalBinary() = %x, want %x", data, draft05Record)
+	}
+
+	keys.Version = VersionDraft04
+	if data, err = keys.MarshalBinary(); err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	} else if len(data) != len(draft05Record)+16 {
+		t.Fatalf("draft-04 record is %d bytes, want %d", len(data), len(draft05Record)+16)
+	}
+}
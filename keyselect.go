@@ -0,0 +1,161 @@
+package esni
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// SelectionPolicy specifies how SelectKeys chooses
+// between several valid Keys records, the preferences
+// are applied in the order of the fields
+type SelectionPolicy struct {
+	// EvalContext specifies the time the validity of
+	// the records is evaluated at, records that aren't
+	// valid are never selected
+	EvalContext *EvalContext
+
+	// Addresses specifies the addresses resolved for
+	// the server, records with an address set containing
+	// one of them are preferred
+	Addresses []net.IP
+
+	// Groups specifies the preferred key share groups
+	// in order, records offering an earlier group are
+	// preferred
+	Groups []Group
+
+	// CipherSuites specifies the preferred cipher suites
+	// in order, records offering an earlier suite are
+	// preferred
+	CipherSuites []CipherSuite
+
+	// PreferLongestValidity specifies if records that
+	// remain valid for longer are preferred, otherwise
+	// the order of the records is kept
+	PreferLongestValidity bool
+}
+
+// keysCandidate represents a valid record
+// ranked by the selection policy
+type keysCandidate struct {
+	index        int
+	addressMatch bool
+	group        int
+	suite        int
+	remaining    time.Duration
+}
+
+// SelectKeys returns the record preferred by the policy
+// among the records that are well formed and valid as of
+// its evaluation context, ErrNoKeys is returned if none
+// of the records are usable
+func SelectKeys(records []Keys, policy SelectionPolicy) (*Keys, error) {
+	now := policy.EvalContext.Now()
+
+	var candidates []keysCandidate
+	for i := range records {
+		keys := &records[i]
+		if keys.Validate() != nil || !keys.Valid(policy.EvalContext) {
+			continue
+		}
+
+		candidate := keysCandidate{
+			index:        i,
+			addressMatch: keys.matchesAddress(policy.Addresses),
+			group:        math.MaxInt32,
+			suite:        math.MaxInt32,
+			remaining:    math.MaxInt64,
+		}
+
+		for _, entry := range keys.Keys {
+			if pref := groupPreference(policy.Groups, entry.Group); pref < candidate.group {
+				candidate.group = pref
+			}
+		}
+
+		for _, suite := range keys.CipherSuites {
+			if pref := suitePreference(policy.CipherSuites, suite); pref < candidate.suite {
+				candidate.suite = pref
+			}
+		}
+
+		if keys.Version.HasValidityPeriod() {
+			candidate.remaining = keys.NotAfter.Sub(now)
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+
+		switch {
+		case a.addressMatch != b.addressMatch:
+			return a.addressMatch
+		case a.group != b.group:
+			return a.group < b.group
+		case a.suite != b.suite:
+			return a.suite < b.suite
+		case policy.PreferLongestValidity:
+			return a.remaining > b.remaining
+		}
+
+		return false
+	})
+
+	return &records[candidates[0].index], nil
+}
+
+// matchesAddress returns if the address set
+// extension of the record contains any of
+// the addresses
+func (keys *Keys) matchesAddress(addresses []net.IP) bool {
+	for _, ext := range keys.Extensions {
+		set, ok := ext.(*AddressSet)
+		if !ok {
+			continue
+		}
+
+		for _, address := range set.Addresses {
+			for _, resolved := range addresses {
+				if address.Equal(resolved) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// groupPreference returns the position of the group
+// in the preferences, groups that aren't preferred
+// are ranked after all those that are
+func groupPreference(groups []Group, group Group) int {
+	for i := range groups {
+		if groups[i] == group {
+			return i
+		}
+	}
+
+	return len(groups)
+}
+
+// suitePreference returns the position of the suite
+// in the preferences, suites that aren't preferred
+// are ranked after all those that are
+func suitePreference(suites []CipherSuite, suite CipherSuite) int {
+	for i := range suites {
+		if suites[i] == suite {
+			return i
+		}
+	}
+
+	return len(suites)
+}
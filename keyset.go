@@ -0,0 +1,308 @@
+package esni
+
+import (
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ServerKeySet holds the Keys records a server is
+// actively accepting encrypted SNIs for along with
+// their private keys, allowing keys to be rotated
+// with an overlap where clients may use any of the
+// records.
+//
+// Records are indexed by their record digest for
+// each of their cipher suites, it is safe for
+// concurrent use.
+type ServerKeySet struct {
+	mu      sync.RWMutex
+	records []*serverKeyRecord
+	digests map[string]*serverKeyRecord
+	replay  *ReplayCache
+	limiter *RateLimiter
+	hooks   []FailureHook
+	options DecryptOptions
+}
+
+// serverKeyRecord pairs a Keys record
+// with its private keys
+type serverKeyRecord struct {
+	keys        *Keys
+	privateKeys *PrivateKeys
+	digests     []string
+}
+
+// DecryptResult describes the outcome of
+// decrypting an SNI with a ServerKeySet
+type DecryptResult struct {
+	// ServerName specifies the
+	// decrypted server name
+	ServerName string
+
+	// Nonce specifies the nonce that must
+	// be echoed to the client
+	Nonce [NonceSize]byte
+
+	// Keys specifies the Keys record
+	// that decrypted the SNI
+	Keys *Keys
+
+	// Trial specifies if the record was found
+	// by trial decryption because the record
+	// digest sent by the client was unknown
+	Trial bool
+}
+
+// NewServerKeySet returns a new
+// empty ServerKeySet
+func NewServerKeySet() *ServerKeySet {
+	return &ServerKeySet{digests: make(map[string]*serverKeyRecord)}
+}
+
+// SetReplayCache sets the replay cache that every
+// encrypted SNI decrypted by the set is checked
+// against, a nil cache disables replay detection
+func (set *ServerKeySet) SetReplayCache(cache *ReplayCache) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	set.replay = cache
+}
+
+// SetRateLimiter sets the limiter charged for each
+// decryption failure, sources that have exhausted it
+// are refused with ErrRateLimited by DecryptFrom
+func (set *ServerKeySet) SetRateLimiter(limiter *RateLimiter) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	set.limiter = limiter
+}
+
+// AddFailureHook adds a hook to be called
+// each time the set fails to decrypt an SNI
+func (set *ServerKeySet) AddFailureHook(hook FailureHook) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	set.hooks = append(set.hooks, hook)
+}
+
+// SetDecryptOptions sets the options used when
+// decrypting SNIs, such as the constant-time mode
+func (set *ServerKeySet) SetDecryptOptions(opts DecryptOptions) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	set.options = opts
+}
+
+// Add adds the Keys record and its private keys to
+// the set, the private keys must cover every key share
+// of the record
+func (set *ServerKeySet) Add(keys *Keys, privateKeys *PrivateKeys) error {
+	if err := privateKeys.Covers(keys); err != nil {
+		return err
+	}
+
+	record := &serverKeyRecord{keys: keys, privateKeys: privateKeys}
+
+	digests, err := keys.RecordDigests()
+	if err != nil {
+		return errors.Wrap(err, "compute record digests")
+	}
+
+	seen := make(map[string]bool, len(digests))
+	for _, digest := range digests {
+		if !seen[string(digest)] {
+			seen[string(digest)] = true
+			record.digests = append(record.digests, string(digest))
+		}
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for _, digest := range record.digests {
+		if _, exists := set.digests[digest]; exists {
+			return errors.New("record is already in the set")
+		}
+	}
+
+	for _, digest := range record.digests {
+		set.digests[digest] = record
+	}
+
+	set.records = append(set.records, record)
+	return nil
+}
+
+// Remove removes the Keys record from the set,
+// returning if the record was in the set
+func (set *ServerKeySet) Remove(keys *Keys) bool {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	for i, record := range set.records {
+		if record.keys != keys {
+			continue
+		}
+
+		for _, digest := range record.digests {
+			delete(set.digests, digest)
+		}
+
+		set.records = append(set.records[:i], set.records[i+1:]...)
+		return true
+	}
+
+	return false
+}
+
+// Prune removes every record that is no longer
+// valid at the time of the evaluation context,
+// returning the number of records removed
+func (set *ServerKeySet) Prune(ectx *EvalContext) int {
+	var expired []*Keys
+
+	for _, keys := range set.Keys() {
+		if keys.Version.HasValidityPeriod() && ectx.Now().After(keys.NotAfter) {
+			expired = append(expired, keys)
+		}
+	}
+
+	for _, keys := range expired {
+		set.Remove(keys)
+	}
+
+	return len(expired)
+}
+
+// Keys returns the Keys records
+// in the set
+func (set *ServerKeySet) Keys() []*Keys {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	keys := make([]*Keys, len(set.records))
+	for i := range set.records {
+		keys[i] = set.records[i].keys
+	}
+
+	return keys
+}
+
+// Decrypt decrypts the SNI sent by a client using the
+// record matching the record digest it sent, if no record
+// matches the digest each record with a key share for the
+// group of the client is tried in turn.
+//
+// The client hello random and client key share are
+// those expected by DecryptSNI. If a replay cache is
+// set ErrReplayDetected is returned for an encrypted
+// SNI, or nonce, that has already been seen.
+func (set *ServerKeySet) Decrypt(encrypted ClientEncryptedSNI, clientHelloRandom, clientKeyShare []byte) (*DecryptResult, error) {
+	return set.DecryptFrom(nil, encrypted, clientHelloRandom, clientKeyShare)
+}
+
+// DecryptFrom decrypts the SNI sent by the client at the
+// source address in the same way as Decrypt, failures are
+// reported to the failure hooks and rate limiter of the
+// set with the source address.
+//
+// ErrRateLimited is returned without attempting
+// decryption if the source has been rate limited.
+func (set *ServerKeySet) DecryptFrom(source net.Addr, encrypted ClientEncryptedSNI, clientHelloRandom, clientKeyShare []byte) (*DecryptResult, error) {
+	set.mu.RLock()
+	limiter := set.limiter
+	hooks := set.hooks
+	set.mu.RUnlock()
+
+	if limiter != nil && !limiter.Allow(source) {
+		return nil, ErrRateLimited
+	}
+
+	result, err := set.decryptChecked(encrypted, clientHelloRandom, clientKeyShare)
+	if err != nil {
+		failure := DecryptFailure{Source: source, RecordDigest: encrypted.RecordDigest, Err: err}
+
+		if limiter != nil {
+			limiter.DecryptFailed(failure)
+		}
+
+		for _, hook := range hooks {
+			hook.DecryptFailed(failure)
+		}
+
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// decryptChecked decrypts the SNI checking the
+// encrypted SNI and nonce against the replay cache
+func (set *ServerKeySet) decryptChecked(encrypted ClientEncryptedSNI, clientHelloRandom, clientKeyShare []byte) (*DecryptResult, error) {
+	set.mu.RLock()
+	replay := set.replay
+	set.mu.RUnlock()
+
+	if replay != nil {
+		if err := replay.CheckEncryptedSNI(encrypted); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := set.decrypt(encrypted, clientHelloRandom, clientKeyShare)
+	if err != nil {
+		return nil, err
+	}
+
+	if replay != nil {
+		if err := replay.CheckNonce(result.Nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// decrypt decrypts the SNI using the record matching
+// the record digest, falling back to trial decryption
+func (set *ServerKeySet) decrypt(encrypted ClientEncryptedSNI, clientHelloRandom, clientKeyShare []byte) (*DecryptResult, error) {
+	set.mu.RLock()
+	record, ok := set.digests[string(encrypted.RecordDigest)]
+	records := append([]*serverKeyRecord(nil), set.records...)
+	opts := set.options
+	set.mu.RUnlock()
+
+	if ok {
+		name, nonce, err := DecryptSNIWithOptions(encrypted, record.keys, record.privateKeys, clientHelloRandom, clientKeyShare, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DecryptResult{ServerName: name, Nonce: nonce, Keys: record.keys}, nil
+	}
+
+	for _, record := range records {
+		serverShare := findKeyShare(record.keys.Keys, encrypted.KeyShare.Group)
+		if serverShare == nil {
+			continue
+		}
+
+		entry, ok := record.privateKeys.LookupKeyShare(*serverShare)
+		if !ok {
+			continue
+		}
+
+		name, nonce, err := decryptSNI(encrypted, record.keys, entry, clientHelloRandom, clientKeyShare, false, opts)
+		if err == nil {
+			return &DecryptResult{ServerName: name, Nonce: nonce, Keys: record.keys, Trial: true}, nil
+		}
+	}
+
+	return nil, ErrDecryptionFailed
+}
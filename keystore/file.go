@@ -0,0 +1,139 @@
+package keystore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// fileExtension specifies the extension of
+	// the files written by FileStore
+	fileExtension = ".pem"
+)
+
+// FileStore implements a KeyStore that stores each
+// entry as a PEM file named after the record digest
+// in a directory, files are only readable by the
+// owner of the process
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore that stores
+// entries in the directory, which is created if
+// it doesn't exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "create key store directory")
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the path of the
+// file for the record digest
+func (store *FileStore) path(digest []byte) (string, error) {
+	name, err := entryName(digest)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(store.dir, name+fileExtension), nil
+}
+
+// Put stores the private keys for the record digest,
+// the file is written to a temporary file and renamed
+// so that readers never observe a partial entry
+func (store *FileStore) Put(ctx context.Context, digest []byte, keys *esni.PrivateKeys) error {
+	path, err := store.path(digest)
+	if err != nil {
+		return err
+	}
+
+	data, err := esni.EncodePrivateKeysPEM(keys)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(store.dir, ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "create temporary file")
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "write temporary file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "close temporary file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "rename temporary file")
+}
+
+// Get returns the private keys
+// stored for the record digest
+func (store *FileStore) Get(ctx context.Context, digest []byte) (*esni.PrivateKeys, error) {
+	path, err := store.path(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "read key file")
+	}
+
+	keys, _, err := esni.DecodePrivateKeysPEM(data)
+	return keys, err
+}
+
+// List returns the record digest of
+// every entry in the directory
+func (store *FileStore) List(ctx context.Context) ([][]byte, error) {
+	files, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key store directory")
+	}
+
+	var digests [][]byte
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExtension) {
+			continue
+		}
+
+		if digest, ok := parseEntryName(strings.TrimSuffix(file.Name(), fileExtension)); ok {
+			digests = append(digests, digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// Delete removes the file of
+// the record digest
+func (store *FileStore) Delete(ctx context.Context, digest []byte) error {
+	path, err := store.path(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "remove key file")
+	}
+
+	return nil
+}
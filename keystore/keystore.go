@@ -0,0 +1,79 @@
+// Package keystore provides persistent storage for
+// the private keys of ESNI Keys records, indexed by
+// the digest of the record they belong to
+package keystore
+
+import (
+	"context"
+	"encoding/hex"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when no private
+	// keys are stored for a record digest
+	ErrNotFound = errors.New("private keys not found")
+)
+
+// KeyStore represents a store of the private keys
+// of Keys records, each entry is keyed by the record
+// digest produced by Keys.RecordDigest
+type KeyStore interface {
+	// Put stores the private keys for the record
+	// digest, replacing any existing entry
+	Put(ctx context.Context, digest []byte, keys *esni.PrivateKeys) error
+
+	// Get returns the private keys stored for the
+	// record digest, ErrNotFound is returned if
+	// there is no entry for the digest
+	Get(ctx context.Context, digest []byte) (*esni.PrivateKeys, error)
+
+	// List returns the record digest of
+	// every entry in the store
+	List(ctx context.Context) ([][]byte, error)
+
+	// Delete removes the entry for the record
+	// digest, ErrNotFound is returned if there
+	// is no entry for the digest
+	Delete(ctx context.Context, digest []byte) error
+}
+
+// PutRecord stores the private keys of the Keys
+// record in the store, keyed by the record digest
+// for the first cipher suite of the record
+func PutRecord(ctx context.Context, store KeyStore, keys *esni.Keys, privateKeys *esni.PrivateKeys) ([]byte, error) {
+	if len(keys.CipherSuites) == 0 {
+		return nil, errors.New("record has no cipher suites")
+	}
+
+	if err := privateKeys.Covers(keys); err != nil {
+		return nil, err
+	}
+
+	digest, err := keys.RecordDigest(keys.CipherSuites[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "compute record digest")
+	}
+
+	return digest, store.Put(ctx, digest, privateKeys)
+}
+
+// entryName returns the name of the
+// entry for the record digest
+func entryName(digest []byte) (string, error) {
+	if len(digest) == 0 {
+		return "", errors.New("record digest is empty")
+	}
+
+	return hex.EncodeToString(digest), nil
+}
+
+// parseEntryName returns the record digest
+// of the entry name, ok is false if the name
+// isn't the name of an entry
+func parseEntryName(name string) (digest []byte, ok bool) {
+	digest, err := hex.DecodeString(name)
+	return digest, err == nil && len(digest) > 0
+}
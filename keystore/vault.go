@@ -0,0 +1,255 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// vaultFieldPrivateKeys specifies the field of the
+	// secret that holds the base64 encoding of the
+	// binary private keys
+	vaultFieldPrivateKeys = "private_keys"
+)
+
+// VaultConfig specifies the parameters used
+// by a VaultStore to reach a HashiCorp Vault
+// KV version 2 secrets engine
+type VaultConfig struct {
+	// Address specifies the base URL of the
+	// Vault server, such as https://vault:8200
+	Address string
+
+	// Token specifies the Vault token used
+	// to authenticate requests
+	Token string
+
+	// Namespace specifies the Vault Enterprise
+	// namespace of the secrets engine, if any
+	Namespace string
+
+	// Mount specifies the path the KV secrets
+	// engine is mounted at, if not set "secret"
+	// is used
+	Mount string
+
+	// Path specifies the path under the mount
+	// that entries are stored beneath
+	Path string
+
+	// Client specifies the HTTP client used to
+	// make requests, if not set http.DefaultClient
+	// is used
+	Client *http.Client
+}
+
+// VaultStore implements a KeyStore that stores each
+// entry as a secret in a HashiCorp Vault KV version 2
+// secrets engine, named after the record digest
+type VaultStore struct {
+	config VaultConfig
+}
+
+// NewVaultStore returns a VaultStore
+// using the provided configuration
+func NewVaultStore(config VaultConfig) (*VaultStore, error) {
+	if len(config.Address) == 0 {
+		return nil, errors.New("vault address is required")
+	}
+
+	if len(config.Mount) == 0 {
+		config.Mount = "secret"
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	config.Mount = strings.Trim(config.Mount, "/")
+	config.Path = strings.Trim(config.Path, "/")
+
+	return &VaultStore{config: config}, nil
+}
+
+// url returns the URL of the API endpoint of
+// the KV engine for the kind of request and
+// the entry name, which may be empty
+func (store *VaultStore) url(kind, name string) string {
+	parts := []string{store.config.Address, "v1", store.config.Mount, kind}
+	if len(store.config.Path) > 0 {
+		parts = append(parts, store.config.Path)
+	}
+
+	if len(name) > 0 {
+		parts = append(parts, name)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// do performs a request against the Vault API,
+// decoding the JSON response into out if it is
+// not nil, a 404 response returns ErrNotFound
+func (store *VaultStore) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encode request")
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", store.config.Token)
+	if len(store.config.Namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", store.config.Namespace)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := store.config.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "vault request")
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("vault returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	case out == nil || resp.StatusCode == http.StatusNoContent:
+		return nil
+	}
+
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decode response")
+}
+
+// Put writes the private keys for the
+// record digest as a new version of
+// the secret
+func (store *VaultStore) Put(ctx context.Context, digest []byte, keys *esni.PrivateKeys) error {
+	name, err := entryName(digest)
+	if err != nil {
+		return err
+	}
+
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "marshal private keys")
+	}
+
+	body := map[string]interface{}{
+		"data": map[string]string{vaultFieldPrivateKeys: base64.StdEncoding.EncodeToString(data)},
+	}
+
+	return store.do(ctx, http.MethodPost, store.url("data", name), body, nil)
+}
+
+// Get reads the latest version of the
+// secret for the record digest
+func (store *VaultStore) Get(ctx context.Context, digest []byte) (*esni.PrivateKeys, error) {
+	name, err := entryName(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := store.do(ctx, http.MethodGet, store.url("data", name), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	encoded, ok := resp.Data.Data[vaultFieldPrivateKeys]
+	if !ok {
+		return nil, errors.Errorf("secret has no %s field", vaultFieldPrivateKeys)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode private keys")
+	}
+
+	keys := new(esni.PrivateKeys)
+	if err := keys.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "unmarshal private keys")
+	}
+
+	return keys, nil
+}
+
+// List returns the record digest of every
+// secret beneath the configured path
+func (store *VaultStore) List(ctx context.Context) ([][]byte, error) {
+	var resp struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	err := store.do(ctx, "LIST", store.url("metadata", ""), nil, &resp)
+	if err == ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var digests [][]byte
+	for _, name := range resp.Data.Keys {
+		if digest, ok := parseEntryName(name); ok {
+			digests = append(digests, digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// Delete removes every version and the
+// metadata of the secret for the record
+// digest
+func (store *VaultStore) Delete(ctx context.Context, digest []byte) error {
+	name, err := entryName(digest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.Get(ctx, digest); err != nil {
+		return err
+	}
+
+	return store.do(ctx, http.MethodDelete, store.url("metadata", name), nil, nil)
+}
+
+// String returns a friendly representation
+// of the store
+func (store *VaultStore) String() string {
+	return fmt.Sprintf("vault:%s", store.url("data", ""))
+}
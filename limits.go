@@ -0,0 +1,76 @@
+package esni
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLimitExceeded is returned during unmarshalling
+	// of a Keys record when the record exceeds one of
+	// the configured parse limits
+	ErrLimitExceeded = errors.New("parse limit exceeded")
+
+	// DefaultLimits specifies the parse limits used
+	// by UnmarshalBinary, they comfortably fit every
+	// record seen in real deployments while protecting
+	// against hostile records
+	DefaultLimits = Limits{
+		MaxKeyShares:        16,
+		MaxExtensions:       16,
+		MaxPublicNameLength: maxPublicNameLength,
+		MaxRecordSize:       8192,
+	}
+)
+
+// Limits specifies the upper bounds enforced when
+// unmarshalling a Keys record from untrusted data,
+// a zero value for any limit disables that check
+type Limits struct {
+	// MaxKeyShares specifies the maximum number
+	// of key share entries in a record
+	MaxKeyShares int
+
+	// MaxExtensions specifies the maximum number
+	// of extensions in a record
+	MaxExtensions int
+
+	// MaxPublicNameLength specifies the maximum
+	// length of the public name in a record
+	MaxPublicNameLength int
+
+	// MaxRecordSize specifies the maximum size,
+	// in bytes, of a binary record
+	MaxRecordSize int
+}
+
+// check returns ErrLimitExceeded if the value
+// is larger than the specified maximum
+func (Limits) check(name string, value, max int) error {
+	if max > 0 && value > max {
+		return errors.Wrapf(ErrLimitExceeded, "%s of %d exceeds maximum of %d", name, value, max)
+	}
+
+	return nil
+}
+
+// checkLength ensures that a length declared in
+// a record can be satisfied by the data remaining
+// in the reader before any memory is allocated
+// for it
+func checkLength(reader *bytes.Reader, length int) error {
+	if length > reader.Len() {
+		return errors.Wrapf(io.ErrUnexpectedEOF, "declared length of %d exceeds remaining %d bytes", length, reader.Len())
+	}
+
+	return nil
+}
+
+// isTruncated reports whether the error was caused
+// by the data ending before a record was complete
+func isTruncated(err error) bool {
+	cause := errors.Cause(err)
+	return cause == io.ErrUnexpectedEOF || cause == io.EOF
+}
@@ -0,0 +1,59 @@
+package esni
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeRecordSizeLimit(t *testing.T) {
+	raw, err := newTestKeys(t).MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal keys: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		max     int
+		wantErr error
+	}{
+		{
+			name: "within limit",
+			data: raw,
+			max:  len(raw),
+		},
+		{
+			name: "trailing data beyond limit",
+			data: append(append([]byte{}, raw...), make([]byte, 64)...),
+			max:  len(raw),
+		},
+		{
+			name:    "record exceeds limit",
+			data:    raw,
+			max:     len(raw) - 1,
+			wantErr: ErrLimitExceeded,
+		},
+		{
+			name: "limit disabled",
+			data: raw,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limits := DefaultLimits
+			limits.MaxRecordSize = test.max
+
+			var keys Keys
+			n, err := keys.DecodeWithOptions(test.data, DecodeOptions{Limits: &limits})
+			if errors.Cause(err) != test.wantErr {
+				t.Fatalf("DecodeWithOptions() error = %v, want %v", err, test.wantErr)
+			}
+
+			if err == nil && n != len(raw) {
+				t.Errorf("DecodeWithOptions() consumed %d bytes, want %d", n, len(raw))
+			}
+		})
+	}
+}
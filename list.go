@@ -0,0 +1,74 @@
+package esni
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// TrailingDataError is returned when data remains
+// after the last Keys record that could be parsed
+// from a buffer
+type TrailingDataError struct {
+	// Offset specifies the position in the
+	// buffer where the trailing data starts
+	Offset int
+
+	// Data contains the trailing data
+	Data []byte
+
+	// Err specifies why the trailing data could
+	// not be parsed as a Keys record, if known
+	Err error
+}
+
+// Error returns a description of the trailing
+// data and why it could not be parsed
+func (err *TrailingDataError) Error() string {
+	msg := fmt.Sprintf("%d bytes of trailing data at offset %d", len(err.Data), err.Offset)
+	if err.Err != nil {
+		msg += ": " + err.Err.Error()
+	}
+
+	return msg
+}
+
+// Cause returns the reason the trailing data
+// could not be parsed as a Keys record
+func (err *TrailingDataError) Cause() error {
+	return err.Err
+}
+
+// ParseKeysList will attempt to parse multiple Keys
+// records that have been concatenated into a single
+// buffer, as done by some publishers in a single TXT
+// record.
+//
+// Every record that could be parsed is returned, if
+// data remains after the last record that could not
+// be parsed a *TrailingDataError describing it is also
+// returned along with the parsed records.
+func ParseKeysList(data []byte) ([]Keys, error) {
+	if len(data) == 0 {
+		return nil, errors.New("buffer is empty")
+	}
+
+	var list []Keys
+	for pos := 0; pos < len(data); {
+		var keys Keys
+
+		n, err := keys.unmarshal(data[pos:], DefaultLimits)
+		if err != nil {
+			if len(list) == 0 {
+				return nil, errors.Wrap(err, "unmarshal keys")
+			}
+
+			return list, &TrailingDataError{Offset: pos, Data: data[pos:], Err: err}
+		}
+
+		list = append(list, keys)
+		pos += n
+	}
+
+	return list, nil
+}
@@ -0,0 +1,74 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PaddingPolicy specifies how a padded length
+// is derived from the set of host names a server
+// expects to receive
+type PaddingPolicy struct {
+	// BucketSize specifies the multiple the padded
+	// length is rounded up to, larger buckets leak
+	// less information about the length of the name
+	BucketSize uint16
+
+	// Minimum specifies the smallest padded length
+	// that will be recommended
+	Minimum uint16
+}
+
+// DefaultPaddingPolicy specifies the padding policy
+// recommended by the ESNI specification, which rounds
+// the largest ServerNameList up to a multiple of 16
+var DefaultPaddingPolicy = PaddingPolicy{
+	BucketSize: 16,
+	Minimum:    MinPaddedLength,
+}
+
+// serverNameListSize returns the size of a
+// ServerNameList containing a single host name,
+// which is made up of the list length, name
+// type, name length and the name itself
+func serverNameListSize(hostname string) int {
+	return 2 + 1 + 2 + len(hostname)
+}
+
+// RecommendPaddedLength computes a padded length that
+// hides the length of every host name in the provided
+// set, it is the size of the ServerNameList for the
+// longest name rounded up to the bucket size of the
+// policy, limited to MaxPaddedLength
+func RecommendPaddedLength(hostnames []string, policy PaddingPolicy) (uint16, error) {
+	if len(hostnames) == 0 {
+		return 0, errors.New("no host names provided")
+	}
+
+	var longest int
+	for _, hostname := range hostnames {
+		if len(hostname) == 0 {
+			return 0, errors.New("host name is empty")
+		} else if len(hostname) > maxPublicNameLength {
+			return 0, errors.Errorf("host name %q is longer than %d bytes", hostname, maxPublicNameLength)
+		}
+
+		if size := serverNameListSize(hostname); size > longest {
+			longest = size
+		}
+	}
+
+	length := longest
+	if bucket := int(policy.BucketSize); bucket > 1 {
+		length = (length + bucket - 1) / bucket * bucket
+	}
+
+	if length < int(policy.Minimum) {
+		length = int(policy.Minimum)
+	}
+
+	if length > int(MaxPaddedLength) {
+		length = int(MaxPaddedLength)
+	}
+
+	return uint16(length), nil
+}
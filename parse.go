@@ -0,0 +1,203 @@
+package esni
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Record represents any of the record structures
+// published by a server across the versions of the
+// ESNI and ECH specifications
+type Record interface {
+	// Version returns the specification
+	// version of the record
+	Version() Version
+
+	// Validity returns the period the record is
+	// valid for, ok is false if the record doesn't
+	// carry a validity period
+	Validity() (notBefore, notAfter time.Time, ok bool)
+
+	// String returns a friendly representation
+	// of the record
+	String() string
+}
+
+// ParsedKeys represents a Keys record
+// returned by ParseAny
+type ParsedKeys struct {
+	*Keys
+}
+
+// Version returns the specification
+// version of the Keys record
+func (parsed ParsedKeys) Version() Version {
+	return parsed.Keys.Version
+}
+
+// Validity returns the validity period of the
+// Keys record if its version carries one
+func (parsed ParsedKeys) Validity() (time.Time, time.Time, bool) {
+	if !parsed.Keys.Version.HasValidityPeriod() {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return parsed.NotBefore, parsed.NotAfter, true
+}
+
+// ParsedESNIRecord represents an ESNIRecord
+// returned by ParseAny
+type ParsedESNIRecord struct {
+	*ESNIRecord
+}
+
+// Version returns the specification
+// version of the ESNIRecord
+func (parsed ParsedESNIRecord) Version() Version {
+	return parsed.ESNIRecord.Version
+}
+
+// Validity always reports that an ESNIRecord
+// has no validity period
+func (parsed ParsedESNIRecord) Validity() (time.Time, time.Time, bool) {
+	return time.Time{}, time.Time{}, false
+}
+
+// Version returns the specification version of
+// the first config in the list
+func (list ECHConfigList) Version() Version {
+	if len(list) == 0 {
+		return 0
+	}
+
+	return list[0].Version
+}
+
+// Validity always reports that an ECHConfigList
+// has no validity period
+func (list ECHConfigList) Validity() (time.Time, time.Time, bool) {
+	return time.Time{}, time.Time{}, false
+}
+
+// String returns a friendly representation
+// of the configs in the list
+func (list ECHConfigList) String() string {
+	var builder strings.Builder
+	builder.WriteString("[")
+
+	for i := range list {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+
+		builder.WriteString(list[i].String())
+	}
+
+	builder.WriteString("]")
+	return builder.String()
+}
+
+// ParseOptions specifies the options that
+// control how ParseAnyWithOptions handles
+// the data it is provided
+type ParseOptions struct {
+	// PreserveUnknownVersions specifies if data
+	// that isn't a record of a known version is
+	// returned as a RawRecord rather than an error
+	PreserveUnknownVersions bool
+
+	// ValidatePublicName specifies if parsing
+	// should fail with a *PublicNameError when the
+	// public name of a record is invalid
+	ValidatePublicName bool
+}
+
+// ParseAny inspects the leading bytes of the data to
+// determine which record structure it contains and
+// parses it accordingly.
+//
+// Keys records from draft-01 to draft-05 are returned as
+// ParsedKeys and draft-06 records as ParsedESNIRecord.
+// Data that isn't prefixed by a known ESNI version is
+// parsed as an ECHConfigList, a bare ECHConfig is also
+// accepted and returned as a list of one config.
+func ParseAny(data []byte) (Record, error) {
+	return ParseAnyWithOptions(data, ParseOptions{})
+}
+
+// ParseAnyWithOptions parses the data as done by
+// ParseAny using the provided options.
+//
+// When unknown versions are preserved, data that
+// can't be parsed as an ECHConfigList is returned
+// as a RawRecord rather than an error, records of
+// a known version that fail to parse are still
+// reported as an error.
+func ParseAnyWithOptions(data []byte, opts ParseOptions) (Record, error) {
+	if len(data) < 2 {
+		return nil, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for version")
+	}
+
+	switch version := Version(binary.BigEndian.Uint16(data)); {
+	case version.supported():
+		keys := new(Keys)
+		if _, err := keys.DecodeWithOptions(data, DecodeOptions{RejectTrailingData: true, ValidatePublicName: opts.ValidatePublicName}); err != nil {
+			return nil, errors.Wrap(err, "unmarshal keys")
+		}
+
+		return ParsedKeys{Keys: keys}, nil
+
+	case version == VersionDraft06:
+		record := new(ESNIRecord)
+		if err := record.UnmarshalBinary(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshal esni record")
+		}
+
+		if opts.ValidatePublicName {
+			if err := ValidatePublicName(record.PublicName); err != nil {
+				return nil, err
+			}
+		}
+
+		return ParsedESNIRecord{ESNIRecord: record}, nil
+
+	case version == VersionDraft13:
+		var config ECHConfig
+		if n, err := config.unmarshal(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshal ech config")
+		} else if n < len(data) {
+			return nil, &TrailingDataError{Offset: n, Data: data[n:]}
+		}
+
+		if opts.ValidatePublicName {
+			if err := ValidatePublicName(config.PublicName); err != nil {
+				return nil, err
+			}
+		}
+
+		return ECHConfigList{config}, nil
+	}
+
+	list, err := ParseECHConfigList(data)
+	if err != nil {
+		if opts.PreserveUnknownVersions {
+			return RawRecord{Data: append([]byte(nil), data...)}, nil
+		}
+
+		return nil, errors.Wrap(err, "unmarshal ech config list")
+	}
+
+	if opts.ValidatePublicName {
+		for i := range list {
+			if err := ValidatePublicName(list[i].PublicName); err != nil {
+				return nil, errors.Wrapf(err, "config %d", i)
+			}
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,117 @@
+package esni
+
+import (
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PEMTypeKeys specifies the PEM block type
+	// used when armoring a binary Keys record
+	PEMTypeKeys = "ESNI KEYS"
+
+	// PEMTypePrivateKey specifies the PEM block
+	// type used when armoring the private key of
+	// a key share entry
+	PEMTypePrivateKey = "ESNI PRIVATE KEY"
+
+	// pemHeaderGroup specifies the PEM header that
+	// carries the group of an armored private key
+	pemHeaderGroup = "Group"
+)
+
+var (
+	// ErrNoPEMBlock is returned when decoding PEM
+	// data that doesn't contain a block of the
+	// expected type
+	ErrNoPEMBlock = errors.New("no matching PEM block found")
+)
+
+// EncodePEM will marshal the Keys record into its
+// binary format and armor it in an "ESNI KEYS"
+// PEM block
+func EncodePEM(keys *Keys) ([]byte, error) {
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal keys")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeKeys, Bytes: data}), nil
+}
+
+// DecodePEM will search the PEM data for the first
+// "ESNI KEYS" block and attempt to unmarshal a Keys
+// record from it, blocks of other types, such as
+// certificates stored in the same file, are skipped.
+//
+// The remaining data after the decoded block is
+// returned to allow for multiple records to be
+// decoded from the same file.
+func DecodePEM(data []byte) (*Keys, []byte, error) {
+	block, rest := findPEMBlock(data, PEMTypeKeys)
+	if block == nil {
+		return nil, rest, ErrNoPEMBlock
+	}
+
+	keys := new(Keys)
+	if err := keys.UnmarshalBinary(block.Bytes); err != nil {
+		return nil, rest, errors.Wrap(err, "unmarshal keys")
+	}
+
+	return keys, rest, nil
+}
+
+// EncodePrivateKeyPEM will armor the private key
+// belonging to a key share entry of the specified
+// group in an "ESNI PRIVATE KEY" PEM block
+func EncodePrivateKeyPEM(group Group, privateKey []byte) ([]byte, error) {
+	groupName, err := group.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	block := &pem.Block{
+		Type:    PEMTypePrivateKey,
+		Headers: map[string]string{pemHeaderGroup: string(groupName)},
+		Bytes:   privateKey,
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// DecodePrivateKeyPEM will search the PEM data for
+// the first "ESNI PRIVATE KEY" block and return the
+// group and private key it contains along with the
+// remaining data after the block
+func DecodePrivateKeyPEM(data []byte) (Group, []byte, []byte, error) {
+	block, rest := findPEMBlock(data, PEMTypePrivateKey)
+	if block == nil {
+		return 0, nil, rest, ErrNoPEMBlock
+	}
+
+	var group Group
+	if err := group.UnmarshalText([]byte(block.Headers[pemHeaderGroup])); err != nil {
+		return 0, nil, rest, errors.Wrap(err, "read private key group")
+	}
+
+	return group, block.Bytes, rest, nil
+}
+
+// findPEMBlock returns the first PEM block of the
+// specified type in the data and the data following
+// it, if no block is found nil is returned
+func findPEMBlock(data []byte, blockType string) (*pem.Block, []byte) {
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return nil, rest
+		}
+
+		if block.Type == blockType {
+			return block, rest
+		}
+
+		data = rest
+	}
+}
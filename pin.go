@@ -0,0 +1,281 @@
+package esni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrPinNotFound is returned by a PinStore
+	// when no pin is stored for a domain
+	ErrPinNotFound = errors.New("no pin for domain")
+)
+
+// Pin represents the Keys records last seen for
+// a domain, recorded the first time the domain is
+// fetched and updated as its keys are rotated
+type Pin struct {
+	// Domain specifies the domain
+	// the records were fetched for
+	Domain string `json:"domain"`
+
+	// Fingerprints specifies the fingerprints
+	// of the records last seen
+	Fingerprints []Fingerprint `json:"fingerprints"`
+
+	// NotAfter specifies the latest time any of
+	// the records last seen remains valid
+	NotAfter time.Time `json:"not_after"`
+
+	// FirstSeen specifies the time the
+	// domain was first pinned
+	FirstSeen time.Time `json:"first_seen"`
+
+	// UpdatedAt specifies the time the
+	// pin was last updated
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// contains checks if any of the
+// fingerprints are pinned
+func (pin *Pin) contains(fingerprints []Fingerprint) bool {
+	for i := range pin.Fingerprints {
+		for j := range fingerprints {
+			if pin.Fingerprints[i] == fingerprints[j] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// PinAlert is returned when the Keys records fetched
+// for a pinned domain share no record with the pin
+// while the pinned records are still valid, which is
+// not how keys are rotated and may indicate the DNS
+// responses for the domain have been tampered with
+type PinAlert struct {
+	// Pinned specifies the pin of the domain,
+	// it is left unchanged by the fetch
+	Pinned Pin
+
+	// Observed specifies the fingerprints
+	// of the records that were fetched
+	Observed []Fingerprint
+
+	// ObservedAt specifies the time
+	// the records were fetched
+	ObservedAt time.Time
+}
+
+// Error returns a description of the alert
+func (alert *PinAlert) Error() string {
+	return fmt.Sprintf("keys of %s changed before pinned keys expire at %s",
+		alert.Pinned.Domain, alert.Pinned.NotAfter.UTC().Format(time.RFC3339))
+}
+
+// PinStore represents the storage used for
+// trust-on-first-use pinning of fetched keys
+type PinStore interface {
+	// LoadPin returns the pin of the domain,
+	// ErrPinNotFound is returned if the domain
+	// isn't pinned
+	LoadPin(ctx context.Context, domain string) (*Pin, error)
+
+	// SavePin stores the pin, replacing any
+	// existing pin of its domain
+	SavePin(ctx context.Context, pin *Pin) error
+}
+
+// checkPin compares the fetched records against the
+// pin of the domain, a domain seen for the first time
+// is pinned to the records and the pin follows the
+// records as long as each fetch overlaps with the
+// pinned records or the pinned records have expired
+func (fetcher *Fetcher) checkPin(ctx context.Context, domain string, fetched []FetchedKeys, now time.Time) error {
+	if fetcher.Pins == nil {
+		return nil
+	}
+
+	domain = pinDomain(domain)
+
+	observed := make([]Fingerprint, len(fetched))
+	var notAfter time.Time
+
+	for i := range fetched {
+		fingerprint, err := fetched[i].Keys.Fingerprint()
+		if err != nil {
+			return errors.Wrap(err, "fingerprint keys")
+		}
+
+		observed[i] = fingerprint
+		if fetched[i].Keys.NotAfter.After(notAfter) {
+			notAfter = fetched[i].Keys.NotAfter
+		}
+	}
+
+	pin, err := fetcher.Pins.LoadPin(ctx, domain)
+	switch {
+	case err == ErrPinNotFound:
+		pin = &Pin{Domain: domain, FirstSeen: now}
+
+	case err != nil:
+		return errors.Wrapf(err, "load pin of %s", domain)
+
+	case !pin.contains(observed) && now.Before(pin.NotAfter):
+		return &PinAlert{Pinned: *pin, Observed: observed, ObservedAt: now}
+	}
+
+	pin.Fingerprints = observed
+	pin.NotAfter = notAfter
+	pin.UpdatedAt = now
+
+	return errors.Wrapf(fetcher.Pins.SavePin(ctx, pin), "save pin of %s", domain)
+}
+
+// pinDomain returns the form of the
+// domain pins are stored under
+func pinDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// MemoryPinStore implements a PinStore that holds
+// the pins in memory, it is safe for concurrent use
+type MemoryPinStore struct {
+	mu   sync.RWMutex
+	pins map[string]Pin
+}
+
+// NewMemoryPinStore returns an empty MemoryPinStore
+func NewMemoryPinStore() *MemoryPinStore {
+	return &MemoryPinStore{pins: make(map[string]Pin)}
+}
+
+// LoadPin returns a copy of the pin of the domain
+func (store *MemoryPinStore) LoadPin(ctx context.Context, domain string) (*Pin, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	pin, ok := store.pins[pinDomain(domain)]
+	if !ok {
+		return nil, ErrPinNotFound
+	}
+
+	return &pin, nil
+}
+
+// SavePin stores a copy of the pin
+func (store *MemoryPinStore) SavePin(ctx context.Context, pin *Pin) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.pins[pinDomain(pin.Domain)] = *pin
+	return nil
+}
+
+// FilePinStore implements a PinStore that keeps the
+// pins of every domain in a single JSON file, the
+// file is rewritten on each save and is only
+// readable by the owner of the process
+type FilePinStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFilePinStore returns a FilePinStore using the
+// file at path, which is created on the first save
+func NewFilePinStore(path string) *FilePinStore {
+	return &FilePinStore{path: path}
+}
+
+// LoadPin reads the pin of the domain from the file
+func (store *FilePinStore) LoadPin(ctx context.Context, domain string) (*Pin, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	pins, err := store.read()
+	if err != nil {
+		return nil, err
+	}
+
+	pin, ok := pins[pinDomain(domain)]
+	if !ok {
+		return nil, ErrPinNotFound
+	}
+
+	return &pin, nil
+}
+
+// SavePin stores the pin in the file, the file is
+// written to a temporary file and renamed so that
+// readers never observe a partial file
+func (store *FilePinStore) SavePin(ctx context.Context, pin *Pin) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	pins, err := store.read()
+	if err != nil {
+		return err
+	}
+
+	pins[pinDomain(pin.Domain)] = *pin
+
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode pins")
+	}
+
+	return writeFileAtomic(store.path, data)
+}
+
+// read returns the pins stored in the file,
+// a missing file holds no pins
+func (store *FilePinStore) read() (map[string]Pin, error) {
+	pins := make(map[string]Pin)
+
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return pins, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "read pins")
+	}
+
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, errors.Wrap(err, "decode pins")
+	}
+
+	return pins, nil
+}
+
+// writeFileAtomic writes the data to a temporary file
+// in the directory of path and renames it over path,
+// the file is only readable by the owner
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "create temporary file")
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "write temporary file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "close temporary file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "rename temporary file")
+}
@@ -0,0 +1,154 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PEMTypePrivateKeys specifies the PEM block
+	// type used when armoring the binary format of
+	// a PrivateKeys structure
+	PEMTypePrivateKeys = "ESNI PRIVATE KEYS"
+)
+
+// PrivateKeys represents the private keys
+// belonging to the key share entries of
+// a Keys record
+type PrivateKeys struct {
+	Entries []PrivateKeyEntry
+}
+
+// PrivateKeyEntry pairs a key share entry
+// with its private key
+type PrivateKeyEntry struct {
+	KeyShareEntry
+	PrivateKey []byte
+}
+
+// Lookup returns the entry for the group, ok
+// is false if there is no private key for the
+// group
+func (keys *PrivateKeys) Lookup(group Group) (entry *PrivateKeyEntry, ok bool) {
+	for i := range keys.Entries {
+		if keys.Entries[i].Group == group {
+			return &keys.Entries[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// LookupKeyShare returns the entry whose public
+// key matches the key share entry, ok is false if
+// there is no matching private key
+func (keys *PrivateKeys) LookupKeyShare(share KeyShareEntry) (entry *PrivateKeyEntry, ok bool) {
+	entry, ok = keys.Lookup(share.Group)
+	if !ok || !bytes.Equal(entry.KeyExchange, share.KeyExchange) {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// Covers checks that there is a private key for
+// every key share entry of the Keys record
+func (keys *PrivateKeys) Covers(record *Keys) error {
+	for _, share := range record.Keys {
+		if _, ok := keys.LookupKeyShare(share); !ok {
+			return errors.Errorf("no private key for key share of group %s", share.Group)
+		}
+	}
+
+	return nil
+}
+
+// MarshalBinary will marshal the private keys
+// into a binary format, each entry is encoded as
+// its key share entry followed by the private key
+// with a 2 byte length prefix
+func (keys PrivateKeys) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+
+	for i := range keys.Entries {
+		entry, err := keys.Entries[i].KeyShareEntry.MarshalBinary()
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal key share entry")
+		}
+
+		data.Write(entry)
+		if err := writeVector16(&data, keys.Entries[i].PrivateKey); err != nil {
+			return nil, errors.Wrapf(err, "write private key for %s", keys.Entries[i].Group)
+		}
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal
+// the private keys from the provided binary data
+func (keys *PrivateKeys) UnmarshalBinary(data []byte) error {
+	keys.Entries = nil
+
+	for pos := 0; pos < len(data); {
+		var entry PrivateKeyEntry
+		if err := entry.KeyShareEntry.UnmarshalBinary(data[pos:]); err != nil {
+			return errors.Wrap(err, "unmarshal key share entry")
+		}
+
+		pos += int(entry.KeyShareEntry.Size())
+		if len(data[pos:]) < 2 {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for private key length")
+		}
+
+		keyLen := int(binary.BigEndian.Uint16(data[pos:]))
+		if len(data[pos+2:]) < keyLen {
+			return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for private key")
+		}
+
+		entry.PrivateKey = append([]byte(nil), data[pos+2:pos+2+keyLen]...)
+		pos += keyLen + 2
+
+		if _, exists := keys.Lookup(entry.Group); exists {
+			return errors.New("duplicate private key group")
+		}
+
+		keys.Entries = append(keys.Entries, entry)
+	}
+
+	return nil
+}
+
+// EncodePrivateKeysPEM will marshal the private keys
+// into their binary format and armor them in an
+// "ESNI PRIVATE KEYS" PEM block
+func EncodePrivateKeysPEM(keys *PrivateKeys) ([]byte, error) {
+	data, err := keys.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal private keys")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKeys, Bytes: data}), nil
+}
+
+// DecodePrivateKeysPEM will search the PEM data for
+// the first "ESNI PRIVATE KEYS" block and attempt to
+// unmarshal the private keys from it, the remaining
+// data after the block is returned
+func DecodePrivateKeysPEM(data []byte) (*PrivateKeys, []byte, error) {
+	block, rest := findPEMBlock(data, PEMTypePrivateKeys)
+	if block == nil {
+		return nil, rest, ErrNoPEMBlock
+	}
+
+	keys := new(PrivateKeys)
+	if err := keys.UnmarshalBinary(block.Bytes); err != nil {
+		return nil, rest, errors.Wrap(err, "unmarshal private keys")
+	}
+
+	return keys, rest, nil
+}
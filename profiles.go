@@ -0,0 +1,128 @@
+package esni
+
+import (
+	"time"
+)
+
+// Profile represents an opinionated set of
+// parameters for a Keys record, allowing operators
+// to produce records that interoperate with common
+// deployments without studying the specification
+type Profile struct {
+	// Name specifies the unique name of the profile
+	Name string
+
+	// Version specifies the ESNI version of records
+	// produced by the profile
+	Version Version
+
+	// Groups specifies the groups that records
+	// produced by the profile must have key shares for
+	Groups []Group
+
+	// CipherSuites specifies the cipher suites, in
+	// order of preference, permitted by the profile
+	CipherSuites []CipherSuite
+
+	// PaddedLength specifies the padded length of
+	// records produced by the profile
+	PaddedLength uint16
+
+	// Lifetime specifies the validity period of
+	// records produced by the profile
+	Lifetime time.Duration
+}
+
+var (
+	// ProfileDefault specifies the recommended
+	// parameters for new deployments, targeting
+	// draft-03 of the ESNI specification
+	ProfileDefault = Profile{
+		Name:    "default",
+		Version: VersionDraft03,
+		Groups:  []Group{GroupX25519},
+		CipherSuites: []CipherSuite{
+			CipherSuite_TLS_AES_128_GCM_SHA256,
+			CipherSuite_TLS_CHACHA20_POLY1305_SHA256,
+			CipherSuite_TLS_AES_256_GCM_SHA384,
+		},
+		PaddedLength: MaxPaddedLength,
+		Lifetime:     DefaultLifetime,
+	}
+
+	// ProfileCloudflare specifies parameters that
+	// match the records published by Cloudflare,
+	// which are also the only parameters supported
+	// by the ESNI implementation found in Firefox
+	ProfileCloudflare = Profile{
+		Name:         "cloudflare",
+		Version:      VersionDraft01,
+		Groups:       []Group{GroupX25519},
+		CipherSuites: []CipherSuite{CipherSuite_TLS_AES_128_GCM_SHA256},
+		PaddedLength: MaxPaddedLength,
+		Lifetime:     24 * time.Hour,
+	}
+
+	// Profile_name specifies a map of profile names
+	// to their respective profile
+	Profile_name = map[string]Profile{
+		ProfileDefault.Name:    ProfileDefault,
+		ProfileCloudflare.Name: ProfileCloudflare,
+	}
+)
+
+// LookupProfile attempts to return the profile
+// with the specified name from Profile_name
+func LookupProfile(name string) (Profile, bool) {
+	profile, ok := Profile_name[name]
+	return profile, ok
+}
+
+// Builder returns a new KeysBuilder populated with
+// the parameters of the profile, a key share is
+// generated for each group of the profile that
+// doesn't have one added when the record is built.
+//
+// The public name is ignored if the version of the
+// profile doesn't support it.
+func (profile Profile) Builder(publicName string) *KeysBuilder {
+	builder := NewKeysBuilder().
+		Version(profile.Version).
+		PaddedLength(profile.PaddedLength).
+		Lifetime(profile.Lifetime)
+
+	if profile.Version.HasPublicName() {
+		builder.PublicName(publicName)
+	}
+
+	for _, group := range profile.Groups {
+		builder.AddGroup(group)
+	}
+
+	for _, suite := range profile.CipherSuites {
+		builder.AddCipherSuite(suite)
+	}
+
+	return builder
+}
+
+// NewDefaultKeys returns a new Keys record produced
+// by ProfileDefault for the public name along with
+// its private keys, a key share is generated using
+// GenerateKeys unless one is provided.
+//
+// Private keys are only returned for the generated
+// key shares, the caller retains the private keys
+// of any key shares provided.
+func NewDefaultKeys(publicName string, keyShares ...KeyShareEntry) (*Keys, *PrivateKeys, error) {
+	if len(keyShares) == 0 {
+		return GenerateKeys(&GenerateOptions{Profile: &ProfileDefault, PublicName: publicName})
+	}
+
+	builder := ProfileDefault.Builder(publicName)
+	for i := range keyShares {
+		builder.AddKeyShare(keyShares[i])
+	}
+
+	return builder.BuildWithPrivateKeys()
+}
@@ -0,0 +1,34 @@
+package esni
+
+import (
+	"testing"
+)
+
+func TestNewDefaultKeys(t *testing.T) {
+	keys, privateKeys, err := NewDefaultKeys("cdn.example")
+	if err != nil {
+		t.Fatalf("NewDefaultKeys() error = %s", err)
+	}
+
+	if keys.Version != ProfileDefault.Version || keys.PublicName != "cdn.example" {
+		t.Errorf("NewDefaultKeys() = %s %q, want %s %q", keys.Version, keys.PublicName, ProfileDefault.Version, "cdn.example")
+	}
+
+	if err := privateKeys.Covers(keys); err != nil {
+		t.Errorf("private keys don't cover the record: %s", err)
+	}
+
+	supplied, _, err := Group(GroupX25519).NewKeyPair()
+	if err != nil {
+		t.Fatalf("generate key pair: %s", err)
+	}
+
+	keys, privateKeys, err = NewDefaultKeys("cdn.example", KeyShareEntry{Group: GroupX25519, KeyExchange: supplied})
+	if err != nil {
+		t.Fatalf("NewDefaultKeys() with key share error = %s", err)
+	}
+
+	if len(keys.Keys) != 1 || len(privateKeys.Entries) != 0 {
+		t.Errorf("NewDefaultKeys() with key share returned %d key shares and %d private keys, want 1 and 0", len(keys.Keys), len(privateKeys.Entries))
+	}
+}
@@ -0,0 +1,181 @@
+package esni
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+const (
+	// maxLabelLength specifies the maximum
+	// length of a single DNS label
+	maxLabelLength = 63
+)
+
+var (
+	// ErrPublicNameEmpty is the cause of a
+	// PublicNameError for an empty public name
+	ErrPublicNameEmpty = errors.New("public name is empty")
+
+	// ErrPublicNameTooLong is the cause of a
+	// PublicNameError for a public name longer
+	// than permitted by the record
+	ErrPublicNameTooLong = errors.New("public name is too long")
+
+	// ErrPublicNameDot is the cause of a
+	// PublicNameError for a public name that
+	// begins or ends with a dot
+	ErrPublicNameDot = errors.New("public name begins or ends with a dot")
+
+	// ErrPublicNameLabel is the cause of a
+	// PublicNameError for a public name with a
+	// label that isn't a valid LDH label
+	ErrPublicNameLabel = errors.New("public name has an invalid label")
+
+	// ErrPublicNameIPAddress is the cause of a
+	// PublicNameError for a public name that would
+	// be interpreted as an IPv4 address
+	ErrPublicNameIPAddress = errors.New("public name is an IP address")
+)
+
+// PublicNameError is returned when a public name
+// doesn't conform to the requirements of the ECH
+// specification, such names are ignored by clients
+type PublicNameError struct {
+	// Name specifies the invalid public name
+	Name string
+
+	// Err specifies which requirement the
+	// public name doesn't conform to
+	Err error
+}
+
+// Error returns a description of why
+// the public name is invalid
+func (err *PublicNameError) Error() string {
+	return "invalid public name " + `"` + err.Name + `": ` + err.Err.Error()
+}
+
+// Cause returns the requirement the public
+// name doesn't conform to
+func (err *PublicNameError) Cause() error {
+	return err.Err
+}
+
+// ValidatePublicName checks the public name is a
+// dot separated sequence of LDH labels without a
+// leading or trailing dot and that the final label
+// can't be interpreted as part of an IPv4 address,
+// as required of the public name of an ECHConfig.
+// Labels carrying the "xn--" prefix must also be
+// valid IDNA2008 A-labels.
+//
+// A *PublicNameError is returned if the name
+// is invalid.
+func ValidatePublicName(name string) error {
+	switch {
+	case len(name) == 0:
+		return &PublicNameError{Name: name, Err: ErrPublicNameEmpty}
+
+	case len(name) > maxPublicNameLength:
+		return &PublicNameError{Name: name, Err: ErrPublicNameTooLong}
+
+	case strings.HasPrefix(name, ".") || strings.HasSuffix(name, "."):
+		return &PublicNameError{Name: name, Err: ErrPublicNameDot}
+	}
+
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !isLDHLabel(label) {
+			return &PublicNameError{Name: name, Err: errors.Wrapf(ErrPublicNameLabel, "label %q", label)}
+		}
+
+		if isALabel(label) {
+			if _, err := idna.Lookup.ToUnicode(label); err != nil {
+				return &PublicNameError{Name: name, Err: errors.Wrapf(ErrPublicNameLabel, "label %q is not a valid A-label", label)}
+			}
+		}
+	}
+
+	if isNumericLabel(labels[len(labels)-1]) {
+		return &PublicNameError{Name: name, Err: ErrPublicNameIPAddress}
+	}
+
+	return nil
+}
+
+// isLDHLabel returns if the label consists of
+// letters, digits and hyphens and doesn't begin
+// or end with a hyphen
+func isLDHLabel(label string) bool {
+	if len(label) == 0 || len(label) > maxLabelLength {
+		return false
+	}
+
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isNumericLabel returns if the label consists of
+// only decimal digits or is a hexadecimal number
+// prefixed by "0x", either of which cause the name
+// to be parsed as an IPv4 address
+func isNumericLabel(label string) bool {
+	digits, base := label, "0123456789"
+	if strings.HasPrefix(label, "0x") || strings.HasPrefix(label, "0X") {
+		digits, base = label[2:], "0123456789abcdefABCDEF"
+	} else if len(label) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if !strings.ContainsRune(base, rune(digits[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validate checks the ECHConfig against the
+// requirements of the ECH specification that are
+// not enforced during unmarshalling.
+//
+// If any violations are found a *ValidationError
+// listing every violation is returned.
+func (config *ECHConfig) Validate() error {
+	verr := new(ValidationError)
+
+	if !config.Version.UsesHPKE() {
+		verr.add("version 0x%04x is not an ECHConfig version", uint16(config.Version))
+	}
+
+	if err := ValidatePublicName(config.PublicName); err != nil {
+		verr.Violations = append(verr.Violations, err)
+	}
+
+	if len(config.PublicKey) == 0 {
+		verr.add("public key is empty")
+	}
+
+	if len(config.CipherSuites) == 0 {
+		verr.add("cipher suite list is empty")
+	}
+
+	if len(verr.Violations) > 0 {
+		return verr
+	}
+
+	return nil
+}
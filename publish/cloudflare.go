@@ -0,0 +1,324 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCloudflareEndpoint specifies the
+	// base URL of the Cloudflare API
+	DefaultCloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+)
+
+// CloudflareConfig specifies the parameters used by
+// a CloudflarePublisher to reach the Cloudflare API
+type CloudflareConfig struct {
+	// APIToken specifies the API token used to
+	// authenticate requests, it requires the
+	// DNS edit permission for the zone
+	APIToken string
+
+	// ZoneID specifies the identifier of
+	// the zone records are published to
+	ZoneID string
+
+	// Endpoint specifies the base URL of the API,
+	// if not set DefaultCloudflareEndpoint is used
+	Endpoint string
+
+	// ZoneOptions specifies the options used to
+	// derive the TTL of the published records, if
+	// it specifies an HTTPS record one is published
+	// alongside each TXT record
+	ZoneOptions esni.ZoneOptions
+
+	// Client specifies the HTTP client used to
+	// make requests, if not set http.DefaultClient
+	// is used
+	Client *http.Client
+}
+
+// CloudflarePublisher implements a Publisher that
+// manages the _esni TXT records of a zone hosted
+// by Cloudflare.
+//
+// The records of a Keys record are changed with a
+// single batch request, which Cloudflare applies
+// atomically, so the TXT and HTTPS records never
+// carry different key material.
+type CloudflarePublisher struct {
+	config CloudflareConfig
+}
+
+// cloudflareRecord represents a DNS
+// record in the Cloudflare API
+type cloudflareRecord struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Content string          `json:"content,omitempty"`
+	Data    *cloudflareSVCB `json:"data,omitempty"`
+	TTL     uint32          `json:"ttl"`
+}
+
+// cloudflareSVCB represents the structured
+// data of an HTTPS record in the Cloudflare
+// API, the value holds the SvcParams in
+// presentation format
+type cloudflareSVCB struct {
+	Priority uint16 `json:"priority"`
+	Target   string `json:"target"`
+	Value    string `json:"value"`
+}
+
+// cloudflareBatch represents the body of a
+// request changing multiple DNS records
+type cloudflareBatch struct {
+	Deletes []cloudflareRecord `json:"deletes,omitempty"`
+	Posts   []cloudflareRecord `json:"posts,omitempty"`
+	Puts    []cloudflareRecord `json:"puts,omitempty"`
+}
+
+// NewCloudflarePublisher returns a CloudflarePublisher
+// using the provided configuration
+func NewCloudflarePublisher(config CloudflareConfig) (*CloudflarePublisher, error) {
+	if len(config.APIToken) == 0 {
+		return nil, errors.New("cloudflare api token is required")
+	}
+
+	if len(config.ZoneID) == 0 {
+		return nil, errors.New("cloudflare zone id is required")
+	}
+
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = DefaultCloudflareEndpoint
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &CloudflarePublisher{config: config}, nil
+}
+
+// Publish creates the records of the Keys record,
+// or updates their TTL if they already exist, and
+// verifies they are all present afterwards
+func (publisher *CloudflarePublisher) Publish(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	var batch cloudflareBatch
+	for _, record := range records {
+		body, err := newCloudflareRecord(record)
+		if err != nil {
+			return err
+		}
+
+		existing, err := publisher.find(ctx, record)
+		if err != nil && err != ErrNotPublished {
+			return err
+		}
+
+		if err == ErrNotPublished {
+			batch.Posts = append(batch.Posts, body)
+			continue
+		}
+
+		body.ID = existing.ID
+		batch.Puts = append(batch.Puts, body)
+	}
+
+	if err := publisher.do(ctx, http.MethodPost, publisher.url("")+"/batch", batch, nil); err != nil {
+		return err
+	}
+
+	return verifyPublished(ctx, publisher, domain, keys)
+}
+
+// Remove deletes the records of the Keys record
+func (publisher *CloudflarePublisher) Remove(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	var batch cloudflareBatch
+	for _, record := range records {
+		existing, err := publisher.find(ctx, record)
+		switch {
+		case err == ErrNotPublished:
+			continue
+
+		case err != nil:
+			return err
+		}
+
+		batch.Deletes = append(batch.Deletes, cloudflareRecord{ID: existing.ID})
+	}
+
+	if len(batch.Deletes) == 0 {
+		return ErrNotPublished
+	}
+
+	return publisher.do(ctx, http.MethodPost, publisher.url("")+"/batch", batch, nil)
+}
+
+// Verify checks every record of the
+// Keys record exists in the zone
+func (publisher *CloudflarePublisher) Verify(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := publisher.find(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// find returns the existing record of the same type
+// carrying the same Keys record as the record,
+// ErrNotPublished is returned if there is none
+func (publisher *CloudflarePublisher) find(ctx context.Context, record record) (cloudflareRecord, error) {
+	query := url.Values{"type": {record.rtype}, "name": {strings.TrimSuffix(record.name, ".")}, "per_page": {"100"}}
+
+	var existing []cloudflareRecord
+	if err := publisher.do(ctx, http.MethodGet, publisher.url("")+"?"+query.Encode(), nil, &existing); err != nil {
+		return cloudflareRecord{}, err
+	}
+
+	for _, candidate := range existing {
+		if record.matches(candidate.Content) {
+			return candidate, nil
+		}
+	}
+
+	return cloudflareRecord{}, ErrNotPublished
+}
+
+// newCloudflareRecord converts the record into the
+// body used to create it, HTTPS records are created
+// from their structured data rather than content
+func newCloudflareRecord(record record) (cloudflareRecord, error) {
+	body := cloudflareRecord{Type: record.rtype, Name: strings.TrimSuffix(record.name, "."), TTL: record.ttl}
+	if record.rtype != "HTTPS" {
+		body.Content = record.value
+		return body, nil
+	}
+
+	fields := strings.SplitN(record.value, " ", 3)
+	if len(fields) < 3 {
+		return cloudflareRecord{}, errors.Errorf("malformed https record %q", record.value)
+	}
+
+	priority, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return cloudflareRecord{}, errors.Wrap(err, "parse https record priority")
+	}
+
+	body.Data = &cloudflareSVCB{Priority: uint16(priority), Target: fields[1], Value: fields[2]}
+	return body, nil
+}
+
+// url returns the URL of the DNS records endpoint
+// of the zone, or of the record with the ID
+func (publisher *CloudflarePublisher) url(id string) string {
+	endpoint := fmt.Sprintf("%s/zones/%s/dns_records", publisher.config.Endpoint, url.PathEscape(publisher.config.ZoneID))
+	if len(id) > 0 {
+		endpoint += "/" + url.PathEscape(id)
+	}
+
+	return endpoint
+}
+
+// do performs a request against the Cloudflare API,
+// decoding the result of the response into out if
+// it is not nil
+func (publisher *CloudflarePublisher) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encode request")
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+publisher.config.APIToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := publisher.config.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "cloudflare request")
+	}
+
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	}
+
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return errors.Errorf("cloudflare returned %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	if !envelope.Success {
+		if len(envelope.Errors) > 0 {
+			return errors.Errorf("cloudflare returned %s: %s (code %d)", resp.Status, envelope.Errors[0].Message, envelope.Errors[0].Code)
+		}
+
+		return errors.Errorf("cloudflare returned %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal(envelope.Result, out), "decode response")
+}
+
+// String returns a friendly representation
+// of the publisher
+func (publisher *CloudflarePublisher) String() string {
+	return fmt.Sprintf("cloudflare:%s", publisher.config.ZoneID)
+}
@@ -0,0 +1,188 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+// fakeCloudflare implements the subset of the
+// Cloudflare DNS records API used by the publisher
+type fakeCloudflare struct {
+	mu      sync.Mutex
+	records map[string]cloudflareRecord
+	nextID  int
+	batches int
+
+	// dropHTTPS discards HTTPS records written
+	// to the zone, as a provider without HTTPS
+	// support might
+	dropHTTPS bool
+}
+
+func (server *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	var result interface{}
+	switch {
+	case r.Method == http.MethodGet:
+		list := []cloudflareRecord{}
+		for _, record := range server.records {
+			if record.Type == r.URL.Query().Get("type") && record.Name == r.URL.Query().Get("name") {
+				list = append(list, record)
+			}
+		}
+
+		result = list
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/batch"):
+		var batch cloudflareBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		server.batches++
+		for _, record := range batch.Deletes {
+			delete(server.records, record.ID)
+		}
+
+		for _, record := range append(batch.Posts, batch.Puts...) {
+			if len(record.ID) == 0 {
+				server.nextID++
+				record.ID = strconv.Itoa(server.nextID)
+			}
+
+			if record.Type == "HTTPS" && server.dropHTTPS {
+				continue
+			}
+
+			if record.Data != nil {
+				record.Content = fmt.Sprintf("%d %s %s", record.Data.Priority, record.Data.Target, record.Data.Value)
+			}
+
+			server.records[record.ID] = record
+		}
+
+	default:
+		http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result})
+}
+
+func TestCloudflarePublisherHTTPS(t *testing.T) {
+	server := &fakeCloudflare{records: make(map[string]cloudflareRecord)}
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+
+	publisher, err := NewCloudflarePublisher(CloudflareConfig{
+		APIToken: "token",
+		ZoneID:   "zone",
+		Endpoint: endpoint.URL,
+		ZoneOptions: esni.ZoneOptions{
+			HTTPS: &esni.SVCBRecord{Priority: 1, Target: "."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCloudflarePublisher() error = %s", err)
+	}
+
+	keys := newTestKeys(t)
+	ctx := context.Background()
+
+	if err := publisher.Verify(ctx, "example.com", keys); err != ErrNotPublished {
+		t.Fatalf("Verify() before publish error = %v, want %v", err, ErrNotPublished)
+	}
+
+	if err := publisher.Publish(ctx, "example.com", keys); err != nil {
+		t.Fatalf("Publish() error = %s", err)
+	}
+
+	if len(server.records) != 2 || server.batches != 1 {
+		t.Fatalf("Publish() created %d records in %d batches, want 2 in 1", len(server.records), server.batches)
+	}
+
+	if err := publisher.Verify(ctx, "example.com", keys); err != nil {
+		t.Fatalf("Verify() after publish error = %s", err)
+	}
+
+	for id, record := range server.records {
+		if record.Type == "HTTPS" {
+			delete(server.records, id)
+		}
+	}
+
+	if err := publisher.Verify(ctx, "example.com", keys); err != ErrNotPublished {
+		t.Fatalf("Verify() without https record error = %v, want %v", err, ErrNotPublished)
+	}
+
+	if err := publisher.Remove(ctx, "example.com", keys); err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+
+	if len(server.records) != 0 {
+		t.Errorf("Remove() left %d records", len(server.records))
+	}
+
+	if err := publisher.Remove(ctx, "example.com", keys); err != ErrNotPublished {
+		t.Errorf("Remove() after removal error = %v, want %v", err, ErrNotPublished)
+	}
+}
+
+func TestCloudflarePublisherVerifiesPublish(t *testing.T) {
+	server := &fakeCloudflare{records: make(map[string]cloudflareRecord), dropHTTPS: true}
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+
+	publisher, err := NewCloudflarePublisher(CloudflareConfig{
+		APIToken: "token",
+		ZoneID:   "zone",
+		Endpoint: endpoint.URL,
+		ZoneOptions: esni.ZoneOptions{
+			HTTPS: &esni.SVCBRecord{Priority: 1, Target: "."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCloudflarePublisher() error = %s", err)
+	}
+
+	err = publisher.Publish(context.Background(), "example.com", newTestKeys(t))
+	if errors.Cause(err) != ErrNotPublished {
+		t.Errorf("Publish() without https record error = %v, want %v", err, ErrNotPublished)
+	}
+}
+
+// newTestKeys returns a Keys record with a freshly
+// generated X25519 key share valid for two days
+func newTestKeys(t *testing.T) *esni.Keys {
+	t.Helper()
+
+	publicKey, _, err := esni.Group(esni.GroupX25519).NewKeyPair()
+	if err != nil {
+		t.Fatalf("generate key pair: %s", err)
+	}
+
+	keys, err := esni.NewKeysBuilder().
+		PublicName("public.example.com").
+		Lifetime(48 * time.Hour).
+		AddKeyShare(esni.KeyShareEntry{Group: esni.GroupX25519, KeyExchange: publicKey}).
+		Build()
+	if err != nil {
+		t.Fatalf("build keys: %s", err)
+	}
+
+	return keys
+}
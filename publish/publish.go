@@ -0,0 +1,142 @@
+// Package publish provides publishers that push the
+// _esni TXT records of Keys records to hosted DNS
+// providers, allowing freshly generated records to be
+// published automatically as keys are rotated.
+//
+// When the zone options of a publisher specify an
+// HTTPS record, the HTTPS record whose "ech" parameter
+// carries the ECHConfig converted from the same keys
+// is managed alongside the TXT record, so clients of
+// both protocols see the same key material.
+package publish
+
+import (
+	"context"
+	"strings"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotPublished is returned when the TXT
+	// record of a Keys record isn't published
+	// at the provider
+	ErrNotPublished = errors.New("record is not published")
+)
+
+// Publisher represents a hosted DNS provider the
+// _esni TXT records of a domain are published to,
+// each Keys record is published as a separate TXT
+// record so old and new records can overlap while
+// keys are rotated.
+//
+// If the zone options of the publisher specify an
+// HTTPS record, each Keys record is also published
+// as a separate HTTPS record of the domain and the
+// two records are always changed together.
+type Publisher interface {
+	// Publish adds the records of the Keys record
+	// to the records of the domain, publishing a
+	// record that is already published updates
+	// its TTL. The records are verified once they
+	// are published, an error whose cause is
+	// ErrNotPublished is returned if the provider
+	// doesn't report all of them.
+	Publish(ctx context.Context, domain string, keys *esni.Keys) error
+
+	// Remove removes the records of the Keys record
+	// from the records of the domain, ErrNotPublished
+	// is returned if none of them are published
+	Remove(ctx context.Context, domain string, keys *esni.Keys) error
+
+	// Verify checks every record of the Keys record
+	// is published at the provider, ErrNotPublished
+	// is returned if any of them isn't
+	Verify(ctx context.Context, domain string, keys *esni.Keys) error
+}
+
+// verifyPublished verifies the records of the Keys
+// record after they have been published, wrapping
+// the error if any of them aren't reported by the
+// provider
+func verifyPublished(ctx context.Context, publisher Publisher, domain string, keys *esni.Keys) error {
+	return errors.Wrap(publisher.Verify(ctx, domain, keys), "verify published records")
+}
+
+// record represents a DNS record carrying a Keys
+// record, either the _esni TXT record or the HTTPS
+// record carrying its converted ECHConfig
+type record struct {
+	name  string
+	rtype string
+	ttl   uint32
+	value string
+}
+
+// newRecords returns the TXT record carrying the
+// Keys record followed by its HTTPS record if one
+// is specified by the zone options, the TTL is
+// derived from the validity of the record
+func newRecords(domain string, keys *esni.Keys, opts esni.ZoneOptions) ([]record, error) {
+	zone, err := esni.ZoneRecords(domain, []*esni.Keys{keys}, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate records")
+	}
+
+	records := make([]record, len(zone))
+	for i := range zone {
+		records[i] = record{
+			name:  zone[i].Name,
+			rtype: dns.TypeToString[zone[i].Type],
+			ttl:   zone[i].TTL,
+			value: zone[i].Data,
+		}
+	}
+
+	return records, nil
+}
+
+// matches returns if the presentation value of a
+// record returned by a provider carries the same
+// Keys record as the record, TXT records are
+// compared ignoring how their character-strings
+// are split and quoted while HTTPS records are
+// compared by their "ech" parameter only
+func (record record) matches(value string) bool {
+	if record.rtype == "HTTPS" {
+		ech := svcbParam(record.value, "ech")
+		return len(ech) > 0 && svcbParam(value, "ech") == ech
+	}
+
+	return txtContent(value) == txtContent(record.value)
+}
+
+// txtContent returns the content of the presentation
+// value of a TXT record with its character-strings
+// joined, the base64 records never contain quotes
+// or whitespace
+func txtContent(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '"' || r == ' ' || r == '\t' {
+			return -1
+		}
+
+		return r
+	}, value)
+}
+
+// svcbParam returns the unquoted value of the
+// SvcParam with the key in the presentation value
+// of an HTTPS record, an empty string is returned
+// if the record doesn't carry the parameter
+func svcbParam(value, key string) string {
+	for _, field := range strings.Fields(value) {
+		if strings.HasPrefix(field, key+"=") {
+			return strings.Trim(field[len(key)+1:], `"`)
+		}
+	}
+
+	return ""
+}
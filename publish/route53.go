@@ -0,0 +1,379 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	esni "github.com/LiamHaworth/go-esni"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRoute53Endpoint specifies the
+	// base URL of the Route53 API
+	DefaultRoute53Endpoint = "https://route53.amazonaws.com"
+
+	// DefaultRoute53Region specifies the region
+	// requests to the Route53 API are signed for
+	DefaultRoute53Region = "us-east-1"
+
+	// route53Namespace specifies the XML
+	// namespace of the Route53 API
+	route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+// Route53Config specifies the parameters used by
+// a Route53Publisher to reach the Route53 API
+type Route53Config struct {
+	// AccessKeyID and SecretAccessKey specify the
+	// AWS credentials used to sign requests
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken specifies the session token
+	// of temporary AWS credentials
+	SessionToken string
+
+	// HostedZoneID specifies the identifier of the
+	// hosted zone records are published to
+	HostedZoneID string
+
+	// Endpoint specifies the base URL of the API,
+	// if not set DefaultRoute53Endpoint is used
+	Endpoint string
+
+	// Region specifies the region requests are
+	// signed for, if not set DefaultRoute53Region
+	// is used
+	Region string
+
+	// ZoneOptions specifies the options used to
+	// derive the TTL of the published records, if
+	// it specifies an HTTPS record one is published
+	// alongside each TXT record
+	ZoneOptions esni.ZoneOptions
+
+	// Client specifies the HTTP client used to
+	// make requests, if not set http.DefaultClient
+	// is used
+	Client *http.Client
+}
+
+// Route53Publisher implements a Publisher that
+// manages the _esni TXT records of a hosted zone
+// in AWS Route53.
+//
+// Route53 holds all the records of a name and type
+// in a single record set, publishing and removing
+// records rewrites the sets with the values of other
+// records preserved. The TXT and HTTPS records of a
+// Keys record are changed in a single change batch.
+type Route53Publisher struct {
+	config Route53Config
+	now    func() time.Time
+}
+
+// route53RecordSet represents a resource
+// record set in the Route53 API
+type route53RecordSet struct {
+	Name   string   `xml:"Name"`
+	Type   string   `xml:"Type"`
+	TTL    uint32   `xml:"TTL"`
+	Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+// route53Change represents a single change
+// to a resource record set
+type route53Change struct {
+	Action    string           `xml:"Action"`
+	RecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+// route53ChangeRequest represents the body of
+// a ChangeResourceRecordSets request
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Comment string          `xml:"ChangeBatch>Comment,omitempty"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+// route53ListResponse represents the body of
+// a ListResourceRecordSets response
+type route53ListResponse struct {
+	RecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+// route53ErrorResponse represents the body
+// of an error returned by the Route53 API
+type route53ErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// NewRoute53Publisher returns a Route53Publisher
+// using the provided configuration
+func NewRoute53Publisher(config Route53Config) (*Route53Publisher, error) {
+	if len(config.AccessKeyID) == 0 || len(config.SecretAccessKey) == 0 {
+		return nil, errors.New("route53 credentials are required")
+	}
+
+	if len(config.HostedZoneID) == 0 {
+		return nil, errors.New("route53 hosted zone id is required")
+	}
+
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = DefaultRoute53Endpoint
+	}
+
+	if len(config.Region) == 0 {
+		config.Region = DefaultRoute53Region
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.HostedZoneID = strings.TrimPrefix(config.HostedZoneID, "/hostedzone/")
+
+	return &Route53Publisher{config: config, now: time.Now}, nil
+}
+
+// Publish adds the records of the Keys record to the
+// record sets of the domain, the TTL of each set is
+// lowered to the TTL of the records if required. The
+// sets are read back afterwards to verify them.
+func (publisher *Route53Publisher) Publish(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]route53Change, len(records))
+	for i, record := range records {
+		set, err := publisher.recordSet(ctx, record.name, record.rtype)
+		if err != nil {
+			return err
+		}
+
+		updated := route53RecordSet{Name: record.name, Type: record.rtype, TTL: record.ttl}
+		if set != nil {
+			if set.TTL < updated.TTL {
+				updated.TTL = set.TTL
+			}
+
+			for _, value := range set.Values {
+				if !record.matches(value) {
+					updated.Values = append(updated.Values, value)
+				}
+			}
+		}
+
+		updated.Values = append(updated.Values, record.value)
+		changes[i] = route53Change{Action: "UPSERT", RecordSet: updated}
+	}
+
+	if err := publisher.change(ctx, changes...); err != nil {
+		return err
+	}
+
+	return verifyPublished(ctx, publisher, domain, keys)
+}
+
+// Remove removes the records of the Keys record from
+// the record sets of the domain, a set is deleted
+// when no other records remain in it
+func (publisher *Route53Publisher) Remove(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	var changes []route53Change
+	for _, record := range records {
+		set, err := publisher.recordSet(ctx, record.name, record.rtype)
+		if err != nil {
+			return err
+		}
+
+		if set == nil {
+			continue
+		}
+
+		remaining := route53RecordSet{Name: set.Name, Type: set.Type, TTL: set.TTL}
+		for _, value := range set.Values {
+			if !record.matches(value) {
+				remaining.Values = append(remaining.Values, value)
+			}
+		}
+
+		switch {
+		case len(remaining.Values) == len(set.Values):
+			continue
+
+		case len(remaining.Values) == 0:
+			changes = append(changes, route53Change{Action: "DELETE", RecordSet: *set})
+
+		default:
+			changes = append(changes, route53Change{Action: "UPSERT", RecordSet: remaining})
+		}
+	}
+
+	if len(changes) == 0 {
+		return ErrNotPublished
+	}
+
+	return publisher.change(ctx, changes...)
+}
+
+// Verify checks every record of the Keys record
+// is in the record sets of the domain
+func (publisher *Route53Publisher) Verify(ctx context.Context, domain string, keys *esni.Keys) error {
+	records, err := newRecords(domain, keys, publisher.config.ZoneOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		set, err := publisher.recordSet(ctx, record.name, record.rtype)
+		if err != nil {
+			return err
+		}
+
+		if set == nil || !set.contains(record) {
+			return ErrNotPublished
+		}
+	}
+
+	return nil
+}
+
+// contains returns if the record set holds
+// a value matching the record
+func (set *route53RecordSet) contains(record record) bool {
+	for _, value := range set.Values {
+		if record.matches(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordSet returns the record set of the name and
+// type, if there is no set nil is returned
+func (publisher *Route53Publisher) recordSet(ctx context.Context, name, rtype string) (*route53RecordSet, error) {
+	query := url.Values{"name": {name}, "type": {rtype}, "maxitems": {"1"}}
+
+	var list route53ListResponse
+	if err := publisher.do(ctx, http.MethodGet, publisher.url()+"?"+query.Encode(), nil, &list); err != nil {
+		return nil, err
+	}
+
+	// The listing starts at the requested name
+	// but continues with the sets that follow it
+	// when there is no set for the name itself
+	for i := range list.RecordSets {
+		set := list.RecordSets[i]
+		if set.Type == rtype && strings.EqualFold(strings.TrimSuffix(set.Name, "."), strings.TrimSuffix(name, ".")) {
+			return &set, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// change submits a change batch holding the
+// changes to the hosted zone, Route53 applies
+// the changes of a batch atomically
+func (publisher *Route53Publisher) change(ctx context.Context, changes ...route53Change) error {
+	request := route53ChangeRequest{
+		XMLNS:   route53Namespace,
+		Comment: "esni keys rotation",
+		Changes: changes,
+	}
+
+	return publisher.do(ctx, http.MethodPost, publisher.url(), request, nil)
+}
+
+// url returns the URL of the resource
+// record sets of the hosted zone
+func (publisher *Route53Publisher) url() string {
+	return fmt.Sprintf("%s/2013-04-01/hostedzone/%s/rrset", publisher.config.Endpoint, url.PathEscape(publisher.config.HostedZoneID))
+}
+
+// do performs a signed request against the Route53
+// API, decoding the response into out if it is not nil
+func (publisher *Route53Publisher) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		data, err := xml.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "encode request")
+		}
+
+		payload = append([]byte(xml.Header), data...)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+
+	creds := sigV4Credentials{
+		AccessKeyID:     publisher.config.AccessKeyID,
+		SecretAccessKey: publisher.config.SecretAccessKey,
+		SessionToken:    publisher.config.SessionToken,
+		Region:          publisher.config.Region,
+		Service:         "route53",
+	}
+
+	creds.sign(req, payload, publisher.now())
+
+	resp, err := publisher.config.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "route53 request")
+	}
+
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return errors.Wrap(err, "read response")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr route53ErrorResponse
+		if err := xml.Unmarshal(data, &apiErr); err == nil && len(apiErr.Code) > 0 {
+			return errors.Errorf("route53 returned %s: %s: %s", resp.Status, apiErr.Code, apiErr.Message)
+		}
+
+		return errors.Errorf("route53 returned %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return errors.Wrap(xml.Unmarshal(data, out), "decode response")
+}
+
+// String returns a friendly representation
+// of the publisher
+func (publisher *Route53Publisher) String() string {
+	return fmt.Sprintf("route53:%s", publisher.config.HostedZoneID)
+}
@@ -0,0 +1,108 @@
+package publish
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	esni "github.com/LiamHaworth/go-esni"
+)
+
+// fakeRoute53 implements the subset of the Route53
+// resource record sets API used by the publisher
+type fakeRoute53 struct {
+	mu      sync.Mutex
+	sets    map[string]route53RecordSet
+	batches int
+}
+
+func (server *fakeRoute53) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if len(r.Header.Get("Authorization")) == 0 {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var list route53ListResponse
+		if set, ok := server.sets[r.URL.Query().Get("type")+" "+r.URL.Query().Get("name")]; ok {
+			list.RecordSets = append(list.RecordSets, set)
+		}
+
+		_ = xml.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var request route53ChangeRequest
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		server.batches++
+		for _, change := range request.Changes {
+			key := change.RecordSet.Type + " " + change.RecordSet.Name
+			if change.Action == "DELETE" {
+				delete(server.sets, key)
+				continue
+			}
+
+			server.sets[key] = change.RecordSet
+		}
+	}
+}
+
+func TestRoute53PublisherHTTPS(t *testing.T) {
+	server := &fakeRoute53{sets: make(map[string]route53RecordSet)}
+	endpoint := httptest.NewServer(server)
+	defer endpoint.Close()
+
+	publisher, err := NewRoute53Publisher(Route53Config{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		HostedZoneID:    "/hostedzone/Z1",
+		Endpoint:        endpoint.URL,
+		ZoneOptions: esni.ZoneOptions{
+			HTTPS: &esni.SVCBRecord{Priority: 1, Target: "."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRoute53Publisher() error = %s", err)
+	}
+
+	current, next := newTestKeys(t), newTestKeys(t)
+	ctx := context.Background()
+
+	for _, keys := range []*esni.Keys{current, next} {
+		if err := publisher.Publish(ctx, "example.com", keys); err != nil {
+			t.Fatalf("Publish() error = %s", err)
+		}
+	}
+
+	if server.batches != 2 {
+		t.Fatalf("Publish() submitted %d change batches, want 2", server.batches)
+	}
+
+	for _, key := range []string{"TXT _esni.example.com.", "HTTPS example.com."} {
+		if set := server.sets[key]; len(set.Values) != 2 {
+			t.Errorf("%s record set holds %d values, want 2", key, len(set.Values))
+		}
+	}
+
+	if err := publisher.Remove(ctx, "example.com", current); err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+
+	if err := publisher.Verify(ctx, "example.com", current); err != ErrNotPublished {
+		t.Errorf("Verify() of removed keys error = %v, want %v", err, ErrNotPublished)
+	}
+
+	if err := publisher.Verify(ctx, "example.com", next); err != nil {
+		t.Errorf("Verify() of remaining keys error = %s", err)
+	}
+}
@@ -0,0 +1,103 @@
+package publish
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// sigV4Algorithm specifies the signing
+	// algorithm identifier of AWS signature v4
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+	// sigV4TimeFormat specifies the format
+	// of the X-Amz-Date header
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// sigV4Credentials specifies the credentials and
+// scope used to sign requests with AWS signature v4
+type sigV4Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+}
+
+// sign adds the X-Amz-Date and Authorization headers
+// to the request, signing the host, date and session
+// token headers along with the payload
+func (creds sigV4Credentials) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	date := now.Format(sigV4TimeFormat)
+
+	req.Header.Set("X-Amz-Date", date)
+	if len(creds.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": date}
+	if len(creds.SessionToken) > 0 {
+		headers["x-amz-security-token"] = creds.SessionToken
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(payload)
+
+	path := req.URL.EscapedPath()
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date[:8], creds.Region, creds.Service)
+
+	stringToSign := strings.Join([]string{sigV4Algorithm, date, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date[:8])
+	key = hmacSHA256(key, creds.Region)
+	key = hmacSHA256(key, creds.Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256
+// of the data using the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
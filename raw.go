@@ -0,0 +1,63 @@
+package esni
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// RawRecord represents a record of a version this
+// package is unable to parse, the binary data of the
+// record is preserved unmodified so it can be stored
+// or forwarded without loss
+type RawRecord struct {
+	// Data contains the binary data of the
+	// record, including the leading version
+	Data []byte
+}
+
+// Version returns the version value read
+// from the leading bytes of the record
+func (raw RawRecord) Version() Version {
+	if len(raw.Data) < 2 {
+		return 0
+	}
+
+	return Version(binary.BigEndian.Uint16(raw.Data))
+}
+
+// Body returns the opaque data of the
+// record following the version
+func (raw RawRecord) Body() []byte {
+	if len(raw.Data) < 2 {
+		return nil
+	}
+
+	return raw.Data[2:]
+}
+
+// Validity always reports that the validity
+// period of a RawRecord is unknown
+func (raw RawRecord) Validity() (time.Time, time.Time, bool) {
+	return time.Time{}, time.Time{}, false
+}
+
+// String returns a friendly representation
+// of the information stored in this structure
+func (raw RawRecord) String() string {
+	return fmt.Sprintf("{Version:0x%04x, Body:%s}", uint16(raw.Version()), hex.EncodeToString(raw.Body()))
+}
+
+// MarshalBinary returns a copy of the
+// binary data of the record
+func (raw RawRecord) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), raw.Data...), nil
+}
+
+// UnmarshalBinary stores a copy of the
+// provided binary data
+func (raw *RawRecord) UnmarshalBinary(data []byte) error {
+	raw.Data = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,131 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// recordReader captures the bytes of a single
+// Keys record as they are read from an underlying
+// reader, allowing the extent of the record to be
+// determined without reading past its end
+type recordReader struct {
+	reader io.Reader
+	limits Limits
+	raw    bytes.Buffer
+}
+
+// read will read exactly n bytes from the underlying
+// reader, appending them to the captured record data
+func (rr *recordReader) read(n int) ([]byte, error) {
+	if err := rr.limits.check("record size", rr.raw.Len()+n, rr.limits.MaxRecordSize); err != nil {
+		return nil, err
+	}
+
+	start := rr.raw.Len()
+	if _, err := io.CopyN(&rr.raw, rr.reader, int64(n)); err != nil {
+		if err == io.EOF && rr.raw.Len() > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return rr.raw.Bytes()[start:], nil
+}
+
+// readVector will read a length prefixed vector,
+// where the length is encoded in either 1 or 2 bytes
+func (rr *recordReader) readVector(lengthSize int) error {
+	prefix, err := rr.read(lengthSize)
+	if err != nil {
+		return err
+	}
+
+	length := int(prefix[0])
+	if lengthSize == 2 {
+		length = int(binary.BigEndian.Uint16(prefix))
+	}
+
+	_, err = rr.read(length)
+	return err
+}
+
+// ParseKeys will read exactly one Keys record from
+// the reader and attempt to unmarshal it, no data
+// after the end of the record is consumed allowing
+// records embedded in larger structures to be parsed
+// without pre-slicing the data.
+//
+// If the reader is at EOF before any data of the
+// record is read, io.EOF is returned. Only versions
+// built into the package can be parsed as the extent
+// of records using a registered codec is unknown.
+func ParseKeys(r io.Reader) (*Keys, error) {
+	return ParseKeysWithLimits(r, DefaultLimits)
+}
+
+// ParseKeysWithLimits will read exactly one Keys
+// record from the reader, as done by ParseKeys,
+// enforcing the provided parse limits
+func ParseKeysWithLimits(r io.Reader, limits Limits) (*Keys, error) {
+	rr := &recordReader{reader: r, limits: limits}
+
+	header, err := rr.read(2)
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+
+		return nil, errors.Wrap(err, "read version")
+	}
+
+	version := Version(binary.BigEndian.Uint16(header))
+	if !version.hasLayout() {
+		return nil, unsupportedVersionError(version)
+	}
+
+	if version.HasChecksum() {
+		if _, err := rr.read(4); err != nil {
+			return nil, errors.Wrap(err, "read checksum")
+		}
+	}
+
+	if version.publicNameLengthSize() > 0 {
+		if err := rr.readVector(version.publicNameLengthSize()); err != nil {
+			return nil, errors.Wrap(err, "read public name")
+		}
+	}
+
+	if err := rr.readVector(2); err != nil {
+		return nil, errors.Wrap(err, "read key share list")
+	}
+
+	if err := rr.readVector(2); err != nil {
+		return nil, errors.Wrap(err, "read cipher suite list")
+	}
+
+	if _, err := rr.read(2); err != nil {
+		return nil, errors.Wrap(err, "read padded length")
+	}
+
+	if version.HasValidityPeriod() {
+		if _, err := rr.read(16); err != nil {
+			return nil, errors.Wrap(err, "read validity period")
+		}
+	}
+
+	if err := rr.readVector(2); err != nil {
+		return nil, errors.Wrap(err, "read extensions list")
+	}
+
+	keys := new(Keys)
+	if _, err := keys.unmarshal(rr.raw.Bytes(), limits); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,232 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ESNIRecord represents the record structure
+// introduced in draft-06 of the ESNI specification,
+// which wraps the key material in a version and length
+// prefixed structure with its own dedicated extensions
+type ESNIRecord struct {
+	// Version specifies the ESNI specification
+	// version the record conforms too
+	Version Version
+
+	// PublicName specifies the clear text SNI
+	// to be used during the TLS handshake
+	PublicName string
+
+	// Keys defines the list of public keys
+	// permitted to be used for generating the
+	// shared encryption secret
+	Keys KeyShareEntryList
+
+	// CipherSuites defines the list of cipher
+	// suites permitted to be used for the
+	// encryption of the SNI
+	CipherSuites []CipherSuite
+
+	// PaddedLength specifies the length the
+	// SNI must be padded to before encryption
+	PaddedLength uint16
+
+	// Extensions specifies the extensions
+	// dedicated to the record
+	Extensions ExtensionList
+}
+
+// NewESNIRecord converts the Keys record into an
+// ESNIRecord, the checksum and validity period of
+// the Keys record have no equivalent in an ESNIRecord
+// and are dropped
+func NewESNIRecord(keys *Keys) *ESNIRecord {
+	return &ESNIRecord{
+		Version:      VersionDraft06,
+		PublicName:   keys.PublicName,
+		Keys:         keys.Keys,
+		CipherSuites: keys.CipherSuites,
+		PaddedLength: keys.PaddedLength,
+		Extensions:   keys.Extensions,
+	}
+}
+
+// ToKeys converts the ESNIRecord into a Keys record
+// using the draft-05 layout, which carries the same
+// fields as an ESNIRecord without the wrapping
+func (record *ESNIRecord) ToKeys() *Keys {
+	return &Keys{
+		Version:      VersionDraft05,
+		PublicName:   record.PublicName,
+		Keys:         record.Keys,
+		CipherSuites: record.CipherSuites,
+		PaddedLength: record.PaddedLength,
+		Extensions:   record.Extensions,
+	}
+}
+
+// String returns a friendly representation
+// of the information stored in this structure
+func (record *ESNIRecord) String() string {
+	var builder strings.Builder
+	builder.WriteString("{")
+
+	_, _ = fmt.Fprintf(&builder, "Version:%s, ", record.Version)
+	_, _ = fmt.Fprintf(&builder, "PublicName:%s, ", record.PublicName)
+	_, _ = fmt.Fprintf(&builder, "Keys:%s, ", record.Keys)
+	_, _ = fmt.Fprintf(&builder, "CipherSuites:%s, ", record.CipherSuites)
+	_, _ = fmt.Fprintf(&builder, "PaddedLength:%d, ", record.PaddedLength)
+	_, _ = fmt.Fprintf(&builder, "Extensions:%s", record.Extensions)
+
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// MarshalBinary will marshal the ESNIRecord into
+// its binary format, the version followed by the
+// length of the key material and extensions
+func (record ESNIRecord) MarshalBinary() ([]byte, error) {
+	if record.Version != VersionDraft06 {
+		return nil, unsupportedVersionError(record.Version)
+	}
+
+	body, err := record.marshalContents()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) > 0xffff {
+		return nil, errors.New("record contents are too large")
+	}
+
+	data := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint16(data[0:], uint16(record.Version))
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)))
+	copy(data[4:], body)
+
+	return data, nil
+}
+
+// marshalContents will marshal the fields of the
+// record that follow its version and length, the
+// key shares, cipher suites and extensions share
+// their encoding with the Keys record
+func (record ESNIRecord) marshalContents() ([]byte, error) {
+	var data bytes.Buffer
+
+	if len(record.PublicName) == 0 {
+		return nil, errors.New("public name is empty")
+	}
+
+	if err := writeVector16(&data, []byte(record.PublicName)); err != nil {
+		return nil, errors.Wrap(err, "write public name")
+	}
+
+	keys := Keys{Keys: record.Keys, CipherSuites: record.CipherSuites, Extensions: record.Extensions}
+
+	if err := keys.marshalKeyShareList(&data); err != nil {
+		return nil, errors.Wrap(err, "marshal key share list")
+	}
+
+	if err := keys.marshalCipherSuites(&data); err != nil {
+		return nil, errors.Wrap(err, "marshal cipher suite list")
+	}
+
+	if err := binary.Write(&data, binary.BigEndian, record.PaddedLength); err != nil {
+		return nil, errors.Wrap(err, "write padded length")
+	}
+
+	if err := keys.marshalExtensions(&data); err != nil {
+		return nil, errors.Wrap(err, "marshal extensions list")
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal an
+// ESNIRecord from the provided binary data
+func (record *ESNIRecord) UnmarshalBinary(data []byte) error {
+	_, err := record.unmarshal(data, DefaultLimits)
+	return err
+}
+
+// unmarshal will attempt to unmarshal a single
+// ESNIRecord from the start of the binary data,
+// returning the number of bytes occupied by it
+func (record *ESNIRecord) unmarshal(data []byte, limits Limits) (int, error) {
+	if len(data) < 4 {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for version and length")
+	}
+
+	record.Version = Version(binary.BigEndian.Uint16(data[0:]))
+	if record.Version != VersionDraft06 {
+		return 0, unsupportedVersionError(record.Version)
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if err := limits.check("record size", 4+length, limits.MaxRecordSize); err != nil {
+		return 0, err
+	}
+
+	if len(data) < 4+length {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for record contents")
+	}
+
+	reader := bytes.NewReader(data[4 : 4+length])
+	if err := record.unmarshalContents(reader, limits); err != nil {
+		return 0, err
+	}
+
+	if reader.Len() > 0 {
+		return 0, errors.Errorf("%d bytes of unexpected data in record contents", reader.Len())
+	}
+
+	return 4 + length, nil
+}
+
+// unmarshalContents will unmarshal the fields of
+// the record that follow its version and length
+func (record *ESNIRecord) unmarshalContents(reader *bytes.Reader, limits Limits) error {
+	name, err := readVector16(reader)
+	if err != nil {
+		return errors.Wrap(err, "read public name")
+	}
+
+	if len(name) == 0 {
+		return errors.New("public name is empty")
+	}
+
+	if err := limits.check("public name length", len(name), limits.MaxPublicNameLength); err != nil {
+		return err
+	}
+
+	var keys Keys
+	if err := keys.unmarshalKeyShareList(reader, limits); err != nil {
+		return errors.Wrap(err, "unmarshal key share list")
+	}
+
+	if err := keys.unmarshalCipherSuites(reader); err != nil {
+		return errors.Wrap(err, "unmarshal cipher suite list")
+	}
+
+	if err := binary.Read(reader, binary.BigEndian, &record.PaddedLength); err != nil {
+		return errors.Wrap(err, "read padded length")
+	}
+
+	if err := keys.unmarshalExtensions(reader, limits); err != nil {
+		return errors.Wrap(err, "unmarshal extensions list")
+	}
+
+	record.PublicName = string(name)
+	record.Keys = keys.Keys
+	record.CipherSuites = keys.CipherSuites
+	record.Extensions = keys.Extensions
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package esni
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// draft06Record is an ESNIRecord assembled by
+// hand, the contents follow the version and
+// their length
+var draft06Record = mustDecodeTestHex("" +
+	"ff05" + "003b" + // version, length
+	"000b" + "6578616d706c652e636f6d" + // public_name "example.com"
+	"0024" + "001d" + "0020" + // keys, x25519 key share
+	"9fd7ad6dcff4298dd3f96d5b1b2af910a0535b1488d7f8fabb349a982880b615" +
+	"0002" + "1301" + // cipher_suites, TLS_AES_128_GCM_SHA256
+	"0104" + // padded_length 260
+	"0000") // extensions
+
+func TestESNIRecordRoundTrip(t *testing.T) {
+	record := new(ESNIRecord)
+	if err := record.UnmarshalBinary(draft06Record); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	if record.Version != VersionDraft06 || record.PublicName != "example.com" || record.PaddedLength != 260 {
+		t.Errorf("unexpected record contents %s", record)
+	}
+
+	if len(record.Keys) != 1 || record.Keys[0].Group != GroupX25519 {
+		t.Errorf("Keys = %s, want a single x25519 key share", record.Keys)
+	}
+
+	data, err := record.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(data, draft06Record) {
+		t.Fatalf("MarshalBinary() = %x, want %x", data, draft06Record)
+	}
+}
+
+func TestESNIRecordConversion(t *testing.T) {
+	keys := new(Keys)
+	if err := keys.UnmarshalBinary(draft04Record); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	data, err := NewESNIRecord(keys).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(data, draft06Record) {
+		t.Fatalf("MarshalBinary() = %x, want %x", data, draft06Record)
+	}
+
+	converted, err := NewESNIRecord(keys).ToKeys().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(converted, draft05Record) {
+		t.Fatalf("ToKeys() = %x, want %x", converted, draft05Record)
+	}
+}
+
+func TestESNIRecordRejects(t *testing.T) {
+	trailing := append(append([]byte(nil), draft06Record...), 0x00)
+	trailing[3]++
+
+	tests := map[string]struct {
+		data []byte
+		want error
+	}{
+		"truncated contents": {data: draft06Record[:len(draft06Record)-1], want: io.ErrUnexpectedEOF},
+		"draft-05 record":    {data: draft05Record, want: ErrUnsupportedVersion},
+		"trailing contents":  {data: trailing},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := new(ESNIRecord).UnmarshalBinary(test.data)
+			if err == nil {
+				t.Fatal("UnmarshalBinary() succeeded, want error")
+			}
+
+			if test.want != nil && errors.Cause(err) != test.want {
+				t.Fatalf("UnmarshalBinary() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
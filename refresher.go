@@ -0,0 +1,260 @@
+package esni
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinRefreshInterval specifies the shortest
+	// interval between refreshes of a domain
+	MinRefreshInterval = 30 * time.Second
+
+	// MaxRefreshInterval specifies the longest
+	// interval between refreshes of a domain
+	MaxRefreshInterval = time.Hour
+
+	// refreshFraction specifies the fraction of the
+	// remaining lifetime of the records after which
+	// they are refreshed
+	refreshFraction = 0.75
+
+	// refreshJitter specifies the fraction each
+	// interval is randomly varied by, spreading
+	// the refreshes of many clients
+	refreshJitter = 0.1
+
+	// minRefreshBackoff and maxRefreshBackoff specify
+	// the bounds of the delay before retrying a failed
+	// refresh, which doubles after each failure
+	minRefreshBackoff = time.Second
+	maxRefreshBackoff = 5 * time.Minute
+)
+
+var (
+	// ErrDomainNotWatched is returned when requesting
+	// the records of a domain that isn't watched by
+	// a Refresher
+	ErrDomainNotWatched = errors.New("domain is not watched")
+)
+
+// KeysChangeFunc is called by a Refresher when the
+// Keys records fetched for a domain change, old is
+// nil for the first successful fetch
+type KeysChangeFunc func(domain string, old, new []FetchedKeys)
+
+// Refresher keeps the Keys records of a set of domains
+// fresh, running a goroutine per domain that refetches
+// the records before they expire.
+//
+// Refreshes are scheduled with random jitter and failed
+// fetches are retried with exponential backoff while the
+// last fetched records continue to be served, it is safe
+// for concurrent use.
+type Refresher struct {
+	fetcher  *Fetcher
+	onChange KeysChangeFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	domains map[string]*refreshedDomain
+}
+
+// refreshedDomain represents the
+// state of a refreshed domain
+type refreshedDomain struct {
+	cancel context.CancelFunc
+	keys   []FetchedKeys
+	err    error
+}
+
+// NewRefresher returns a new Refresher that fetches
+// records using the fetcher, or a default Fetcher if
+// nil, calling onChange, if not nil, whenever the
+// records of a domain change
+func NewRefresher(fetcher *Fetcher, onChange KeysChangeFunc) *Refresher {
+	if fetcher == nil {
+		fetcher = new(Fetcher)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Refresher{
+		fetcher:  fetcher,
+		onChange: onChange,
+		ctx:      ctx,
+		cancel:   cancel,
+		domains:  make(map[string]*refreshedDomain),
+	}
+}
+
+// Watch starts refreshing the records of the domain,
+// the first fetch is made immediately, watching a
+// domain that is already watched has no effect
+func (refresher *Refresher) Watch(domain string) {
+	key := cacheKey(domain)
+
+	refresher.mu.Lock()
+	defer refresher.mu.Unlock()
+
+	if _, ok := refresher.domains[key]; ok || refresher.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(refresher.ctx)
+	refresher.domains[key] = &refreshedDomain{cancel: cancel}
+
+	refresher.wg.Add(1)
+	go refresher.run(ctx, key)
+}
+
+// Unwatch stops refreshing the records of the
+// domain and discards the records fetched for it
+func (refresher *Refresher) Unwatch(domain string) {
+	refresher.mu.Lock()
+	defer refresher.mu.Unlock()
+
+	if state, ok := refresher.domains[cacheKey(domain)]; ok {
+		state.cancel()
+		delete(refresher.domains, cacheKey(domain))
+	}
+}
+
+// Keys returns the last records fetched for the
+// domain, if none have been fetched the error of
+// the last fetch is returned
+func (refresher *Refresher) Keys(domain string) ([]FetchedKeys, error) {
+	refresher.mu.Lock()
+	defer refresher.mu.Unlock()
+
+	state, ok := refresher.domains[cacheKey(domain)]
+	if !ok {
+		return nil, ErrDomainNotWatched
+	}
+
+	if len(state.keys) == 0 && state.err == nil {
+		return nil, ErrNoKeys
+	}
+
+	if len(state.keys) == 0 {
+		return nil, state.err
+	}
+
+	return state.keys, nil
+}
+
+// Close stops refreshing all domains and
+// waits for the refresh goroutines to exit
+func (refresher *Refresher) Close() {
+	refresher.cancel()
+	refresher.wg.Wait()
+}
+
+// run refreshes the records of the domain until the
+// context is cancelled
+func (refresher *Refresher) run(ctx context.Context, domain string) {
+	defer refresher.wg.Done()
+
+	backoff := minRefreshBackoff
+	for {
+		var delay time.Duration
+
+		fetched, _, err := refresher.fetcher.FetchKeys(ctx, domain)
+		if err != nil {
+			delay = jitter(backoff)
+
+			if backoff *= 2; backoff > maxRefreshBackoff {
+				backoff = maxRefreshBackoff
+			}
+		} else {
+			delay = refreshDelay(fetched)
+			backoff = minRefreshBackoff
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		refresher.update(domain, fetched, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// update records the result of a fetch of the domain,
+// calling the change callback if the records changed
+func (refresher *Refresher) update(domain string, fetched []FetchedKeys, err error) {
+	refresher.mu.Lock()
+
+	state, ok := refresher.domains[domain]
+	if !ok {
+		refresher.mu.Unlock()
+		return
+	}
+
+	state.err = err
+	if err != nil {
+		refresher.mu.Unlock()
+		return
+	}
+
+	old := state.keys
+	state.keys = fetched
+	refresher.mu.Unlock()
+
+	if refresher.onChange != nil && !sameFetchedKeys(old, fetched) {
+		refresher.onChange(domain, old, fetched)
+	}
+}
+
+// refreshDelay returns the jittered delay before the
+// fetched records are refreshed, a fraction of their
+// remaining lifetime bounded by the refresh intervals
+func refreshDelay(fetched []FetchedKeys) time.Duration {
+	delay := time.Duration(float64(fetchedExpiry(fetched, DefaultCacheTTL).Sub(fetched[0].Source.FetchedAt)) * refreshFraction)
+
+	if delay = jitter(delay); delay < MinRefreshInterval {
+		return MinRefreshInterval
+	} else if delay > MaxRefreshInterval {
+		return MaxRefreshInterval
+	}
+
+	return delay
+}
+
+// jitter randomly varies the duration
+// by up to the refresh jitter fraction
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + refreshJitter*(2*rand.Float64()-1)))
+}
+
+// sameFetchedKeys returns if the two sets of
+// fetched records contain the same records
+// in the same order
+func sameFetchedKeys(a, b []FetchedKeys) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i].Source.Raw, b[i].Source.Raw) {
+			return false
+		}
+	}
+
+	return true
+}
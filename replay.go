@@ -0,0 +1,131 @@
+package esni
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrReplayDetected is returned when an encrypted
+	// SNI, or the nonce it carries, has already been
+	// seen within the window of the replay cache
+	ErrReplayDetected = errors.New("replayed encrypted sni")
+)
+
+// ReplayCache remembers the encrypted SNIs and nonces
+// seen by a server within a time window, allowing
+// replayed ClientHellos used to probe the server to
+// be detected.
+//
+// The cache is bounded, once full the oldest entry
+// is evicted, it is safe for concurrent use.
+type ReplayCache struct {
+	mu       sync.Mutex
+	capacity int
+	window   time.Duration
+	entries  map[[sha256.Size]byte]*list.Element
+	order    *list.List
+}
+
+// replayEntry represents a single
+// entry in the replay cache
+type replayEntry struct {
+	key  [sha256.Size]byte
+	seen time.Time
+}
+
+// NewReplayCache returns a new ReplayCache holding
+// at most capacity entries, each entry is remembered
+// for the duration of the window
+func NewReplayCache(capacity int, window time.Duration) *ReplayCache {
+	return &ReplayCache{
+		capacity: capacity,
+		window:   window,
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Check records the value as seen at the current
+// time, returning true if it was already seen
+// within the window
+func (cache *ReplayCache) Check(value []byte) bool {
+	return cache.CheckAt(value, time.Now())
+}
+
+// CheckAt records the value as seen at the
+// provided time, returning true if it was
+// already seen within the window
+func (cache *ReplayCache) CheckAt(value []byte, now time.Time) bool {
+	key := sha256.Sum256(value)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.expire(now)
+
+	if _, seen := cache.entries[key]; seen {
+		return true
+	}
+
+	if cache.capacity > 0 && cache.order.Len() >= cache.capacity {
+		oldest := cache.order.Front()
+		delete(cache.entries, oldest.Value.(*replayEntry).key)
+		cache.order.Remove(oldest)
+	}
+
+	cache.entries[key] = cache.order.PushBack(&replayEntry{key: key, seen: now})
+	return false
+}
+
+// Len returns the number of
+// entries in the cache
+func (cache *ReplayCache) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return cache.order.Len()
+}
+
+// expire removes the entries that were
+// seen before the start of the window
+func (cache *ReplayCache) expire(now time.Time) {
+	cutoff := now.Add(-cache.window)
+
+	for element := cache.order.Front(); element != nil; element = cache.order.Front() {
+		entry := element.Value.(*replayEntry)
+		if entry.seen.After(cutoff) {
+			return
+		}
+
+		delete(cache.entries, entry.key)
+		cache.order.Remove(element)
+	}
+}
+
+// CheckEncryptedSNI records the key share and encrypted
+// SNI sent by a client, returning ErrReplayDetected if
+// the same payload was already seen within the window
+func (cache *ReplayCache) CheckEncryptedSNI(encrypted ClientEncryptedSNI) error {
+	value := append(append([]byte("payload"), encrypted.KeyShare.KeyExchange...), encrypted.EncryptedSNI...)
+	if cache.Check(value) {
+		return ErrReplayDetected
+	}
+
+	return nil
+}
+
+// CheckNonce records the nonce of a decrypted SNI,
+// returning ErrReplayDetected if the same nonce
+// was already seen within the window
+func (cache *ReplayCache) CheckNonce(nonce [NonceSize]byte) error {
+	if cache.Check(append([]byte("nonce"), nonce[:]...)) {
+		return ErrReplayDetected
+	}
+
+	return nil
+}
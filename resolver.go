@@ -0,0 +1,352 @@
+package esni
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// Resolver is implemented by the DNS backends used
+// to fetch ESNI and ECH records, NetResolver adapts
+// a *net.Resolver and DNSClientResolver a miekg/dns
+// client to it
+type Resolver interface {
+	// LookupTXT returns the TXT records of the
+	// name, each record as a single string with
+	// its character-strings joined
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+
+	// LookupHTTPS returns the
+	// HTTPS records of the name
+	LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error)
+}
+
+// LookupInfo describes the records
+// returned by a lookup
+type LookupInfo struct {
+	// DNSSEC specifies the DNSSEC
+	// status of the records
+	DNSSEC DNSSECStatus
+
+	// TTL specifies the lowest TTL of the
+	// records, zero if it isn't known
+	TTL time.Duration
+}
+
+// DetailedResolver is implemented by the DNS backends
+// able to report the details of the records they
+// return, such as their DNSSEC status and TTL.
+//
+// The DoH, DoT and miekg/dns resolvers of this package
+// act as stub resolvers, they request validation from
+// the recursive resolver but only trust the AD bit of
+// its response when the channel to it is authenticated,
+// that is DNS-over-HTTPS, DNS-over-TLS or a resolver on
+// a loopback address. Records authenticated over any
+// other channel are reported as DNSSECIndeterminate,
+// as the AD bit can be set by anyone on the path.
+type DetailedResolver interface {
+	// LookupTXTDetailed returns the TXT records
+	// of the name and their details
+	LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error)
+
+	// LookupHTTPSDetailed returns the HTTPS records
+	// of the name and their details
+	LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error)
+}
+
+// NetResolver adapts a *net.Resolver to the Resolver
+// interface, as net.Resolver can't look up HTTPS records
+// they are queried directly from the name servers of the
+// system resolver configuration using the Dial function
+// of the resolver when it is set
+type NetResolver struct {
+	// Resolver specifies the resolver, if
+	// nil net.DefaultResolver is used
+	Resolver *net.Resolver
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver NetResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return resolver.resolver().LookupTXT(ctx, name)
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver NetResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := lookupHTTPS(ctx, &DNSClientResolver{Dial: resolver.resolver().Dial}, name)
+	return records, err
+}
+
+// resolver returns the adapted resolver
+func (resolver NetResolver) resolver() *net.Resolver {
+	if resolver.Resolver != nil {
+		return resolver.Resolver
+	}
+
+	return net.DefaultResolver
+}
+
+// DNSClientResolver adapts a miekg/dns client to the
+// Resolver interface, sending queries to each of the
+// name servers in turn until one of them responds
+type DNSClientResolver struct {
+	// Client specifies the client used to send
+	// queries, if nil queries are sent over UDP
+	// and retried over TCP if the response is
+	// truncated
+	Client *dns.Client
+
+	// Servers specifies the addresses of the name
+	// servers in host:port form, if empty those of
+	// the system resolver configuration are used
+	Servers []string
+
+	// Dial optionally specifies the function used to
+	// connect to the name servers, in the same manner
+	// as the Dial field of net.Resolver
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// TrustAD specifies if the AD bit of responses is
+	// trusted even though the name servers aren't on
+	// a loopback address or reached over TLS, in the
+	// same manner as the trust-ad option of resolv.conf,
+	// it must only be set when the path to the name
+	// servers is otherwise secured
+	TrustAD bool
+}
+
+// NewDNSClientResolver returns a resolver that sends
+// queries to the servers using the client
+func NewDNSClientResolver(client *dns.Client, servers ...string) *DNSClientResolver {
+	return &DNSClientResolver{Client: client, Servers: servers}
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver *DNSClientResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := lookupTXT(ctx, resolver, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name and the details reported by the server
+func (resolver *DNSClientResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	return lookupTXT(ctx, resolver, name)
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver *DNSClientResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := lookupHTTPS(ctx, resolver, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of the
+// name and the details reported by the server
+func (resolver *DNSClientResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	return lookupHTTPS(ctx, resolver, name)
+}
+
+// Exchange sends the query to each name server in
+// turn and returns the first response received
+func (resolver *DNSClientResolver) Exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	servers, err := resolver.servers()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error = errors.New("no name servers configured")
+	for _, address := range servers {
+		response, err := resolver.exchange(ctx, query, address)
+		if err == nil {
+			return response, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		lastErr = errors.Wrapf(err, "query %s", address)
+	}
+
+	return nil, lastErr
+}
+
+// exchange sends the query to the name server,
+// retrying over TCP if the default client is in
+// use and the response is truncated
+func (resolver *DNSClientResolver) exchange(ctx context.Context, query *dns.Msg, address string) (*dns.Msg, error) {
+	if resolver.Client != nil {
+		return resolver.exchangeWith(ctx, resolver.Client, query, address)
+	}
+
+	response, err := resolver.exchangeWith(ctx, new(dns.Client), query, address)
+	if err == nil && response.Truncated {
+		return resolver.exchangeWith(ctx, &dns.Client{Net: "tcp"}, query, address)
+	}
+
+	return response, err
+}
+
+// exchangeWith sends the query to the name server
+// using the client, connecting with the Dial
+// function of the resolver if it is set
+func (resolver *DNSClientResolver) exchangeWith(ctx context.Context, client *dns.Client, query *dns.Msg, address string) (*dns.Msg, error) {
+	if resolver.Dial == nil {
+		response, _, err := client.ExchangeContext(ctx, query, address)
+		return response, err
+	}
+
+	network := client.Net
+	if len(network) == 0 {
+		network = "udp"
+	}
+
+	conn, err := resolver.Dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	response, _, err := client.ExchangeWithConnContext(ctx, query, &dns.Conn{Conn: conn})
+	return response, err
+}
+
+// trustsAD returns if the AD bit of responses can be
+// trusted, which requires every name server to be on
+// a loopback address or reached over TLS unless the
+// resolver has been told to trust it
+func (resolver *DNSClientResolver) trustsAD() bool {
+	if resolver.TrustAD {
+		return true
+	}
+
+	if resolver.Client != nil && resolver.Client.Net == "tcp-tls" {
+		return resolver.Client.TLSConfig == nil || !resolver.Client.TLSConfig.InsecureSkipVerify
+	}
+
+	// A custom dial function may route the
+	// queries anywhere regardless of address
+	if resolver.Dial != nil {
+		return false
+	}
+
+	servers, err := resolver.servers()
+	if err != nil || len(servers) == 0 {
+		return false
+	}
+
+	for _, address := range servers {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil || !isLoopbackHost(host) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isLoopbackHost returns if the host is
+// a loopback address or localhost
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// servers returns the addresses of the name
+// servers queries are sent to
+func (resolver *DNSClientResolver) servers() ([]string, error) {
+	if len(resolver.Servers) > 0 {
+		return resolver.Servers, nil
+	}
+
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, errors.Wrap(err, "read resolver config")
+	}
+
+	servers := make([]string, len(config.Servers))
+	for i := range config.Servers {
+		servers[i] = net.JoinHostPort(config.Servers[i], config.Port)
+	}
+
+	return servers, nil
+}
+
+// StaticResolver is a Resolver that answers lookups
+// from fixed sets of records, it is intended for use
+// in tests and for injecting records fetched by
+// other means
+type StaticResolver struct {
+	// TXT specifies the TXT records of each
+	// name, keyed without the trailing dot
+	TXT map[string][]string
+
+	// HTTPS specifies the HTTPS records of each
+	// name, keyed without the trailing dot
+	HTTPS map[string][]SVCBRecord
+
+	// Info specifies the details
+	// reported for every lookup
+	Info LookupInfo
+
+	// Err specifies an error returned
+	// by every lookup, if set
+	Err error
+}
+
+// LookupTXT returns the TXT records of the name
+func (resolver *StaticResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	txts, _, err := resolver.LookupTXTDetailed(ctx, name)
+	return txts, err
+}
+
+// LookupTXTDetailed returns the TXT records of
+// the name and the details of the resolver
+func (resolver *StaticResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	if resolver.Err != nil {
+		return nil, LookupInfo{}, resolver.Err
+	}
+
+	txts, ok := resolver.TXT[strings.TrimSuffix(name, ".")]
+	if !ok {
+		return nil, LookupInfo{}, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return txts, resolver.Info, nil
+}
+
+// LookupHTTPS returns the HTTPS records of the name
+func (resolver *StaticResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	records, _, err := resolver.LookupHTTPSDetailed(ctx, name)
+	return records, err
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of
+// the name and the details of the resolver
+func (resolver *StaticResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	if resolver.Err != nil {
+		return nil, LookupInfo{}, resolver.Err
+	}
+
+	records, ok := resolver.HTTPS[strings.TrimSuffix(name, ".")]
+	if !ok {
+		return nil, LookupInfo{}, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, resolver.Info, nil
+}
+
+// isNotFound checks if the error reports that
+// the records being looked up don't exist
+func isNotFound(err error) bool {
+	dnsErr, ok := errors.Cause(err).(*net.DNSError)
+	return ok && dnsErr.IsNotFound
+}
@@ -0,0 +1,59 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNonceMismatch is returned when the nonce
+	// echoed by the server doesn't match the nonce
+	// sent by the client
+	ErrNonceMismatch = errors.New("echoed nonce does not match")
+)
+
+// ServerEncryptedSNI represents the body of the
+// encrypted_server_name extension sent by the server
+// in EncryptedExtensions, echoing the nonce from the
+// ClientESNIInner to prove it decrypted the SNI
+type ServerEncryptedSNI struct {
+	// Nonce specifies the nonce of
+	// the ClientESNIInner
+	Nonce [NonceSize]byte
+}
+
+// MarshalBinary will marshal the ServerEncryptedSNI
+// into the binary format used as the body of the
+// encrypted_server_name extension
+func (response ServerEncryptedSNI) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), response.Nonce[:]...), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ServerEncryptedSNI from the body of an
+// encrypted_server_name extension
+func (response *ServerEncryptedSNI) UnmarshalBinary(data []byte) error {
+	if len(data) != NonceSize {
+		return errors.Errorf("invalid nonce length of %d", len(data))
+	}
+
+	copy(response.Nonce[:], data)
+	return nil
+}
+
+// VerifyNonceEcho parses the encrypted_server_name
+// extension from the server's EncryptedExtensions and
+// checks the echoed nonce matches the nonce of the
+// ClientESNIInner sent by the client, ErrNonceMismatch
+// is returned if it doesn't
+func VerifyNonceEcho(inner *ClientESNIInner, extensionData []byte) error {
+	var response ServerEncryptedSNI
+	if err := response.UnmarshalBinary(extensionData); err != nil {
+		return errors.Wrap(err, "unmarshal server encrypted sni")
+	}
+
+	if !inner.MatchNonce(response.Nonce[:]) {
+		return ErrNonceMismatch
+	}
+
+	return nil
+}
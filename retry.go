@@ -0,0 +1,67 @@
+package esni
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// TLSExtensionEncryptedClientHello specifies the
+	// TLS extension type of the encrypted_client_hello
+	// extension carried in the ClientHello and in the
+	// EncryptedExtensions of the server
+	TLSExtensionEncryptedClientHello uint16 = 0xfe0d
+)
+
+// ECHEncryptedExtensions represents the payload of the
+// encrypted_client_hello extension sent by a server in
+// EncryptedExtensions, it carries the configs a client
+// should retry with when the server rejected ECH
+type ECHEncryptedExtensions struct {
+	// RetryConfigs specifies the configs the
+	// client should use to retry the connection,
+	// configs of unsupported versions are skipped
+	RetryConfigs ECHConfigList
+
+	// Raw contains the binary data of the retry
+	// configs as sent by the server, including
+	// any configs of unsupported versions
+	Raw []byte
+}
+
+// MarshalBinary will marshal the retry configs
+// into the payload of the extension
+func (ext ECHEncryptedExtensions) MarshalBinary() ([]byte, error) {
+	if len(ext.RetryConfigs) == 0 {
+		return nil, errors.New("retry config list is empty")
+	}
+
+	return ext.RetryConfigs.MarshalBinary()
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// retry configs from the payload of the extension
+func (ext *ECHEncryptedExtensions) UnmarshalBinary(data []byte) error {
+	if err := ext.RetryConfigs.UnmarshalBinary(data); err != nil {
+		return errors.Wrap(err, "unmarshal retry configs")
+	}
+
+	ext.Raw = append([]byte(nil), data...)
+	return nil
+}
+
+// ParseRetryConfigs will attempt to parse the retry
+// configs from the payload of the encrypted_client_hello
+// extension in EncryptedExtensions, an error is returned
+// if the server sent no configs with a supported version
+func ParseRetryConfigs(extensionData []byte) (ECHConfigList, error) {
+	var ext ECHEncryptedExtensions
+	if err := ext.UnmarshalBinary(extensionData); err != nil {
+		return nil, err
+	}
+
+	if len(ext.RetryConfigs) == 0 {
+		return nil, errors.Wrap(ErrUnsupportedVersion, "no retry configs with a supported version")
+	}
+
+	return ext.RetryConfigs, nil
+}
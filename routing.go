@@ -0,0 +1,168 @@
+package esni
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// RouteRule represents a rule of a RoutingResolver,
+// sending the lookups of names under a domain suffix
+// to a resolver while its network condition holds
+type RouteRule struct {
+	// Suffix specifies the domain the rule applies
+	// to, matching the domain and every name below
+	// it, an empty suffix matches every name
+	Suffix string
+
+	// Networks specifies the local networks the rule
+	// is limited to, it only applies while one of the
+	// addresses of the host is in one of the networks,
+	// if empty the rule applies on every network
+	Networks []*net.IPNet
+
+	// Resolver specifies the resolver the
+	// lookups matching the rule are sent to
+	Resolver Resolver
+}
+
+// matches checks if the rule applies
+// to the name from the host addresses
+func (rule RouteRule) matches(name string, addrs []net.IP) bool {
+	suffix := cacheKey(rule.Suffix)
+	if len(suffix) > 0 && name != suffix && !strings.HasSuffix(name, "."+suffix) {
+		return false
+	}
+
+	if len(rule.Networks) == 0 {
+		return true
+	}
+
+	for _, network := range rule.Networks {
+		for _, addr := range addrs {
+			if network.Contains(addr) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RoutingResolver implements a Resolver that sends
+// each lookup to a resolver picked by the name being
+// looked up and the network the host is attached to,
+// allowing clients to honour split-horizon DNS, such
+// as resolving internal domains through corporate
+// name servers and everything else through DoH.
+//
+// Of the rules that apply to a name the one with
+// the longest suffix is used, rules with the same
+// suffix are tried in order. Names no rule applies
+// to are sent to the default resolver.
+type RoutingResolver struct {
+	// Rules specifies the routing rules
+	Rules []RouteRule
+
+	// Default specifies the resolver used when no
+	// rule applies, if nil net.DefaultResolver is
+	// used through a NetResolver
+	Default Resolver
+
+	// LocalAddrs specifies the function returning the
+	// addresses of the host that network conditions are
+	// evaluated against, if nil the addresses of the
+	// network interfaces are used
+	LocalAddrs func() ([]net.IP, error)
+}
+
+// NewRoutingResolver returns a RoutingResolver sending
+// lookups to the resolver of the best matching rule,
+// or to the default resolver if none apply
+func NewRoutingResolver(defaultResolver Resolver, rules ...RouteRule) *RoutingResolver {
+	return &RoutingResolver{Rules: rules, Default: defaultResolver}
+}
+
+// Route returns the resolver the
+// lookups of the name are sent to
+func (routing *RoutingResolver) Route(name string) Resolver {
+	name = cacheKey(name)
+
+	var addrs []net.IP
+	for _, rule := range routing.Rules {
+		if len(rule.Networks) > 0 {
+			addrs = routing.localAddrs()
+			break
+		}
+	}
+
+	var (
+		best       Resolver
+		bestLength = -1
+	)
+
+	for _, rule := range routing.Rules {
+		if length := len(cacheKey(rule.Suffix)); length > bestLength && rule.matches(name, addrs) {
+			best, bestLength = rule.Resolver, length
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+
+	if routing.Default == nil {
+		return NetResolver{}
+	}
+
+	return routing.Default
+}
+
+// LookupTXT returns the TXT records of the
+// name from the resolver it is routed to
+func (routing *RoutingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return routing.Route(name).LookupTXT(ctx, name)
+}
+
+// LookupTXTDetailed returns the TXT records of the
+// name from the resolver it is routed to, reporting
+// their details if the resolver supports it
+func (routing *RoutingResolver) LookupTXTDetailed(ctx context.Context, name string) ([]string, LookupInfo, error) {
+	return lookupTXTDetailed(ctx, routing.Route(name), name)
+}
+
+// LookupHTTPS returns the HTTPS records of the
+// name from the resolver it is routed to
+func (routing *RoutingResolver) LookupHTTPS(ctx context.Context, name string) ([]SVCBRecord, error) {
+	return routing.Route(name).LookupHTTPS(ctx, name)
+}
+
+// LookupHTTPSDetailed returns the HTTPS records of the
+// name from the resolver it is routed to, reporting
+// their details if the resolver supports it
+func (routing *RoutingResolver) LookupHTTPSDetailed(ctx context.Context, name string) ([]SVCBRecord, LookupInfo, error) {
+	return lookupHTTPSDetailed(ctx, routing.Route(name), name)
+}
+
+// localAddrs returns the addresses of the host, if
+// they can't be determined no network rule applies
+func (routing *RoutingResolver) localAddrs() []net.IP {
+	if routing.LocalAddrs != nil {
+		addrs, _ := routing.LocalAddrs()
+		return addrs
+	}
+
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+
+	addrs := make([]net.IP, 0, len(interfaceAddrs))
+	for _, addr := range interfaceAddrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			addrs = append(addrs, ipNet.IP)
+		}
+	}
+
+	return addrs
+}
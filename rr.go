@@ -0,0 +1,235 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// ResourceRecord represents a complete DNS resource
+// record with opaque RDATA, allowing records to be
+// exchanged with DNS provisioning APIs in their wire
+// format or the generic presentation format of
+// RFC 3597
+type ResourceRecord struct {
+	// Name specifies the fully
+	// qualified owner name
+	Name string
+
+	// Type specifies the type of the record
+	Type uint16
+
+	// Class specifies the class of the record
+	Class uint16
+
+	// TTL specifies the TTL of
+	// the record in seconds
+	TTL uint32
+
+	// Data specifies the RDATA
+	// of the record
+	Data []byte
+}
+
+// NewTXTResourceRecord returns the _esni TXT
+// resource record of the domain carrying the
+// Keys record
+func NewTXTResourceRecord(domain string, keys *Keys, ttl uint32) (ResourceRecord, error) {
+	chunks, err := keys.MarshalTXT()
+	if err != nil {
+		return ResourceRecord{}, err
+	}
+
+	data := new(bytes.Buffer)
+	for _, chunk := range chunks {
+		data.WriteByte(byte(len(chunk)))
+		data.WriteString(chunk)
+	}
+
+	return ResourceRecord{
+		Name:  dns.Fqdn(ESNIQueryName(domain)),
+		Type:  dns.TypeTXT,
+		Class: dns.ClassINET,
+		TTL:   ttl,
+		Data:  data.Bytes(),
+	}, nil
+}
+
+// NewHTTPSResourceRecord returns the HTTPS
+// resource record of the name carrying the
+// SVCB record
+func NewHTTPSResourceRecord(name string, record SVCBRecord, ttl uint32) (ResourceRecord, error) {
+	data, err := record.MarshalBinary()
+	if err != nil {
+		return ResourceRecord{}, errors.Wrap(err, "marshal https record")
+	}
+
+	return ResourceRecord{
+		Name:  dns.Fqdn(name),
+		Type:  dns.TypeHTTPS,
+		Class: dns.ClassINET,
+		TTL:   ttl,
+		Data:  data,
+	}, nil
+}
+
+// MarshalBinary will marshal the resource record
+// into its DNS wire format, the owner name is
+// written uncompressed
+func (rr ResourceRecord) MarshalBinary() ([]byte, error) {
+	if len(rr.Data) > 0xFFFF {
+		return nil, errors.New("rdata is too long")
+	}
+
+	data := new(bytes.Buffer)
+	if err := writeDomainName(data, rr.Name); err != nil {
+		return nil, errors.Wrap(err, "write owner name")
+	}
+
+	binary.Write(data, binary.BigEndian, rr.Type)
+	binary.Write(data, binary.BigEndian, rr.Class)
+	binary.Write(data, binary.BigEndian, rr.TTL)
+	binary.Write(data, binary.BigEndian, uint16(len(rr.Data)))
+	data.Write(rr.Data)
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// resource record from its DNS wire format, the
+// owner name must not be compressed
+func (rr *ResourceRecord) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	name, err := readDomainName(reader)
+	if err != nil {
+		return errors.Wrap(err, "read owner name")
+	}
+
+	var header struct {
+		Type, Class uint16
+		TTL         uint32
+		Length      uint16
+	}
+
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return errors.Wrap(io.ErrUnexpectedEOF, "read record header")
+	}
+
+	if reader.Len() != int(header.Length) {
+		return errors.Errorf("rdata length %d does not match remaining %d bytes", header.Length, reader.Len())
+	}
+
+	rr.Name = name
+	rr.Type = header.Type
+	rr.Class = header.Class
+	rr.TTL = header.TTL
+	rr.Data = make([]byte, header.Length)
+	reader.Read(rr.Data)
+
+	return nil
+}
+
+// String returns the record in the generic
+// presentation format of RFC 3597 using the
+// generic type and class names
+func (rr ResourceRecord) String() string {
+	return fmt.Sprintf("%s\t%d\tCLASS%d\tTYPE%d\t\\# %d %x", rr.Name, rr.TTL, rr.Class, rr.Type, len(rr.Data), rr.Data)
+}
+
+// ParseResourceRecord parses a resource record from a
+// single zone file line in the generic presentation
+// format of RFC 3597, both the generic and mnemonic
+// names of types and classes are accepted and the TTL
+// and class may appear in either order
+func ParseResourceRecord(text string) (ResourceRecord, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 4 {
+		return ResourceRecord{}, errors.New("record has too few fields")
+	}
+
+	rr := ResourceRecord{Name: dns.Fqdn(fields[0]), Class: dns.ClassINET}
+	fields = fields[1:]
+
+	for seenTTL, seenClass := false, false; len(fields) > 0; fields = fields[1:] {
+		if ttl, err := strconv.ParseUint(fields[0], 10, 32); err == nil && !seenTTL {
+			rr.TTL, seenTTL = uint32(ttl), true
+		} else if class, ok := parseRRClass(fields[0]); ok && !seenClass {
+			rr.Class, seenClass = class, true
+		} else {
+			break
+		}
+	}
+
+	if len(fields) < 3 {
+		return ResourceRecord{}, errors.New("record has no type or rdata")
+	}
+
+	rrType, ok := parseRRType(fields[0])
+	if !ok {
+		return ResourceRecord{}, errors.Errorf("unknown record type %q", fields[0])
+	}
+
+	rr.Type = rrType
+
+	if fields[1] != `\#` {
+		return ResourceRecord{}, errors.New(`rdata is not in the generic \# format`)
+	}
+
+	length, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return ResourceRecord{}, errors.Wrap(err, "parse rdata length")
+	}
+
+	if rr.Data, err = hex.DecodeString(strings.Join(fields[3:], "")); err != nil {
+		return ResourceRecord{}, errors.Wrap(err, "decode rdata")
+	}
+
+	if len(rr.Data) != int(length) {
+		return ResourceRecord{}, errors.Errorf("rdata is %d bytes, expected %d", len(rr.Data), length)
+	}
+
+	return rr, nil
+}
+
+// parseRRType parses a record type from either its
+// mnemonic or its generic TYPEnnn name
+func parseRRType(text string) (uint16, bool) {
+	if rrType, ok := dns.StringToType[strings.ToUpper(text)]; ok {
+		return rrType, true
+	}
+
+	return parseGenericName(text, "TYPE")
+}
+
+// parseRRClass parses a record class from either
+// its mnemonic or its generic CLASSnnn name
+func parseRRClass(text string) (uint16, bool) {
+	if class, ok := dns.StringToClass[strings.ToUpper(text)]; ok {
+		return class, true
+	}
+
+	return parseGenericName(text, "CLASS")
+}
+
+// parseGenericName parses the numeric value of a
+// generic type or class name with the prefix
+func parseGenericName(text, prefix string) (uint16, bool) {
+	if len(text) <= len(prefix) || !strings.EqualFold(text[:len(prefix)], prefix) {
+		return 0, false
+	}
+
+	value, err := strconv.ParseUint(text[len(prefix):], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(value), true
+}
@@ -0,0 +1,298 @@
+package esni
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultScanConcurrency specifies the number
+	// of domains a Scanner looks up at once
+	DefaultScanConcurrency = 16
+
+	// DefaultScanTimeout specifies the time limit
+	// of the lookups of a single domain
+	DefaultScanTimeout = 10 * time.Second
+)
+
+// ScanReport represents the ESNI and ECH
+// deployment found for a single domain
+type ScanReport struct {
+	// Domain specifies the domain scanned
+	Domain string
+
+	// HasESNI specifies if the domain publishes
+	// usable _esni TXT records
+	HasESNI bool
+
+	// HasECH specifies if the domain publishes
+	// HTTPS records with usable ECH configs
+	HasECH bool
+
+	// ESNIVersions and ECHVersions specify the
+	// distinct versions of the usable records
+	ESNIVersions []Version
+	ECHVersions  []Version
+
+	// Groups specifies the distinct groups of
+	// the key shares of the ESNI records
+	Groups []Group
+
+	// KEMs specifies the distinct HPKE KEMs
+	// of the ECH configs
+	KEMs []HpkeKemId
+
+	// NotBefore and NotAfter specify the earliest
+	// start and latest end of the validity periods
+	// of the ESNI records, zero if none have one
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// ESNISkipped and ECHSkipped specify the number
+	// of records that were found but not usable
+	ESNISkipped int
+	ECHSkipped  int
+
+	// DNSSEC specifies the least secure DNSSEC
+	// status of the usable records
+	DNSSEC DNSSECStatus
+
+	// ESNIErr and ECHErr specify why each lookup
+	// failed, records that don't exist are not
+	// treated as a failure
+	ESNIErr error
+	ECHErr  error
+
+	// Duration specifies how long
+	// the domain took to scan
+	Duration time.Duration
+}
+
+// Scanner looks up the ESNI and ECH records of a
+// stream of domains with bounded concurrency and
+// reports the deployment found for each, intended
+// for measuring the adoption of the extensions
+type Scanner struct {
+	// Fetcher specifies the fetcher used to look
+	// up and parse the records, if nil a default
+	// Fetcher is used
+	Fetcher *Fetcher
+
+	// Concurrency specifies the number of domains
+	// looked up at once, if zero DefaultScanConcurrency
+	// is used
+	Concurrency int
+
+	// Timeout specifies the time limit of the lookups
+	// of a single domain, if zero DefaultScanTimeout
+	// is used
+	Timeout time.Duration
+}
+
+// NewScanner returns a Scanner looking up records with
+// the fetcher and scanning concurrency domains at once
+func NewScanner(fetcher *Fetcher, concurrency int) *Scanner {
+	return &Scanner{Fetcher: fetcher, Concurrency: concurrency}
+}
+
+// Scan reads domains from the channel until it is closed
+// or the context is done and sends a report for each to
+// the returned channel, which is closed once every domain
+// read has been reported or the context is done. Reports
+// are sent in the order the scans complete rather than
+// the order of the domains.
+func (scanner *Scanner) Scan(ctx context.Context, domains <-chan string) <-chan ScanReport {
+	concurrency := scanner.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultScanConcurrency
+	}
+
+	reports := make(chan ScanReport)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case domain, ok := <-domains:
+					if !ok {
+						return
+					}
+
+					select {
+					case reports <- scanner.ScanDomain(ctx, domain):
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(reports)
+	}()
+
+	return reports
+}
+
+// ScanDomain looks up the ESNI and ECH
+// records of a single domain
+func (scanner *Scanner) ScanDomain(ctx context.Context, domain string) ScanReport {
+	fetcher := scanner.Fetcher
+	if fetcher == nil {
+		fetcher = new(Fetcher)
+	}
+
+	timeout := scanner.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScanTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	report := ScanReport{Domain: domain, DNSSEC: DNSSECSecure}
+
+	var (
+		wg              sync.WaitGroup
+		keys            []FetchedKeys
+		configs         []FetchedECHConfigs
+		esniSkipped     []SkippedRecord
+		echSkipped      []SkippedRecord
+		esniErr, echErr error
+	)
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		keys, esniSkipped, esniErr = fetcher.FetchKeys(ctx, domain)
+	}()
+
+	go func() {
+		defer wg.Done()
+		configs, echSkipped, echErr = fetcher.FetchECHConfigs(ctx, domain)
+	}()
+
+	wg.Wait()
+
+	report.ESNISkipped, report.ECHSkipped = len(esniSkipped), len(echSkipped)
+	report.HasESNI, report.HasECH = len(keys) > 0, len(configs) > 0
+
+	if !isNotFound(esniErr) {
+		report.ESNIErr = esniErr
+	}
+
+	if !isNotFound(echErr) {
+		report.ECHErr = echErr
+	}
+
+	for i := range keys {
+		report.addKeys(keys[i])
+	}
+
+	for i := range configs {
+		report.addConfigs(configs[i])
+	}
+
+	if !report.HasESNI && !report.HasECH {
+		report.DNSSEC = DNSSECIndeterminate
+	}
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// addKeys adds the details of the
+// fetched Keys record to the report
+func (report *ScanReport) addKeys(fetched FetchedKeys) {
+	keys := fetched.Keys
+	report.DNSSEC = leastSecure(report.DNSSEC, fetched.Source.DNSSEC)
+
+	if !containsVersion(report.ESNIVersions, keys.Version) {
+		report.ESNIVersions = append(report.ESNIVersions, keys.Version)
+	}
+
+	for i := range keys.Keys {
+		if !containsGroup(report.Groups, keys.Keys[i].Group) {
+			report.Groups = append(report.Groups, keys.Keys[i].Group)
+		}
+	}
+
+	if !keys.Version.HasValidityPeriod() {
+		return
+	}
+
+	if report.NotBefore.IsZero() || keys.NotBefore.Before(report.NotBefore) {
+		report.NotBefore = keys.NotBefore
+	}
+
+	if keys.NotAfter.After(report.NotAfter) {
+		report.NotAfter = keys.NotAfter
+	}
+}
+
+// addConfigs adds the details of the
+// fetched ECH configs to the report
+func (report *ScanReport) addConfigs(fetched FetchedECHConfigs) {
+	report.DNSSEC = leastSecure(report.DNSSEC, fetched.Source.DNSSEC)
+
+	for i := range fetched.Configs {
+		config := fetched.Configs[i]
+
+		if !containsVersion(report.ECHVersions, config.Version) {
+			report.ECHVersions = append(report.ECHVersions, config.Version)
+		}
+
+		if !containsKEM(report.KEMs, config.KemID) {
+			report.KEMs = append(report.KEMs, config.KemID)
+		}
+	}
+}
+
+// containsVersion checks if the
+// list contains the version
+func containsVersion(list []Version, version Version) bool {
+	for i := range list {
+		if list[i] == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsGroup checks if the
+// list contains the group
+func containsGroup(list []Group, group Group) bool {
+	for i := range list {
+		if list[i] == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsKEM checks if the
+// list contains the KEM
+func containsKEM(list []HpkeKemId, kem HpkeKemId) bool {
+	for i := range list {
+		if list[i] == kem {
+			return true
+		}
+	}
+
+	return false
+}
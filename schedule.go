@@ -0,0 +1,152 @@
+package esni
+
+import (
+	"bytes"
+
+	"github.com/LiamHaworth/go-esni/internal/tls13"
+	"github.com/pkg/errors"
+)
+
+const (
+	// LabelESNIKey specifies the HKDF label used
+	// to derive the key that encrypts the SNI
+	LabelESNIKey = "esni key"
+
+	// LabelESNIIV specifies the HKDF label used
+	// to derive the IV that encrypts the SNI
+	LabelESNIIV = "esni iv"
+)
+
+// KeyScheduleOptions specifies the options of the
+// ESNI key schedule, allowing private deployments
+// that fork the protocol to use their own labels
+type KeyScheduleOptions struct {
+	// KeyLabel specifies the label used to derive
+	// the key, if empty LabelESNIKey is used
+	KeyLabel string
+
+	// IVLabel specifies the label used to derive
+	// the IV, if empty LabelESNIIV is used
+	IVLabel string
+}
+
+// labels returns the key and IV labels of
+// the options, filling in the defaults
+func (opts KeyScheduleOptions) labels() (keyLabel, ivLabel string) {
+	keyLabel, ivLabel = opts.KeyLabel, opts.IVLabel
+
+	if len(keyLabel) == 0 {
+		keyLabel = LabelESNIKey
+	}
+
+	if len(ivLabel) == 0 {
+		ivLabel = LabelESNIIV
+	}
+
+	return keyLabel, ivLabel
+}
+
+// ESNIContents represents the structure that binds
+// the derived key and IV to the Keys record, the
+// client's key share and the ClientHello
+type ESNIContents struct {
+	// RecordDigest specifies the digest of
+	// the Keys record used by the client
+	RecordDigest []byte
+
+	// KeyShare specifies the key share entry
+	// the client used for the key exchange
+	KeyShare KeyShareEntry
+
+	// ClientHelloRandom specifies the random
+	// value of the ClientHello
+	ClientHelloRandom [32]byte
+}
+
+// MarshalBinary will marshal the contents into
+// the binary format that is hashed to produce the
+// context of the key schedule
+func (contents ESNIContents) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+
+	if err := writeVector16(&data, contents.RecordDigest); err != nil {
+		return nil, errors.Wrap(err, "write record digest")
+	}
+
+	entry, err := contents.KeyShare.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal key share entry")
+	}
+
+	data.Write(entry)
+	data.Write(contents.ClientHelloRandom[:])
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// contents from the provided binary data
+func (contents *ESNIContents) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	digest, err := readVector16(reader)
+	if err != nil {
+		return errors.Wrap(err, "read record digest")
+	}
+
+	rest := data[len(data)-reader.Len():]
+	if err := contents.KeyShare.UnmarshalBinary(rest); err != nil {
+		return err
+	}
+
+	rest = rest[contents.KeyShare.Size():]
+	if len(rest) != len(contents.ClientHelloRandom) {
+		return errors.New("invalid client hello random length")
+	}
+
+	contents.RecordDigest = digest
+	copy(contents.ClientHelloRandom[:], rest)
+
+	return nil
+}
+
+// DeriveSNIKey runs the ESNI key schedule for the cipher
+// suite, deriving the AEAD key and IV used to encrypt the
+// SNI from the shared secret of the key exchange and the
+// ESNI contents:
+//
+//	Zx  = HKDF-Extract(0, Z)
+//	key = HKDF-Expand-Label(Zx, "esni key", Hash(ESNIContents), key_length)
+//	iv  = HKDF-Expand-Label(Zx, "esni iv", Hash(ESNIContents), iv_length)
+func DeriveSNIKey(suite CipherSuite, sharedSecret []byte, contents ESNIContents) (key, iv []byte, err error) {
+	return DeriveSNIKeyWithOptions(suite, sharedSecret, contents, KeyScheduleOptions{})
+}
+
+// DeriveSNIKeyWithOptions runs the ESNI key schedule
+// in the same manner as DeriveSNIKey using the labels
+// specified by the options
+func DeriveSNIKeyWithOptions(suite CipherSuite, sharedSecret []byte, contents ESNIContents, opts KeyScheduleOptions) (key, iv []byte, err error) {
+	params, ok := CipherSuite_parameters[suite]
+	if !ok || !params.Hash.Available() {
+		return nil, nil, errors.Errorf("unsupported cipher suite %s", suite)
+	}
+
+	data, err := contents.MarshalBinary()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "marshal esni contents")
+	}
+
+	digest := params.Hash.New()
+	_, _ = digest.Write(data)
+	context := digest.Sum(nil)
+
+	zx := tls13.Extract(params.Hash.New, sharedSecret, nil)
+	defer Zeroize(zx)
+
+	keyLabel, ivLabel := opts.labels()
+
+	key = tls13.ExpandLabel(params.Hash.New, zx, keyLabel, context, params.KeyLength)
+	iv = tls13.ExpandLabel(params.Hash.New, zx, ivLabel, context, params.NonceLength)
+
+	return key, iv, nil
+}
@@ -0,0 +1,146 @@
+package esni
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hkdf"
+	"encoding/binary"
+	"testing"
+)
+
+// testESNIContents returns ESNI contents with
+// a fixed digest, key share and random
+func testESNIContents() ESNIContents {
+	contents := ESNIContents{
+		RecordDigest: bytes.Repeat([]byte{0x11}, 32),
+		KeyShare:     KeyShareEntry{Group: GroupX25519, KeyExchange: bytes.Repeat([]byte{0x22}, 32)},
+	}
+
+	copy(contents.ClientHelloRandom[:], bytes.Repeat([]byte{0x33}, 32))
+	return contents
+}
+
+// expandLabel computes HKDF-Expand-Label with the
+// standard library, independently of internal/tls13
+func expandLabel(t *testing.T, hash crypto.Hash, secret []byte, label string, context []byte, length int) []byte {
+	t.Helper()
+
+	info := binary.BigEndian.AppendUint16(nil, uint16(length))
+	info = append(info, byte(len("tls13 "+label)))
+	info = append(info, "tls13 "+label...)
+	info = append(info, byte(len(context)))
+	info = append(info, context...)
+
+	out, err := hkdf.Expand(hash.New, secret, string(info), length)
+	if err != nil {
+		t.Fatalf("expand label %q: %s", label, err)
+	}
+
+	return out
+}
+
+func TestDeriveSNIKey(t *testing.T) {
+	sharedSecret := bytes.Repeat([]byte{0x44}, 32)
+	contents := testESNIContents()
+
+	tests := []struct {
+		suite             CipherSuite
+		hash              crypto.Hash
+		keyLength, ivSize int
+	}{
+		{suite: CipherSuite_TLS_AES_128_GCM_SHA256, hash: crypto.SHA256, keyLength: 16, ivSize: 12},
+		{suite: CipherSuite_TLS_AES_256_GCM_SHA384, hash: crypto.SHA384, keyLength: 32, ivSize: 12},
+		{suite: CipherSuite_TLS_CHACHA20_POLY1305_SHA256, hash: crypto.SHA256, keyLength: 32, ivSize: 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.suite.String(), func(t *testing.T) {
+			key, iv, err := DeriveSNIKey(test.suite, sharedSecret, contents)
+			if err != nil {
+				t.Fatalf("DeriveSNIKey() error = %v", err)
+			}
+
+			data, err := contents.MarshalBinary()
+			if err != nil {
+				t.Fatalf("marshal contents: %s", err)
+			}
+
+			digest := test.hash.New()
+			digest.Write(data)
+			context := digest.Sum(nil)
+
+			zx, err := hkdf.Extract(test.hash.New, sharedSecret, nil)
+			if err != nil {
+				t.Fatalf("extract: %s", err)
+			}
+
+			if want := expandLabel(t, test.hash, zx, "esni key", context, test.keyLength); !bytes.Equal(key, want) {
+				t.Errorf("DeriveSNIKey() key = %x, want %x", key, want)
+			}
+
+			if want := expandLabel(t, test.hash, zx, "esni iv", context, test.ivSize); !bytes.Equal(iv, want) {
+				t.Errorf("DeriveSNIKey() iv = %x, want %x", iv, want)
+			}
+		})
+	}
+}
+
+func TestDeriveSNIKeyBindsContents(t *testing.T) {
+	sharedSecret := bytes.Repeat([]byte{0x44}, 32)
+
+	key, _, err := DeriveSNIKey(CipherSuite_TLS_AES_128_GCM_SHA256, sharedSecret, testESNIContents())
+	if err != nil {
+		t.Fatalf("DeriveSNIKey() error = %v", err)
+	}
+
+	tests := map[string]func(contents *ESNIContents){
+		"record digest": func(contents *ESNIContents) { contents.RecordDigest[0] ^= 0xff },
+		"key share":     func(contents *ESNIContents) { contents.KeyShare.KeyExchange[0] ^= 0xff },
+		"random":        func(contents *ESNIContents) { contents.ClientHelloRandom[0] ^= 0xff },
+	}
+
+	for name, edit := range tests {
+		t.Run(name, func(t *testing.T) {
+			contents := testESNIContents()
+			edit(&contents)
+
+			other, _, err := DeriveSNIKey(CipherSuite_TLS_AES_128_GCM_SHA256, sharedSecret, contents)
+			if err != nil {
+				t.Fatalf("DeriveSNIKey() error = %v", err)
+			}
+
+			if bytes.Equal(key, other) {
+				t.Fatal("key was not bound to the contents")
+			}
+		})
+	}
+
+	if _, _, err := DeriveSNIKey(CipherSuite(0x13ff), sharedSecret, testESNIContents()); err == nil {
+		t.Fatal("expected an unknown cipher suite to be rejected")
+	}
+}
+
+func TestESNIContentsRoundTrip(t *testing.T) {
+	contents := testESNIContents()
+
+	data, err := contents.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	var decoded ESNIContents
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	if !bytes.Equal(decoded.RecordDigest, contents.RecordDigest) ||
+		!bytes.Equal(decoded.KeyShare.KeyExchange, contents.KeyShare.KeyExchange) ||
+		decoded.KeyShare.Group != contents.KeyShare.Group ||
+		decoded.ClientHelloRandom != contents.ClientHelloRandom {
+		t.Fatalf("UnmarshalBinary() = %+v, want %+v", decoded, contents)
+	}
+
+	if err := decoded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("expected truncated contents to be rejected")
+	}
+}
@@ -0,0 +1,41 @@
+package esni
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+)
+
+// seededReader implements a deterministic random
+// source producing the AES-256-CTR keystream keyed
+// by the digest of a seed
+type seededReader struct {
+	stream cipher.Stream
+}
+
+// NewSeededReader returns a deterministic random
+// source derived from the seed, the same seed always
+// produces the same sequence of bytes.
+//
+// It allows key pairs and nonces to be reproduced for
+// test fixtures and deterministic infrastructure, it
+// must never be used with a secret that is guessable.
+func NewSeededReader(seed []byte) io.Reader {
+	key := sha256.Sum256(seed)
+	defer Zeroize(key[:])
+
+	block, _ := aes.NewCipher(key[:])
+	iv := make([]byte, aes.BlockSize)
+
+	return &seededReader{stream: cipher.NewCTR(block, iv)}
+}
+
+// Read fills the buffer with the
+// next bytes of the keystream
+func (reader *seededReader) Read(p []byte) (int, error) {
+	Zeroize(p)
+	reader.stream.XORKeyStream(p, p)
+
+	return len(p), nil
+}
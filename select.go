@@ -0,0 +1,282 @@
+package esni
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoUsableConfig is returned by SelectConfig
+	// when none of the configs in the list can be
+	// used by the client
+	ErrNoUsableConfig = errors.New("no usable config")
+)
+
+// SkipReason specifies why a config was
+// skipped during config selection
+type SkipReason uint8
+
+const (
+	SkipReasonUnsupportedVersion SkipReason = iota + 1
+	SkipReasonMandatoryExtension
+	SkipReasonInvalidPublicName
+	SkipReasonUnsupportedKEM
+	SkipReasonUnsupportedCipherSuite
+	SkipReasonMaximumNameLength
+)
+
+// SkipReason_name specifies a map of SkipReasons
+// to their respective string representation
+var SkipReason_name = map[SkipReason]string{
+	SkipReasonUnsupportedVersion:     "unsupported version",
+	SkipReasonMandatoryExtension:     "unsupported mandatory extension",
+	SkipReasonInvalidPublicName:      "invalid public name",
+	SkipReasonUnsupportedKEM:         "unsupported kem",
+	SkipReasonUnsupportedCipherSuite: "no supported cipher suite",
+	SkipReasonMaximumNameLength:      "maximum name length too large",
+}
+
+// String attempts to return the string
+// representation of the SkipReason based
+// on those specified in SkipReason_name, if
+// no match is found "UNKNOWN" is returned
+func (reason SkipReason) String() string {
+	if name, ok := SkipReason_name[reason]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// SkippedConfig describes a config that
+// was skipped during config selection
+type SkippedConfig struct {
+	// Index specifies the position of the
+	// config in the list
+	Index int
+
+	// ConfigID specifies the identifier
+	// of the skipped config
+	ConfigID uint8
+
+	// Reason specifies why the config
+	// was skipped
+	Reason SkipReason
+
+	// Detail provides additional information
+	// about the reason, such as the type of an
+	// unsupported extension
+	Detail string
+}
+
+// String returns a friendly representation
+// of the skipped config
+func (skipped SkippedConfig) String() string {
+	msg := fmt.Sprintf("config %d (id %d): %s", skipped.Index, skipped.ConfigID, skipped.Reason)
+	if skipped.Detail != "" {
+		msg += ": " + skipped.Detail
+	}
+
+	return msg
+}
+
+// ClientCapabilities describes the features
+// supported by a client, it is used to determine
+// which configs the client is able to use. An empty
+// list of algorithms permits any algorithm.
+type ClientCapabilities struct {
+	// KEMs specifies the HPKE KEMs supported
+	// by the client in order of preference
+	KEMs []HpkeKemId
+
+	// KDFs specifies the HPKE KDFs supported
+	// by the client
+	KDFs []HpkeKdfId
+
+	// AEADs specifies the HPKE AEAD algorithms
+	// supported by the client
+	AEADs []HpkeAeadId
+
+	// MaxNameLength specifies the largest maximum
+	// name length the client is willing to pad its
+	// server name to, zero permits any length
+	MaxNameLength uint8
+
+	// Extensions specifies the ECHConfig
+	// extension types understood by the client
+	Extensions []ECHExtensionType
+}
+
+// DefaultClientCapabilities specifies the capabilities
+// of a typical client, matching the algorithms supported
+// by the crypto/tls package
+var DefaultClientCapabilities = ClientCapabilities{
+	KEMs: []HpkeKemId{
+		HpkeKemId_DHKEM_X25519_HKDF_SHA256,
+		HpkeKemId_DHKEM_P256_HKDF_SHA256,
+		HpkeKemId_DHKEM_P384_HKDF_SHA384,
+		HpkeKemId_DHKEM_P521_HKDF_SHA512,
+	},
+	KDFs: []HpkeKdfId{
+		HpkeKdfId_HKDF_SHA256,
+		HpkeKdfId_HKDF_SHA384,
+		HpkeKdfId_HKDF_SHA512,
+	},
+	AEADs: []HpkeAeadId{
+		HpkeAeadId_AES_128_GCM,
+		HpkeAeadId_AES_256_GCM,
+		HpkeAeadId_CHACHA20_POLY1305,
+	},
+}
+
+// kemPreference returns the position of the KEM in
+// the preferences of the client, -1 is returned if
+// the client doesn't support the KEM
+func (caps ClientCapabilities) kemPreference(kem HpkeKemId) int {
+	if len(caps.KEMs) == 0 {
+		return 0
+	}
+
+	for i, supported := range caps.KEMs {
+		if supported == kem {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// supportsCipherSuite returns if the client
+// supports both algorithms of the cipher suite
+func (caps ClientCapabilities) supportsCipherSuite(suite HpkeSymmetricCipherSuite) bool {
+	if suite.AEAD == HpkeAeadId_EXPORT_ONLY {
+		return false
+	}
+
+	kdfOK, aeadOK := len(caps.KDFs) == 0, len(caps.AEADs) == 0
+	for _, kdf := range caps.KDFs {
+		kdfOK = kdfOK || kdf == suite.KDF
+	}
+
+	for _, aead := range caps.AEADs {
+		aeadOK = aeadOK || aead == suite.AEAD
+	}
+
+	return kdfOK && aeadOK
+}
+
+// CipherSuite returns the first cipher suite of
+// the config supported by the client, ok is false
+// if the client supports none of them
+func (caps ClientCapabilities) CipherSuite(config *ECHConfig) (HpkeSymmetricCipherSuite, bool) {
+	for _, suite := range config.CipherSuites {
+		if caps.supportsCipherSuite(suite) {
+			return suite, true
+		}
+	}
+
+	return HpkeSymmetricCipherSuite{}, false
+}
+
+// supportsExtension returns if the client
+// understands the extension type
+func (caps ClientCapabilities) supportsExtension(extType ECHExtensionType) bool {
+	for _, supported := range caps.Extensions {
+		if supported == extType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SelectConfig returns the first config in the list
+// the client is able to use along with the reason every
+// config before it was skipped.
+//
+// As required by the specification, configs with an
+// invalid public name or carrying a mandatory extension
+// the client doesn't understand are skipped. ErrNoUsableConfig is returned if every config
+// was skipped.
+func SelectConfig(list ECHConfigList, caps ClientCapabilities) (*ECHConfig, []SkippedConfig, error) {
+	var skipped []SkippedConfig
+
+	for i := range list {
+		reason, detail := caps.checkConfig(&list[i])
+		if reason == 0 {
+			return &list[i], skipped, nil
+		}
+
+		skipped = append(skipped, SkippedConfig{Index: i, ConfigID: list[i].ConfigID, Reason: reason, Detail: detail})
+	}
+
+	return nil, skipped, ErrNoUsableConfig
+}
+
+// checkConfig returns the reason the client
+// is unable to use the config, zero is returned
+// if the config is usable
+func (caps ClientCapabilities) checkConfig(config *ECHConfig) (SkipReason, string) {
+	if !config.Version.UsesHPKE() {
+		return SkipReasonUnsupportedVersion, fmt.Sprintf("version 0x%04x", uint16(config.Version))
+	}
+
+	if err := ValidatePublicName(config.PublicName); err != nil {
+		return SkipReasonInvalidPublicName, errors.Cause(err).Error()
+	}
+
+	for _, ext := range config.Extensions {
+		if ext.Type.Mandatory() && !caps.supportsExtension(ext.Type) {
+			return SkipReasonMandatoryExtension, fmt.Sprintf("extension 0x%04x", uint16(ext.Type))
+		}
+	}
+
+	if caps.kemPreference(config.KemID) < 0 {
+		return SkipReasonUnsupportedKEM, fmt.Sprintf("kem 0x%04x", uint16(config.KemID))
+	}
+
+	if _, ok := caps.CipherSuite(config); !ok {
+		return SkipReasonUnsupportedCipherSuite, ""
+	}
+
+	if caps.MaxNameLength != 0 && config.MaximumNameLength > caps.MaxNameLength {
+		return SkipReasonMaximumNameLength, fmt.Sprintf("%d", config.MaximumNameLength)
+	}
+
+	return 0, ""
+}
+
+// SelectBestConfig returns the config in the list
+// the client prefers along with the cipher suite to
+// use with it and the reason every unusable config
+// was skipped.
+//
+// Of the usable configs the one using the KEM most
+// preferred by the client is selected, ties are
+// resolved by the order of the list as published by
+// the server. ErrNoUsableConfig is returned if every
+// config was skipped.
+func SelectBestConfig(list ECHConfigList, caps ClientCapabilities) (*ECHConfig, HpkeSymmetricCipherSuite, []SkippedConfig, error) {
+	var skipped []SkippedConfig
+	var best *ECHConfig
+
+	for i := range list {
+		reason, detail := caps.checkConfig(&list[i])
+		if reason != 0 {
+			skipped = append(skipped, SkippedConfig{Index: i, ConfigID: list[i].ConfigID, Reason: reason, Detail: detail})
+			continue
+		}
+
+		if best == nil || caps.kemPreference(list[i].KemID) < caps.kemPreference(best.KemID) {
+			best = &list[i]
+		}
+	}
+
+	if best == nil {
+		return nil, HpkeSymmetricCipherSuite{}, skipped, ErrNoUsableConfig
+	}
+
+	suite, _ := caps.CipherSuite(best)
+	return best, suite, skipped, nil
+}
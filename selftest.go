@@ -0,0 +1,217 @@
+package esni
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/LiamHaworth/go-esni/internal/tls13"
+	"github.com/pkg/errors"
+)
+
+// selfTest represents a single
+// known answer test run by SelfTest
+type selfTest struct {
+	name string
+	run  func() error
+}
+
+// selfTests specifies the known answer tests
+// run by SelfTest in order, packages building
+// on this one append their own tests using
+// RegisterSelfTest
+var selfTests = []selfTest{
+	{"x25519", selfTestX25519},
+	{"hkdf", selfTestHKDF},
+	{"ccm", selfTestCCM},
+	{"key schedule", selfTestKeySchedule},
+	{"esni", selfTestESNI},
+}
+
+// RegisterSelfTest registers a known answer test to
+// be run by SelfTest after those of this package,
+// allowing packages that provide crypto built on it,
+// such as the HPKE provider of the ech package, to
+// have their implementations verified at startup
+func RegisterSelfTest(name string, run func() error) {
+	for _, test := range selfTests {
+		if test.name == name {
+			panic("self test already registered")
+		}
+	}
+
+	selfTests = append(selfTests, selfTest{name: name, run: run})
+}
+
+// SelfTest runs the known answer tests embedded in
+// the package against the key exchange, key schedule,
+// AEAD and record digest implementations currently
+// registered, round-tripping the encryption and
+// decryption of an SNI, followed by any tests added
+// using RegisterSelfTest.
+//
+// It is intended to be called at startup to verify
+// the crypto providers of the process are configured
+// correctly, the first failure is returned.
+func SelfTest() error {
+	for _, test := range selfTests {
+		if err := test.run(); err != nil {
+			return errors.Wrapf(err, "self test %s", test.name)
+		}
+	}
+
+	return nil
+}
+
+// selfTestX25519 checks the shared
+// secret of the X25519 vector
+func selfTestX25519() error {
+	sharedSecret, err := DeriveSharedSecret(GroupX25519, mustDecodeHex(x25519Vector.privateKey), mustDecodeHex(x25519Vector.peerPublic))
+	if err != nil {
+		return err
+	}
+
+	return expectHex("shared secret", sharedSecret, x25519Vector.sharedSecret)
+}
+
+// selfTestHKDF checks the pseudorandom key
+// and output of the HKDF vector
+func selfTestHKDF() error {
+	prk := tls13.Extract(sha256.New, mustDecodeHex(hkdfVector.ikm), mustDecodeHex(hkdfVector.salt))
+	if err := expectHex("prk", prk, hkdfVector.prk); err != nil {
+		return err
+	}
+
+	okm := tls13.Expand(sha256.New, prk, mustDecodeHex(hkdfVector.info), len(hkdfVector.okm)/2)
+	return expectHex("okm", okm, hkdfVector.okm)
+}
+
+// selfTestCCM checks the sealing and
+// opening of the CCM vector
+func selfTestCCM() error {
+	aead, err := ccmVector.suite.NewAEAD(mustDecodeHex(ccmVector.key))
+	if err != nil {
+		return err
+	}
+
+	nonce := mustDecodeHex(ccmVector.nonce)
+	additionalData := mustDecodeHex(ccmVector.additionalData)
+
+	ciphertext := aead.Seal(nil, nonce, mustDecodeHex(ccmVector.plaintext), additionalData)
+	if err := expectHex("ciphertext", ciphertext, ccmVector.ciphertext); err != nil {
+		return err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return err
+	}
+
+	return expectHex("plaintext", plaintext, ccmVector.plaintext)
+}
+
+// selfTestKeySchedule checks the key and IV
+// derived for each cipher suite against the
+// regression vectors
+func selfTestKeySchedule() error {
+	var contents ESNIContents
+	if err := contents.UnmarshalBinary(mustDecodeHex(keyScheduleRegressionVectors.contents)); err != nil {
+		return errors.Wrap(err, "unmarshal esni contents")
+	}
+
+	for suite, expected := range keyScheduleRegressionVectors.suites {
+		key, iv, err := DeriveSNIKey(suite, mustDecodeHex(keyScheduleRegressionVectors.sharedSecret), contents)
+		if err != nil {
+			return err
+		}
+
+		if err := expectHex(suite.String()+" key", key, expected[0]); err != nil {
+			return err
+		}
+
+		if err := expectHex(suite.String()+" iv", iv, expected[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selfTestESNI checks the record digest and the
+// encrypted SNI of the ESNI regression vector, then
+// decrypts it with the private key of the record
+func selfTestESNI() error {
+	keys := new(Keys)
+	if err := keys.UnmarshalBinary(mustDecodeHex(esniRegressionVector.keys)); err != nil {
+		return errors.Wrap(err, "unmarshal keys")
+	}
+
+	digest, err := keys.RecordDigest(keys.CipherSuites[0])
+	if err != nil {
+		return err
+	}
+
+	if err := expectHex("record digest", digest, esniRegressionVector.recordDigest); err != nil {
+		return err
+	}
+
+	clientRandom := mustDecodeHex(esniRegressionVector.clientRandom)
+	clientKeyShare := KeyShareEntry{Group: GroupX25519, KeyExchange: mustDecodeHex(esniRegressionVector.clientKeyShare)}
+
+	encrypted, inner, err := EncryptSNIFromReader(keys, esniRegressionVector.serverName, clientRandom, clientKeyShare, NewSeededReader([]byte(esniRegressionVector.seed)))
+	if err != nil {
+		return err
+	}
+
+	if err := expectHex("nonce", inner.Nonce[:], esniRegressionVector.nonce); err != nil {
+		return err
+	}
+
+	raw, err := encrypted.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := expectHex("encrypted sni", raw, esniRegressionVector.encryptedSNI); err != nil {
+		return err
+	}
+
+	aad, err := marshalKeyShareClientHello(clientKeyShare)
+	if err != nil {
+		return err
+	}
+
+	priv := &PrivateKeys{Entries: []PrivateKeyEntry{{KeyShareEntry: keys.Keys[0], PrivateKey: mustDecodeHex(esniRegressionVector.privateKey)}}}
+
+	serverName, nonce, err := DecryptSNI(*encrypted, keys, priv, clientRandom, aad)
+	if err != nil {
+		return err
+	}
+
+	if serverName != esniRegressionVector.serverName {
+		return errors.Errorf("decrypted server name %q, expected %q", serverName, esniRegressionVector.serverName)
+	}
+
+	return expectHex("decrypted nonce", nonce[:], esniRegressionVector.nonce)
+}
+
+// expectHex returns an error if the value doesn't
+// match the expected hexadecimal encoding
+func expectHex(name string, value []byte, expected string) error {
+	if !bytes.Equal(value, mustDecodeHex(expected)) {
+		return errors.Errorf("%s is %x, expected %s", name, value, expected)
+	}
+
+	return nil
+}
+
+// mustDecodeHex decodes the hexadecimal
+// vector, panicking if it is invalid
+func mustDecodeHex(value string) []byte {
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		panic("esni: invalid test vector: " + err.Error())
+	}
+
+	return decoded
+}
@@ -0,0 +1,24 @@
+package esni
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatalf("self test failed: %v", err)
+	}
+}
+
+func TestRegisterSelfTest(t *testing.T) {
+	defer func(saved []selfTest) { selfTests = saved }(selfTests)
+
+	failure := errors.New("failure")
+	RegisterSelfTest("failing", func() error { return failure })
+
+	if err := SelfTest(); errors.Cause(err) != failure {
+		t.Fatalf("SelfTest() error = %v, want %v", err, failure)
+	}
+}
@@ -0,0 +1,107 @@
+package esni
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// serverNameTypeHostName specifies the
+	// NameType of a host name in a ServerNameList
+	serverNameTypeHostName = 0
+)
+
+var (
+	// ErrServerNameTooLong is returned when the
+	// ServerNameList of a server name doesn't fit
+	// within the padded length
+	ErrServerNameTooLong = errors.New("server name list exceeds padded length")
+
+	// ErrNonZeroPadding is returned when the padding
+	// of a PaddedServerNameList contains a byte that
+	// isn't zero
+	ErrNonZeroPadding = errors.New("padding contains non-zero bytes")
+
+	// ErrInvalidServerNameList is returned when the
+	// ServerNameList of a PaddedServerNameList doesn't
+	// contain exactly one host name
+	ErrInvalidServerNameList = errors.New("invalid server name list")
+)
+
+// PaddedServerNameList represents the ServerNameList
+// carrying the real SNI, padded with zeros to the
+// padded length of the Keys record so that the length
+// of the name isn't revealed by the encrypted SNI
+type PaddedServerNameList struct {
+	// ServerName specifies the host
+	// name of the server
+	ServerName string
+
+	// PaddedLength specifies the length the
+	// ServerNameList is padded to
+	PaddedLength uint16
+}
+
+// MarshalBinary will marshal a ServerNameList
+// containing the host name padded with zeros to
+// the padded length, ErrServerNameTooLong is returned
+// if the list doesn't fit within the padded length
+func (list PaddedServerNameList) MarshalBinary() ([]byte, error) {
+	if len(list.ServerName) == 0 {
+		return nil, errors.Wrap(ErrInvalidServerNameList, "server name is empty")
+	}
+
+	size := serverNameListSize(list.ServerName)
+	if size > int(list.PaddedLength) {
+		return nil, errors.Wrapf(ErrServerNameTooLong, "%d bytes exceeds %d", size, list.PaddedLength)
+	}
+
+	data := make([]byte, list.PaddedLength)
+	binary.BigEndian.PutUint16(data[0:2], uint16(size-2))
+	data[2] = serverNameTypeHostName
+	binary.BigEndian.PutUint16(data[3:5], uint16(len(list.ServerName)))
+	copy(data[5:], list.ServerName)
+
+	return data, nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// padded list from the provided binary data, the
+// padded length is taken from the length of the
+// data and the padding must consist of zeros
+func (list *PaddedServerNameList) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for server name list")
+	}
+
+	listLen := int(binary.BigEndian.Uint16(data))
+	if len(data) < listLen+2 {
+		return errors.Wrap(io.ErrUnexpectedEOF, "buffer is too small for server name list")
+	}
+
+	entries := data[2 : listLen+2]
+	if len(entries) < 3 || entries[0] != serverNameTypeHostName {
+		return ErrInvalidServerNameList
+	}
+
+	nameLen := int(binary.BigEndian.Uint16(entries[1:]))
+	if nameLen == 0 || len(entries) != nameLen+3 {
+		return ErrInvalidServerNameList
+	}
+
+	var padding byte
+	for _, b := range data[listLen+2:] {
+		padding |= b
+	}
+
+	if padding != 0 {
+		return ErrNonZeroPadding
+	}
+
+	list.ServerName = string(entries[3:])
+	list.PaddedLength = uint16(len(data))
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package esni
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// keys signature extension type
+func init() {
+	RegisterExtensionType(ExtensionTypeKeysSignature, "keys_signature", func() Extension { return new(KeysSignature) })
+}
+
+const (
+	// ExtensionTypeKeysSignature specifies the type of the
+	// extension carrying a signature over the Keys record,
+	// it isn't mandatory so clients that don't understand
+	// it can still use the record
+	ExtensionTypeKeysSignature ExtensionType = 0x0f01
+
+	// keysSignatureContext specifies the context string
+	// prepended to the canonical encoding of the record
+	// before it is signed
+	keysSignatureContext = "esni keys signature\x00"
+)
+
+var (
+	// ErrNoSignature is returned when verifying the
+	// signature of a Keys record that isn't signed
+	ErrNoSignature = errors.New("keys record is not signed")
+
+	// ErrInvalidSignature is returned when the
+	// signature of a Keys record doesn't verify
+	ErrInvalidSignature = errors.New("invalid keys record signature")
+)
+
+// KeysSignature represents an ESNI extension carrying a
+// signature over the canonical encoding of the Keys
+// record, made with the key of the certificate for the
+// public name, allowing clients to authenticate records
+// fetched from untrusted resolvers
+type KeysSignature struct {
+	// Scheme specifies the TLS signature
+	// scheme of the signature
+	Scheme tls.SignatureScheme
+
+	// Signature specifies the signature
+	Signature []byte
+}
+
+// Type returns the unique identifier
+// for the ESNI extension
+func (*KeysSignature) Type() ExtensionType {
+	return ExtensionTypeKeysSignature
+}
+
+// Size returns the number of bytes that would
+// be produced if the extension were to be marshaled
+// to it's binary format
+func (sig *KeysSignature) Size() uint16 {
+	return uint16(4 + len(sig.Signature))
+}
+
+// MarshalBinary will marshal the ESNI extension
+// value to a binary format for inclusion in an
+// extension list
+func (sig *KeysSignature) MarshalBinary() ([]byte, error) {
+	if len(sig.Signature) > 0xFFFF-4 {
+		return nil, errors.New("signature too long")
+	}
+
+	data := bytes.NewBuffer(make([]byte, 0, sig.Size()))
+	binary.Write(data, binary.BigEndian, uint16(sig.Scheme))
+	binary.Write(data, binary.BigEndian, uint16(len(sig.Signature)))
+	data.Write(sig.Signature)
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// ESNI extension value from the provided binary
+// data
+func (sig *KeysSignature) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 || int(binary.BigEndian.Uint16(data[2:])) != len(data)-4 {
+		return errors.New("invalid signature length")
+	}
+
+	sig.Scheme = tls.SignatureScheme(binary.BigEndian.Uint16(data))
+	sig.Signature = append([]byte(nil), data[4:]...)
+
+	return nil
+}
+
+// String returns a friendly representation of
+// the ESNI extension value
+func (sig *KeysSignature) String() string {
+	return fmt.Sprintf("%s:%x", sig.Scheme, sig.Signature)
+}
+
+// SignKeys signs the canonical encoding of the Keys
+// record with the signer, which should hold the key of
+// the certificate for the public name, and adds the
+// signature to the record as a keys_signature extension
+// replacing any existing signature
+func SignKeys(keys *Keys, signer crypto.Signer, scheme tls.SignatureScheme) error {
+	hash, opts, err := signatureSchemeParameters(scheme)
+	if err != nil {
+		return err
+	}
+
+	keys.Extensions = withoutKeysSignature(keys.Extensions)
+
+	signed, err := keysSignedData(*keys)
+	if err != nil {
+		return err
+	}
+
+	digest := signed
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return errors.Wrap(err, "sign keys")
+	}
+
+	keys.Extensions = append(keys.Extensions, &KeysSignature{Scheme: scheme, Signature: signature})
+	return nil
+}
+
+// VerifySignature verifies the certificate chain for the
+// public name of the Keys record against the system roots
+// and checks the keys_signature extension of the record
+// was made with the key of the leaf certificate, the
+// chain is ordered from the leaf
+func VerifySignature(keys *Keys, certChain []*x509.Certificate) error {
+	return VerifySignatureWithOptions(keys, certChain, x509.VerifyOptions{})
+}
+
+// VerifySignatureWithOptions verifies the signature of
+// the Keys record in the same manner as VerifySignature
+// using the options to verify the certificate chain, the
+// DNS name and intermediates are set from the record
+// and the chain
+func VerifySignatureWithOptions(keys *Keys, certChain []*x509.Certificate, opts x509.VerifyOptions) error {
+	if len(certChain) == 0 {
+		return errors.New("empty certificate chain")
+	}
+
+	opts.DNSName = keys.PublicName
+	opts.Intermediates = x509.NewCertPool()
+	for _, cert := range certChain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := certChain[0].Verify(opts); err != nil {
+		return errors.Wrap(err, "verify certificate chain")
+	}
+
+	return verifyKeysSignature(keys, certChain[0])
+}
+
+// verifyKeysSignature checks the keys_signature
+// extension of the Keys record was made with the
+// key of the certificate
+func verifyKeysSignature(keys *Keys, cert *x509.Certificate) error {
+	sig := findKeysSignature(keys.Extensions)
+	if sig == nil {
+		return ErrNoSignature
+	}
+
+	hash, _, err := signatureSchemeParameters(sig.Scheme)
+	if err != nil {
+		return err
+	}
+
+	unsigned := *keys
+	unsigned.Extensions = withoutKeysSignature(keys.Extensions)
+
+	signed, err := keysSignedData(unsigned)
+	if err != nil {
+		return err
+	}
+
+	digest := signed
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	valid := false
+	switch publicKey := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = isECDSAScheme(sig.Scheme) && ecdsa.VerifyASN1(publicKey, digest, sig.Signature)
+	case *rsa.PublicKey:
+		valid = isRSAPSSScheme(sig.Scheme) && rsa.VerifyPSS(publicKey, hash, digest, sig.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case ed25519.PublicKey:
+		valid = sig.Scheme == tls.Ed25519 && ed25519.Verify(publicKey, digest, sig.Signature)
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// keysSignedData returns the data covered by the
+// signature, the context string followed by the
+// canonical encoding of the unsigned record
+func keysSignedData(keys Keys) ([]byte, error) {
+	canonical, err := keys.MarshalCanonical()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal canonical keys")
+	}
+
+	return append([]byte(keysSignatureContext), canonical...), nil
+}
+
+// signatureSchemeParameters returns the hash and signer
+// options of a supported signature scheme, the hash is
+// zero for schemes that sign the message directly
+func signatureSchemeParameters(scheme tls.SignatureScheme) (crypto.Hash, crypto.SignerOpts, error) {
+	switch scheme {
+	case tls.ECDSAWithP256AndSHA256:
+		return crypto.SHA256, crypto.SHA256, nil
+	case tls.ECDSAWithP384AndSHA384:
+		return crypto.SHA384, crypto.SHA384, nil
+	case tls.ECDSAWithP521AndSHA512:
+		return crypto.SHA512, crypto.SHA512, nil
+	case tls.PSSWithSHA256:
+		return crypto.SHA256, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, nil
+	case tls.PSSWithSHA384:
+		return crypto.SHA384, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, nil
+	case tls.PSSWithSHA512:
+		return crypto.SHA512, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, nil
+	case tls.Ed25519:
+		return 0, crypto.Hash(0), nil
+	}
+
+	return 0, nil, errors.Errorf("unsupported signature scheme %s", scheme)
+}
+
+// isECDSAScheme returns true if the
+// signature scheme uses ECDSA
+func isECDSAScheme(scheme tls.SignatureScheme) bool {
+	return scheme == tls.ECDSAWithP256AndSHA256 || scheme == tls.ECDSAWithP384AndSHA384 || scheme == tls.ECDSAWithP521AndSHA512
+}
+
+// isRSAPSSScheme returns true if the
+// signature scheme uses RSA-PSS
+func isRSAPSSScheme(scheme tls.SignatureScheme) bool {
+	return scheme == tls.PSSWithSHA256 || scheme == tls.PSSWithSHA384 || scheme == tls.PSSWithSHA512
+}
+
+// findKeysSignature returns the keys_signature
+// extension of the list, nil is returned if
+// the list has no signature
+func findKeysSignature(list ExtensionList) *KeysSignature {
+	for _, ext := range list {
+		if sig, ok := ext.(*KeysSignature); ok {
+			return sig
+		}
+	}
+
+	return nil
+}
+
+// withoutKeysSignature returns a copy of the list
+// with any keys_signature extensions removed
+func withoutKeysSignature(list ExtensionList) ExtensionList {
+	filtered := make(ExtensionList, 0, len(list))
+
+	for _, ext := range list {
+		if ext.Type() != ExtensionTypeKeysSignature {
+			filtered = append(filtered, ext)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,254 @@
+package esni
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrEnvelopeAuthentication is returned when the
+	// MAC of a forwarding envelope doesn't verify
+	ErrEnvelopeAuthentication = errors.New("forwarding envelope failed authentication")
+
+	// ErrClientHelloMismatch is returned when a forwarding
+	// envelope was produced for a different ClientHello
+	// than the one received by the backend server
+	ErrClientHelloMismatch = errors.New("forwarding envelope is for a different client hello")
+
+	// ErrServerNameNotServed is returned when the server
+	// name in a forwarding envelope isn't served by the
+	// backend server
+	ErrServerNameNotServed = errors.New("server name not served by backend")
+)
+
+// ForwardingEnvelope represents the information a
+// fronting server forwards to the backend server in
+// split mode alongside the ClientHello, carrying the
+// decrypted server name and the nonce the backend
+// must echo to the client.
+//
+// The binary format is the client hello random, the
+// nonce, the server name as an opaque<1..2^16-1> and
+// a MAC as an opaque<0..255>.
+type ForwardingEnvelope struct {
+	// ClientHelloRandom specifies the random of
+	// the ClientHello the SNI was decrypted from
+	ClientHelloRandom [32]byte
+
+	// ServerName specifies the
+	// decrypted server name
+	ServerName string
+
+	// Nonce specifies the nonce of the
+	// ClientESNIInner sent by the client
+	Nonce [NonceSize]byte
+
+	// MAC specifies the HMAC-SHA256 of the
+	// envelope under the forwarding key, it
+	// is empty if no key is shared
+	MAC []byte
+}
+
+// MarshalBinary will marshal the ForwardingEnvelope
+// into its binary format
+func (env ForwardingEnvelope) MarshalBinary() ([]byte, error) {
+	if len(env.ServerName) == 0 || len(env.ServerName) > 0xFFFF {
+		return nil, errors.Errorf("invalid server name length of %d", len(env.ServerName))
+	}
+
+	if len(env.MAC) > 0xFF {
+		return nil, errors.Errorf("invalid mac length of %d", len(env.MAC))
+	}
+
+	data := bytes.NewBuffer(env.signedData())
+	data.WriteByte(byte(len(env.MAC)))
+	data.Write(env.MAC)
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal
+// the ForwardingEnvelope from its binary format
+func (env *ForwardingEnvelope) UnmarshalBinary(data []byte) error {
+	const header = 32 + NonceSize + 2
+	if len(data) < header {
+		return errors.New("envelope too short")
+	}
+
+	copy(env.ClientHelloRandom[:], data[:32])
+	copy(env.Nonce[:], data[32:32+NonceSize])
+
+	nameLen := int(binary.BigEndian.Uint16(data[32+NonceSize:]))
+	if nameLen == 0 || len(data) < header+nameLen+1 {
+		return errors.New("invalid server name length")
+	}
+
+	env.ServerName = string(data[header : header+nameLen])
+	data = data[header+nameLen:]
+
+	macLen := int(data[0])
+	if len(data) != macLen+1 {
+		return errors.New("invalid mac length")
+	}
+
+	env.MAC = append([]byte(nil), data[1:]...)
+	return nil
+}
+
+// Sign sets the MAC of the envelope using the
+// forwarding key shared with the backend server,
+// an empty key clears the MAC
+func (env *ForwardingEnvelope) Sign(key []byte) {
+	env.MAC = nil
+
+	if len(key) > 0 {
+		env.MAC = env.computeMAC(key)
+	}
+}
+
+// Verify checks the MAC of the envelope using the
+// forwarding key shared with the fronting server,
+// an empty key requires the MAC to be empty
+func (env *ForwardingEnvelope) Verify(key []byte) error {
+	if len(key) == 0 {
+		if len(env.MAC) != 0 {
+			return ErrEnvelopeAuthentication
+		}
+
+		return nil
+	}
+
+	if !hmac.Equal(env.MAC, env.computeMAC(key)) {
+		return ErrEnvelopeAuthentication
+	}
+
+	return nil
+}
+
+// Response returns the encrypted_server_name
+// extension the backend server must send to
+// echo the nonce to the client
+func (env *ForwardingEnvelope) Response() ServerEncryptedSNI {
+	return ServerEncryptedSNI{Nonce: env.Nonce}
+}
+
+// signedData returns the binary format of
+// the envelope that is covered by the MAC
+func (env *ForwardingEnvelope) signedData() []byte {
+	data := make([]byte, 32+NonceSize+2, 32+NonceSize+2+len(env.ServerName)+1+len(env.MAC))
+	copy(data, env.ClientHelloRandom[:])
+	copy(data[32:], env.Nonce[:])
+	binary.BigEndian.PutUint16(data[32+NonceSize:], uint16(len(env.ServerName)))
+
+	return append(data, env.ServerName...)
+}
+
+// computeMAC returns the HMAC-SHA256
+// of the envelope under the key
+func (env *ForwardingEnvelope) computeMAC(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(env.signedData())
+
+	return mac.Sum(nil)
+}
+
+// FrontingServer represents the client-facing server
+// in split mode, it holds the ESNI keys, decrypts the
+// SNI and forwards the ClientHello to the backend
+// server that serves the decrypted name
+type FrontingServer struct {
+	// KeySet specifies the keys used
+	// to decrypt the SNI of clients
+	KeySet *ServerKeySet
+
+	// ForwardingKey specifies the key shared with
+	// the backend servers used to authenticate
+	// forwarding envelopes
+	ForwardingKey []byte
+}
+
+// Forward decrypts the SNI sent by the client at the
+// source address and returns the envelope to forward
+// to the backend server alongside the ClientHello.
+//
+// The client hello random and client key share are
+// those expected by DecryptSNI.
+func (front *FrontingServer) Forward(source net.Addr, encrypted ClientEncryptedSNI, clientHelloRandom, clientKeyShare []byte) (*ForwardingEnvelope, error) {
+	if len(clientHelloRandom) != 32 {
+		return nil, errors.Errorf("invalid client hello random length of %d", len(clientHelloRandom))
+	}
+
+	result, err := front.KeySet.DecryptFrom(source, encrypted, clientHelloRandom, clientKeyShare)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &ForwardingEnvelope{ServerName: result.ServerName, Nonce: result.Nonce}
+	copy(env.ClientHelloRandom[:], clientHelloRandom)
+	env.Sign(front.ForwardingKey)
+
+	return env, nil
+}
+
+// BackendServer represents a backend server in split
+// mode, it terminates the TLS connection forwarded by
+// the fronting server and validates the forwarded name
+// and nonce before echoing the nonce to the client
+type BackendServer struct {
+	// ServerNames specifies the server
+	// names served by the backend
+	ServerNames []string
+
+	// ForwardingKey specifies the key shared with
+	// the fronting server used to authenticate
+	// forwarding envelopes
+	ForwardingKey []byte
+}
+
+// Accept parses and validates the forwarding envelope
+// received for the ClientHello with the provided
+// random, checking the envelope is authentic, was
+// produced for the ClientHello and names a server
+// served by the backend
+func (backend *BackendServer) Accept(data, clientHelloRandom []byte) (*ForwardingEnvelope, error) {
+	env := new(ForwardingEnvelope)
+	if err := env.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "unmarshal forwarding envelope")
+	}
+
+	if err := env.Verify(backend.ForwardingKey); err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare(env.ClientHelloRandom[:], clientHelloRandom) != 1 {
+		return nil, ErrClientHelloMismatch
+	}
+
+	if !backend.Serves(env.ServerName) {
+		return nil, ErrServerNameNotServed
+	}
+
+	return env, nil
+}
+
+// Serves returns true if the server name is one of
+// the names served by the backend, names are
+// compared case-insensitively
+func (backend *BackendServer) Serves(serverName string) bool {
+	serverName = strings.TrimSuffix(serverName, ".")
+
+	for _, name := range backend.ServerNames {
+		if strings.EqualFold(strings.TrimSuffix(name, "."), serverName) {
+			return true
+		}
+	}
+
+	return false
+}
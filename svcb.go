@@ -0,0 +1,147 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SvcParamKeyECH specifies the SvcParamKey of
+	// the "ech" parameter of SVCB and HTTPS records,
+	// which carries an ECHConfigList
+	SvcParamKeyECH uint16 = 5
+
+	// svcParamNameECH specifies the presentation
+	// name of the "ech" parameter
+	svcParamNameECH = "ech"
+)
+
+// MarshalSvcParamECH returns the wire format value
+// of the "ech" SvcParam for the config list, which
+// is the binary ECHConfigList
+func MarshalSvcParamECH(list ECHConfigList) ([]byte, error) {
+	if len(list) == 0 {
+		return nil, errors.New("config list is empty")
+	}
+
+	return list.MarshalBinary()
+}
+
+// ParseSvcParamECH parses the config list from
+// the wire format value of the "ech" SvcParam
+func ParseSvcParamECH(value []byte) (ECHConfigList, error) {
+	return ParseECHConfigList(value)
+}
+
+// MarshalText returns the presentation format
+// of the config list as used in the "ech" SvcParam
+// of a zone file, the base64 encoding of the binary
+// ECHConfigList
+func (list ECHConfigList) MarshalText() ([]byte, error) {
+	data, err := list.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(text, data)
+
+	return text, nil
+}
+
+// UnmarshalText parses the config list from the
+// presentation format used in the "ech" SvcParam,
+// the value may be quoted and may optionally be
+// prefixed with "ech="
+func (list *ECHConfigList) UnmarshalText(text []byte) error {
+	value := strings.TrimPrefix(string(text), svcParamNameECH+"=")
+	value = strings.Trim(value, `"`)
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return errors.Wrap(err, "decode base64")
+	}
+
+	return list.UnmarshalBinary(data)
+}
+
+// FindSvcParam searches the wire format SvcParams of
+// an SVCB or HTTPS record for the parameter with the
+// specified key and returns its value, ok is false if
+// the parameter isn't present
+func FindSvcParam(params []byte, key uint16) (value []byte, ok bool, err error) {
+	reader := bytes.NewReader(params)
+
+	for reader.Len() > 0 {
+		var header struct{ Key, Length uint16 }
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return nil, false, errors.Wrap(err, "read svc param header")
+		}
+
+		if reader.Len() < int(header.Length) {
+			return nil, false, errors.Wrap(io.ErrUnexpectedEOF, "read svc param value")
+		}
+
+		data := make([]byte, header.Length)
+		_, _ = io.ReadFull(reader, data)
+
+		if header.Key == key {
+			return data, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// AppendSvcParamECH appends the "ech" parameter
+// carrying the config list to the wire format
+// SvcParams, which must not already contain the
+// parameter and must be sorted by key
+func AppendSvcParamECH(params []byte, list ECHConfigList) ([]byte, error) {
+	value, err := MarshalSvcParamECH(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	reader := bytes.NewReader(params)
+	inserted := false
+
+	for reader.Len() > 0 {
+		var header struct{ Key, Length uint16 }
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return nil, errors.Wrap(err, "read svc param header")
+		}
+
+		if reader.Len() < int(header.Length) {
+			return nil, errors.Wrap(io.ErrUnexpectedEOF, "read svc param value")
+		}
+
+		if header.Key == SvcParamKeyECH {
+			return nil, errors.New("svc params already contain ech")
+		}
+
+		if header.Key > SvcParamKeyECH && !inserted {
+			_ = binary.Write(&data, binary.BigEndian, SvcParamKeyECH)
+			_ = writeVector16(&data, value)
+			inserted = true
+		}
+
+		_ = binary.Write(&data, binary.BigEndian, header)
+		_, _ = io.CopyN(&data, reader, int64(header.Length))
+	}
+
+	if !inserted {
+		_ = binary.Write(&data, binary.BigEndian, SvcParamKeyECH)
+		if err := writeVector16(&data, value); err != nil {
+			return nil, errors.Wrap(err, "write ech svc param")
+		}
+	}
+
+	return data.Bytes(), nil
+}
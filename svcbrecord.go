@@ -0,0 +1,230 @@
+package esni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SVCBRecord represents the RDATA of an SVCB or
+// HTTPS resource record as specified by RFC 9460
+type SVCBRecord struct {
+	// Priority specifies the priority of the
+	// record, zero indicates AliasMode
+	Priority uint16
+
+	// Target specifies the fully qualified
+	// target name of the record, "." refers
+	// to the owner name in ServiceMode
+	Target string
+
+	// Params specifies the SvcParams
+	// of the record sorted by key
+	Params []SvcParam
+}
+
+// SvcParam represents a single
+// SvcParam of an SVCB record
+type SvcParam struct {
+	Key   uint16
+	Value []byte
+}
+
+// AliasMode returns true if the record
+// aliases the owner name to the target
+func (record SVCBRecord) AliasMode() bool {
+	return record.Priority == 0
+}
+
+// Param returns the value of the SvcParam with
+// the key, ok is false if the record doesn't
+// carry the parameter
+func (record SVCBRecord) Param(key uint16) (value []byte, ok bool) {
+	for _, param := range record.Params {
+		if param.Key == key {
+			return param.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetParam sets the value of the SvcParam with the
+// key, replacing any existing value and keeping the
+// parameters sorted by key
+func (record *SVCBRecord) SetParam(key uint16, value []byte) {
+	i := sort.Search(len(record.Params), func(i int) bool {
+		return record.Params[i].Key >= key
+	})
+
+	if i < len(record.Params) && record.Params[i].Key == key {
+		record.Params[i].Value = value
+		return
+	}
+
+	record.Params = append(record.Params, SvcParam{})
+	copy(record.Params[i+1:], record.Params[i:])
+	record.Params[i] = SvcParam{Key: key, Value: value}
+}
+
+// ECHConfigList returns the config list carried in
+// the "ech" parameter of the record, ok is false if
+// the record doesn't carry the parameter
+func (record SVCBRecord) ECHConfigList() (list ECHConfigList, ok bool, err error) {
+	value, ok := record.Param(SvcParamKeyECH)
+	if !ok {
+		return nil, false, nil
+	}
+
+	list, err = ParseSvcParamECH(value)
+	return list, true, err
+}
+
+// MarshalBinary will marshal the record into the
+// wire format of its RDATA, the target name is
+// written uncompressed
+func (record SVCBRecord) MarshalBinary() ([]byte, error) {
+	var data bytes.Buffer
+	_ = binary.Write(&data, binary.BigEndian, record.Priority)
+
+	if err := writeDomainName(&data, record.Target); err != nil {
+		return nil, errors.Wrap(err, "write target name")
+	}
+
+	params := append([]SvcParam(nil), record.Params...)
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Key < params[j].Key })
+
+	for i, param := range params {
+		if i > 0 && params[i-1].Key == param.Key {
+			return nil, errors.Errorf("duplicate svc param %d", param.Key)
+		}
+
+		_ = binary.Write(&data, binary.BigEndian, param.Key)
+		if err := writeVector16(&data, param.Value); err != nil {
+			return nil, errors.Wrapf(err, "write svc param %d", param.Key)
+		}
+	}
+
+	return data.Bytes(), nil
+}
+
+// UnmarshalBinary will attempt to unmarshal the
+// record from the wire format of its RDATA, the
+// SvcParams must be in strictly increasing order
+// of their keys
+func (record *SVCBRecord) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	if err := binary.Read(reader, binary.BigEndian, &record.Priority); err != nil {
+		return errors.Wrap(err, "read priority")
+	}
+
+	target, err := readDomainName(reader)
+	if err != nil {
+		return errors.Wrap(err, "read target name")
+	}
+
+	record.Target = target
+	record.Params = nil
+
+	for reader.Len() > 0 {
+		var key uint16
+		if err := binary.Read(reader, binary.BigEndian, &key); err != nil {
+			return errors.Wrap(err, "read svc param key")
+		}
+
+		if n := len(record.Params); n > 0 && record.Params[n-1].Key >= key {
+			return errors.New("svc params are not in increasing order")
+		}
+
+		value, err := readVector16(reader)
+		if err != nil {
+			return errors.Wrapf(err, "read svc param %d", key)
+		}
+
+		record.Params = append(record.Params, SvcParam{Key: key, Value: value})
+	}
+
+	return nil
+}
+
+// String returns a friendly representation
+// of the SVCB record
+func (record SVCBRecord) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d %s", record.Priority, record.Target)
+
+	for _, param := range record.Params {
+		if param.Key == SvcParamKeyECH {
+			list, err := ParseSvcParamECH(param.Value)
+			if text, textErr := list.MarshalText(); err == nil && textErr == nil {
+				fmt.Fprintf(&builder, " %s=%s", svcParamNameECH, text)
+				continue
+			}
+		}
+
+		fmt.Fprintf(&builder, " key%d=%x", param.Key, param.Value)
+	}
+
+	return builder.String()
+}
+
+// writeDomainName writes the domain name to the
+// buffer as an uncompressed sequence of labels
+func writeDomainName(data *bytes.Buffer, name string) error {
+	name = strings.TrimSuffix(name, ".")
+
+	if len(name) > 0 {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return errors.Errorf("invalid label %q", label)
+			}
+
+			data.WriteByte(byte(len(label)))
+			data.WriteString(label)
+		}
+	}
+
+	data.WriteByte(0)
+	return nil
+}
+
+// readDomainName reads an uncompressed domain
+// name from the reader and returns it as a fully
+// qualified name
+func readDomainName(reader *bytes.Reader) (string, error) {
+	var labels []string
+
+	for length := 1; ; {
+		size, err := reader.ReadByte()
+		if err != nil {
+			return "", errors.Wrap(io.ErrUnexpectedEOF, "read label length")
+		}
+
+		if size == 0 {
+			break
+		}
+
+		if size > 63 {
+			return "", errors.New("compressed or invalid label")
+		}
+
+		if length += int(size) + 1; length > 255 {
+			return "", errors.New("domain name too long")
+		}
+
+		label := make([]byte, size)
+		if _, err := io.ReadFull(reader, label); err != nil {
+			return "", errors.Wrap(io.ErrUnexpectedEOF, "read label")
+		}
+
+		labels = append(labels, string(label))
+	}
+
+	return strings.Join(labels, ".") + ".", nil
+}
@@ -0,0 +1,74 @@
+package esni
+
+import (
+	"crypto/tls"
+	stderrors "errors"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfigList returns the binary ECHConfigList in
+// the form expected by the EncryptedClientHelloConfigList
+// field of a crypto/tls client config
+func (list ECHConfigList) TLSConfigList() ([]byte, error) {
+	if len(list) == 0 {
+		return nil, errors.New("config list is empty")
+	}
+
+	return list.MarshalBinary()
+}
+
+// ConfigureTLSClient sets the EncryptedClientHelloConfigList
+// of the crypto/tls config to the provided list, which
+// causes the client to require ECH for the connection
+func ConfigureTLSClient(config *tls.Config, list ECHConfigList) error {
+	data, err := list.TLSConfigList()
+	if err != nil {
+		return err
+	}
+
+	config.EncryptedClientHelloConfigList = data
+	return nil
+}
+
+// TLSServerKey returns the ECHConfig and its HPKE
+// private key in the form expected by the
+// EncryptedClientHelloKeys field of a crypto/tls
+// server config
+func (config *ECHConfig) TLSServerKey(privateKey []byte, sendAsRetry bool) (tls.EncryptedClientHelloKey, error) {
+	data, err := config.MarshalBinary()
+	if err != nil {
+		return tls.EncryptedClientHelloKey{}, errors.Wrap(err, "marshal config")
+	}
+
+	return tls.EncryptedClientHelloKey{
+		Config:      data,
+		PrivateKey:  append([]byte(nil), privateKey...),
+		SendAsRetry: sendAsRetry,
+	}, nil
+}
+
+// RetryConfigsFromError extracts the retry configs from
+// a crypto/tls ECHRejectionError returned when a server
+// rejected ECH, ok is false if the error isn't caused
+// by a rejection.
+//
+// A rejection without retry configs results in an empty
+// list, which the client may treat as a secure signal
+// that the server has disabled ECH.
+func RetryConfigsFromError(err error) (list ECHConfigList, ok bool, parseErr error) {
+	var rejection *tls.ECHRejectionError
+	if !stderrors.As(err, &rejection) {
+		return nil, false, nil
+	}
+
+	if len(rejection.RetryConfigList) == 0 {
+		return nil, true, nil
+	}
+
+	if err := list.UnmarshalBinary(rejection.RetryConfigList); err != nil {
+		return nil, true, errors.Wrap(err, "unmarshal retry configs")
+	}
+
+	return list, true, nil
+}
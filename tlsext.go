@@ -0,0 +1,141 @@
+package esni
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TLSExtensionEncryptedServerName specifies the
+	// TLS extension type of the encrypted_server_name
+	// extension carried in the ClientHello and in the
+	// EncryptedExtensions of the server
+	TLSExtensionEncryptedServerName uint16 = 0xffce
+)
+
+// MarshalTLSExtension frames the body as a TLS
+// extension of the specified type, a 16-bit type
+// followed by the body as an opaque<0..2^16-1>
+func MarshalTLSExtension(extType uint16, body []byte) ([]byte, error) {
+	if len(body) > 0xFFFF {
+		return nil, errors.Errorf("extension body of %d bytes is too long", len(body))
+	}
+
+	data := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint16(data, extType)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(body)))
+
+	return append(data, body...), nil
+}
+
+// ParseTLSExtension parses a single TLS extension,
+// returning its type and body, the data must contain
+// exactly one extension
+func ParseTLSExtension(data []byte) (uint16, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, errors.Wrap(io.ErrUnexpectedEOF, "read extension header")
+	}
+
+	extType := binary.BigEndian.Uint16(data)
+	bodyLen := int(binary.BigEndian.Uint16(data[2:]))
+
+	if len(data) < 4+bodyLen {
+		return 0, nil, errors.Wrap(io.ErrUnexpectedEOF, "read extension body")
+	}
+
+	if len(data) > 4+bodyLen {
+		return 0, nil, &TrailingDataError{Offset: 4 + bodyLen, Data: data[4+bodyLen:]}
+	}
+
+	return extType, data[4:], nil
+}
+
+// FindTLSExtension searches the extensions block of a
+// ClientHello or EncryptedExtensions message, excluding
+// its outer length, for the extension of the specified
+// type and returns its body
+func FindTLSExtension(extensions []byte, extType uint16) ([]byte, bool, error) {
+	for len(extensions) > 0 {
+		if len(extensions) < 4 {
+			return nil, false, errors.Wrap(io.ErrUnexpectedEOF, "read extension header")
+		}
+
+		bodyLen := int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+bodyLen {
+			return nil, false, errors.Wrap(io.ErrUnexpectedEOF, "read extension body")
+		}
+
+		if binary.BigEndian.Uint16(extensions) == extType {
+			return extensions[4 : 4+bodyLen], true, nil
+		}
+
+		extensions = extensions[4+bodyLen:]
+	}
+
+	return nil, false, nil
+}
+
+// MarshalTLSExtension will marshal the ClientEncryptedSNI
+// framed as the encrypted_server_name extension, ready to
+// be spliced into the extensions of a ClientHello
+func (encrypted ClientEncryptedSNI) MarshalTLSExtension() ([]byte, error) {
+	body, err := encrypted.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return MarshalTLSExtension(TLSExtensionEncryptedServerName, body)
+}
+
+// UnmarshalTLSExtension will attempt to unmarshal the
+// ClientEncryptedSNI from an encrypted_server_name
+// extension including its framing
+func (encrypted *ClientEncryptedSNI) UnmarshalTLSExtension(data []byte) error {
+	body, err := parseEncryptedServerName(data)
+	if err != nil {
+		return err
+	}
+
+	return encrypted.UnmarshalBinary(body)
+}
+
+// MarshalTLSExtension will marshal the ServerEncryptedSNI
+// framed as the encrypted_server_name extension, ready to
+// be spliced into the EncryptedExtensions of the server
+func (response ServerEncryptedSNI) MarshalTLSExtension() ([]byte, error) {
+	body, err := response.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return MarshalTLSExtension(TLSExtensionEncryptedServerName, body)
+}
+
+// UnmarshalTLSExtension will attempt to unmarshal the
+// ServerEncryptedSNI from an encrypted_server_name
+// extension including its framing
+func (response *ServerEncryptedSNI) UnmarshalTLSExtension(data []byte) error {
+	body, err := parseEncryptedServerName(data)
+	if err != nil {
+		return err
+	}
+
+	return response.UnmarshalBinary(body)
+}
+
+// parseEncryptedServerName returns the body of
+// the framed encrypted_server_name extension
+func parseEncryptedServerName(data []byte) ([]byte, error) {
+	extType, body, err := ParseTLSExtension(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if extType != TLSExtensionEncryptedServerName {
+		return nil, errors.Errorf("unexpected extension type 0x%04x", extType)
+	}
+
+	return body, nil
+}
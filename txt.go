@@ -0,0 +1,51 @@
+package esni
+
+import (
+	"strings"
+)
+
+const (
+	// MaxTXTStringLength specifies the maximum length
+	// of a single character-string in a DNS TXT record
+	MaxTXTStringLength = 255
+)
+
+// MarshalTXT will marshal the Keys record into its
+// base64 text format and split it into the DNS
+// character-strings of a TXT record, each being
+// no longer than MaxTXTStringLength
+func (keys Keys) MarshalTXT() ([]string, error) {
+	text, err := keys.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	return ChunkTXT(string(text)), nil
+}
+
+// UnmarshalTXT will join the character-strings
+// of a TXT record and attempt to unmarshal the
+// Keys record from the joined base64 value
+func (keys *Keys) UnmarshalTXT(chunks []string) error {
+	return keys.UnmarshalText([]byte(strings.TrimSpace(JoinTXT(chunks))))
+}
+
+// ChunkTXT splits the value into the character-strings
+// of a TXT record, each being no longer than
+// MaxTXTStringLength
+func ChunkTXT(value string) []string {
+	chunks := make([]string, 0, len(value)/MaxTXTStringLength+1)
+
+	for len(value) > MaxTXTStringLength {
+		chunks = append(chunks, value[:MaxTXTStringLength])
+		value = value[MaxTXTStringLength:]
+	}
+
+	return append(chunks, value)
+}
+
+// JoinTXT joins the character-strings of
+// a TXT record back into a single value
+func JoinTXT(chunks []string) string {
+	return strings.Join(chunks, "")
+}
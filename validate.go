@@ -0,0 +1,139 @@
+package esni
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinPaddedLength specifies the smallest padded
+	// length permitted in a Keys record, it is the
+	// size of a ServerNameList containing a single
+	// one character host name
+	MinPaddedLength uint16 = 6
+
+	// MaxPaddedLength specifies the largest padded
+	// length permitted in a Keys record, it is the
+	// size of a ServerNameList containing a single
+	// host name of the maximum DNS name length
+	MaxPaddedLength uint16 = 260
+
+	// maxPublicNameLength specifies the maximum
+	// length of the public name in a Keys record
+	maxPublicNameLength = 255
+)
+
+// ValidationError is returned by Validate when
+// a Keys record or ECHConfig breaks one or more of
+// the invariants required by the specification, it
+// lists every violation found in the record
+type ValidationError struct {
+	// Violations specifies each individual
+	// problem found in the record
+	Violations []error
+}
+
+// Error returns a single line representation
+// of all the violations found in the record
+func (err *ValidationError) Error() string {
+	var builder strings.Builder
+	builder.WriteString("invalid record: ")
+
+	for i := range err.Violations {
+		if i > 0 {
+			builder.WriteString("; ")
+		}
+
+		builder.WriteString(err.Violations[i].Error())
+	}
+
+	return builder.String()
+}
+
+// add appends a new violation to the list
+func (err *ValidationError) add(format string, args ...interface{}) {
+	err.Violations = append(err.Violations, errors.Errorf(format, args...))
+}
+
+// Validate checks the Keys record against the
+// semantic invariants of the ESNI specification
+// that are not enforced during unmarshalling.
+//
+// If any violations are found a *ValidationError
+// listing every violation is returned.
+func (keys *Keys) Validate() error {
+	verr := new(ValidationError)
+
+	if !keys.Version.supported() {
+		verr.add("version 0x%04x is not supported", uint16(keys.Version))
+	}
+
+	if keys.Version.HasValidityPeriod() && !keys.NotBefore.Before(keys.NotAfter) {
+		verr.add("not before (%s) must be before not after (%s)", keys.NotBefore, keys.NotAfter)
+	}
+
+	if keys.Version.HasPublicName() {
+		if err := ValidatePublicName(keys.PublicName); err != nil {
+			verr.Violations = append(verr.Violations, err)
+		}
+	}
+
+	if keys.PaddedLength < MinPaddedLength || keys.PaddedLength > MaxPaddedLength {
+		verr.add("padded length %d is outside of the range %d-%d", keys.PaddedLength, MinPaddedLength, MaxPaddedLength)
+	}
+
+	keys.validateKeyShares(verr)
+	keys.validateCipherSuites(verr)
+
+	if len(verr.Violations) > 0 {
+		return verr
+	}
+
+	return nil
+}
+
+// validateKeyShares checks that the key share
+// list isn't empty, doesn't contain duplicate
+// groups and each key exchange value is of the
+// expected length for its group
+func (keys *Keys) validateKeyShares(verr *ValidationError) {
+	if len(keys.Keys) == 0 {
+		verr.add("key share list is empty")
+		return
+	}
+
+	seen := make(map[Group]bool, len(keys.Keys))
+	for i := range keys.Keys {
+		entry := keys.Keys[i]
+
+		if seen[entry.Group] {
+			verr.add("duplicate key share group %s", entry.Group)
+		}
+		seen[entry.Group] = true
+
+		if len(entry.KeyExchange) == 0 {
+			verr.add("key share %d (%s) has an empty key exchange", i, entry.Group)
+		} else if expected := entry.Group.KeyExchangeLength(); expected > 0 && len(entry.KeyExchange) != expected {
+			verr.add("key share %d (%s) has a key exchange of %d bytes, expected %d", i, entry.Group, len(entry.KeyExchange), expected)
+		}
+	}
+}
+
+// validateCipherSuites checks that the cipher
+// suite list isn't empty and doesn't contain
+// duplicate cipher suites
+func (keys *Keys) validateCipherSuites(verr *ValidationError) {
+	if len(keys.CipherSuites) == 0 {
+		verr.add("cipher suite list is empty")
+		return
+	}
+
+	seen := make(map[CipherSuite]bool, len(keys.CipherSuites))
+	for _, suite := range keys.CipherSuites {
+		if seen[suite] {
+			verr.add("duplicate cipher suite %s", suite)
+		}
+		seen[suite] = true
+	}
+}
@@ -0,0 +1,115 @@
+package esni
+
+// This file contains the known answer vectors used
+// by SelfTest. The X25519, HKDF and CCM vectors are
+// taken from RFC 7748, RFC 5869 and NIST SP 800-38C.
+//
+// The ESNI drafts don't publish test vectors, so the
+// key schedule and encrypted SNI vectors are regression
+// vectors rather than draft vectors. They were generated
+// by this package from fixed inputs, with the key
+// schedule cross-checked against an independent HKDF
+// implementation, and only guard against regressions
+// in the crypto providers registered at runtime.
+
+// x25519Vector specifies the key exchange
+// from section 6.1 of RFC 7748
+var x25519Vector = struct {
+	privateKey, peerPublic, sharedSecret string
+}{
+	privateKey:   "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a",
+	peerPublic:   "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f",
+	sharedSecret: "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742",
+}
+
+// hkdfVector specifies test case
+// 1 from appendix A of RFC 5869
+var hkdfVector = struct {
+	ikm, salt, info, prk, okm string
+}{
+	ikm:  "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b",
+	salt: "000102030405060708090a0b0c",
+	info: "f0f1f2f3f4f5f6f7f8f9",
+	prk:  "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+	okm: "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf" +
+		"34007208d5b887185865",
+}
+
+// ccmVector specifies example 3 from
+// appendix C of NIST SP 800-38C
+var ccmVector = struct {
+	suite                                 CipherSuite
+	key, nonce, additionalData, plaintext string
+	ciphertext                            string
+}{
+	suite:          CipherSuite_TLS_AES_128_CCM_8_SHA256,
+	key:            "404142434445464748494a4b4c4d4e4f",
+	nonce:          "101112131415161718191a1b",
+	additionalData: "000102030405060708090a0b0c0d0e0f10111213",
+	plaintext:      "202122232425262728292a2b2c2d2e2f3031323334353637",
+	ciphertext:     "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951",
+}
+
+// keyScheduleRegressionVectors specifies the key
+// and IV derived by the ESNI key schedule for each
+// cipher suite from the shared secret of the X25519
+// vector and the contents, as generated by this
+// package
+var keyScheduleRegressionVectors = struct {
+	sharedSecret, contents string
+	suites                 map[CipherSuite][2]string
+}{
+	sharedSecret: "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742",
+	contents: "00202cdac81e6de8cf17c675682b30ba37ef69a872c2313eaf535a66e5afa71d" +
+		"3190001d00208520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4" +
+		"a98eaa9b4e6a000102030405060708090a0b0c0d0e0f101112131415161718191a" +
+		"1b1c1d1e1f",
+	suites: map[CipherSuite][2]string{
+		CipherSuite_TLS_AES_128_GCM_SHA256: {
+			"8204a00ef7094522b9a8f4a335c3b86d",
+			"3b1b67411864c3da2f1787bd",
+		},
+		CipherSuite_TLS_AES_256_GCM_SHA384: {
+			"e84857db1102538840badfec1bc74407dc1867d289e64f26ad36640c8fe45334",
+			"040c93bd961d17f71e88ea2b",
+		},
+		CipherSuite_TLS_CHACHA20_POLY1305_SHA256: {
+			"c9be16ab9168d59786fe2b68b029446c2e6c4a74a55939661b7b421b94a0eef5",
+			"3b1b67411864c3da2f1787bd",
+		},
+	},
+}
+
+// esniRegressionVector specifies a draft-03 Keys
+// record, its private key and an SNI encrypted to
+// it by this package with the ephemeral key and
+// nonce read from the seed
+var esniRegressionVector = struct {
+	keys, privateKey, recordDigest string
+	serverName, clientRandom       string
+	clientKeyShare, seed           string
+	nonce, encryptedSNI            string
+}{
+	keys: "ff02aa104db1127075626c69632e6578616d706c652e636f6d0024001d0020de" +
+		"5328c9434eadba6e5bc68f8545069ed105a3895838a5947139457a7fd79e6000" +
+		"061301130313020104000000005d929700000000005d9bd1800000",
+	privateKey:     "82730d0b7dd2b86c677d492f9ff81736673eaf6058004a5dae2192680b5efb2f",
+	recordDigest:   "2cdac81e6de8cf17c675682b30ba37ef69a872c2313eaf535a66e5afa71d3190",
+	serverName:     "private.example.com",
+	clientRandom:   "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+	clientKeyShare: "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f",
+	seed:           "go-esni self test client",
+	nonce:          "2ffedb45ac0bda0119128c59d530a8d5",
+	encryptedSNI: "1301001d0020f254fa2954b6873ea4b6c5e2c9977326660b2c66f51925c38a7e" +
+		"54458420726600202cdac81e6de8cf17c675682b30ba37ef69a872c2313eaf53" +
+		"5a66e5afa71d319001244f80037ae8d70046755f16192a944c3d8d1f89c418d6" +
+		"001a5abfb57a020dcade488a42e849e32cb97f6ec33bebefdac680f7d76add9a" +
+		"1e5295fc3bd13077b9bb16ec456ceae1c85fbc6b977a03d054d9888b73294c9e" +
+		"471d0d4dd78358d844b45b0da5d03f2bae2f0c703d9d08f32060018383237d82" +
+		"c47eca0bef56ed25e919693c99e31520df980c32cb3d4d38ec5ed0aca24c7122" +
+		"1dc24df23e979c9646afdb6e48c08c3c49783f6ecc2de9dba01c970cc1a410c3" +
+		"cecce9df9df713a9693645a082a6b1e4738ad8b7a9d1a47de65819d4f2e718b8" +
+		"cb3466247ce54569c52381dbf3126516d130a47f86d2c25acadc1149cdaa51af" +
+		"a5992d98fc91e4a956930589a514a7f920db0d5c7b19374f42273a2acd3a5e82" +
+		"5c2b387919df10b89da6d2ca10fb",
+}
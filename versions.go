@@ -1,5 +1,19 @@
 package esni
 
+import (
+	"crypto"
+	_ "crypto/sha256"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnsupportedVersion is returned when attempting
+	// to marshal or unmarshal a Keys record of a version
+	// that has no codec registered
+	ErrUnsupportedVersion = errors.New("unsupported version")
+)
+
 // Version represents a specific ESNI
 // specification version for the DNS
 // ESNI record
@@ -16,6 +30,40 @@ const (
 	// VersionDraft03 represents the version value
 	// for the third draft of the ESNI specification
 	VersionDraft03 Version = 0xff02
+
+	// VersionDraft04 represents the version value
+	// for the fourth draft of the ESNI specification,
+	// which removed the checksum from the record
+	VersionDraft04 Version = 0xff03
+
+	// VersionDraft05 represents the version value
+	// used by this package for the fifth draft of
+	// the ESNI specification, which removed the
+	// validity period from the record in favour of
+	// the DNS TTL.
+	//
+	// The value continues the sequence of the earlier
+	// drafts and hasn't been checked against a record
+	// published by another implementation, records of
+	// this version may not be understood elsewhere.
+	VersionDraft05 Version = 0xff04
+
+	// VersionDraft06 represents the version value
+	// used by this package for the sixth draft of
+	// the ESNI specification, which wraps the key
+	// material in an ESNIRecord rather than publishing
+	// a flat Keys record.
+	//
+	// Like VersionDraft05 the value continues the
+	// sequence of the earlier drafts and hasn't been
+	// checked against another implementation.
+	VersionDraft06 Version = 0xff05
+
+	// VersionDraft13 represents the version value
+	// of an ECHConfig from the thirteenth draft of
+	// the specification, by which point ESNI had
+	// become Encrypted Client Hello
+	VersionDraft13 Version = 0xfe0d
 )
 
 // Version_name specifies a map of versions
@@ -23,6 +71,40 @@ const (
 var Version_name = map[Version]string{
 	VersionDraft01: "draft-ietf-tls-esni-01",
 	VersionDraft03: "draft-ietf-tls-esni-03",
+	VersionDraft04: "draft-ietf-tls-esni-04",
+	VersionDraft05: "draft-ietf-tls-esni-05",
+	VersionDraft06: "draft-ietf-tls-esni-06",
+	VersionDraft13: "draft-ietf-tls-esni-13",
+}
+
+// versionLayouts specifies a map of versions
+// to the layout of their binary Keys record,
+// it is used to select the correct encoding
+// when marshalling and unmarshalling a record
+var versionLayouts = map[Version]recordLayout{
+	VersionDraft01: {checksum: crypto.SHA256, validityPeriod: true},
+	VersionDraft03: {checksum: crypto.SHA256, publicNameLengthSize: 1, validityPeriod: true},
+	VersionDraft04: {publicNameLengthSize: 2, validityPeriod: true},
+	VersionDraft05: {publicNameLengthSize: 2},
+}
+
+// recordLayout describes which of the optional
+// fields are present in the binary Keys record
+// of a specific version
+type recordLayout struct {
+	// checksum specifies the digest algorithm of the
+	// checksum carried after the version, it is zero
+	// if the record has no checksum
+	checksum crypto.Hash
+
+	// publicNameLengthSize specifies the number of
+	// bytes used to encode the length of the public
+	// name, it is zero if the record has no public name
+	publicNameLengthSize int
+
+	// validityPeriod specifies if the record
+	// carries the not before and not after times
+	validityPeriod bool
 }
 
 // String attempts to return the string
@@ -36,3 +118,80 @@ func (v Version) String() string {
 
 	return "UNKNOWN"
 }
+
+// supported returns if a codec is registered
+// for records of the version
+func (v Version) supported() bool {
+	return v.Codec() != nil
+}
+
+// hasLayout returns if the layout of records
+// of the version is known
+func (v Version) hasLayout() bool {
+	_, ok := versionLayouts[v]
+	return ok
+}
+
+// HasChecksum returns if records of the
+// version carry a checksum after the version
+func (v Version) HasChecksum() bool {
+	return versionLayouts[v].checksum != 0
+}
+
+// ChecksumHash returns the digest algorithm used
+// to compute the checksum of records of the version,
+// zero is returned if records of the version have
+// no checksum
+func (v Version) ChecksumHash() crypto.Hash {
+	return versionLayouts[v].checksum
+}
+
+// HasPublicName returns if records of the
+// version carry the clear text SNI to be used
+// during the TLS handshake
+func (v Version) HasPublicName() bool {
+	switch v {
+	case VersionDraft06, VersionDraft13:
+		return true
+	}
+
+	return v.publicNameLengthSize() > 0
+}
+
+// UsesHPKE returns if records of the version
+// are ECHConfig structures, which use HPKE for
+// encryption rather than the ESNI key schedule
+func (v Version) UsesHPKE() bool {
+	return v == VersionDraft13
+}
+
+// headerSize returns the number of bytes
+// occupied by the version and checksum of
+// records of the version
+func (v Version) headerSize() int {
+	if v.HasChecksum() {
+		return 6
+	}
+
+	return 2
+}
+
+// publicNameLengthSize returns the number of
+// bytes used to encode the length of the public
+// name in records of the version, zero is returned
+// if records of the version have no public name
+func (v Version) publicNameLengthSize() int {
+	return versionLayouts[v].publicNameLengthSize
+}
+
+// HasValidityPeriod returns if records of the
+// version carry the not before and not after times
+func (v Version) HasValidityPeriod() bool {
+	return versionLayouts[v].validityPeriod
+}
+
+// unsupportedVersionError wraps ErrUnsupportedVersion
+// with the numeric value of the version
+func unsupportedVersionError(v Version) error {
+	return errors.Wrapf(ErrUnsupportedVersion, "version 0x%04x", uint16(v))
+}
@@ -0,0 +1,275 @@
+package esni
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultWellKnownPath specifies the path Keys
+	// records are published at over HTTPS
+	DefaultWellKnownPath = "/.well-known/esni"
+
+	// WellKnownContentTypeBinary specifies the media
+	// type of the binary form of the published records,
+	// the records are concatenated in their binary format
+	WellKnownContentTypeBinary = "application/esni-keys"
+
+	// WellKnownContentTypeBase64 specifies the media
+	// type of the base64 form of the published records,
+	// each record is base64 encoded on its own line
+	// exactly as it would be in a TXT record
+	WellKnownContentTypeBase64 = "text/plain"
+
+	// WellKnownContentTypeECHConfigList specifies the
+	// media type of the binary form of a published
+	// ECHConfigList, its base64 form is served as
+	// WellKnownContentTypeBase64 on a single line
+	WellKnownContentTypeECHConfigList = "application/ech-config-list"
+
+	// maxWellKnownSize specifies the largest
+	// response read from a well-known URL
+	maxWellKnownSize = 64 * 1024
+)
+
+// WellKnownFetcher fetches the Keys records a server
+// publishes over HTTPS at a well-known path of its
+// public name, an alternative to DNS for clients on
+// networks where the _esni records are blocked or
+// filtered.
+//
+// The records go through the same parsing and
+// validation as those fetched from DNS.
+type WellKnownFetcher struct {
+	// Fetcher specifies the options used to evaluate,
+	// decode and pin the records, if nil a default
+	// Fetcher is used
+	Fetcher *Fetcher
+
+	// Client specifies the HTTP client used to
+	// make requests, if nil http.DefaultClient
+	// is used
+	Client *http.Client
+
+	// Path specifies the path the records are
+	// published at, if empty DefaultWellKnownPath
+	// is used
+	Path string
+}
+
+// FetchWellKnownKeys fetches the Keys records
+// published at the default well-known path of
+// the host using http.DefaultClient
+func FetchWellKnownKeys(ctx context.Context, host string) ([]FetchedKeys, []SkippedRecord, error) {
+	return new(WellKnownFetcher).FetchKeys(ctx, host)
+}
+
+// FetchKeys requests the records published at the
+// well-known path of the host over HTTPS, parses each
+// of them and returns the records that are valid as of
+// the evaluation context along with those that were
+// skipped. The Cache-Control max-age of the response
+// is reported as the TTL of the records.
+//
+// ErrNoKeys is returned if the request succeeded
+// but none of the records are usable.
+func (wellKnown *WellKnownFetcher) FetchKeys(ctx context.Context, host string) ([]FetchedKeys, []SkippedRecord, error) {
+	fetcher := wellKnown.Fetcher
+	if fetcher == nil {
+		fetcher = new(Fetcher)
+	}
+
+	endpoint := wellKnown.url(host)
+
+	data, ttl, binary, err := wellKnown.get(ctx, endpoint)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "fetch %s", endpoint)
+	}
+
+	ectx := fetcher.EvalContext
+	if ectx == nil {
+		ectx = EvalContextFrom(ctx)
+	}
+
+	fetchedAt := ectx.Now()
+
+	var (
+		count    int
+		parse    func(int) (*Keys, []byte, error)
+		splitErr error
+		fetched  []FetchedKeys
+		skipped  []SkippedRecord
+	)
+
+	if binary {
+		var records [][]byte
+		records, splitErr = splitKeysRecords(data, fetcher.DecodeOptions)
+
+		count = len(records)
+		parse = func(i int) (*Keys, []byte, error) {
+			keys, err := fetcher.parseRecord(records[i], ectx)
+			return keys, records[i], err
+		}
+	} else {
+		lines := base64Lines(data)
+
+		count = len(lines)
+		parse = func(i int) (*Keys, []byte, error) {
+			return fetcher.parseTXT(lines[i], ectx)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		keys, raw, err := parse(i)
+		if err != nil {
+			skipped = append(skipped, SkippedRecord{Index: i, Err: err})
+			continue
+		}
+
+		fetched = append(fetched, FetchedKeys{
+			Keys:   keys,
+			Source: KeysSource{Domain: host, Name: endpoint, Index: i, Raw: raw, FetchedAt: fetchedAt, TTL: ttl},
+		})
+	}
+
+	// Records following one that couldn't be
+	// decoded can't be located in the binary form
+	if splitErr != nil {
+		skipped = append(skipped, SkippedRecord{Index: count, Err: splitErr})
+	}
+
+	if len(fetched) == 0 {
+		return nil, skipped, ErrNoKeys
+	}
+
+	if err := fetcher.checkPin(ctx, host, fetched, fetchedAt); err != nil {
+		return nil, skipped, err
+	}
+
+	return fetched, skipped, nil
+}
+
+// url returns the well-known URL of the host
+func (wellKnown *WellKnownFetcher) url(host string) string {
+	path := wellKnown.Path
+	if len(path) == 0 {
+		path = DefaultWellKnownPath
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "https", Host: strings.TrimSuffix(host, "."), Path: path}).String()
+}
+
+// get requests the well-known URL, returning the body
+// of the response, the max-age of the response and if
+// it holds the binary form of the records
+func (wellKnown *WellKnownFetcher) get(ctx context.Context, endpoint string) ([]byte, time.Duration, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, false, errors.Wrap(err, "create request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", WellKnownContentTypeBinary+", "+WellKnownContentTypeBase64+";q=0.9")
+
+	client := wellKnown.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, false, errors.Errorf("server returned %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWellKnownSize+1))
+	if err != nil {
+		return nil, 0, false, errors.Wrap(err, "read response")
+	}
+
+	if len(data) > maxWellKnownSize {
+		return nil, 0, false, errors.Errorf("response exceeds %d bytes", maxWellKnownSize)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return data, cacheControlMaxAge(resp.Header.Get("Cache-Control")), mediaType != WellKnownContentTypeBase64, nil
+}
+
+// splitKeysRecords splits the binary form of the
+// published records into the individual records,
+// the records located before a record that can't be
+// decoded are returned along with the error
+func splitKeysRecords(data []byte, opts DecodeOptions) ([][]byte, error) {
+	opts.RejectTrailingData = false
+
+	var records [][]byte
+	for pos := 0; pos < len(data); {
+		var keys Keys
+
+		n, err := keys.DecodeWithOptions(data[pos:], opts)
+		if err != nil {
+			return records, errors.Wrap(err, "unmarshal keys")
+		}
+
+		records = append(records, data[pos:pos+n])
+		pos += n
+	}
+
+	return records, nil
+}
+
+// base64Lines returns the non-empty lines of
+// the base64 form of the published records
+func base64Lines(data []byte) []string {
+	var lines []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// cacheControlMaxAge returns the max-age directive
+// of a Cache-Control header, zero if it isn't set
+func cacheControlMaxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(strings.ToLower(directive), "max-age=") {
+			continue
+		}
+
+		seconds, err := strconv.ParseUint(directive[len("max-age="):], 10, 32)
+		if err != nil {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
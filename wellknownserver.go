@@ -0,0 +1,176 @@
+package esni
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WellKnownHandler is an http.Handler that serves the
+// records held by a KeyManager, so an origin server can
+// publish its keys over HTTPS for clients using a
+// WellKnownFetcher when DNS is unavailable to them.
+//
+// The records are served in their binary form unless
+// the Accept header of the request prefers the base64
+// form, with one record per line. If ECHConfigs is set
+// the ECHConfigList converted from the records is served
+// instead.
+//
+// Only the records that are valid as of the evaluation
+// context of the options are served, the max-age of
+// the response is the TTL derived by ZoneRecords.
+type WellKnownHandler struct {
+	// Keys specifies the source
+	// of the published records
+	Keys KeyManager
+
+	// Options specifies the options used to
+	// derive the max-age of the responses and
+	// the config IDs of the converted configs
+	Options ZoneOptions
+
+	// ECHConfigs specifies if the ECHConfigList
+	// converted from the records is served
+	ECHConfigs bool
+}
+
+// NewWellKnownHandler returns a new WellKnownHandler
+// publishing the records of the key manager
+func NewWellKnownHandler(keys KeyManager) *WellKnownHandler {
+	return &WellKnownHandler{Keys: keys}
+}
+
+// ServeHTTP answers the request
+func (handler *WellKnownHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+
+	var keys []*Keys
+	for _, record := range handler.Keys.Keys() {
+		if record.Valid(handler.Options.EvalContext) {
+			keys = append(keys, record)
+		}
+	}
+
+	if len(keys) == 0 {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "no keys published", http.StatusNotFound)
+		return
+	}
+
+	ttl, err := zoneTTL(keys, handler.Options)
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	body, contentType, err := handler.body(keys, prefersBase64(req.Header.Get("Accept")))
+	if err != nil {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.FormatUint(uint64(ttl), 10))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(body))
+}
+
+// body returns the response body holding
+// the records in the requested form
+func (handler *WellKnownHandler) body(keys []*Keys, base64Form bool) ([]byte, string, error) {
+	if handler.ECHConfigs {
+		list, err := zoneECHConfigList(keys, handler.Options)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if base64Form {
+			text, err := list.MarshalText()
+			return append(text, '\n'), WellKnownContentTypeBase64 + "; charset=utf-8", err
+		}
+
+		data, err := list.MarshalBinary()
+		return data, WellKnownContentTypeECHConfigList, err
+	}
+
+	var body bytes.Buffer
+	for _, record := range keys {
+		data, err := record.MarshalBinary()
+		if err != nil {
+			return nil, "", err
+		}
+
+		if base64Form {
+			body.WriteString(base64.StdEncoding.EncodeToString(data))
+			body.WriteByte('\n')
+		} else {
+			body.Write(data)
+		}
+	}
+
+	if base64Form {
+		return body.Bytes(), WellKnownContentTypeBase64 + "; charset=utf-8", nil
+	}
+
+	return body.Bytes(), WellKnownContentTypeBinary, nil
+}
+
+// prefersBase64 returns if the Accept header gives
+// the base64 form a higher quality than the binary
+// form, the binary form is served by default
+func prefersBase64(accept string) bool {
+	var binary, text float64
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		switch mediaType {
+		case WellKnownContentTypeBase64, "text/*":
+			if quality > text {
+				text = quality
+			}
+
+		case WellKnownContentTypeBinary, WellKnownContentTypeECHConfigList, "application/*":
+			if quality > binary {
+				binary = quality
+			}
+
+		case "*/*":
+			if quality > text {
+				text = quality
+			}
+
+			if quality > binary {
+				binary = quality
+			}
+		}
+	}
+
+	return text > binary
+}
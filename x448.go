@@ -0,0 +1,55 @@
+//go:build circl
+// +build circl
+
+package esni
+
+import (
+	"io"
+
+	"github.com/cloudflare/circl/dh/x448"
+	"github.com/pkg/errors"
+)
+
+// init is called when the package is first
+// imported in the runtime, it registers the
+// X448 key exchange provided by circl, which
+// is only built with the "circl" build tag
+func init() {
+	RegisterKeyPairGenerator(GroupX448, x448KeyPair)
+	RegisterSharedSecretFunc(GroupX448, x448SharedSecret)
+}
+
+// x448KeyPair generates a new X448 key pair,
+// returning the public key and private key
+func x448KeyPair(random io.Reader) ([]byte, []byte, error) {
+	var public, secret x448.Key
+	if _, err := io.ReadFull(random, secret[:]); err != nil {
+		return nil, nil, errors.Wrap(err, "read random")
+	}
+
+	x448.KeyGen(&public, &secret)
+	return public[:], secret[:], nil
+}
+
+// x448SharedSecret derives the X448 shared
+// secret, rejecting peer public keys of low
+// order that produce an all zero secret
+func x448SharedSecret(privateKey, peerPublic []byte) ([]byte, error) {
+	if len(privateKey) != x448.Size {
+		return nil, errors.New("invalid private key")
+	}
+
+	if len(peerPublic) != x448.Size {
+		return nil, errors.New("invalid peer public key")
+	}
+
+	var shared, secret, public x448.Key
+	copy(secret[:], privateKey)
+	copy(public[:], peerPublic)
+
+	if !x448.Shared(&shared, &secret, &public) {
+		return nil, errors.New("peer public key is of low order")
+	}
+
+	return shared[:], nil
+}
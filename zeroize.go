@@ -0,0 +1,29 @@
+package esni
+
+// Zeroize overwrites each of the buffers with
+// zeros, it is used to wipe private keys and
+// derived secrets once they are no longer needed
+func Zeroize(buffers ...[]byte) {
+	for _, buffer := range buffers {
+		for i := range buffer {
+			buffer[i] = 0
+		}
+	}
+}
+
+// Zeroize overwrites the private key
+// of the entry with zeros
+func (entry *PrivateKeyEntry) Zeroize() {
+	Zeroize(entry.PrivateKey)
+}
+
+// Destroy overwrites every private key with
+// zeros and removes the entries, the private
+// keys can't be used after they are destroyed
+func (keys *PrivateKeys) Destroy() {
+	for i := range keys.Entries {
+		keys.Entries[i].Zeroize()
+	}
+
+	keys.Entries = nil
+}
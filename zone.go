@@ -0,0 +1,262 @@
+package esni
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultZoneTTLMargin specifies the margin kept
+	// between the expiry of cached records and the
+	// NotAfter time of the Keys records they carry
+	DefaultZoneTTLMargin = time.Hour
+)
+
+// ZoneOptions specifies the options used
+// when generating zone file records
+type ZoneOptions struct {
+	// Margin specifies the time subtracted from the
+	// remaining validity of the records to derive the
+	// TTL, if zero DefaultZoneTTLMargin is used
+	Margin time.Duration
+
+	// MaxTTL specifies the maximum TTL of the records,
+	// it must be set for records whose version has no
+	// validity period
+	MaxTTL time.Duration
+
+	// EvalContext specifies the time the remaining
+	// validity of the records is evaluated at
+	EvalContext *EvalContext
+
+	// HTTPS optionally specifies an HTTPS record to
+	// generate for the domain, its "ech" parameter
+	// is set to the ECHConfigList converted from the
+	// Keys records
+	HTTPS *SVCBRecord
+
+	// ConfigID specifies the config ID of the first
+	// converted ECHConfig, each following config
+	// uses the next ID
+	ConfigID uint8
+}
+
+// ZoneRecord represents a single resource
+// record in the presentation format of a
+// BIND style zone file
+type ZoneRecord struct {
+	// Name specifies the fully qualified
+	// owner name of the record
+	Name string
+
+	// TTL specifies the TTL of
+	// the record in seconds
+	TTL uint32
+
+	// Type specifies the type of the record
+	Type uint16
+
+	// Data specifies the presentation
+	// format of the record data
+	Data string
+}
+
+// String returns the record as a zone file line
+func (record ZoneRecord) String() string {
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", record.Name, record.TTL, dns.TypeToString[record.Type], record.Data)
+}
+
+// ZoneRecords generates the _esni TXT records for the
+// Keys records of the domain, and an HTTPS record if
+// one is specified by the options.
+//
+// The records share a TTL which is the shortest time
+// remaining before any of the Keys records expire less
+// the margin, so resolvers never cache a record past
+// its NotAfter time. An error is returned if any of
+// the records expire within the margin.
+func ZoneRecords(domain string, keys []*Keys, opts ZoneOptions) ([]ZoneRecord, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys records")
+	}
+
+	ttl, err := zoneTTL(keys, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rrs, err := zoneTXTRRs(domain, keys, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.HTTPS != nil {
+		rr, err := zoneHTTPSRR(domain, keys, ttl, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		rrs = append(rrs, rr)
+	}
+
+	records := make([]ZoneRecord, len(rrs))
+	for i := range rrs {
+		records[i] = zoneRecordFromRR(rrs[i])
+	}
+
+	return records, nil
+}
+
+// WriteZone writes the records to the
+// writer as zone file lines
+func WriteZone(w io.Writer, records []ZoneRecord) error {
+	for _, record := range records {
+		if _, err := fmt.Fprintln(w, record.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// zoneTTL returns the TTL of the records generated
+// for the Keys records
+func zoneTTL(keys []*Keys, opts ZoneOptions) (uint32, error) {
+	margin := opts.Margin
+	if margin == 0 {
+		margin = DefaultZoneTTLMargin
+	}
+
+	now := opts.EvalContext.Now()
+
+	ttl := opts.MaxTTL
+	for i := range keys {
+		if !keys[i].Version.HasValidityPeriod() {
+			continue
+		}
+
+		remaining := keys[i].NotAfter.Sub(now) - margin
+		if remaining < time.Second {
+			return 0, errors.Errorf("keys %d expire within the ttl margin", i)
+		}
+
+		if ttl == 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	if ttl <= 0 {
+		return 0, errors.New("max ttl must be set for keys without a validity period")
+	}
+
+	seconds := ttl / time.Second
+	if seconds > math.MaxInt32 {
+		seconds = math.MaxInt32
+	}
+
+	return uint32(seconds), nil
+}
+
+// zoneTXTRRs generates the _esni TXT
+// records carrying the Keys records
+func zoneTXTRRs(domain string, keys []*Keys, ttl uint32) ([]dns.RR, error) {
+	rrs := make([]dns.RR, len(keys))
+	for i := range keys {
+		chunks, err := keys[i].MarshalTXT()
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal keys %d", i)
+		}
+
+		rrs[i] = &dns.TXT{
+			Hdr: dns.RR_Header{Name: dns.Fqdn(ESNIQueryName(domain)), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl},
+			Txt: chunks,
+		}
+	}
+
+	return rrs, nil
+}
+
+// zoneHTTPSRR generates the HTTPS record of the
+// options with its "ech" parameter carrying the
+// configs converted from the Keys records
+func zoneHTTPSRR(domain string, keys []*Keys, ttl uint32, opts ZoneOptions) (dns.RR, error) {
+	list, err := zoneECHConfigList(keys, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := MarshalSvcParamECH(list)
+	if err != nil {
+		return nil, err
+	}
+
+	record := *opts.HTTPS
+	record.Params = append([]SvcParam(nil), record.Params...)
+	record.SetParam(SvcParamKeyECH, value)
+
+	return svcbRRFromRecord(dns.Fqdn(strings.TrimSuffix(domain, ".")), ttl, record)
+}
+
+// zoneECHConfigList converts the Keys records into
+// an ECHConfigList starting at the config ID of
+// the options
+func zoneECHConfigList(keys []*Keys, opts ZoneOptions) (ECHConfigList, error) {
+	list := make(ECHConfigList, len(keys))
+	for i := range keys {
+		config, err := ConvertKeysToECHConfig(keys[i], opts.ConfigID+uint8(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert keys %d", i)
+		}
+
+		list[i] = *config
+	}
+
+	return list, nil
+}
+
+// svcbRRFromRecord converts the record into
+// a miekg/dns HTTPS resource record
+func svcbRRFromRecord(name string, ttl uint32, record SVCBRecord) (dns.RR, error) {
+	rdata, err := record.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal https record")
+	}
+
+	generic := &dns.RFC3597{
+		Hdr:   dns.RR_Header{Name: name, Rrtype: dns.TypeHTTPS, Class: dns.ClassINET, Ttl: ttl},
+		Rdata: fmt.Sprintf("%x", rdata),
+	}
+
+	buf := make([]byte, dns.Len(generic))
+
+	end, err := dns.PackRR(generic, buf, 0, nil, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "pack https record")
+	}
+
+	rr, _, err := dns.UnpackRR(buf[:end], 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "unpack https record")
+	}
+
+	return rr, nil
+}
+
+// zoneRecordFromRR converts the miekg/dns
+// resource record into a ZoneRecord
+func zoneRecordFromRR(rr dns.RR) ZoneRecord {
+	header := rr.Header()
+
+	return ZoneRecord{
+		Name: header.Name,
+		TTL:  header.Ttl,
+		Type: header.Rrtype,
+		Data: strings.TrimPrefix(rr.String(), header.String()),
+	}
+}